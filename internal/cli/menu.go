@@ -71,6 +71,7 @@ func RunMainMenu() (string, error) {
 		item{title: "Configure DNS", desc: "Select a preset or enter custom IPs (Interactive)", cmd: "set"},
 		item{title: "List Servers", desc: "View all available DNS presets", cmd: "list"},
 		item{title: "Check Status", desc: "View current DNS settings and active interfaces", cmd: "status"},
+		item{title: "Diagnose Problems", desc: "Check for conflicting managers, stale files and silent resolvers", cmd: "doctor"},
 		item{title: "Quick Reset", desc: "Restore previous DNS configuration", cmd: "reset"},
 		item{title: "Version Info", desc: "Display application version and build details", cmd: "version"},
 	}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"runtime/debug"
 
@@ -13,8 +14,26 @@ import (
 
 // Dependencies holds all dependencies needed by CLI commands
 type Dependencies struct {
-	Config *config.Config
-	Logger *slog.Logger
+	Config  *config.Config
+	Logger  *slog.Logger
+	Tracer  CommandTracer
+	Root    SystemRoot
+	Backend BackendSelector
+}
+
+// BackendSelector replaces backend detection with a backend chosen by name
+type BackendSelector interface {
+	SetOverride(name, source string) error
+}
+
+// SystemRoot selects the filesystem cdns configures
+type SystemRoot interface {
+	Set(dir string) error
+}
+
+// CommandTracer reports the external commands run by cdns
+type CommandTracer interface {
+	Trace(w io.Writer)
 }
 
 // NewRootCmd creates the root command with dependency injection
@@ -28,6 +47,25 @@ func NewRootCmd(deps Dependencies) *cobra.Command {
 		Long:          ui.GetBanner() + "\n\nA trusted, Linux-first DNS management CLI tool",
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := ui.OutputFormat(cmd); err != nil {
+				return err
+			}
+			if trace, _ := cmd.Flags().GetBool("trace-commands"); trace && deps.Tracer != nil {
+				deps.Tracer.Trace(cmd.ErrOrStderr())
+			}
+			if root, _ := cmd.Flags().GetString("root"); root != "" && deps.Root != nil {
+				if err := deps.Root.Set(root); err != nil {
+					return err
+				}
+			}
+			if name, _ := cmd.Flags().GetString("backend"); name != "" && deps.Backend != nil {
+				if err := deps.Backend.SetOverride(name, "--backend"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// If no subcommand is provided, show the main menu
 			choice, err := RunMainMenu()
@@ -69,6 +107,10 @@ func NewRootCmd(deps Dependencies) *cobra.Command {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ~/.config/cdns/config.yaml)")
 	rootCmd.PersistentFlags().String("log-level", "warn", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "show verbose logs")
+	rootCmd.PersistentFlags().String("root", "", "configure the mounted image or chroot at this directory offline, through its files only")
+	rootCmd.PersistentFlags().String("backend", "", "skip detection and use this backend (networkmanager, systemd-resolved, resolv.conf, netplan)")
+	rootCmd.PersistentFlags().StringP("output", "o", string(ui.FormatTable), "output format: table, plain, json, or yaml")
+	rootCmd.PersistentFlags().Bool("trace-commands", false, "print every external command run, with its duration and exit status")
 
 	return rootCmd
 }
@@ -22,11 +22,24 @@ logger:
 
 dns:
   default_scope: active
+  # auto skips IPv6 resolvers on interfaces without a global IPv6 address
+  address_family: auto
+  # Skip detection and always use this backend: networkmanager,
+  # systemd-resolved, resolv.conf or netplan
+  backend: ""
   default_interfaces: []
+  exclude_interfaces: ["docker*", "veth*", "virbr*", "br-*", "tailscale*", "wg*", "tun*", "tap*"]
+  # Per-interface DNS applied by 'cdns set' without arguments, e.g.
+  # interface_map: ["wlan0=cloudflare", "eth0=10.0.0.53,10.0.0.54"]
+  interface_map: []
   custom_presets:
     personal: ["1.1.1.1", "1.0.0.1"]
 `
 
+// DefaultExcludeInterfaces are the container bridges, veth pairs and VPN
+// tunnels left alone unless dns.exclude_interfaces says otherwise
+var DefaultExcludeInterfaces = []string{"docker*", "veth*", "virbr*", "br-*", "tailscale*", "wg*", "tun*", "tap*"}
+
 // Config represents the application configuration
 type Config struct {
 	Logger     LoggerConfig `koanf:"logger"`
@@ -34,7 +47,8 @@ type Config struct {
 	LoadedFrom string       `koanf:"-"` // Not loaded from config, but set by loader
 }
 
-// Validate ensures the configuration is valid
+// Validate ensures the configuration is valid. Backend names, interface
+// patterns and interface_map entries are checked by the features using them.
 func (c *Config) Validate() error {
 	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !validLevels[strings.ToLower(c.Logger.Level)] {
@@ -51,6 +65,11 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid dns.default_scope: %s", c.DNS.DefaultScope)
 	}
 
+	validFamilies := map[string]bool{"auto": true, "ipv4": true, "ipv6": true, "both": true}
+	if c.DNS.AddressFamily != "" && !validFamilies[strings.ToLower(c.DNS.AddressFamily)] {
+		return fmt.Errorf("invalid dns.address_family: %s", c.DNS.AddressFamily)
+	}
+
 	return nil
 }
 
@@ -63,7 +82,11 @@ type LoggerConfig struct {
 // DNSConfig contains DNS-specific settings
 type DNSConfig struct {
 	DefaultScope      string              `koanf:"default_scope"`
-	DefaultInterfaces []string            `koanf:"default_interfaces"`
+	DefaultInterfaces []string            `koanf:"default_interfaces"` // names or glob/regex patterns
+	ExcludeInterfaces []string            `koanf:"exclude_interfaces"` // patterns never configured
+	InterfaceMap      []string            `koanf:"interface_map"`      // "iface=preset" or "iface=ip[,ip...]"
+	AddressFamily     string              `koanf:"address_family"`     // "auto", "ipv4", "ipv6" or "both"
+	Backend           string              `koanf:"backend"`            // empty to detect it
 	CustomPresets     map[string][]string `koanf:"custom_presets"`
 }
 
@@ -158,8 +181,9 @@ func (l *Loader) Load(configFile string, flags *pflag.FlagSet) (*Config, error)
 // loadDefaults sets default configuration values
 func (l *Loader) loadDefaults() error {
 	defaults := map[string]interface{}{
-		"logger.level":  "warn",
-		"logger.format": "text",
+		"logger.level":           "warn",
+		"logger.format":          "text",
+		"dns.exclude_interfaces": DefaultExcludeInterfaces,
 	}
 
 	for k, v := range defaults {
@@ -85,3 +85,29 @@ func TestLoadPriority(t *testing.T) {
 		t.Errorf("expected logger level to be 'error' (from flag), got %s", cfg.Logger.Level)
 	}
 }
+
+func TestLoadDefaultExcludeInterfaces(t *testing.T) {
+	cfg, err := NewLoader().Load("", nil)
+	if err != nil {
+		t.Fatalf("unexpected error loading defaults: %v", err)
+	}
+
+	if len(cfg.DNS.ExcludeInterfaces) != len(DefaultExcludeInterfaces) {
+		t.Errorf("expected default exclude patterns %v, got %v", DefaultExcludeInterfaces, cfg.DNS.ExcludeInterfaces)
+	}
+}
+
+func TestValidateAddressFamily(t *testing.T) {
+	cfg := &Config{
+		Logger: LoggerConfig{Level: "warn", Format: "text"},
+		DNS:    DNSConfig{AddressFamily: "ipv4"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error for valid address family: %v", err)
+	}
+
+	cfg.DNS.AddressFamily = "inet6"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid dns.address_family")
+	}
+}
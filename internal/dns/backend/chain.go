@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// resolvedStubAddr is the address the systemd-resolved stub listens on
+const resolvedStubAddr = "127.0.0.53"
+
+// NetworkManager dns= modes
+const (
+	nmDNSDefault  = "default"
+	nmDNSNone     = "none"
+	nmDNSResolved = "systemd-resolved"
+	nmDNSDnsmasq  = "dnsmasq"
+)
+
+// traceResolvConf records where /etc/resolv.conf links to and who writes
+// it, and returns the nameservers it lists. ok is false when the file
+// cannot be read.
+func (d *Detector) traceResolvConf(chain *models.ResolutionChain) (nameservers []string, ok bool) {
+	if isLink, err := d.sysOps.IsSymlink(resolvConfPath); err == nil && isLink {
+		if target, err := d.sysOps.ReadLink(resolvConfPath); err == nil {
+			chain.ResolvConfTarget = target
+		}
+	}
+
+	data, err := d.sysOps.ReadFile(resolvConfPath)
+	if err != nil {
+		chain.ResolvConfOwner = resolvConfOwner(chain.ResolvConfTarget, nil)
+		return nil, false
+	}
+	chain.ResolvConfOwner = resolvConfOwner(chain.ResolvConfTarget, data)
+
+	iface, err := parseResolvConf(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	return append(iface.IPv4, iface.IPv6...), true
+}
+
+// resolvConfOwner names the daemon writing /etc/resolv.conf from where it
+// links to or, for a regular file, from the comment its writer leaves
+func resolvConfOwner(target string, data []byte) string {
+	if target != "" {
+		switch {
+		case isResolvedTarget(target):
+			return "systemd-resolved"
+		case strings.Contains(target, "NetworkManager/"):
+			return "NetworkManager"
+		case strings.Contains(target, "resolvconf/"):
+			return "resolvconf"
+		case strings.Contains(target, "netconfig/"):
+			return "netconfig"
+		}
+		return ""
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "NetworkManager"):
+			return "NetworkManager"
+		case strings.Contains(line, "systemd-resolved"):
+			return "systemd-resolved"
+		case strings.Contains(line, "resolvconf"):
+			return "resolvconf"
+		case strings.Contains(line, "netconfig"):
+			return "netconfig"
+		}
+	}
+	return ""
+}
+
+// isResolvedTarget reports whether a resolv.conf symlink target is one of
+// the files systemd-resolved maintains
+func isResolvedTarget(target string) bool {
+	return strings.Contains(target, "systemd/resolve/") || strings.HasSuffix(target, "systemd/resolv.conf")
+}
+
+// nmDNSSettings returns the dns= and rc-manager= settings NetworkManager
+// runs with, applying its defaults for unset keys
+func (d *Detector) nmDNSSettings(chain *models.ResolutionChain) (mode, rcManager string) {
+	files, _ := nmConfOrder(nmConfPath, nmConfDirs, d.sysOps.Glob)
+	for _, path := range files {
+		data, err := d.sysOps.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		f := parseKeyfile(string(data))
+		if value, ok := f.Get("main", "dns"); ok {
+			mode = strings.TrimSpace(value)
+		}
+		if value, ok := f.Get("main", "rc-manager"); ok {
+			rcManager = strings.TrimSpace(value)
+		}
+	}
+
+	// Without dns=, NetworkManager follows a resolv.conf linked to
+	// systemd-resolved and otherwise writes the file itself
+	if mode == "" {
+		mode = nmDNSDefault
+		if isResolvedTarget(chain.ResolvConfTarget) {
+			mode = nmDNSResolved
+		}
+	}
+	if rcManager == "" {
+		rcManager = "symlink"
+	}
+	return mode, rcManager
+}
+
+// nmManagesDNS reports whether the DNS of NetworkManager connections
+// reaches applications, and if not why
+func nmManagesDNS(chain *models.ResolutionChain) (bool, string) {
+	switch chain.NMDNSMode {
+	case nmDNSNone:
+		return false, "NetworkManager leaves DNS alone (dns=none)"
+	case nmDNSResolved, nmDNSDnsmasq:
+		return true, ""
+	}
+
+	switch {
+	case chain.NMRcManager == "unmanaged":
+		return false, fmt.Sprintf("NetworkManager does not write %s (rc-manager=unmanaged)", resolvConfPath)
+	case chain.NMRcManager == "symlink" && chain.ResolvConfTarget != "" && chain.ResolvConfOwner != "NetworkManager":
+		return false, fmt.Sprintf("NetworkManager does not replace %s, which links to %s (rc-manager=symlink)", resolvConfPath, chain.ResolvConfTarget)
+	}
+	return true, ""
+}
+
+// nmModeReason describes where NetworkManager sends the DNS of its
+// connections, for the detection reason
+func nmModeReason(chain *models.ResolutionChain) string {
+	switch chain.NMDNSMode {
+	case nmDNSResolved:
+		return "; it hands DNS to systemd-resolved (dns=systemd-resolved)"
+	case nmDNSDnsmasq:
+		return "; it runs a local dnsmasq (dns=dnsmasq)"
+	}
+	return ""
+}
+
+// answeredBy names what answers queries given the nameservers listed in
+// /etc/resolv.conf
+func answeredBy(chain *models.ResolutionChain, nameservers []string) string {
+	if len(nameservers) == 0 {
+		return fmt.Sprintf("nothing: %s lists no nameserver", resolvConfPath)
+	}
+	first := nameservers[0]
+	if first == resolvedStubAddr || first == "127.0.0.54" {
+		return fmt.Sprintf("systemd-resolved (%s)", first)
+	}
+	if ip := net.ParseIP(first); ip != nil && ip.IsLoopback() {
+		if chain.NMDNSMode == nmDNSDnsmasq {
+			return fmt.Sprintf("dnsmasq started by NetworkManager (%s)", first)
+		}
+		return fmt.Sprintf("a local resolver at %s", first)
+	}
+	return fmt.Sprintf("the servers in %s: %s", resolvConfPath, strings.Join(nameservers, ", "))
+}
+
+// symlinkError explains why a symlinked /etc/resolv.conf whose owner is not
+// running, or not supported, leaves no backend to write to
+func symlinkError(chain *models.ResolutionChain) error {
+	target := chain.ResolvConfTarget
+	switch {
+	case target == "":
+		return errors.New("resolv.conf is a symlink (managed by a service)")
+	case chain.ResolvConfOwner == "systemd-resolved" || chain.ResolvConfOwner == "NetworkManager":
+		return fmt.Errorf("%s links to %s, but %s is not running", resolvConfPath, target, chain.ResolvConfOwner)
+	case chain.ResolvConfOwner == "resolvconf":
+		return fmt.Errorf("%s links to %s and is generated by resolvconf; set DNS in the configuration feeding it, e.g. dns-nameservers in /etc/network/interfaces", resolvConfPath, target)
+	case chain.ResolvConfOwner == "netconfig":
+		return fmt.Errorf("%s links to %s and is generated by netconfig; set NETCONFIG_DNS_STATIC_SERVERS in /etc/sysconfig/network/config", resolvConfPath, target)
+	}
+	return fmt.Errorf("%s links to %s, which no supported backend manages", resolvConfPath, target)
+}
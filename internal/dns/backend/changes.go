@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ChangeWatcher reports changes signalled by NetworkManager and
+// systemd-resolved on the system bus, such as a new DHCP lease or a
+// connection going up or down
+type ChangeWatcher struct {
+	root *Root
+}
+
+// NewChangeWatcher creates a watcher for the system under root
+func NewChangeWatcher(root *Root) *ChangeWatcher {
+	return &ChangeWatcher{root: root}
+}
+
+// Changes returns a channel receiving a value after any signal from either
+// daemon. Bursts of signals are coalesced while the receiver is busy. The
+// channel is closed when ctx ends or the bus connection drops. Offline
+// there is no bus and an error is returned.
+func (w *ChangeWatcher) Changes(ctx context.Context) (<-chan struct{}, error) {
+	if w.root.Offline() {
+		return nil, errors.New("no change signals for an offline root")
+	}
+	conn, err := dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	changes, err := watchChanges(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return changes, nil
+}
+
+// watchChanges subscribes conn to the signals of both daemons and closes it
+// when ctx ends
+func watchChanges(ctx context.Context, conn *dbus.Conn) (<-chan struct{}, error) {
+	for _, sender := range []string{nmBusName, resolve1Dest} {
+		if err := conn.AddMatchSignalContext(ctx, dbus.WithMatchSender(sender)); err != nil {
+			return nil, fmt.Errorf("failed to subscribe to %s signals: %w", sender, err)
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		defer conn.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-signals:
+				if !ok {
+					return
+				}
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchChanges(t *testing.T) {
+	address := startPrivateBus(t)
+
+	// Stands in for NetworkManager
+	nm, err := dbus.Connect(address)
+	require.NoError(t, err)
+	t.Cleanup(func() { nm.Close() })
+	reply, err := nm.RequestName(nmBusName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+
+	// Some other service on the bus
+	other, err := dbus.Connect(address)
+	require.NoError(t, err)
+	t.Cleanup(func() { other.Close() })
+
+	conn, err := dbus.Connect(address)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := watchChanges(ctx, conn)
+	require.NoError(t, err)
+
+	require.NoError(t, other.Emit("/org/example", "org.example.Thing.Changed"))
+	select {
+	case <-changes:
+		t.Fatal("signal from another service reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Signals from NetworkManager are reported
+	for range 5 {
+		require.NoError(t, nm.Emit("/org/freedesktop/NetworkManager", "org.freedesktop.NetworkManager.StateChanged", uint32(70)))
+	}
+	select {
+	case _, ok := <-changes:
+		assert.True(t, ok)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestChangeWatcher_Offline(t *testing.T) {
+	root := offlineRoot(t, nil)
+	_, err := NewChangeWatcher(root).Changes(context.Background())
+	assert.Error(t, err)
+}
@@ -1,10 +1,14 @@
 package backend
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"gitlab.com/junevm/cdns/internal/dns/models"
 )
@@ -17,10 +21,16 @@ type SystemOps interface {
 	FileExists(path string) bool
 	IsRegularFile(path string) (bool, error)
 	IsSymlink(path string) (bool, error)
+	ReadLink(path string) (string, error)
+	ReadFile(path string) ([]byte, error)
+	Glob(pattern string) ([]string, error)
 }
 
 // DefaultSystemOps implements SystemOps using real system calls
-type DefaultSystemOps struct{}
+type DefaultSystemOps struct {
+	// Runner runs systemctl; nil runs it directly on the host
+	Runner CommandRunner
+}
 
 // NewDefaultSystemOps creates a new DefaultSystemOps instance
 func NewDefaultSystemOps() *DefaultSystemOps {
@@ -35,11 +45,15 @@ func (d *DefaultSystemOps) CommandExists(cmd string) bool {
 
 // ServiceRunning checks if a systemd service is running
 func (d *DefaultSystemOps) ServiceRunning(service string) (bool, error) {
-	cmd := exec.Command("systemctl", "is-active", service)
-	err := cmd.Run()
+	runner := d.Runner
+	if runner == nil {
+		runner = NewExecRunner()
+	}
+	_, err := runner.Run(context.Background(), "systemctl", "is-active", service)
 	if err != nil {
 		// is-active returns non-zero if service is not active
-		if _, ok := err.(*exec.ExitError); ok {
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) {
 			return false, nil
 		}
 		// Other errors (command not found, permission denied, etc.)
@@ -72,14 +86,55 @@ func (d *DefaultSystemOps) IsSymlink(path string) (bool, error) {
 	return info.Mode()&os.ModeSymlink != 0, nil
 }
 
+// ReadLink returns the target of a symbolic link
+func (d *DefaultSystemOps) ReadLink(path string) (string, error) {
+	return os.Readlink(path)
+}
+
+// ReadFile returns the contents of a file
+func (d *DefaultSystemOps) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// Glob returns the paths matching pattern
+func (d *DefaultSystemOps) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// nmEnabledPath exists when the NetworkManager service is enabled
+const nmEnabledPath = "/etc/systemd/system/multi-user.target.wants/NetworkManager.service"
+
 // Detector handles backend detection
 type Detector struct {
 	sysOps SystemOps
+	// nmOnBus reports whether NetworkManager can be managed over D-Bus
+	nmOnBus func() bool
+	root    *Root
+	// override is the backend chosen by the user, empty to detect one
+	override       models.Backend
+	overrideSource string
+}
+
+// NewDetector creates a new Detector with the given SystemOps. A nil or
+// live root detects the backend of the running system.
+func NewDetector(sysOps SystemOps, root *Root) *Detector {
+	return &Detector{sysOps: sysOps, nmOnBus: NMAvailableOnBus, root: root}
 }
 
-// NewDetector creates a new Detector with the given SystemOps
-func NewDetector(sysOps SystemOps) *Detector {
-	return &Detector{sysOps: sysOps}
+// SetOverride makes the detector use the backend called name instead of
+// detecting one; source names the flag or configuration key it came from.
+// An empty name restores detection.
+func (d *Detector) SetOverride(name, source string) error {
+	if name == "" {
+		d.override, d.overrideSource = "", ""
+		return nil
+	}
+	backend, err := models.ParseBackend(name)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", source, err)
+	}
+	d.override, d.overrideSource = backend, source
+	return nil
 }
 
 // Detect identifies and returns the active DNS backend
@@ -91,20 +146,80 @@ func (d *Detector) Detect() (models.Backend, error) {
 
 // DetectWithReason identifies the active DNS backend and returns a reason
 func (d *Detector) DetectWithReason() (models.Backend, string, error) {
+	chain, err := d.DetectChain()
+	return chain.Backend, chain.Reason, err
+}
+
+// DetectChain follows how the system resolves names and picks the backend
+// whose settings applications actually use. The chain is returned on error
+// too, explaining why no backend fits.
+func (d *Detector) DetectChain() (*models.ResolutionChain, error) {
+	if d.root.Offline() {
+		return d.detectOffline()
+	}
+
+	chain := &models.ResolutionChain{}
+	nameservers, readable := d.traceResolvConf(chain)
+	var err error
+	if d.override != "" {
+		err = d.checkOverride(chain)
+	} else {
+		err = d.detectLive(chain, nameservers, readable)
+	}
+	if readable {
+		chain.AnsweredBy = answeredBy(chain, nameservers)
+	}
+	if err != nil && chain.Reason == "" {
+		chain.Reason = err.Error()
+	}
+	return chain, err
+}
+
+// detectLive sets the backend of the running system on chain
+func (d *Detector) detectLive(chain *models.ResolutionChain, nameservers []string, readable bool) error {
+	// bypassed explains why a running daemon was passed over
+	var bypassed []string
+	pick := func(backend models.Backend, reason string) error {
+		chain.Backend = backend
+		chain.Reason = strings.Join(append(bypassed, reason), "; ")
+		return nil
+	}
+	reject := func(backend models.Backend, why string) {
+		chain.Rejected = append(chain.Rejected, models.Candidate{Backend: backend, Reason: why})
+	}
+
 	// 1. Check for NetworkManager
 	nmRunning, _ := d.sysOps.ServiceRunning("NetworkManager")
 	if nmRunning {
-		if d.sysOps.CommandExists("nmcli") {
-			return models.BackendNetworkManager,
-				"nmcli command available and NetworkManager service is running",
-				nil
+		reason := ""
+		switch {
+		case d.sysOps.CommandExists("nmcli"):
+			reason = "nmcli command available and NetworkManager service is running"
+		case d.nmOnBus != nil && d.nmOnBus():
+			reason = "NetworkManager service is running and reachable over D-Bus"
+		default:
+			// NetworkManager is running but can be reached neither by nmcli nor D-Bus
+			return errors.New("NetworkManager is running but 'nmcli' command is missing and D-Bus is unavailable.\n\n" +
+				"To continue, please install the NetworkManager CLI tool:\n" +
+				"  - Debian/Ubuntu: sudo apt install network-manager\n" +
+				"  - Fedora/RHEL: sudo dnf install NetworkManager\n" +
+				"  - Arch Linux: sudo pacman -S networkmanager")
+		}
+
+		chain.NMDNSMode, chain.NMRcManager = d.nmDNSSettings(chain)
+		managed, why := nmManagesDNS(chain)
+		if managed {
+			return pick(models.BackendNetworkManager, reason+nmModeReason(chain))
 		}
-		// NetworkManager is running but nmcli is missing
-		return "", "", errors.New("NetworkManager is running but 'nmcli' command is missing.\n\n" +
-			"To continue, please install the NetworkManager CLI tool:\n" +
-			"  - Debian/Ubuntu: sudo apt install network-manager\n" +
-			"  - Fedora/RHEL: sudo dnf install NetworkManager\n" +
-			"  - Arch Linux: sudo pacman -S networkmanager")
+		bypassed = append(bypassed, why)
+		reject(models.BackendNetworkManager, why)
+	} else if d.sysOps.FileExists(nmEnabledPath) && d.sysOps.FileExists(nmKeyfileDir) {
+		// NetworkManager enabled but stopped, e.g. during provisioning or in
+		// recovery mode: its profiles are edited on disk for the next start
+		return pick(models.BackendNetworkManager,
+			fmt.Sprintf("NetworkManager is enabled but not running; profiles in %s are edited directly", nmKeyfileDir))
+	} else {
+		reject(models.BackendNetworkManager, "NetworkManager service is not running")
 	}
 
 	// 2. Check for systemd-resolved
@@ -114,44 +229,222 @@ func (d *Detector) DetectWithReason() (models.Backend, string, error) {
 	if hasResolvectl || hasSystemdResolve {
 		running, err := d.sysOps.ServiceRunning("systemd-resolved")
 		if err != nil {
-			return "", "", fmt.Errorf("failed to check systemd-resolved status: %w", err)
+			return fmt.Errorf("failed to check systemd-resolved status: %w", err)
 		}
 		if running {
-			cmdName := "resolvectl"
-			if !hasResolvectl {
-				cmdName = "systemd-resolve"
+			// A resolv.conf written by someone else sends queries past it
+			if readable && chain.ResolvConfOwner != "systemd-resolved" && !slices.Contains(nameservers, resolvedStubAddr) {
+				why := fmt.Sprintf("systemd-resolved is running but %s does not use it", resolvConfPath)
+				bypassed = append(bypassed, why)
+				reject(models.BackendSystemdResolved, why)
+			} else {
+				cmdName := "resolvectl"
+				if !hasResolvectl {
+					cmdName = "systemd-resolve"
+				}
+				return pick(models.BackendSystemdResolved,
+					fmt.Sprintf("%s command available and systemd-resolved service is running", cmdName))
 			}
-			return models.BackendSystemdResolved,
-				fmt.Sprintf("%s command available and systemd-resolved service is running", cmdName),
-				nil
+		} else {
+			reject(models.BackendSystemdResolved, "systemd-resolved service is not running")
 		}
+	} else {
+		reject(models.BackendSystemdResolved, "neither resolvectl nor systemd-resolve is installed")
 	}
 
 	// 3. Check for unmanaged resolv.conf
-	if d.sysOps.FileExists("/etc/resolv.conf") {
-		// Check if it's a symlink (managed by systemd-resolved or others)
-		isSymlink, err := d.sysOps.IsSymlink("/etc/resolv.conf")
+	if d.sysOps.FileExists(resolvConfPath) {
+		// A symlink points to a file some service generates
+		isSymlink, err := d.sysOps.IsSymlink(resolvConfPath)
 		if err != nil {
-			return "", "", fmt.Errorf("failed to check if resolv.conf is a symlink: %w", err)
+			return fmt.Errorf("failed to check if resolv.conf is a symlink: %w", err)
 		}
 		if isSymlink {
-			return "", "resolv.conf is a symlink (managed by a service)",
-				errors.New("resolv.conf is managed by a service")
+			err := symlinkError(chain)
+			reject(models.BackendResolvConf, err.Error())
+			chain.Reason = strings.Join(append(bypassed, err.Error()), "; ")
+			return err
 		}
 
 		// Check if it's a regular file
-		isRegular, err := d.sysOps.IsRegularFile("/etc/resolv.conf")
+		isRegular, err := d.sysOps.IsRegularFile(resolvConfPath)
 		if err != nil {
-			return "", "", fmt.Errorf("failed to check if resolv.conf is a regular file: %w", err)
+			return fmt.Errorf("failed to check if resolv.conf is a regular file: %w", err)
 		}
 		if isRegular {
-			return models.BackendResolvConf,
-				"/etc/resolv.conf exists and is a regular file (not managed by a service)",
-				nil
+			return pick(models.BackendResolvConf,
+				"/etc/resolv.conf exists and is a regular file (not managed by a service)")
 		}
+		reject(models.BackendResolvConf, fmt.Sprintf("%s is not a regular file", resolvConfPath))
+	} else {
+		reject(models.BackendResolvConf, fmt.Sprintf("%s does not exist", resolvConfPath))
 	}
 
 	// No supported backend found
-	return "", "no supported DNS backend found",
-		errors.New("no supported DNS backend found")
+	chain.Reason = "no supported DNS backend found"
+	return errors.New("no supported DNS backend found")
+}
+
+// detectOffline identifies the backend of an image from its files alone.
+// Nothing runs in an image, so no daemon answers queries.
+func (d *Detector) detectOffline() (*models.ResolutionChain, error) {
+	chain := &models.ResolutionChain{}
+	chain.ResolvConfTarget, _ = os.Readlink(d.root.Path(resolvConfPath))
+	data, _ := os.ReadFile(d.root.resolve(resolvConfPath))
+	chain.ResolvConfOwner = resolvConfOwner(chain.ResolvConfTarget, data)
+
+	if d.override != "" {
+		return chain, d.checkOverride(chain)
+	}
+	return chain, offlineBackend(d.root, chain)
+}
+
+// nmInstallPaths exist in an image with NetworkManager installed
+var nmInstallPaths = []string{"/usr/sbin/NetworkManager", "/usr/bin/NetworkManager", nmKeyfileDir}
+
+// offlineBackend picks the backend of an image. Netplan comes first since
+// it generates the NetworkManager or networkd configuration at boot.
+func offlineBackend(root *Root, chain *models.ResolutionChain) error {
+	pick := func(backend models.Backend, reason string) error {
+		chain.Backend = backend
+		chain.Reason = reason
+		return nil
+	}
+	reject := func(backend models.Backend, why string) {
+		chain.Rejected = append(chain.Rejected, models.Candidate{Backend: backend, Reason: why})
+	}
+
+	if names, _ := filepath.Glob(filepath.Join(root.Path(netplanDir), "*.yaml")); len(names) > 0 {
+		return pick(models.BackendNetplan, fmt.Sprintf("netplan configuration found in %s", netplanDir))
+	}
+	reject(models.BackendNetplan, fmt.Sprintf("no configuration in %s", netplanDir))
+
+	for _, path := range nmInstallPaths {
+		if _, err := os.Lstat(root.Path(path)); err == nil {
+			return pick(models.BackendNetworkManager,
+				fmt.Sprintf("%s exists; profiles in %s are edited directly", path, nmKeyfileDir))
+		}
+	}
+	reject(models.BackendNetworkManager, "NetworkManager is not installed")
+
+	if target, err := os.Readlink(root.Path(resolvConfPath)); err == nil {
+		if strings.Contains(target, "systemd/resolve") {
+			return pick(models.BackendSystemdResolved,
+				fmt.Sprintf("%s links to %s; a drop-in in %s is written", resolvConfPath, target, resolvedDropInDir))
+		}
+		err := fmt.Errorf("%s links to %s, which no supported backend manages", resolvConfPath, target)
+		reject(models.BackendSystemdResolved, fmt.Sprintf("%s does not link to systemd-resolved", resolvConfPath))
+		reject(models.BackendResolvConf, err.Error())
+		chain.Reason = fmt.Sprintf("%s links to %s", resolvConfPath, target)
+		return err
+	}
+
+	// Enabled units are symlinks to absolute paths, so only the link is checked
+	for _, wants := range []string{"multi-user.target.wants", "sysinit.target.wants"} {
+		if _, err := os.Lstat(root.Path(filepath.Join("/etc/systemd/system", wants, "systemd-resolved.service"))); err == nil {
+			return pick(models.BackendSystemdResolved,
+				fmt.Sprintf("systemd-resolved is enabled; a drop-in in %s is written", resolvedDropInDir))
+		}
+	}
+	reject(models.BackendSystemdResolved, "systemd-resolved is not enabled")
+
+	return pick(models.BackendResolvConf,
+		fmt.Sprintf("no network manager found; %s is written directly", resolvConfPath))
+}
+
+// checkOverride makes the backend chosen by the user the backend of chain,
+// failing when it cannot work on this system
+func (d *Detector) checkOverride(chain *models.ResolutionChain) error {
+	chain.Override = d.overrideSource
+
+	var why string
+	if d.root.Offline() {
+		why = d.unusableOffline(chain)
+	} else {
+		why = d.unusableLive(chain)
+	}
+	if why != "" {
+		err := fmt.Errorf("%s %s cannot be used: %s", d.overrideSource, d.override, why)
+		chain.Reason = err.Error()
+		return err
+	}
+
+	chain.Backend = d.override
+	chain.Reason = fmt.Sprintf("chosen with %s; detection skipped", d.overrideSource)
+	if d.override == models.BackendNetworkManager && chain.NMDNSMode != "" {
+		// Honoured, but the servers set may never reach applications
+		if managed, note := nmManagesDNS(chain); !managed {
+			chain.Reason += "; note: " + note
+		}
+	}
+	return nil
+}
+
+// unusableLive explains why the chosen backend cannot configure the
+// running system, or returns "" when it can
+func (d *Detector) unusableLive(chain *models.ResolutionChain) string {
+	switch d.override {
+	case models.BackendNetworkManager:
+		if running, _ := d.sysOps.ServiceRunning("NetworkManager"); running {
+			if !d.sysOps.CommandExists("nmcli") && (d.nmOnBus == nil || !d.nmOnBus()) {
+				return "NetworkManager is running but neither nmcli nor D-Bus is available"
+			}
+			chain.NMDNSMode, chain.NMRcManager = d.nmDNSSettings(chain)
+			return ""
+		}
+		if !d.sysOps.FileExists(nmEnabledPath) || !d.sysOps.FileExists(nmKeyfileDir) {
+			return "NetworkManager is neither running nor enabled"
+		}
+	case models.BackendSystemdResolved:
+		if !d.sysOps.CommandExists("resolvectl") && !d.sysOps.CommandExists("systemd-resolve") {
+			return "neither resolvectl nor systemd-resolve is installed"
+		}
+		if running, err := d.sysOps.ServiceRunning("systemd-resolved"); err != nil || !running {
+			return "systemd-resolved service is not running"
+		}
+	case models.BackendResolvConf:
+		return resolvConfLinked(chain)
+	case models.BackendNetplan:
+		if !d.sysOps.CommandExists("netplan") {
+			return "netplan is not installed"
+		}
+	}
+	return ""
+}
+
+// unusableOffline explains why the chosen backend cannot configure the
+// image, or returns "" when it can
+func (d *Detector) unusableOffline(chain *models.ResolutionChain) string {
+	switch d.override {
+	case models.BackendNetworkManager:
+		for _, path := range nmInstallPaths {
+			if _, err := os.Lstat(d.root.Path(path)); err == nil {
+				return ""
+			}
+		}
+		return "NetworkManager is not installed in the image"
+	case models.BackendResolvConf:
+		return resolvConfLinked(chain)
+	case models.BackendNetplan:
+		for _, path := range []string{"/usr/sbin/netplan", "/usr/bin/netplan"} {
+			if _, err := os.Lstat(d.root.Path(path)); err == nil {
+				return ""
+			}
+		}
+		return "netplan is not installed in the image"
+	}
+	return ""
+}
+
+// resolvConfLinked explains why /etc/resolv.conf cannot be written
+// directly when it links to a file another service generates
+func resolvConfLinked(chain *models.ResolutionChain) string {
+	if chain.ResolvConfTarget == "" {
+		return ""
+	}
+	owner := chain.ResolvConfOwner
+	if owner == "" {
+		owner = "another service"
+	}
+	return fmt.Sprintf("%s links to %s, which %s regenerates", resolvConfPath, chain.ResolvConfTarget, owner)
 }
@@ -3,6 +3,10 @@ package backend_test
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"gitlab.com/junevm/cdns/internal/dns/backend"
@@ -16,6 +20,8 @@ type mockSystemOps struct {
 	fileExists     func(string) bool
 	isRegularFile  func(string) (bool, error)
 	isSymlink      func(string) (bool, error)
+	files          map[string]string
+	links          map[string]string
 }
 
 func (m *mockSystemOps) CommandExists(cmd string) bool {
@@ -53,6 +59,30 @@ func (m *mockSystemOps) IsSymlink(path string) (bool, error) {
 	return false, nil
 }
 
+func (m *mockSystemOps) ReadLink(path string) (string, error) {
+	if target, ok := m.links[path]; ok {
+		return target, nil
+	}
+	return "", os.ErrInvalid
+}
+
+func (m *mockSystemOps) ReadFile(path string) ([]byte, error) {
+	if data, ok := m.files[path]; ok {
+		return []byte(data), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (m *mockSystemOps) Glob(pattern string) ([]string, error) {
+	var paths []string
+	for path := range m.files {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
 func TestDetector_Detect(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -240,7 +270,7 @@ func TestDetector_Detect(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			detector := backend.NewDetector(tt.sysOps)
+			detector := backend.NewDetector(tt.sysOps, nil)
 			got, err := detector.Detect()
 
 			if tt.wantErr {
@@ -287,6 +317,24 @@ func TestDetector_DetectWithReason(t *testing.T) {
 			wantReason: "nmcli command available and NetworkManager service is running",
 			wantErr:    false,
 		},
+		{
+			name: "NetworkManager enabled but stopped",
+			sysOps: &mockSystemOps{
+				commandExists: func(cmd string) bool {
+					return cmd == "nmcli" || cmd == "resolvectl"
+				},
+				serviceRunning: func(service string) (bool, error) {
+					return service == "systemd-resolved", nil
+				},
+				fileExists: func(path string) bool {
+					return path == "/etc/systemd/system/multi-user.target.wants/NetworkManager.service" ||
+						path == "/etc/NetworkManager/system-connections"
+				},
+			},
+			want:       models.BackendNetworkManager,
+			wantReason: "NetworkManager is enabled but not running; profiles in /etc/NetworkManager/system-connections are edited directly",
+			wantErr:    false,
+		},
 		{
 			name: "systemd-resolved with reason",
 			sysOps: &mockSystemOps{
@@ -342,7 +390,7 @@ func TestDetector_DetectWithReason(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			detector := backend.NewDetector(tt.sysOps)
+			detector := backend.NewDetector(tt.sysOps, nil)
 			got, reason, err := detector.DetectWithReason()
 
 			if tt.wantErr {
@@ -374,6 +422,304 @@ func TestDetector_DetectWithReason(t *testing.T) {
 	}
 }
 
+// chainSystem mocks a system running the given services, with nmcli and
+// resolvectl installed and the given symlinks and file contents
+func chainSystem(running []string, links, files map[string]string) *mockSystemOps {
+	return &mockSystemOps{
+		commandExists: func(cmd string) bool {
+			return cmd == "nmcli" || cmd == "resolvectl"
+		},
+		serviceRunning: func(service string) (bool, error) {
+			return slices.Contains(running, service), nil
+		},
+		fileExists: func(path string) bool {
+			_, isFile := files[path]
+			_, isLink := links[path]
+			return isFile || isLink
+		},
+		isRegularFile: func(path string) (bool, error) {
+			_, isLink := links[path]
+			return !isLink, nil
+		},
+		isSymlink: func(path string) (bool, error) {
+			_, isLink := links[path]
+			return isLink, nil
+		},
+		links: links,
+		files: files,
+	}
+}
+
+func TestDetector_DetectChain(t *testing.T) {
+	stub := map[string]string{"/etc/resolv.conf": "../run/systemd/resolve/stub-resolv.conf"}
+	stubConf := "# This is /run/systemd/resolve/stub-resolv.conf managed by man:systemd-resolved(8).\nnameserver 127.0.0.53\noptions edns0 trust-ad\n"
+
+	tests := []struct {
+		name       string
+		sysOps     *mockSystemOps
+		want       models.ResolutionChain
+		wantErr    string
+		wantReason string
+	}{
+		{
+			name: "NetworkManager with dns=none leaves DNS to systemd-resolved",
+			sysOps: chainSystem([]string{"NetworkManager", "systemd-resolved"}, stub, map[string]string{
+				"/etc/resolv.conf":                    stubConf,
+				"/etc/NetworkManager/conf.d/dns.conf": "[main]\ndns=none\n",
+			}),
+			want: models.ResolutionChain{
+				Backend:          models.BackendSystemdResolved,
+				ResolvConfTarget: "../run/systemd/resolve/stub-resolv.conf",
+				ResolvConfOwner:  "systemd-resolved",
+				NMDNSMode:        "none",
+				NMRcManager:      "symlink",
+				AnsweredBy:       "systemd-resolved (127.0.0.53)",
+				Rejected: []models.Candidate{
+					{Backend: models.BackendNetworkManager, Reason: "NetworkManager leaves DNS alone (dns=none)"},
+				},
+			},
+			wantReason: "NetworkManager leaves DNS alone (dns=none); resolvectl command available and systemd-resolved service is running",
+		},
+		{
+			name: "NetworkManager follows a resolv.conf linked to the stub",
+			sysOps: chainSystem([]string{"NetworkManager", "systemd-resolved"}, stub, map[string]string{
+				"/etc/resolv.conf": stubConf,
+			}),
+			want: models.ResolutionChain{
+				Backend:          models.BackendNetworkManager,
+				ResolvConfTarget: "../run/systemd/resolve/stub-resolv.conf",
+				ResolvConfOwner:  "systemd-resolved",
+				NMDNSMode:        "systemd-resolved",
+				NMRcManager:      "symlink",
+				AnsweredBy:       "systemd-resolved (127.0.0.53)",
+			},
+			wantReason: "nmcli command available and NetworkManager service is running; it hands DNS to systemd-resolved (dns=systemd-resolved)",
+		},
+		{
+			name: "NetworkManager with dnsmasq",
+			sysOps: chainSystem([]string{"NetworkManager"}, nil, map[string]string{
+				"/etc/resolv.conf":                        "# Generated by NetworkManager\nnameserver 127.0.1.1\n",
+				"/etc/NetworkManager/NetworkManager.conf": "[main]\nplugins=ifupdown,keyfile\ndns=dnsmasq\n",
+			}),
+			want: models.ResolutionChain{
+				Backend:         models.BackendNetworkManager,
+				ResolvConfOwner: "NetworkManager",
+				NMDNSMode:       "dnsmasq",
+				NMRcManager:     "symlink",
+				AnsweredBy:      "dnsmasq started by NetworkManager (127.0.1.1)",
+			},
+			wantReason: "nmcli command available and NetworkManager service is running; it runs a local dnsmasq (dns=dnsmasq)",
+		},
+		{
+			name: "conf.d overrides NetworkManager.conf",
+			sysOps: chainSystem([]string{"NetworkManager"}, map[string]string{"/etc/resolv.conf": "/run/NetworkManager/resolv.conf"}, map[string]string{
+				"/etc/resolv.conf":                        "nameserver 192.168.1.1\n",
+				"/etc/NetworkManager/NetworkManager.conf": "[main]\ndns=none\n",
+				"/usr/lib/NetworkManager/conf.d/dns.conf": "[main]\ndns=default\n",
+			}),
+			want: models.ResolutionChain{
+				Backend:          models.BackendNetworkManager,
+				ResolvConfTarget: "/run/NetworkManager/resolv.conf",
+				ResolvConfOwner:  "NetworkManager",
+				NMDNSMode:        "default",
+				NMRcManager:      "symlink",
+				AnsweredBy:       "the servers in /etc/resolv.conf: 192.168.1.1",
+			},
+			wantReason: "nmcli command available and NetworkManager service is running",
+		},
+		{
+			name: "NetworkManager with rc-manager=unmanaged",
+			sysOps: chainSystem([]string{"NetworkManager"}, nil, map[string]string{
+				"/etc/resolv.conf":                   "nameserver 9.9.9.9\n",
+				"/etc/NetworkManager/conf.d/rc.conf": "[main]\nrc-manager=unmanaged\n",
+			}),
+			want: models.ResolutionChain{
+				Backend:     models.BackendResolvConf,
+				NMDNSMode:   "default",
+				NMRcManager: "unmanaged",
+				AnsweredBy:  "the servers in /etc/resolv.conf: 9.9.9.9",
+				Rejected: []models.Candidate{
+					{Backend: models.BackendNetworkManager, Reason: "NetworkManager does not write /etc/resolv.conf (rc-manager=unmanaged)"},
+					{Backend: models.BackendSystemdResolved, Reason: "systemd-resolved service is not running"},
+				},
+			},
+			wantReason: "NetworkManager does not write /etc/resolv.conf (rc-manager=unmanaged); /etc/resolv.conf exists and is a regular file (not managed by a service)",
+		},
+		{
+			name: "systemd-resolved bypassed by a static resolv.conf",
+			sysOps: chainSystem([]string{"systemd-resolved"}, nil, map[string]string{
+				"/etc/resolv.conf": "nameserver 1.1.1.1\n",
+			}),
+			want: models.ResolutionChain{
+				Backend:    models.BackendResolvConf,
+				AnsweredBy: "the servers in /etc/resolv.conf: 1.1.1.1",
+				Rejected: []models.Candidate{
+					{Backend: models.BackendNetworkManager, Reason: "NetworkManager service is not running"},
+					{Backend: models.BackendSystemdResolved, Reason: "systemd-resolved is running but /etc/resolv.conf does not use it"},
+				},
+			},
+			wantReason: "systemd-resolved is running but /etc/resolv.conf does not use it; /etc/resolv.conf exists and is a regular file (not managed by a service)",
+		},
+		{
+			name: "resolvconf owns resolv.conf",
+			sysOps: chainSystem(nil, map[string]string{"/etc/resolv.conf": "../run/resolvconf/resolv.conf"}, map[string]string{
+				"/etc/resolv.conf": "nameserver 10.0.0.1\n",
+			}),
+			want: models.ResolutionChain{
+				ResolvConfTarget: "../run/resolvconf/resolv.conf",
+				ResolvConfOwner:  "resolvconf",
+				AnsweredBy:       "the servers in /etc/resolv.conf: 10.0.0.1",
+				Rejected: []models.Candidate{
+					{Backend: models.BackendNetworkManager, Reason: "NetworkManager service is not running"},
+					{Backend: models.BackendSystemdResolved, Reason: "systemd-resolved service is not running"},
+					{Backend: models.BackendResolvConf, Reason: "/etc/resolv.conf links to ../run/resolvconf/resolv.conf and is generated by resolvconf; set DNS in the configuration feeding it, e.g. dns-nameservers in /etc/network/interfaces"},
+				},
+			},
+			wantErr: "generated by resolvconf",
+		},
+		{
+			name:   "resolv.conf linked to a stopped systemd-resolved",
+			sysOps: chainSystem(nil, stub, map[string]string{}),
+			want: models.ResolutionChain{
+				ResolvConfTarget: "../run/systemd/resolve/stub-resolv.conf",
+				ResolvConfOwner:  "systemd-resolved",
+				Rejected: []models.Candidate{
+					{Backend: models.BackendNetworkManager, Reason: "NetworkManager service is not running"},
+					{Backend: models.BackendSystemdResolved, Reason: "systemd-resolved service is not running"},
+					{Backend: models.BackendResolvConf, Reason: "/etc/resolv.conf links to ../run/systemd/resolve/stub-resolv.conf, but systemd-resolved is not running"},
+				},
+			},
+			wantErr: "but systemd-resolved is not running",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := backend.NewDetector(tt.sysOps, nil).DetectChain()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DetectChain() error = %v, want %q", err, tt.wantErr)
+				}
+				if chain.Reason != err.Error() {
+					t.Errorf("DetectChain() reason = %q, want the error", chain.Reason)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("DetectChain() unexpected error: %v", err)
+				}
+				if chain.Reason != tt.wantReason {
+					t.Errorf("DetectChain() reason = %q, want %q", chain.Reason, tt.wantReason)
+				}
+			}
+
+			got := *chain
+			got.Reason = ""
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectChain() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetector_Override(t *testing.T) {
+	stub := map[string]string{"/etc/resolv.conf": "../run/systemd/resolve/stub-resolv.conf"}
+	stubConf := "nameserver 127.0.0.53\n"
+
+	tests := []struct {
+		name       string
+		backend    string
+		sysOps     *mockSystemOps
+		want       models.Backend
+		wantReason string
+		wantErr    string
+	}{
+		{
+			name:       "systemd-resolved chosen over NetworkManager",
+			backend:    "resolved",
+			sysOps:     chainSystem([]string{"NetworkManager", "systemd-resolved"}, stub, map[string]string{"/etc/resolv.conf": stubConf}),
+			want:       models.BackendSystemdResolved,
+			wantReason: "chosen with --backend; detection skipped",
+		},
+		{
+			name:    "NetworkManager that leaves DNS alone",
+			backend: "networkmanager",
+			sysOps: chainSystem([]string{"NetworkManager", "systemd-resolved"}, stub, map[string]string{
+				"/etc/resolv.conf":                    stubConf,
+				"/etc/NetworkManager/conf.d/dns.conf": "[main]\ndns=none\n",
+			}),
+			want:       models.BackendNetworkManager,
+			wantReason: "chosen with --backend; detection skipped; note: NetworkManager leaves DNS alone (dns=none)",
+		},
+		{
+			name:    "NetworkManager not running",
+			backend: "NetworkManager",
+			sysOps:  chainSystem([]string{"systemd-resolved"}, stub, map[string]string{"/etc/resolv.conf": stubConf}),
+			wantErr: "--backend NetworkManager cannot be used: NetworkManager is neither running nor enabled",
+		},
+		{
+			name:    "systemd-resolved not running",
+			backend: "systemd-resolved",
+			sysOps:  chainSystem(nil, nil, map[string]string{"/etc/resolv.conf": "nameserver 9.9.9.9\n"}),
+			wantErr: "--backend systemd-resolved cannot be used: systemd-resolved service is not running",
+		},
+		{
+			name:    "resolv.conf generated by systemd-resolved",
+			backend: "resolv.conf",
+			sysOps:  chainSystem([]string{"systemd-resolved"}, stub, map[string]string{"/etc/resolv.conf": stubConf}),
+			wantErr: "--backend resolv.conf cannot be used: /etc/resolv.conf links to ../run/systemd/resolve/stub-resolv.conf, which systemd-resolved regenerates",
+		},
+		{
+			name:       "static resolv.conf",
+			backend:    "resolv.conf",
+			sysOps:     chainSystem([]string{"systemd-resolved"}, nil, map[string]string{"/etc/resolv.conf": "nameserver 9.9.9.9\n"}),
+			want:       models.BackendResolvConf,
+			wantReason: "chosen with --backend; detection skipped",
+		},
+		{
+			name:    "netplan not installed",
+			backend: "netplan",
+			sysOps:  chainSystem(nil, nil, nil),
+			wantErr: "--backend netplan cannot be used: netplan is not installed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := backend.NewDetector(tt.sysOps, nil)
+			if err := detector.SetOverride(tt.backend, "--backend"); err != nil {
+				t.Fatalf("SetOverride(%q) unexpected error: %v", tt.backend, err)
+			}
+			chain, err := detector.DetectChain()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("DetectChain() error = %v, want %q", err, tt.wantErr)
+				}
+				if chain.Backend != "" || chain.Reason != tt.wantErr {
+					t.Errorf("DetectChain() = %+v, want no backend and the error as reason", chain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectChain() unexpected error: %v", err)
+			}
+			if chain.Backend != tt.want || chain.Reason != tt.wantReason || chain.Override != "--backend" {
+				t.Errorf("DetectChain() = %+v, want backend %s with reason %q", chain, tt.want, tt.wantReason)
+			}
+			if len(chain.Rejected) != 0 {
+				t.Errorf("DetectChain() rejected %v, want nothing considered", chain.Rejected)
+			}
+		})
+	}
+
+	detector := backend.NewDetector(chainSystem(nil, nil, nil), nil)
+	if err := detector.SetOverride("dnsmasq", "dns.backend"); err == nil || !strings.Contains(err.Error(), "invalid dns.backend") {
+		t.Errorf("SetOverride(dnsmasq) error = %v, want invalid dns.backend", err)
+	}
+	if err := detector.SetOverride("", "--backend"); err != nil {
+		t.Errorf("SetOverride(\"\") unexpected error: %v", err)
+	}
+}
+
 func TestDefaultSystemOps_CommandExists(t *testing.T) {
 	sysOps := backend.NewDefaultSystemOps()
 
@@ -0,0 +1,157 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/features/status"
+)
+
+// networkdLeaseDir holds the DHCP leases of systemd-networkd, one file per
+// interface index
+const networkdLeaseDir = "/run/systemd/netif/leases"
+
+// effectiveServers tags every server with the same source
+func effectiveServers(servers []string, source string) []status.EffectiveServer {
+	var effective []status.EffectiveServer
+	for _, server := range servers {
+		effective = append(effective, status.EffectiveServer{Address: server, Source: source})
+	}
+	return effective
+}
+
+// splitServers tags the servers in use as manual when they are configured
+// and as DHCP otherwise, and returns the DHCP ones
+func splitServers(inUse, configured []string) (effective []status.EffectiveServer, dhcp []string) {
+	for _, server := range inUse {
+		source := status.SourceManual
+		if !slices.Contains(configured, server) {
+			source = status.SourceDHCP
+			dhcp = append(dhcp, server)
+		}
+		effective = append(effective, status.EffectiveServer{Address: server, Source: source})
+	}
+	return effective, dhcp
+}
+
+// serversOf returns the IPv4 and IPv6 servers of an interface
+func serversOf(iface status.InterfaceStatus) []string {
+	return append(append([]string{}, iface.IPv4...), iface.IPv6...)
+}
+
+// annotateManual marks every server as set by hand, for backends whose
+// servers all come from configuration files
+func annotateManual(info *status.StatusInfo) {
+	for i := range info.Interfaces {
+		iface := &info.Interfaces[i]
+		iface.Configured = serversOf(*iface)
+		iface.Effective = effectiveServers(iface.Configured, status.SourceManual)
+	}
+}
+
+// annotateNetworkManager sets where the servers of each NetworkManager
+// device come from. Global DNS replaces the servers of every device.
+// current holds the server systemd-resolved queries per link, when
+// NetworkManager hands DNS to it.
+func annotateNetworkManager(info *status.StatusInfo, current map[string]string) {
+	if info.Global != nil {
+		info.Global.Configured = serversOf(*info.Global)
+		info.Global.Effective = effectiveServers(info.Global.Configured, status.SourceGlobal)
+	}
+	for i := range info.Interfaces {
+		iface := &info.Interfaces[i]
+		if info.Global != nil {
+			iface.Effective = info.Global.Effective
+		} else {
+			iface.Effective, _ = splitServers(serversOf(*iface), iface.Configured)
+		}
+		iface.Current = current[iface.Name]
+	}
+}
+
+// annotateResolved sets where the servers of systemd-resolved come from:
+// link servers found in the DHCP lease of the link are DHCP ones, the
+// others were set by hand. With no server anywhere, resolved queries its
+// fallback servers, which are reported on the global entry.
+func annotateResolved(info *status.StatusInfo, fallback []string, leases func(name string) []string) {
+	found := false
+	if info.Global != nil {
+		info.Global.Configured = serversOf(*info.Global)
+		info.Global.Effective = effectiveServers(info.Global.Configured, status.SourceGlobal)
+		found = len(info.Global.Configured) > 0
+	}
+	for i := range info.Interfaces {
+		iface := &info.Interfaces[i]
+		servers := serversOf(*iface)
+		lease := leases(iface.Name)
+		for _, server := range servers {
+			if !slices.Contains(lease, server) {
+				iface.Configured = append(iface.Configured, server)
+			}
+		}
+		iface.Effective, iface.DHCP = splitServers(servers, iface.Configured)
+		found = found || len(servers) > 0
+	}
+
+	if !found && len(fallback) > 0 {
+		if info.Global == nil {
+			info.Global = &status.InterfaceStatus{Name: "Global", IPv4: []string{}, IPv6: []string{}}
+		}
+		info.Global.Effective = effectiveServers(fallback, status.SourceFallback)
+	}
+}
+
+// networkdLease returns the DNS servers in the systemd-networkd DHCP lease
+// of an interface, if any
+func (r *ConfigReader) networkdLease(name string) []string {
+	if r.root.Offline() {
+		return nil
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(r.leaseDir, fmt.Sprint(iface.Index)))
+	if err != nil {
+		return nil
+	}
+	return parseLeaseDNS(data)
+}
+
+// parseLeaseDNS returns the DNS= servers of a systemd-networkd lease file
+func parseLeaseDNS(data []byte) []string {
+	var servers []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "DNS="); ok {
+			servers = append(servers, strings.Fields(value)...)
+		}
+	}
+	return servers
+}
+
+// resolvedCurrent returns the server systemd-resolved queries for each
+// link, or nothing when it cannot be reached
+func (r *ConfigReader) resolvedCurrent(ctx context.Context) map[string]string {
+	if r.root.Offline() || r.resolvedState == nil {
+		return nil
+	}
+	state, err := r.resolvedState(ctx)
+	if err != nil {
+		return nil
+	}
+	current := make(map[string]string)
+	for _, link := range state.Links {
+		if link.Current != "" {
+			current[link.Name] = link.Current
+		}
+	}
+	return current
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+)
+
+func TestAnnotateResolved(t *testing.T) {
+	leases := map[string][]string{"eth0": {"192.168.1.1", "fd00::1"}}
+	lease := func(name string) []string { return leases[name] }
+
+	t.Run("link servers from DHCP and by hand", func(t *testing.T) {
+		info := &status.StatusInfo{
+			Global: &status.InterfaceStatus{Name: "Global", IPv4: []string{"9.9.9.9"}},
+			Interfaces: []status.InterfaceStatus{
+				{Name: "eth0", IPv4: []string{"10.0.0.53", "192.168.1.1"}, IPv6: []string{"fd00::1"}},
+				{Name: "wlan0", IPv4: []string{"1.1.1.1"}},
+			},
+		}
+		annotateResolved(info, []string{"8.8.8.8"}, lease)
+
+		assert.Equal(t, []string{"9.9.9.9"}, info.Global.Configured)
+		assert.Equal(t, []status.EffectiveServer{{Address: "9.9.9.9", Source: status.SourceGlobal}}, info.Global.Effective)
+
+		eth0 := info.Interfaces[0]
+		assert.Equal(t, []string{"10.0.0.53"}, eth0.Configured)
+		assert.Equal(t, []string{"192.168.1.1", "fd00::1"}, eth0.DHCP)
+		assert.Equal(t, []status.EffectiveServer{
+			{Address: "10.0.0.53", Source: status.SourceManual},
+			{Address: "192.168.1.1", Source: status.SourceDHCP},
+			{Address: "fd00::1", Source: status.SourceDHCP},
+		}, eth0.Effective)
+
+		wlan0 := info.Interfaces[1]
+		assert.Equal(t, []string{"1.1.1.1"}, wlan0.Configured)
+		assert.Empty(t, wlan0.DHCP)
+	})
+
+	t.Run("fallback servers when nothing is configured", func(t *testing.T) {
+		info := &status.StatusInfo{Interfaces: []status.InterfaceStatus{}}
+		annotateResolved(info, []string{"1.1.1.1", "8.8.8.8"}, lease)
+
+		require.NotNil(t, info.Global)
+		assert.Empty(t, info.Global.Configured)
+		assert.Equal(t, []status.EffectiveServer{
+			{Address: "1.1.1.1", Source: status.SourceFallback},
+			{Address: "8.8.8.8", Source: status.SourceFallback},
+		}, info.Global.Effective)
+	})
+}
+
+func TestAnnotateNetworkManager_Global(t *testing.T) {
+	info := &status.StatusInfo{
+		Global: &status.InterfaceStatus{Name: "Global", IPv4: []string{"9.9.9.9"}, IPv6: []string{"2620:fe::fe"}},
+		Interfaces: []status.InterfaceStatus{
+			{Name: "eth0", IPv4: []string{"10.0.0.53"}, Configured: []string{"10.0.0.53"}},
+		},
+	}
+	annotateNetworkManager(info, nil)
+
+	global := []status.EffectiveServer{
+		{Address: "9.9.9.9", Source: status.SourceGlobal},
+		{Address: "2620:fe::fe", Source: status.SourceGlobal},
+	}
+	assert.Equal(t, global, info.Global.Effective)
+	assert.Equal(t, global, info.Interfaces[0].Effective, "global DNS replaces the servers of the connection")
+	assert.Equal(t, []string{"10.0.0.53"}, info.Interfaces[0].Configured)
+}
+
+func TestConfigReader_NetworkManagerSources(t *testing.T) {
+	_, client := startFakeNM(t)
+	reader := NewConfigReader(nil, client, nil, nil)
+	reader.resolvedState = func(context.Context) (resolvedState, error) {
+		return resolvedState{Links: []status.InterfaceStatus{{Name: "eth0", Current: "fd00::1:53"}}}, nil
+	}
+
+	info, err := reader.ReadDNSConfig(context.Background(), models.BackendNetworkManager)
+	require.NoError(t, err)
+	require.Len(t, info.Interfaces, 1)
+
+	// The active profile sets no server, so both come from DHCP
+	eth0 := info.Interfaces[0]
+	assert.Equal(t, "eth0", eth0.Name)
+	assert.Empty(t, eth0.Configured)
+	assert.Equal(t, []string{"10.0.0.53", "fd00::1:53"}, eth0.DHCP)
+	assert.Equal(t, []status.EffectiveServer{
+		{Address: "10.0.0.53", Source: status.SourceDHCP},
+		{Address: "fd00::1:53", Source: status.SourceDHCP},
+	}, eth0.Effective)
+	assert.Equal(t, "fd00::1:53", eth0.Current)
+}
+
+func TestParseLeaseDNS(t *testing.T) {
+	lease := "# This is private data. Do not parse.\nADDRESS=192.168.1.20\nDNS=192.168.1.1 192.168.1.2\nNTP=192.168.1.1\n"
+	assert.Equal(t, []string{"192.168.1.1", "192.168.1.2"}, parseLeaseDNS([]byte(lease)))
+	assert.Empty(t, parseLeaseDNS(nil))
+}
@@ -0,0 +1,389 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// nmKeyfileDir is where NetworkManager stores connection profiles as keyfiles
+const nmKeyfileDir = "/etc/NetworkManager/system-connections"
+
+// KeyfileNMClient implements NMClient by editing the keyfiles NetworkManager
+// stores its profiles in. It needs no running daemon, so nothing is ever
+// active: changes are picked up the next time NetworkManager loads the
+// profiles.
+type KeyfileNMClient struct {
+	dir string
+}
+
+// NewKeyfileNMClient creates a client for the keyfiles in dir
+func NewKeyfileNMClient(dir string) *KeyfileNMClient {
+	return &KeyfileNMClient{dir: dir}
+}
+
+// nmKeyfileProfile is a profile loaded from a keyfile
+type nmKeyfileProfile struct {
+	path    string
+	file    *keyfile
+	profile NMProfile
+}
+
+// Profiles returns the profiles of every keyfile, none of them active
+func (c *KeyfileNMClient) Profiles(ctx context.Context) ([]NMProfile, error) {
+	loaded, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]NMProfile, 0, len(loaded))
+	for _, p := range loaded {
+		profiles = append(profiles, p.profile)
+	}
+	return profiles, nil
+}
+
+// ConnectedDevices returns nothing: without a daemon no device is connected
+func (c *KeyfileNMClient) ConnectedDevices(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+// DeviceProfile returns the profile bound to a device with interface-name
+func (c *KeyfileNMClient) DeviceProfile(ctx context.Context, device string) (NMProfile, error) {
+	loaded, err := c.load()
+	if err != nil {
+		return NMProfile{}, err
+	}
+	for _, p := range loaded {
+		if name, _ := p.file.Get("connection", "interface-name"); name == device {
+			return p.profile, nil
+		}
+	}
+	return NMProfile{}, fmt.Errorf("no connection profile in %s is bound to interface %s", c.dir, device)
+}
+
+// ReadDNS returns the DNS configured in a profile
+func (c *KeyfileNMClient) ReadDNS(ctx context.Context, profile NMProfile) (NMDNS, error) {
+	p, err := c.find(profile)
+	if err != nil {
+		return NMDNS{}, err
+	}
+	f := p.file
+	dns := NMDNS{
+		IPv4:         keyfileList(f, "ipv4", "dns"),
+		IPv6:         keyfileList(f, "ipv6", "dns"),
+		Search:       keyfileList(f, "ipv4", "dns-search"),
+		Options:      keyfileList(f, "ipv4", "dns-options"),
+		LLMNR:        "default",
+		MulticastDNS: "default",
+	}
+	dns.ConfiguredIPv4, dns.ConfiguredIPv6 = dns.IPv4, dns.IPv6
+	if value, ok := f.Get("ipv4", "ignore-auto-dns"); ok {
+		dns.IgnoreAutoDNS = value == "true"
+	}
+	if value, ok := f.Get("connection", "llmnr"); ok {
+		dns.LLMNR = nmcliSettingValue(value)
+	}
+	if value, ok := f.Get("connection", "mdns"); ok {
+		dns.MulticastDNS = nmcliSettingValue(value)
+	}
+	return dns, nil
+}
+
+// UpdateDNS edits the DNS keys of a profile, keeping every other key and
+// comment as they are
+func (c *KeyfileNMClient) UpdateDNS(ctx context.Context, profile NMProfile, update NMDNSUpdate) error {
+	p, err := c.find(profile)
+	if err != nil {
+		return err
+	}
+	f := p.file
+
+	setList := func(section, key string, values []string) {
+		if values == nil {
+			return
+		}
+		if len(values) == 0 {
+			f.Delete(section, key)
+			return
+		}
+		f.Set(section, key, strings.Join(values, ";")+";")
+	}
+	setBool := func(section, key string, value *bool) {
+		if value != nil {
+			f.Set(section, key, strconv.FormatBool(*value))
+		}
+	}
+	setPriority := func(section string, value *int32) {
+		switch {
+		case value == nil:
+		case *value == 0:
+			f.Delete(section, "dns-priority")
+		default:
+			f.Set(section, "dns-priority", strconv.Itoa(int(*value)))
+		}
+	}
+	setLink := func(key, value string) error {
+		if value == "" {
+			return nil
+		}
+		n, err := nmLinkSettingValue(value)
+		if err != nil {
+			return err
+		}
+		if n == -1 {
+			f.Delete("connection", key)
+		} else {
+			f.Set("connection", key, strconv.Itoa(int(n)))
+		}
+		return nil
+	}
+
+	// Keyfiles hold plain addresses only, like the D-Bus API
+	if err := checkServerAddresses(models.BackendNetworkManager, append(slices.Clone(update.IPv4DNS), update.IPv6DNS...)); err != nil {
+		return err
+	}
+
+	setList("ipv4", "dns", update.IPv4DNS)
+	setBool("ipv4", "ignore-auto-dns", update.IPv4IgnoreAuto)
+	setList("ipv6", "dns", update.IPv6DNS)
+	setBool("ipv6", "ignore-auto-dns", update.IPv6IgnoreAuto)
+	setPriority("ipv4", update.IPv4Priority)
+	setPriority("ipv6", update.IPv6Priority)
+	setList("ipv4", "dns-search", update.Search)
+	setList("ipv4", "dns-options", update.Options)
+	if err := setLink("llmnr", update.LLMNR); err != nil {
+		return err
+	}
+	if err := setLink("mdns", update.MulticastDNS); err != nil {
+		return err
+	}
+
+	// NetworkManager ignores keyfiles other users can read
+	return writeFile(p.path, []byte(f.String()), 0600)
+}
+
+// Reapply does nothing: NetworkManager reads the keyfiles when it starts
+func (c *KeyfileNMClient) Reapply(ctx context.Context, device string) error {
+	return nil
+}
+
+// ReloadConfig does nothing: NetworkManager reads its configuration when it starts
+func (c *KeyfileNMClient) ReloadConfig(ctx context.Context) error {
+	return nil
+}
+
+// find returns the keyfile of a profile, by UUID or else by name
+func (c *KeyfileNMClient) find(profile NMProfile) (nmKeyfileProfile, error) {
+	loaded, err := c.load()
+	if err != nil {
+		return nmKeyfileProfile{}, err
+	}
+	for _, p := range loaded {
+		if profile.UUID != "" && strings.EqualFold(p.profile.UUID, profile.UUID) {
+			return p, nil
+		}
+	}
+	if profile.UUID == "" {
+		for _, p := range loaded {
+			if p.profile.Name == profile.Name {
+				return p, nil
+			}
+		}
+	}
+	return nmKeyfileProfile{}, fmt.Errorf("connection profile %s not found in %s", profile.label(), c.dir)
+}
+
+// load reads every keyfile in the directory, in name order
+func (c *KeyfileNMClient) load() ([]nmKeyfileProfile, error) {
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", c.dir, err)
+	}
+
+	var loaded []nmKeyfileProfile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".nmconnection") {
+			continue
+		}
+		path := filepath.Join(c.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		f := parseKeyfile(string(data))
+		id, _ := f.Get("connection", "id")
+		uuid, _ := f.Get("connection", "uuid")
+		kind, _ := f.Get("connection", "type")
+		if id == "" {
+			// NetworkManager names profiles without an id after their file
+			id = strings.TrimSuffix(entry.Name(), ".nmconnection")
+		}
+		loaded = append(loaded, nmKeyfileProfile{
+			path:    path,
+			file:    f,
+			profile: NMProfile{Name: id, UUID: uuid, Type: kind},
+		})
+	}
+	sort.SliceStable(loaded, func(i, j int) bool { return loaded[i].path < loaded[j].path })
+	return loaded, nil
+}
+
+// keyfileList returns a semicolon-separated keyfile list value
+func keyfileList(f *keyfile, section, key string) []string {
+	value, _ := f.Get(section, key)
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// keyfile is a GKeyFile style INI document, as used by NetworkManager.
+// Edits keep comments, blank lines and unknown keys where they are.
+type keyfile struct {
+	lines []string
+}
+
+func parseKeyfile(data string) *keyfile {
+	data = strings.TrimSuffix(data, "\n")
+	if data == "" {
+		return &keyfile{}
+	}
+	return &keyfile{lines: strings.Split(data, "\n")}
+}
+
+// String renders the document
+func (k *keyfile) String() string {
+	if len(k.lines) == 0 {
+		return ""
+	}
+	return strings.Join(k.lines, "\n") + "\n"
+}
+
+// keyfileSection returns the section a line opens, if it is a header
+func keyfileSection(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		return line[1 : len(line)-1], true
+	}
+	return "", false
+}
+
+// keyfileEntry splits a key=value line
+func keyfileEntry(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
+
+// find returns the index of a key's line, and the index after the last
+// entry of its section (-1 when the section is missing)
+func (k *keyfile) find(section, key string) (int, int) {
+	current := ""
+	keyLine, end := -1, -1
+	for i, line := range k.lines {
+		if name, ok := keyfileSection(line); ok {
+			current = name
+			if current == section {
+				end = i + 1
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if name, _, ok := keyfileEntry(line); ok {
+			end = i + 1
+			if name == key {
+				keyLine = i
+			}
+		}
+	}
+	return keyLine, end
+}
+
+// Get returns the unescaped value of a key
+func (k *keyfile) Get(section, key string) (string, bool) {
+	i, _ := k.find(section, key)
+	if i < 0 {
+		return "", false
+	}
+	_, value, _ := keyfileEntry(k.lines[i])
+	return unescapeKeyfileValue(value), true
+}
+
+// Set replaces the value of a key, adding the key at the end of its section
+// and the section at the end of the document when missing
+func (k *keyfile) Set(section, key, value string) {
+	entry := key + "=" + value
+	i, end := k.find(section, key)
+	switch {
+	case i >= 0:
+		k.lines[i] = entry
+	case end >= 0:
+		k.lines = append(k.lines[:end], append([]string{entry}, k.lines[end:]...)...)
+	default:
+		if len(k.lines) > 0 && strings.TrimSpace(k.lines[len(k.lines)-1]) != "" {
+			k.lines = append(k.lines, "")
+		}
+		k.lines = append(k.lines, "["+section+"]", entry)
+	}
+}
+
+// Delete removes a key
+func (k *keyfile) Delete(section, key string) {
+	if i, _ := k.find(section, key); i >= 0 {
+		k.lines = append(k.lines[:i], k.lines[i+1:]...)
+	}
+}
+
+// unescapeKeyfileValue decodes the backslash escapes of GKeyFile values
+func unescapeKeyfileValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+			} else {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		escaped = false
+		switch r {
+		case 's':
+			b.WriteRune(' ')
+		case 'n':
+			b.WriteRune('\n')
+		case 't':
+			b.WriteRune('\t')
+		case 'r':
+			b.WriteRune('\r')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,204 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+const officeKeyfile = `# Provisioned by the image builder
+[connection]
+id=Wired\sOffice
+uuid=5f3c3a2e-6a0e-4f4b-9d55-3c1d2a7b9e10
+type=ethernet
+interface-name=eth0
+
+[ethernet]
+mac-address-blacklist=
+
+[ipv4]
+method=auto
+# keep the lease short
+dhcp-timeout=20
+
+[ipv6]
+addr-gen-mode=stable-privacy
+method=auto
+
+[proxy]
+`
+
+func writeKeyfile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestKeyfileNMClient(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := writeKeyfile(t, dir, "office.nmconnection", officeKeyfile)
+	writeKeyfile(t, dir, "lo.nmconnection", "[connection]\nid=lo\ntype=loopback\n")
+	writeKeyfile(t, dir, "notes.txt", "[connection]\nid=ignored\n")
+	client := NewKeyfileNMClient(dir)
+
+	profiles, err := client.Profiles(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []NMProfile{
+		{Name: "lo", Type: "loopback"},
+		{Name: "Wired Office", UUID: "5f3c3a2e-6a0e-4f4b-9d55-3c1d2a7b9e10", Type: "ethernet"},
+	}, profiles)
+
+	profile, err := client.DeviceProfile(ctx, "eth0")
+	require.NoError(t, err)
+	assert.Equal(t, "Wired Office", profile.Name)
+	_, err = client.DeviceProfile(ctx, "wlan0")
+	assert.Error(t, err)
+
+	yes := true
+	require.NoError(t, client.UpdateDNS(ctx, NMProfile{Name: "Wired Office"}, NMDNSUpdate{
+		IPv4DNS:        []string{"9.9.9.9", "149.112.112.112"},
+		IPv4IgnoreAuto: &yes,
+		IPv6DNS:        []string{"2620:fe::fe"},
+		Search:         []string{"corp.example"},
+		MulticastDNS:   "no",
+	}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# Provisioned by the image builder
+[connection]
+id=Wired\sOffice
+uuid=5f3c3a2e-6a0e-4f4b-9d55-3c1d2a7b9e10
+type=ethernet
+interface-name=eth0
+mdns=0
+
+[ethernet]
+mac-address-blacklist=
+
+[ipv4]
+method=auto
+# keep the lease short
+dhcp-timeout=20
+dns=9.9.9.9;149.112.112.112;
+ignore-auto-dns=true
+dns-search=corp.example;
+
+[ipv6]
+addr-gen-mode=stable-privacy
+method=auto
+dns=2620:fe::fe;
+
+[proxy]
+`, string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	dns, err := client.ReadDNS(ctx, profile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9.9.9.9", "149.112.112.112"}, dns.IPv4)
+	assert.Equal(t, []string{"2620:fe::fe"}, dns.IPv6)
+	assert.Equal(t, []string{"corp.example"}, dns.Search)
+	assert.Equal(t, "no", dns.MulticastDNS)
+	assert.Equal(t, "default", dns.LLMNR)
+	assert.Equal(t, []string{"9.9.9.9", "149.112.112.112"}, dns.ConfiguredIPv4)
+	assert.True(t, dns.IgnoreAutoDNS)
+
+	// Resetting deletes the keys again
+	no := false
+	require.NoError(t, client.UpdateDNS(ctx, profile, NMDNSUpdate{
+		IPv4DNS:        []string{},
+		IPv4IgnoreAuto: &no,
+		IPv6DNS:        []string{},
+		Search:         []string{},
+		MulticastDNS:   "default",
+	}))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "\ndns=")
+	assert.NotContains(t, string(data), "mdns")
+	assert.Contains(t, string(data), "# keep the lease short\n")
+
+	err = client.UpdateDNS(ctx, profile, NMDNSUpdate{IPv4DNS: []string{"9.9.9.9:853"}})
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = client.ReadDNS(ctx, NMProfile{Name: "Missing"})
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestKeyfile_AddsMissingSection(t *testing.T) {
+	f := parseKeyfile("[connection]\nid=vpn\n")
+	f.Set("ipv4", "dns", "10.8.0.1;")
+	assert.Equal(t, "[connection]\nid=vpn\n\n[ipv4]\ndns=10.8.0.1;\n", f.String())
+
+	value, ok := f.Get("ipv4", "dns")
+	assert.True(t, ok)
+	assert.Equal(t, "10.8.0.1;", value)
+	_, ok = f.Get("ipv6", "dns")
+	assert.False(t, ok)
+}
+
+func TestConfigWriter_OfflineNetworkManager(t *testing.T) {
+	ctx := context.Background()
+	root := offlineRoot(t, nil)
+	dir := root.Path(nmKeyfileDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	writeKeyfile(t, dir, "office.nmconnection", officeKeyfile)
+
+	nm := NewNMClient(nil, root)
+	writer := NewConfigWriter(nil, nm, nil, root)
+	require.NoError(t, writer.Apply(ctx, models.BackendNetworkManager, []models.DNSConfig{{
+		Interface: models.NetworkInterface{Connection: "Wired Office"},
+		DNS:       models.DNSServer{IPv4: []string{"1.1.1.1"}},
+	}}))
+
+	info, err := NewConfigReader(nil, nm, nil, root).ReadDNSConfig(ctx, models.BackendNetworkManager)
+	require.NoError(t, err)
+	require.Len(t, info.Interfaces, 1)
+	assert.Equal(t, "Wired Office", info.Interfaces[0].Name)
+	assert.Equal(t, []string{"1.1.1.1"}, info.Interfaces[0].IPv4)
+
+	require.NoError(t, writer.ResetToAutomatic(ctx, models.BackendNetworkManager, []string{"Wired Office"}))
+	dns, err := nm.ReadDNS(ctx, NMProfile{Name: "Wired Office"})
+	require.NoError(t, err)
+	assert.Empty(t, dns.IPv4)
+}
+
+func TestKeyfileNMClient_LiveSystem(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// A profile copied in by hand, readable by everyone
+	path := filepath.Join(dir, "office.nmconnection")
+	require.NoError(t, os.WriteFile(path, []byte(officeKeyfile), 0644))
+
+	// With NetworkManager stopped, the keyfiles stand in for the daemon
+	nm := NewKeyfileNMClient(dir)
+	writer := NewConfigWriter(nil, nm, nil, nil)
+	require.NoError(t, writer.Apply(ctx, models.BackendNetworkManager, []models.DNSConfig{{
+		Interface: models.NetworkInterface{Name: "eth0"},
+		DNS:       models.DNSServer{IPv4: []string{"9.9.9.9"}},
+	}}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "NetworkManager ignores keyfiles others can read")
+
+	status, err := NewConfigReader(nil, nm, nil, nil).ReadDNSConfig(ctx, models.BackendNetworkManager)
+	require.NoError(t, err)
+	require.Len(t, status.Interfaces, 1)
+	assert.Equal(t, "Wired Office", status.Interfaces[0].Name)
+	assert.Equal(t, []string{"9.9.9.9"}, status.Interfaces[0].IPv4)
+
+	require.NoError(t, writer.ResetToAutomatic(ctx, models.BackendNetworkManager, []string{"Wired Office"}))
+	dns, err := nm.ReadDNS(ctx, NMProfile{Name: "Wired Office"})
+	require.NoError(t, err)
+	assert.Empty(t, dns.IPv4)
+}
@@ -0,0 +1,259 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+)
+
+const (
+	// netplanDir holds the netplan configuration
+	netplanDir = "/etc/netplan"
+	// netplanOwnedPath is the netplan file cdns owns. Files are merged in
+	// name order, so the high prefix puts it after the distribution's.
+	netplanOwnedPath = netplanDir + "/90-cdns.yaml"
+)
+
+// netplanDeviceTypes are the netplan sections that define interfaces
+var netplanDeviceTypes = []string{
+	"ethernets", "wifis", "bonds", "bridges", "vlans", "tunnels",
+	"vrfs", "modems", "dummy-devices", "virtual-ethernets",
+}
+
+// netplanNameservers is the nameservers mapping of a netplan interface
+type netplanNameservers struct {
+	Addresses []string `yaml:"addresses,omitempty,flow"`
+	Search    []string `yaml:"search,omitempty,flow"`
+}
+
+// netplanDevice holds the keys cdns reads from a netplan interface
+type netplanDevice struct {
+	Nameservers *netplanNameservers `yaml:"nameservers,omitempty"`
+}
+
+// netplanFile is the layout of the file cdns owns
+type netplanFile struct {
+	Network struct {
+		Version int                                 `yaml:"version"`
+		Devices map[string]map[string]netplanDevice `yaml:",inline"`
+	} `yaml:"network"`
+}
+
+// netplanDefinition is an interface defined in the netplan configuration
+type netplanDefinition struct {
+	Name string
+	Type string // the section defining it, e.g. "ethernets"
+	// Files lists the files mentioning the interface, in merge order
+	Files       []string
+	Nameservers netplanNameservers
+	// NameserverFiles lists the files setting nameservers
+	NameserverFiles []string
+}
+
+// readNetplan returns the interfaces defined across the netplan files, in
+// order of first definition. Nameserver lists of several files are
+// concatenated, as netplan merges them.
+func readNetplan(root *Root) ([]netplanDefinition, error) {
+	paths, err := filepath.Glob(filepath.Join(root.Path(netplanDir), "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	var defs []netplanDefinition
+	index := make(map[string]int)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		var doc struct {
+			Network map[string]yaml.Node `yaml:"network"`
+		}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		name := filepath.Join(netplanDir, filepath.Base(path))
+		for _, kind := range netplanDeviceTypes {
+			section, ok := doc.Network[kind]
+			if !ok || section.Kind != yaml.MappingNode {
+				continue
+			}
+			// Walk the mapping node to keep the order of the file
+			for i := 0; i+1 < len(section.Content); i += 2 {
+				iface := section.Content[i].Value
+				var dev netplanDevice
+				if err := section.Content[i+1].Decode(&dev); err != nil {
+					return nil, fmt.Errorf("failed to parse %s: %s: %w", path, iface, err)
+				}
+
+				n, ok := index[iface]
+				if !ok {
+					n = len(defs)
+					index[iface] = n
+					defs = append(defs, netplanDefinition{Name: iface, Type: kind})
+				}
+				def := &defs[n]
+				def.Files = append(def.Files, name)
+				if dev.Nameservers != nil {
+					def.Nameservers.Addresses = append(def.Nameservers.Addresses, dev.Nameservers.Addresses...)
+					def.Nameservers.Search = append(def.Nameservers.Search, dev.Nameservers.Search...)
+					def.NameserverFiles = append(def.NameserverFiles, name)
+				}
+			}
+		}
+	}
+	return defs, nil
+}
+
+// NetplanInterfaces lists the interfaces defined in the netplan
+// configuration under root
+func NetplanInterfaces(root *Root) ([]string, error) {
+	defs, err := readNetplan(root)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
+	return names, nil
+}
+
+// applyNetplan sets the nameservers of each interface in the netplan file
+// cdns owns, keeping the entries it holds for other interfaces. On a running
+// system the configuration is then applied with 'netplan apply'.
+func (w *ConfigWriter) applyNetplan(ctx context.Context, configs []models.DNSConfig) error {
+	defs, err := readNetplan(w.root)
+	if err != nil {
+		return err
+	}
+	owned, err := w.loadNetplanOwned()
+	if err != nil {
+		return err
+	}
+
+	for _, cfg := range configs {
+		name := cfg.Interface.Name
+		if name == "" {
+			continue
+		}
+
+		kind := "ethernets"
+		for _, def := range defs {
+			if def.Name != name {
+				continue
+			}
+			kind = def.Type
+			// netplan concatenates nameserver lists, so servers set
+			// elsewhere would be queried along with the new ones
+			for _, file := range def.NameserverFiles {
+				if file != netplanOwnedPath {
+					return fmt.Errorf("%w: %s already has nameservers in %s, which netplan would combine with the new ones; remove them there first",
+						ErrUnsupported, name, file)
+				}
+			}
+		}
+
+		// Drop the interface from any other section it was written to
+		for _, devices := range owned.Network.Devices {
+			delete(devices, name)
+		}
+		if owned.Network.Devices[kind] == nil {
+			owned.Network.Devices[kind] = make(map[string]netplanDevice)
+		}
+		owned.Network.Devices[kind][name] = netplanDevice{Nameservers: &netplanNameservers{
+			Addresses: cfg.DNS.Ordered(),
+			Search:    cfg.Search,
+		}}
+	}
+
+	data, err := yaml.Marshal(owned)
+	if err != nil {
+		return fmt.Errorf("failed to encode netplan configuration: %w", err)
+	}
+	// netplan warns about files readable by other users
+	if err := writeFile(w.root.Path(netplanOwnedPath), append([]byte(ownedFileHeader), data...), 0600); err != nil {
+		return err
+	}
+	return w.netplanApply(ctx)
+}
+
+// loadNetplanOwned reads the netplan file cdns owns, if any
+func (w *ConfigWriter) loadNetplanOwned() (*netplanFile, error) {
+	owned := &netplanFile{}
+	path := w.root.Path(netplanOwnedPath)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, owned); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	owned.Network.Version = 2
+	if owned.Network.Devices == nil {
+		owned.Network.Devices = make(map[string]map[string]netplanDevice)
+	}
+	return owned, nil
+}
+
+// resetNetplan removes the netplan file cdns owns
+func (w *ConfigWriter) resetNetplan(ctx context.Context) error {
+	if err := removeFile(w.root.Path(netplanOwnedPath)); err != nil {
+		return err
+	}
+	return w.netplanApply(ctx)
+}
+
+// netplanApply applies the netplan configuration of a running system.
+// Offline, netplan generates the configuration at boot.
+func (w *ConfigWriter) netplanApply(ctx context.Context) error {
+	if w.root.Offline() {
+		return nil
+	}
+	if _, err := w.runner.Run(ctx, "netplan", "apply"); err != nil {
+		return fmt.Errorf("failed to apply netplan configuration: %s: %w", commandStderr(err), err)
+	}
+	return nil
+}
+
+// readNetplanStatus reports the nameservers of each netplan interface
+func (r *ConfigReader) readNetplanStatus() (*status.StatusInfo, error) {
+	defs, err := readNetplan(r.root)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &status.StatusInfo{
+		Backend:    models.BackendNetplan,
+		Interfaces: []status.InterfaceStatus{},
+		Warnings:   []string{},
+	}
+	for _, def := range defs {
+		iface := status.InterfaceStatus{Name: def.Name, IPv4: []string{}, IPv6: []string{}, Search: def.Nameservers.Search}
+		for _, server := range def.Nameservers.Addresses {
+			if models.IsIPv6Server(server) {
+				iface.IPv6 = append(iface.IPv6, server)
+			} else {
+				iface.IPv4 = append(iface.IPv4, server)
+			}
+		}
+		info.Interfaces = append(info.Interfaces, iface)
+	}
+	annotateManual(info)
+	// Nameservers set only in the file cdns owns were applied by cdns
+	for i, def := range defs {
+		info.Interfaces[i].Managed = slices.Equal(def.NameserverFiles, []string{netplanOwnedPath})
+		info.Managed = info.Managed || info.Interfaces[i].Managed
+	}
+	return info, nil
+}
@@ -0,0 +1,186 @@
+package backend
+
+import (
+	"context"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// NMClient reads and updates NetworkManager connection profiles. It is
+// implemented over D-Bus and, where the bus is unavailable, with nmcli.
+type NMClient interface {
+	// Profiles lists every saved connection profile
+	Profiles(ctx context.Context) ([]NMProfile, error)
+	// ConnectedDevices lists the devices in the connected state
+	ConnectedDevices(ctx context.Context) ([]string, error)
+	// DeviceProfile returns the profile active on a device
+	DeviceProfile(ctx context.Context, device string) (NMProfile, error)
+	// ReadDNS returns the DNS settings of a profile. Servers are the ones in
+	// use while the profile is active and the configured ones otherwise.
+	ReadDNS(ctx context.Context, profile NMProfile) (NMDNS, error)
+	// UpdateDNS changes the DNS settings of a profile and saves it
+	UpdateDNS(ctx context.Context, profile NMProfile, update NMDNSUpdate) error
+	// Reapply makes profile changes effective on a device without reconnecting
+	Reapply(ctx context.Context, device string) error
+	// ReloadConfig makes NetworkManager reread NetworkManager.conf and conf.d
+	ReloadConfig(ctx context.Context) error
+}
+
+// NMProfile describes a saved NetworkManager connection profile. Either
+// Name or UUID is enough to address it; the UUID is preferred.
+type NMProfile struct {
+	Name   string
+	UUID   string
+	Type   string
+	Device string // empty when the profile is not active
+}
+
+// label returns the profile name, or its UUID when the name is unknown
+func (p NMProfile) label() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.UUID
+}
+
+// NMDNS holds the DNS settings of a connection profile
+type NMDNS struct {
+	IPv4    []string
+	IPv6    []string
+	Search  []string
+	Options []string
+	// LLMNR and MulticastDNS use nmcli values: "default", "yes", "no" or "resolve"
+	LLMNR        string
+	MulticastDNS string
+	// ConfiguredIPv4 and ConfiguredIPv6 are the servers set in the profile
+	// itself, as opposed to the ones received from DHCP
+	ConfiguredIPv4 []string
+	ConfiguredIPv6 []string
+	// IgnoreAutoDNS reports whether ipv4.ignore-auto-dns keeps DHCP from
+	// adding servers
+	IgnoreAutoDNS bool
+}
+
+// NMDNSUpdate lists the DNS settings to change on a profile. Nil slices and
+// pointers and empty strings leave a setting unchanged; an empty non-nil
+// slice clears it.
+type NMDNSUpdate struct {
+	IPv4DNS []string
+	IPv6DNS []string
+	// IPv4IgnoreAuto and IPv6IgnoreAuto stop DHCP and router advertisements
+	// from adding servers
+	IPv4IgnoreAuto *bool
+	IPv6IgnoreAuto *bool
+	Search         []string
+	Options        []string
+	// IPv4Priority and IPv6Priority order the servers across profiles and
+	// families, lower first; 0 restores the default
+	IPv4Priority *int32
+	IPv6Priority *int32
+	LLMNR        string
+	MulticastDNS string
+}
+
+const (
+	nmBusName = "org.freedesktop.NetworkManager"
+	nmPath    = dbus.ObjectPath("/org/freedesktop/NetworkManager")
+)
+
+// NewNMClient returns a NetworkManager client that talks D-Bus when
+// NetworkManager is on the system bus and falls back to nmcli otherwise.
+// The bus is only contacted on first use. nmcli is run with runner. When
+// NetworkManager is stopped, or with an offline root, its keyfiles are edited
+// instead.
+func NewNMClient(runner CommandRunner, root *Root) NMClient {
+	return &autoNMClient{runner: runner, root: root}
+}
+
+// autoNMClient picks the D-Bus, nmcli or keyfile client on first use
+type autoNMClient struct {
+	runner CommandRunner
+	root   *Root
+	once   sync.Once
+	client NMClient
+}
+
+func (a *autoNMClient) get() NMClient {
+	a.once.Do(func() {
+		if a.root.Offline() {
+			a.client = NewKeyfileNMClient(a.root.Path(nmKeyfileDir))
+			return
+		}
+		if conn, err := dbus.ConnectSystemBus(); err == nil {
+			if nmOnBus(conn) {
+				a.client = NewDBusNMClient(conn)
+				return
+			}
+			conn.Close()
+		}
+		// nmcli needs the daemon as well; while it is stopped, for example
+		// during provisioning or in recovery mode, the profiles are edited
+		// on disk and picked up when it starts
+		sysOps := &DefaultSystemOps{Runner: a.runner}
+		if running, err := sysOps.ServiceRunning("NetworkManager"); err == nil && !running && sysOps.FileExists(nmKeyfileDir) {
+			a.client = NewKeyfileNMClient(nmKeyfileDir)
+			return
+		}
+		a.client = nmcliClient{runner: a.runner}
+	})
+	return a.client
+}
+
+// usesKeyfiles reports whether nm edits keyfiles instead of talking to a
+// running NetworkManager. No profile is active on a device then.
+func usesKeyfiles(nm NMClient) bool {
+	if auto, ok := nm.(*autoNMClient); ok {
+		nm = auto.get()
+	}
+	_, ok := nm.(*KeyfileNMClient)
+	return ok
+}
+
+func (a *autoNMClient) Profiles(ctx context.Context) ([]NMProfile, error) {
+	return a.get().Profiles(ctx)
+}
+
+func (a *autoNMClient) ConnectedDevices(ctx context.Context) ([]string, error) {
+	return a.get().ConnectedDevices(ctx)
+}
+
+func (a *autoNMClient) DeviceProfile(ctx context.Context, device string) (NMProfile, error) {
+	return a.get().DeviceProfile(ctx, device)
+}
+
+func (a *autoNMClient) ReadDNS(ctx context.Context, profile NMProfile) (NMDNS, error) {
+	return a.get().ReadDNS(ctx, profile)
+}
+
+func (a *autoNMClient) UpdateDNS(ctx context.Context, profile NMProfile, update NMDNSUpdate) error {
+	return a.get().UpdateDNS(ctx, profile, update)
+}
+
+func (a *autoNMClient) Reapply(ctx context.Context, device string) error {
+	return a.get().Reapply(ctx, device)
+}
+
+func (a *autoNMClient) ReloadConfig(ctx context.Context) error {
+	return a.get().ReloadConfig(ctx)
+}
+
+// nmOnBus reports whether NetworkManager owns its name on the bus
+func nmOnBus(conn *dbus.Conn) bool {
+	var owned bool
+	err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, nmBusName).Store(&owned)
+	return err == nil && owned
+}
+
+// NMAvailableOnBus reports whether NetworkManager can be reached over the system bus
+func NMAvailableOnBus() bool {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	return nmOnBus(conn)
+}
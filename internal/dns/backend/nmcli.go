@@ -0,0 +1,259 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// nmcliClient implements NMClient by running nmcli
+type nmcliClient struct {
+	runner CommandRunner
+}
+
+// Profiles returns every saved NetworkManager connection profile
+func (c nmcliClient) Profiles(ctx context.Context) ([]NMProfile, error) {
+	output, err := c.run(ctx, "-t", "-f", "NAME,UUID,TYPE,DEVICE", "connection", "show")
+	if err != nil {
+		return nil, err
+	}
+	return parseNMProfiles(output), nil
+}
+
+// ConnectedDevices returns the devices NetworkManager reports as connected
+func (c nmcliClient) ConnectedDevices(ctx context.Context) ([]string, error) {
+	output, err := c.run(ctx, "-t", "-f", "DEVICE,STATE", "device", "status")
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		parts := SplitTerseFields(scanner.Text())
+		if len(parts) >= 2 && parts[1] == "connected" {
+			devices = append(devices, parts[0])
+		}
+	}
+	return devices, nil
+}
+
+// DeviceProfile returns the connection active on a device
+func (c nmcliClient) DeviceProfile(ctx context.Context, device string) (NMProfile, error) {
+	// -g prints just the value
+	output, err := c.run(ctx, "-g", "GENERAL.CONNECTION", "device", "show", device)
+	if err != nil {
+		return NMProfile{}, err
+	}
+	name := strings.TrimSpace(output)
+	if name == "" {
+		return NMProfile{}, fmt.Errorf("no active connection found for interface %s", device)
+	}
+	return NMProfile{Name: name, Device: device}, nil
+}
+
+// ReadDNS reads the DNS related fields of a profile
+func (c nmcliClient) ReadDNS(ctx context.Context, profile NMProfile) (NMDNS, error) {
+	args := append([]string{"-t", "-f", "IP4.DNS,IP6.DNS,ipv4.dns,ipv6.dns,ipv4.dns-search,ipv4.dns-options,ipv4.ignore-auto-dns,connection.llmnr,connection.mdns",
+		"connection", "show"}, nmConnectionID(profile)...)
+	output, err := c.run(ctx, args...)
+	if err != nil {
+		return NMDNS{}, err
+	}
+	return parseNMConnectionDNS(output)
+}
+
+// UpdateDNS changes the DNS settings of a profile with a single
+// 'nmcli connection modify', which saves the profile
+func (c nmcliClient) UpdateDNS(ctx context.Context, profile NMProfile, update NMDNSUpdate) error {
+	props := nmcliProperties(update)
+	if len(props) == 0 {
+		return nil
+	}
+	args := append(append([]string{"connection", "modify"}, nmConnectionID(profile)...), props...)
+	_, err := c.run(ctx, args...)
+	return err
+}
+
+// Reapply reapplies the profile active on a device
+func (c nmcliClient) Reapply(ctx context.Context, device string) error {
+	_, err := c.run(ctx, "device", "reapply", device)
+	return err
+}
+
+// ReloadConfig reloads the NetworkManager configuration files
+func (c nmcliClient) ReloadConfig(ctx context.Context) error {
+	_, err := c.run(ctx, "general", "reload", "conf")
+	return err
+}
+
+// run runs nmcli and returns its output, with its error message included in errors
+func (c nmcliClient) run(ctx context.Context, args ...string) (string, error) {
+	output, err := c.runner.Run(ctx, "nmcli", args...)
+	if err != nil {
+		return "", fmt.Errorf("nmcli error: %s: %w", commandStderr(err), err)
+	}
+	return string(output), nil
+}
+
+// nmcliProperties converts an update into 'nmcli connection modify'
+// property and value arguments
+func nmcliProperties(update NMDNSUpdate) []string {
+	var props []string
+	if update.IPv4DNS != nil {
+		props = append(props, "ipv4.dns", strings.Join(update.IPv4DNS, " "))
+	}
+	if update.IPv4IgnoreAuto != nil {
+		props = append(props, "ipv4.ignore-auto-dns", nmcliBool(*update.IPv4IgnoreAuto))
+	}
+	if update.IPv6DNS != nil {
+		props = append(props, "ipv6.dns", strings.Join(update.IPv6DNS, " "))
+	}
+	if update.IPv6IgnoreAuto != nil {
+		props = append(props, "ipv6.ignore-auto-dns", nmcliBool(*update.IPv6IgnoreAuto))
+	}
+	if update.IPv4Priority != nil {
+		props = append(props, "ipv4.dns-priority", strconv.Itoa(int(*update.IPv4Priority)))
+	}
+	if update.IPv6Priority != nil {
+		props = append(props, "ipv6.dns-priority", strconv.Itoa(int(*update.IPv6Priority)))
+	}
+	if update.Search != nil {
+		props = append(props, "ipv4.dns-search", strings.Join(update.Search, ","))
+	}
+	if update.Options != nil {
+		props = append(props, "ipv4.dns-options", strings.Join(update.Options, ","))
+	}
+	if update.LLMNR != "" {
+		props = append(props, "connection.llmnr", update.LLMNR)
+	}
+	if update.MulticastDNS != "" {
+		props = append(props, "connection.mdns", update.MulticastDNS)
+	}
+	return props
+}
+
+func nmcliBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// parseNMProfiles parses terse 'nmcli -f NAME,UUID,TYPE,DEVICE connection show' output
+func parseNMProfiles(output string) []NMProfile {
+	var profiles []NMProfile
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		parts := SplitTerseFields(scanner.Text())
+		if len(parts) < 4 || parts[0] == "" {
+			continue
+		}
+		profiles = append(profiles, NMProfile{Name: parts[0], UUID: parts[1], Type: parts[2], Device: parts[3]})
+	}
+	return profiles
+}
+
+// parseNMConnectionDNS parses terse nmcli output for the DNS related fields
+// of a connection. The runtime IP4.DNS/IP6.DNS values are only present while
+// the profile is active; otherwise the configured ipv4.dns/ipv6.dns are used.
+// Values are unescaped once, as nmcli escapes ':' and '\' in terse mode.
+func parseNMConnectionDNS(output string) (NMDNS, error) {
+	var dns NMDNS
+	var configured4, configured6 []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := SplitTerseFields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		key, value := fields[0], strings.Join(fields[1:], ":")
+		if value == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(key, "IP4.DNS"):
+			dns.IPv4 = append(dns.IPv4, strings.TrimSpace(value))
+		case strings.HasPrefix(key, "IP6.DNS"):
+			dns.IPv6 = append(dns.IPv6, strings.TrimSpace(value))
+		case key == "ipv4.dns":
+			configured4 = splitNMList(value)
+		case key == "ipv6.dns":
+			configured6 = splitNMList(value)
+		case key == "ipv4.dns-search":
+			dns.Search = splitNMList(value)
+		case key == "ipv4.dns-options":
+			dns.Options = splitNMList(value)
+		case key == "ipv4.ignore-auto-dns":
+			dns.IgnoreAutoDNS = strings.TrimSpace(value) == "yes"
+		case key == "connection.llmnr":
+			dns.LLMNR = nmcliSettingValue(value)
+		case key == "connection.mdns":
+			dns.MulticastDNS = nmcliSettingValue(value)
+		}
+	}
+
+	dns.ConfiguredIPv4, dns.ConfiguredIPv6 = configured4, configured6
+	if len(dns.IPv4) == 0 && len(dns.IPv6) == 0 {
+		dns.IPv4, dns.IPv6 = configured4, configured6
+	}
+
+	return dns, scanner.Err()
+}
+
+// nmcliSettingValue normalizes an LLMNR or mDNS value, which nmcli prints
+// as a name, a number or both, e.g. "yes", "2" or "-1 (default)"
+func nmcliSettingValue(value string) string {
+	value, _, _ = strings.Cut(strings.TrimSpace(value), " (")
+	if n, err := strconv.Atoi(value); err == nil {
+		return nmLinkSettingName(int32(n))
+	}
+	return value
+}
+
+// splitNMList splits a comma-separated nmcli list value
+func splitNMList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// nmConnectionID returns the nmcli arguments addressing a connection profile.
+// The UUID is preferred since profile names need not be unique; the "id"
+// keyword stops names such as "uuid" being taken for a selector.
+func nmConnectionID(profile NMProfile) []string {
+	if profile.UUID != "" {
+		return []string{"uuid", profile.UUID}
+	}
+	return []string{"id", profile.Name}
+}
+
+// SplitTerseFields splits a line of terse nmcli output (-t) into its fields.
+// nmcli escapes literal colons and backslashes inside values with a backslash,
+// so connection names such as "Office: 5GHz" survive the split intact.
+func SplitTerseFields(line string) []string {
+	var fields []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':':
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(fields, current.String())
+}
@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTerseFields(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{name: "plain fields", line: "eth0:ethernet:connected", want: []string{"eth0", "ethernet", "connected"}},
+		{name: "escaped colon", line: `Office\: 5GHz:wifi:`, want: []string{"Office: 5GHz", "wifi", ""}},
+		{name: "escaped backslash", line: `a\\b:c`, want: []string{`a\b`, "c"}},
+		{name: "empty line", line: "", want: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SplitTerseFields(tt.line))
+		})
+	}
+}
+
+func TestParseNMProfiles(t *testing.T) {
+	output := `Wired connection 1:3f2c0a52-8c1e-4a3e-9d6a-0b1c2d3e4f50:802-3-ethernet:eth0
+Office\: HQ:6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff:802-11-wireless:
+lo:9a8b7c6d-2222-4e5f-a0b1-112233445566:loopback:lo
+`
+	assert.Equal(t, []NMProfile{
+		{Name: "Wired connection 1", UUID: "3f2c0a52-8c1e-4a3e-9d6a-0b1c2d3e4f50", Type: "802-3-ethernet", Device: "eth0"},
+		{Name: "Office: HQ", UUID: "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff", Type: "802-11-wireless"},
+		{Name: "lo", UUID: "9a8b7c6d-2222-4e5f-a0b1-112233445566", Type: "loopback", Device: "lo"},
+	}, parseNMProfiles(output))
+}
+
+func TestNMConnectionID(t *testing.T) {
+	assert.Equal(t, []string{"id", "Office WiFi"}, nmConnectionID(NMProfile{Name: "Office WiFi"}))
+	assert.Equal(t, []string{"uuid", "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff"},
+		nmConnectionID(NMProfile{Name: "Office WiFi", UUID: "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff"}))
+}
+
+func TestNMCLIProperties(t *testing.T) {
+	no, priority := false, int32(0)
+	assert.Equal(t, []string{
+		"ipv4.dns", "", "ipv4.ignore-auto-dns", "no", "ipv6.dns", "1.1.1.1 1.0.0.1",
+		"ipv6.dns-priority", "0", "ipv4.dns-search", "corp.example,lab.example", "connection.llmnr", "no",
+	}, nmcliProperties(NMDNSUpdate{
+		IPv4DNS:        []string{},
+		IPv4IgnoreAuto: &no,
+		IPv6DNS:        []string{"1.1.1.1", "1.0.0.1"},
+		IPv6Priority:   &priority,
+		Search:         []string{"corp.example", "lab.example"},
+		LLMNR:          "no",
+	}))
+	assert.Empty(t, nmcliProperties(NMDNSUpdate{}))
+}
+
+func TestParseNMConnectionDNS_LinkSettings(t *testing.T) {
+	dns, err := parseNMConnectionDNS("connection.llmnr:0\nconnection.mdns:-1 (default)\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "no", dns.LLMNR)
+	assert.Equal(t, "default", dns.MulticastDNS)
+
+	dns, err = parseNMConnectionDNS("connection.llmnr:resolve\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "resolve", dns.LLMNR)
+}
+
+func TestParseNMConnectionDNS_Configured(t *testing.T) {
+	// Active with DHCP servers added to the configured one
+	dns, err := parseNMConnectionDNS("IP4.DNS[1]:9.9.9.9\nIP4.DNS[2]:192.168.1.1\nipv4.dns:9.9.9.9\nipv4.ignore-auto-dns:no\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"9.9.9.9", "192.168.1.1"}, dns.IPv4)
+	assert.Equal(t, []string{"9.9.9.9"}, dns.ConfiguredIPv4)
+	assert.False(t, dns.IgnoreAutoDNS)
+
+	dns, err = parseNMConnectionDNS("ipv4.dns:9.9.9.9\nipv4.ignore-auto-dns:yes\n")
+	assert.NoError(t, err)
+	assert.True(t, dns.IgnoreAutoDNS)
+}
@@ -0,0 +1,509 @@
+package backend
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	nmIface           = "org.freedesktop.NetworkManager"
+	nmSettingsPath    = dbus.ObjectPath("/org/freedesktop/NetworkManager/Settings")
+	nmSettingsIface   = "org.freedesktop.NetworkManager.Settings"
+	nmConnectionIface = "org.freedesktop.NetworkManager.Settings.Connection"
+	nmActiveIface     = "org.freedesktop.NetworkManager.Connection.Active"
+	nmDeviceIface     = "org.freedesktop.NetworkManager.Device"
+	nmIP4ConfigIface  = "org.freedesktop.NetworkManager.IP4Config"
+	nmIP6ConfigIface  = "org.freedesktop.NetworkManager.IP6Config"
+
+	// nmDeviceStateActivated is NM_DEVICE_STATE_ACTIVATED, shown as "connected" by nmcli
+	nmDeviceStateActivated = 100
+	// nmReloadConf is NM_MANAGER_RELOAD_FLAG_CONF, rereading the configuration files
+	nmReloadConf = 0x1
+)
+
+// nmSecretSettings are the settings whose secrets GetSettings leaves out.
+// They are fetched before an update so that saving the profile keeps them.
+var nmSecretSettings = []string{"802-11-wireless-security", "802-1x", "vpn", "wireguard", "pppoe", "gsm", "cdma"}
+
+// nmSettings is a connection profile as exchanged over D-Bus, a{sa{sv}}
+type nmSettings map[string]map[string]dbus.Variant
+
+// DBusNMClient implements NMClient with the NetworkManager D-Bus API
+type DBusNMClient struct {
+	conn *dbus.Conn
+}
+
+// NewDBusNMClient creates a client talking to NetworkManager on conn
+func NewDBusNMClient(conn *dbus.Conn) *DBusNMClient {
+	return &DBusNMClient{conn: conn}
+}
+
+func (c *DBusNMClient) object(path dbus.ObjectPath) dbus.BusObject {
+	return c.conn.Object(nmBusName, path)
+}
+
+// Profiles lists every saved connection profile, with the device of the active ones
+func (c *DBusNMClient) Profiles(ctx context.Context) ([]NMProfile, error) {
+	var paths []dbus.ObjectPath
+	if err := c.object(nmSettingsPath).CallWithContext(ctx, nmSettingsIface+".ListConnections", 0).Store(&paths); err != nil {
+		return nil, fmt.Errorf("failed to list connections: %w", err)
+	}
+	devices, err := c.activeDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]NMProfile, 0, len(paths))
+	for _, path := range paths {
+		settings, err := c.settings(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		profile := settings.profile()
+		profile.Device = devices[profile.UUID]
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// ConnectedDevices lists the devices in the activated state
+func (c *DBusNMClient) ConnectedDevices(ctx context.Context) ([]string, error) {
+	var paths []dbus.ObjectPath
+	if err := c.object(nmPath).CallWithContext(ctx, nmIface+".GetDevices", 0).Store(&paths); err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	var devices []string
+	for _, path := range paths {
+		var state uint32
+		var name string
+		if err := storeProperty(ctx, c.object(path), nmDeviceIface, "State", &state); err != nil {
+			return nil, fmt.Errorf("failed to read device state: %w", err)
+		}
+		if state != nmDeviceStateActivated {
+			continue
+		}
+		if err := storeProperty(ctx, c.object(path), nmDeviceIface, "Interface", &name); err != nil {
+			return nil, fmt.Errorf("failed to read device name: %w", err)
+		}
+		devices = append(devices, name)
+	}
+	return devices, nil
+}
+
+// DeviceProfile returns the profile active on a device
+func (c *DBusNMClient) DeviceProfile(ctx context.Context, device string) (NMProfile, error) {
+	devicePath, err := c.devicePath(ctx, device)
+	if err != nil {
+		return NMProfile{}, err
+	}
+	var active dbus.ObjectPath
+	if err := storeProperty(ctx, c.object(devicePath), nmDeviceIface, "ActiveConnection", &active); err != nil {
+		return NMProfile{}, fmt.Errorf("failed to read active connection of %s: %w", device, err)
+	}
+	if active == "/" || active == "" {
+		return NMProfile{}, fmt.Errorf("no active connection found for interface %s", device)
+	}
+
+	profile := NMProfile{Device: device}
+	obj := c.object(active)
+	for name, target := range map[string]*string{"Id": &profile.Name, "Uuid": &profile.UUID, "Type": &profile.Type} {
+		if err := storeProperty(ctx, obj, nmActiveIface, name, target); err != nil {
+			return NMProfile{}, fmt.Errorf("failed to read active connection of %s: %w", device, err)
+		}
+	}
+	return profile, nil
+}
+
+// ReadDNS returns the DNS settings of a profile, with the runtime servers
+// while it is active
+func (c *DBusNMClient) ReadDNS(ctx context.Context, profile NMProfile) (NMDNS, error) {
+	path, err := c.connectionPath(ctx, profile)
+	if err != nil {
+		return NMDNS{}, err
+	}
+	settings, err := c.settings(ctx, path)
+	if err != nil {
+		return NMDNS{}, err
+	}
+
+	dns := settings.dns()
+	ipv4, ipv6, active, err := c.runtimeDNS(ctx, settings.profile().UUID)
+	if err != nil {
+		return NMDNS{}, err
+	}
+	if active && (len(ipv4) > 0 || len(ipv6) > 0) {
+		dns.IPv4, dns.IPv6 = ipv4, ipv6
+	}
+	return dns, nil
+}
+
+// UpdateDNS changes the DNS settings of a profile and saves it to disk
+func (c *DBusNMClient) UpdateDNS(ctx context.Context, profile NMProfile, update NMDNSUpdate) error {
+	path, err := c.connectionPath(ctx, profile)
+	if err != nil {
+		return err
+	}
+	settings, err := c.settings(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	// Update replaces the whole profile, so secrets must be sent back too
+	obj := c.object(path)
+	for _, name := range nmSecretSettings {
+		if _, ok := settings[name]; !ok {
+			continue
+		}
+		var secrets nmSettings
+		if err := obj.CallWithContext(ctx, nmConnectionIface+".GetSecrets", 0, name).Store(&secrets); err != nil {
+			continue // no secrets stored, or owned by an agent
+		}
+		for key, value := range secrets[name] {
+			settings[name][key] = value
+		}
+	}
+
+	if err := settings.apply(update); err != nil {
+		return err
+	}
+	if err := obj.CallWithContext(ctx, nmConnectionIface+".Update", 0, settings).Err; err != nil {
+		return fmt.Errorf("failed to update connection %s: %w", profile.label(), err)
+	}
+	return nil
+}
+
+// Reapply applies the current settings of the profile active on a device
+func (c *DBusNMClient) Reapply(ctx context.Context, device string) error {
+	path, err := c.devicePath(ctx, device)
+	if err != nil {
+		return err
+	}
+	// Empty settings reapply the saved profile; version 0 skips the version check
+	if err := c.object(path).CallWithContext(ctx, nmDeviceIface+".Reapply", 0, nmSettings{}, uint64(0), uint32(0)).Err; err != nil {
+		return fmt.Errorf("failed to reapply configuration on %s: %w", device, err)
+	}
+	return nil
+}
+
+// ReloadConfig reloads the NetworkManager configuration files
+func (c *DBusNMClient) ReloadConfig(ctx context.Context) error {
+	if err := c.object(nmPath).CallWithContext(ctx, nmIface+".Reload", 0, uint32(nmReloadConf)).Err; err != nil {
+		return fmt.Errorf("failed to reload NetworkManager configuration: %w", err)
+	}
+	return nil
+}
+
+// settings reads a connection profile
+func (c *DBusNMClient) settings(ctx context.Context, path dbus.ObjectPath) (nmSettings, error) {
+	var settings nmSettings
+	if err := c.object(path).CallWithContext(ctx, nmConnectionIface+".GetSettings", 0).Store(&settings); err != nil {
+		return nil, fmt.Errorf("failed to read connection %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// connectionPath finds the object of a saved profile, by UUID or else by name
+func (c *DBusNMClient) connectionPath(ctx context.Context, profile NMProfile) (dbus.ObjectPath, error) {
+	settings := c.object(nmSettingsPath)
+	if profile.UUID != "" {
+		var path dbus.ObjectPath
+		if err := settings.CallWithContext(ctx, nmSettingsIface+".GetConnectionByUuid", 0, profile.UUID).Store(&path); err != nil {
+			return "", fmt.Errorf("failed to find connection %s: %w", profile.UUID, err)
+		}
+		return path, nil
+	}
+
+	var paths []dbus.ObjectPath
+	if err := settings.CallWithContext(ctx, nmSettingsIface+".ListConnections", 0).Store(&paths); err != nil {
+		return "", fmt.Errorf("failed to list connections: %w", err)
+	}
+	for _, path := range paths {
+		s, err := c.settings(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		if s.profile().Name == profile.Name {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no connection named %q", profile.Name)
+}
+
+// devicePath finds the object of a device by interface name
+func (c *DBusNMClient) devicePath(ctx context.Context, device string) (dbus.ObjectPath, error) {
+	var path dbus.ObjectPath
+	if err := c.object(nmPath).CallWithContext(ctx, nmIface+".GetDeviceByIpIface", 0, device).Store(&path); err != nil {
+		return "", fmt.Errorf("failed to find device %s: %w", device, err)
+	}
+	return path, nil
+}
+
+// activeConnections returns the object of each active connection by profile UUID
+func (c *DBusNMClient) activeConnections(ctx context.Context) (map[string]dbus.ObjectPath, error) {
+	var paths []dbus.ObjectPath
+	if err := storeProperty(ctx, c.object(nmPath), nmIface, "ActiveConnections", &paths); err != nil {
+		return nil, fmt.Errorf("failed to list active connections: %w", err)
+	}
+	active := make(map[string]dbus.ObjectPath, len(paths))
+	for _, path := range paths {
+		var uuid string
+		if err := storeProperty(ctx, c.object(path), nmActiveIface, "Uuid", &uuid); err != nil {
+			return nil, fmt.Errorf("failed to read active connection: %w", err)
+		}
+		active[uuid] = path
+	}
+	return active, nil
+}
+
+// activeDevices returns the device of each active profile by UUID
+func (c *DBusNMClient) activeDevices(ctx context.Context) (map[string]string, error) {
+	active, err := c.activeConnections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]string, len(active))
+	for uuid, path := range active {
+		var paths []dbus.ObjectPath
+		if err := storeProperty(ctx, c.object(path), nmActiveIface, "Devices", &paths); err != nil || len(paths) == 0 {
+			continue
+		}
+		var name string
+		if err := storeProperty(ctx, c.object(paths[0]), nmDeviceIface, "Interface", &name); err == nil {
+			devices[uuid] = name
+		}
+	}
+	return devices, nil
+}
+
+// runtimeDNS returns the servers in use by an active profile. active is
+// false when the profile is not active.
+func (c *DBusNMClient) runtimeDNS(ctx context.Context, uuid string) (ipv4, ipv6 []string, active bool, err error) {
+	connections, err := c.activeConnections(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	path, ok := connections[uuid]
+	if !ok {
+		return nil, nil, false, nil
+	}
+	obj := c.object(path)
+
+	var ip4Config, ip6Config dbus.ObjectPath
+	if err := storeProperty(ctx, obj, nmActiveIface, "Ip4Config", &ip4Config); err == nil && ip4Config != "/" {
+		// NameserverData replaced the Nameservers integers in NetworkManager 1.14
+		var data []map[string]dbus.Variant
+		if err := storeProperty(ctx, c.object(ip4Config), nmIP4ConfigIface, "NameserverData", &data); err == nil {
+			for _, entry := range data {
+				if addr, ok := entry["address"].Value().(string); ok {
+					ipv4 = append(ipv4, addr)
+				}
+			}
+		} else {
+			var servers []uint32
+			if err := storeProperty(ctx, c.object(ip4Config), nmIP4ConfigIface, "Nameservers", &servers); err == nil {
+				ipv4 = ipv4FromNM(servers)
+			}
+		}
+	}
+	if err := storeProperty(ctx, obj, nmActiveIface, "Ip6Config", &ip6Config); err == nil && ip6Config != "/" {
+		var servers [][]byte
+		if err := storeProperty(ctx, c.object(ip6Config), nmIP6ConfigIface, "Nameservers", &servers); err == nil {
+			ipv6 = ipv6FromNM(servers)
+		}
+	}
+	return ipv4, ipv6, true, nil
+}
+
+// profile returns the identity of a connection profile
+func (s nmSettings) profile() NMProfile {
+	return NMProfile{
+		Name: variantString(s["connection"]["id"]),
+		UUID: variantString(s["connection"]["uuid"]),
+		Type: variantString(s["connection"]["type"]),
+	}
+}
+
+// dns returns the configured DNS settings of a profile
+func (s nmSettings) dns() NMDNS {
+	var dns NMDNS
+	if servers, ok := s["ipv4"]["dns-data"].Value().([]string); ok {
+		dns.IPv4 = servers
+	} else if servers, ok := s["ipv4"]["dns"].Value().([]uint32); ok {
+		dns.IPv4 = ipv4FromNM(servers)
+	}
+	if servers, ok := s["ipv6"]["dns-data"].Value().([]string); ok {
+		dns.IPv6 = servers
+	} else if servers, ok := s["ipv6"]["dns"].Value().([][]byte); ok {
+		dns.IPv6 = ipv6FromNM(servers)
+	}
+	dns.ConfiguredIPv4, dns.ConfiguredIPv6 = dns.IPv4, dns.IPv6
+	dns.IgnoreAutoDNS, _ = s["ipv4"]["ignore-auto-dns"].Value().(bool)
+	dns.Search, _ = s["ipv4"]["dns-search"].Value().([]string)
+	dns.Options, _ = s["ipv4"]["dns-options"].Value().([]string)
+
+	dns.LLMNR, dns.MulticastDNS = "default", "default"
+	if v, ok := s["connection"]["llmnr"].Value().(int32); ok {
+		dns.LLMNR = nmLinkSettingName(v)
+	}
+	if v, ok := s["connection"]["mdns"].Value().(int32); ok {
+		dns.MulticastDNS = nmLinkSettingName(v)
+	}
+	return dns
+}
+
+// apply writes an update into the profile
+func (s nmSettings) apply(update NMDNSUpdate) error {
+	set := func(setting, key string, value any) {
+		if s[setting] == nil {
+			s[setting] = make(map[string]dbus.Variant)
+		}
+		s[setting][key] = dbus.MakeVariant(value)
+	}
+
+	if update.IPv4DNS != nil {
+		servers, err := ipv4ToNM(update.IPv4DNS)
+		if err != nil {
+			return err
+		}
+		set("ipv4", "dns", servers)
+		if _, ok := s["ipv4"]["dns-data"]; ok {
+			set("ipv4", "dns-data", update.IPv4DNS)
+		}
+	}
+	if update.IPv6DNS != nil {
+		servers, err := ipv6ToNM(update.IPv6DNS)
+		if err != nil {
+			return err
+		}
+		set("ipv6", "dns", servers)
+		if _, ok := s["ipv6"]["dns-data"]; ok {
+			set("ipv6", "dns-data", update.IPv6DNS)
+		}
+	}
+	if update.IPv4IgnoreAuto != nil {
+		set("ipv4", "ignore-auto-dns", *update.IPv4IgnoreAuto)
+	}
+	if update.IPv6IgnoreAuto != nil {
+		set("ipv6", "ignore-auto-dns", *update.IPv6IgnoreAuto)
+	}
+	if update.IPv4Priority != nil {
+		set("ipv4", "dns-priority", *update.IPv4Priority)
+	}
+	if update.IPv6Priority != nil {
+		set("ipv6", "dns-priority", *update.IPv6Priority)
+	}
+	if update.Search != nil {
+		set("ipv4", "dns-search", update.Search)
+	}
+	if update.Options != nil {
+		set("ipv4", "dns-options", update.Options)
+	}
+	if update.LLMNR != "" {
+		v, err := nmLinkSettingValue(update.LLMNR)
+		if err != nil {
+			return err
+		}
+		set("connection", "llmnr", v)
+	}
+	if update.MulticastDNS != "" {
+		v, err := nmLinkSettingValue(update.MulticastDNS)
+		if err != nil {
+			return err
+		}
+		set("connection", "mdns", v)
+	}
+
+	// GetSettings returns the deprecated address and route lists next to
+	// their replacements; sending both back is refused by some versions
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if _, ok := s[family]["address-data"]; ok {
+			delete(s[family], "addresses")
+		}
+		if _, ok := s[family]["route-data"]; ok {
+			delete(s[family], "routes")
+		}
+	}
+	return nil
+}
+
+// nmLinkSettingNames maps NetworkManager LLMNR and mDNS values to their nmcli names
+var nmLinkSettingNames = map[int32]string{-1: "default", 0: "no", 1: "resolve", 2: "yes"}
+
+// nmLinkSettingName returns the nmcli name of an LLMNR or mDNS value
+func nmLinkSettingName(v int32) string {
+	if name, ok := nmLinkSettingNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+// nmLinkSettingValue returns the NetworkManager value of an LLMNR or mDNS name
+func nmLinkSettingValue(name string) (int32, error) {
+	for v, n := range nmLinkSettingNames {
+		if n == name {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown NetworkManager LLMNR/mDNS setting %q", ErrUnsupported, name)
+}
+
+// ipv4ToNM converts IPv4 addresses to the network byte order integers NetworkManager uses
+func ipv4ToNM(addresses []string) ([]uint32, error) {
+	servers := make([]uint32, 0, len(addresses))
+	for _, address := range addresses {
+		ip, err := netip.ParseAddr(address)
+		if err != nil || !ip.Is4() {
+			return nil, fmt.Errorf("%w: NetworkManager needs a plain IPv4 address, not %q", ErrUnsupported, address)
+		}
+		b := ip.As4()
+		servers = append(servers, binary.NativeEndian.Uint32(b[:]))
+	}
+	return servers, nil
+}
+
+// ipv4FromNM converts network byte order integers to IPv4 addresses
+func ipv4FromNM(servers []uint32) []string {
+	addresses := make([]string, 0, len(servers))
+	for _, server := range servers {
+		var b [4]byte
+		binary.NativeEndian.PutUint32(b[:], server)
+		addresses = append(addresses, netip.AddrFrom4(b).String())
+	}
+	return addresses
+}
+
+// ipv6ToNM converts IPv6 addresses to the byte arrays NetworkManager uses
+func ipv6ToNM(addresses []string) ([][]byte, error) {
+	servers := make([][]byte, 0, len(addresses))
+	for _, address := range addresses {
+		ip, err := netip.ParseAddr(address)
+		if err != nil || !ip.Is6() || ip.Zone() != "" {
+			return nil, fmt.Errorf("%w: NetworkManager needs a plain IPv6 address, not %q", ErrUnsupported, address)
+		}
+		b := ip.As16()
+		servers = append(servers, b[:])
+	}
+	return servers, nil
+}
+
+// ipv6FromNM converts byte arrays to IPv6 addresses
+func ipv6FromNM(servers [][]byte) []string {
+	addresses := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if ip, ok := netip.AddrFromSlice(server); ok {
+			addresses = append(addresses, ip.String())
+		}
+	}
+	return addresses
+}
+
+// variantString returns the string held by a variant, or "" for other types
+func variantString(v dbus.Variant) string {
+	s, _ := v.Value().(string)
+	return s
+}
@@ -0,0 +1,358 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+const privateBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startPrivateBus runs a dbus-daemon for the test and returns its address
+func startPrivateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "bus.conf")
+	require.NoError(t, os.WriteFile(configPath, []byte(strings.Replace(privateBusConfig, "%s", dir, 1)), 0o644))
+
+	cmd := exec.Command(daemon, "--config-file="+configPath, "--print-address=1", "--nofork")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	return strings.TrimSpace(address)
+}
+
+// fakeNM serves a small part of the NetworkManager D-Bus API: two saved
+// profiles, "Wired: Office" active on eth0 and "Home WiFi" inactive
+type fakeNM struct {
+	mu        sync.Mutex
+	settings  map[dbus.ObjectPath]nmSettings
+	secrets   map[dbus.ObjectPath]nmSettings
+	reapplied []string
+	reloads   []uint32
+}
+
+const (
+	wiredUUID = "3f2c0a52-8c1e-4a3e-9d6a-0b1c2d3e4f50"
+	wifiUUID  = "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff"
+
+	wiredPath  = dbus.ObjectPath("/org/freedesktop/NetworkManager/Settings/1")
+	wifiPath   = dbus.ObjectPath("/org/freedesktop/NetworkManager/Settings/2")
+	eth0Path   = dbus.ObjectPath("/org/freedesktop/NetworkManager/Devices/1")
+	wlan0Path  = dbus.ObjectPath("/org/freedesktop/NetworkManager/Devices/2")
+	activePath = dbus.ObjectPath("/org/freedesktop/NetworkManager/ActiveConnection/1")
+	ip4Path    = dbus.ObjectPath("/org/freedesktop/NetworkManager/IP4Config/1")
+	ip6Path    = dbus.ObjectPath("/org/freedesktop/NetworkManager/IP6Config/1")
+)
+
+// startFakeNM exports the fake service on a private bus and returns a
+// client connected to it
+func startFakeNM(t *testing.T) (*fakeNM, *DBusNMClient) {
+	t.Helper()
+	address := startPrivateBus(t)
+
+	server, err := dbus.Connect(address)
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Close() })
+
+	v := dbus.MakeVariant
+	nm := &fakeNM{
+		settings: map[dbus.ObjectPath]nmSettings{
+			wiredPath: {
+				"connection": {"id": v("Wired: Office"), "uuid": v(wiredUUID), "type": v("802-3-ethernet")},
+				"ipv4": {
+					"method":       v("auto"),
+					"dns":          v([]uint32{}),
+					"addresses":    v([][]uint32{}),
+					"address-data": v([]map[string]dbus.Variant{}),
+				},
+			},
+			wifiPath: {
+				"connection":               {"id": v("Home WiFi"), "uuid": v(wifiUUID), "type": v("802-11-wireless"), "llmnr": v(int32(0))},
+				"ipv4":                     {"method": v("auto"), "dns": v(must(ipv4ToNM([]string{"10.1.0.53"}))), "dns-search": v([]string{"home.example"})},
+				"ipv6":                     {"method": v("auto"), "dns": v(must(ipv6ToNM([]string{"fd00::53"})))},
+				"802-11-wireless-security": {"key-mgmt": v("wpa-psk")},
+			},
+		},
+		secrets: map[dbus.ObjectPath]nmSettings{
+			wifiPath: {"802-11-wireless-security": {"psk": v("correct horse")}},
+		},
+	}
+
+	export := func(methods map[string]any, path dbus.ObjectPath, iface string) {
+		require.NoError(t, server.ExportMethodTable(methods, path, iface))
+	}
+	props := func(path dbus.ObjectPath, iface string, values map[string]any) {
+		m := prop.Map{iface: {}}
+		for name, value := range values {
+			m[iface][name] = &prop.Prop{Value: value, Emit: prop.EmitFalse}
+		}
+		_, err := prop.Export(server, path, m)
+		require.NoError(t, err)
+	}
+
+	devices := map[string]dbus.ObjectPath{"eth0": eth0Path, "wlan0": wlan0Path}
+	export(map[string]any{
+		"GetDevices": func() ([]dbus.ObjectPath, *dbus.Error) {
+			return []dbus.ObjectPath{eth0Path, wlan0Path}, nil
+		},
+		"GetDeviceByIpIface": func(name string) (dbus.ObjectPath, *dbus.Error) {
+			if path, ok := devices[name]; ok {
+				return path, nil
+			}
+			return "", dbus.MakeFailedError(os.ErrNotExist)
+		},
+		"Reload": func(flags uint32) *dbus.Error {
+			nm.mu.Lock()
+			defer nm.mu.Unlock()
+			nm.reloads = append(nm.reloads, flags)
+			return nil
+		},
+	}, nmPath, nmIface)
+	props(nmPath, nmIface, map[string]any{"ActiveConnections": []dbus.ObjectPath{activePath}})
+
+	export(map[string]any{
+		"ListConnections": func() ([]dbus.ObjectPath, *dbus.Error) {
+			return []dbus.ObjectPath{wiredPath, wifiPath}, nil
+		},
+		"GetConnectionByUuid": func(uuid string) (dbus.ObjectPath, *dbus.Error) {
+			nm.mu.Lock()
+			defer nm.mu.Unlock()
+			for path, s := range nm.settings {
+				if s.profile().UUID == uuid {
+					return path, nil
+				}
+			}
+			return "", dbus.MakeFailedError(os.ErrNotExist)
+		},
+	}, nmSettingsPath, nmSettingsIface)
+
+	for path := range nm.settings {
+		export(map[string]any{
+			"GetSettings": func() (nmSettings, *dbus.Error) {
+				nm.mu.Lock()
+				defer nm.mu.Unlock()
+				return nm.settings[path], nil
+			},
+			"GetSecrets": func(setting string) (nmSettings, *dbus.Error) {
+				nm.mu.Lock()
+				defer nm.mu.Unlock()
+				return nmSettings{setting: nm.secrets[path][setting]}, nil
+			},
+			"Update": func(settings nmSettings) *dbus.Error {
+				nm.mu.Lock()
+				defer nm.mu.Unlock()
+				nm.settings[path] = settings
+				return nil
+			},
+		}, path, nmConnectionIface)
+	}
+
+	for name, path := range devices {
+		export(map[string]any{
+			"Reapply": func(settings nmSettings, version uint64, flags uint32) *dbus.Error {
+				nm.mu.Lock()
+				defer nm.mu.Unlock()
+				nm.reapplied = append(nm.reapplied, name)
+				return nil
+			},
+		}, path, nmDeviceIface)
+	}
+	props(eth0Path, nmDeviceIface, map[string]any{"Interface": "eth0", "State": uint32(100), "ActiveConnection": activePath})
+	props(wlan0Path, nmDeviceIface, map[string]any{"Interface": "wlan0", "State": uint32(30), "ActiveConnection": dbus.ObjectPath("/")})
+	props(activePath, nmActiveIface, map[string]any{
+		"Id": "Wired: Office", "Uuid": wiredUUID, "Type": "802-3-ethernet",
+		"Devices": []dbus.ObjectPath{eth0Path}, "Ip4Config": ip4Path, "Ip6Config": ip6Path,
+	})
+	props(ip4Path, nmIP4ConfigIface, map[string]any{
+		"NameserverData": []map[string]dbus.Variant{{"address": v("10.0.0.53")}},
+	})
+	props(ip6Path, nmIP6ConfigIface, map[string]any{"Nameservers": must(ipv6ToNM([]string{"fd00::1:53"}))})
+
+	reply, err := server.RequestName(nmBusName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+
+	client, err := dbus.Connect(address)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	require.True(t, nmOnBus(client))
+
+	return nm, NewDBusNMClient(client)
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestDBusNMClient_Read(t *testing.T) {
+	_, client := startFakeNM(t)
+	ctx := context.Background()
+
+	profiles, err := client.Profiles(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []NMProfile{
+		{Name: "Wired: Office", UUID: wiredUUID, Type: "802-3-ethernet", Device: "eth0"},
+		{Name: "Home WiFi", UUID: wifiUUID, Type: "802-11-wireless"},
+	}, profiles)
+
+	devices, err := client.ConnectedDevices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eth0"}, devices)
+
+	profile, err := client.DeviceProfile(ctx, "eth0")
+	require.NoError(t, err)
+	assert.Equal(t, NMProfile{Name: "Wired: Office", UUID: wiredUUID, Type: "802-3-ethernet", Device: "eth0"}, profile)
+
+	_, err = client.DeviceProfile(ctx, "wlan0")
+	assert.ErrorContains(t, err, "no active connection")
+
+	// Active profiles report the servers in use
+	dns, err := client.ReadDNS(ctx, profile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.53"}, dns.IPv4)
+	assert.Equal(t, []string{"fd00::1:53"}, dns.IPv6)
+	assert.Empty(t, dns.ConfiguredIPv4, "the servers come from DHCP")
+
+	// Inactive profiles report the configured servers, found by name
+	dns, err = client.ReadDNS(ctx, NMProfile{Name: "Home WiFi"})
+	require.NoError(t, err)
+	assert.Equal(t, NMDNS{
+		IPv4:         []string{"10.1.0.53"},
+		IPv6:         []string{"fd00::53"},
+		Search:       []string{"home.example"},
+		LLMNR:        "no",
+		MulticastDNS: "default",
+
+		ConfiguredIPv4: []string{"10.1.0.53"},
+		ConfiguredIPv6: []string{"fd00::53"},
+	}, dns)
+}
+
+func TestDBusNMClient_UpdateDNS(t *testing.T) {
+	nm, client := startFakeNM(t)
+	ctx := context.Background()
+
+	yes, priority := true, int32(99)
+	err := client.UpdateDNS(ctx, NMProfile{UUID: wifiUUID}, NMDNSUpdate{
+		IPv4DNS:        []string{"9.9.9.9", "149.112.112.112"},
+		IPv4IgnoreAuto: &yes,
+		IPv6Priority:   &priority,
+		Options:        []string{"rotate"},
+		MulticastDNS:   "resolve",
+	})
+	require.NoError(t, err)
+
+	nm.mu.Lock()
+	saved := nm.settings[wifiPath]
+	nm.mu.Unlock()
+	assert.Equal(t, []string{"9.9.9.9", "149.112.112.112"}, saved.dns().IPv4)
+	assert.Equal(t, []string{"fd00::53"}, saved.dns().IPv6, "unchanged settings are kept")
+	assert.Equal(t, []string{"home.example"}, saved.dns().Search)
+	assert.Equal(t, []string{"rotate"}, saved.dns().Options)
+	assert.Equal(t, "resolve", saved.dns().MulticastDNS)
+	assert.Equal(t, true, saved["ipv4"]["ignore-auto-dns"].Value())
+	assert.Equal(t, int32(99), saved["ipv6"]["dns-priority"].Value())
+	assert.Equal(t, "correct horse", saved["802-11-wireless-security"]["psk"].Value(), "secrets survive the update")
+
+	// Deprecated address lists are dropped next to their replacements
+	require.NoError(t, client.UpdateDNS(ctx, NMProfile{UUID: wiredUUID}, NMDNSUpdate{IPv4DNS: []string{"1.1.1.1"}}))
+	nm.mu.Lock()
+	saved = nm.settings[wiredPath]
+	nm.mu.Unlock()
+	assert.NotContains(t, saved["ipv4"], "addresses")
+	assert.Contains(t, saved["ipv4"], "address-data")
+
+	err = client.UpdateDNS(ctx, NMProfile{UUID: wiredUUID}, NMDNSUpdate{IPv4DNS: []string{"127.0.0.1:5353"}})
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestConfigWriter_NetworkManagerOverDBus(t *testing.T) {
+	nm, client := startFakeNM(t)
+	writer := NewConfigWriter(nil, client, NewReplayRunner(nil), nil)
+
+	err := writer.Apply(context.Background(), models.BackendNetworkManager, []models.DNSConfig{{
+		Interface: models.NetworkInterface{Name: "eth0", Backend: models.BackendNetworkManager},
+		DNS:       models.DNSServer{IPv4: []string{"1.1.1.1"}, IPv6: []string{"2606:4700:4700::1111"}},
+	}})
+	require.NoError(t, err)
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	saved := nm.settings[wiredPath].dns()
+	assert.Equal(t, []string{"1.1.1.1"}, saved.IPv4)
+	assert.Equal(t, []string{"2606:4700:4700::1111"}, saved.IPv6)
+	assert.Equal(t, []string{"eth0"}, nm.reapplied)
+}
+
+func TestConfigWriter_NMGlobalDNSOverDBus(t *testing.T) {
+	nm, client := startFakeNM(t)
+	writer := NewConfigWriter(nil, client, nil, offlineRoot(t, nil))
+
+	err := writer.ApplyNMGlobalDNS(context.Background(), models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"9.9.9.9"}}})
+	require.NoError(t, err)
+	require.NoError(t, writer.ResetNMGlobalDNS(context.Background()))
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	assert.Equal(t, []uint32{nmReloadConf, nmReloadConf}, nm.reloads)
+}
+
+type busOnlySystemOps struct{ *DefaultSystemOps }
+
+func (busOnlySystemOps) CommandExists(string) bool { return false }
+
+func (busOnlySystemOps) ServiceRunning(service string) (bool, error) {
+	return service == "NetworkManager", nil
+}
+
+func TestDetector_NetworkManagerWithoutNmcli(t *testing.T) {
+	detector := &Detector{sysOps: busOnlySystemOps{&DefaultSystemOps{}}, nmOnBus: func() bool { return true }}
+	backend, reason, err := detector.DetectWithReason()
+	require.NoError(t, err)
+	assert.Equal(t, models.BackendNetworkManager, backend)
+	assert.Contains(t, reason, "D-Bus")
+
+	detector.nmOnBus = func() bool { return false }
+	_, _, err = detector.DetectWithReason()
+	assert.Error(t, err)
+}
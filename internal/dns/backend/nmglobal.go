@@ -0,0 +1,173 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+)
+
+const (
+	// nmConfPath is the main NetworkManager configuration file
+	nmConfPath = "/etc/NetworkManager/NetworkManager.conf"
+	// nmGlobalDNSPath is the conf.d file cdns owns for global DNS. Files are
+	// read in name order, so the high prefix lets it override most others.
+	nmGlobalDNSPath = "/etc/NetworkManager/conf.d/90-cdns-global-dns.conf"
+)
+
+// nmConfDirs hold conf.d snippets, a file in a later directory replacing
+// one of the same name in an earlier one
+var nmConfDirs = []string{
+	"/usr/lib/NetworkManager/conf.d",
+	"/run/NetworkManager/conf.d",
+	"/etc/NetworkManager/conf.d",
+}
+
+// ApplyNMGlobalDNS makes NetworkManager use the servers, search domains and
+// options of cfg for every connection, present and future, overriding the
+// DNS of each profile. The settings are written to a conf.d file cdns owns
+// and NetworkManager reloads its configuration.
+func (w *ConfigWriter) ApplyNMGlobalDNS(ctx context.Context, cfg models.DNSConfig) error {
+	if !cfg.Link.IsZero() {
+		return fmt.Errorf("%w: NetworkManager global DNS has no DNSSEC, LLMNR or MulticastDNS setting", ErrUnsupported)
+	}
+	if len(cfg.DNS.Ordered()) == 0 {
+		return fmt.Errorf("%w: NetworkManager global DNS needs at least one server", ErrUnsupported)
+	}
+	if err := checkServerAddresses(models.BackendNetworkManager, cfg.DNS.Ordered()); err != nil {
+		return err
+	}
+
+	if err := writeFile(w.root.Path(nmGlobalDNSPath), []byte(renderNMGlobalDNS(cfg)), 0644); err != nil {
+		return err
+	}
+	if err := w.nm.ReloadConfig(ctx); err != nil {
+		return fmt.Errorf("wrote %s but %w", nmGlobalDNSPath, err)
+	}
+	return nil
+}
+
+// ResetNMGlobalDNS removes the global DNS file cdns owns, handing DNS back
+// to the connection profiles
+func (w *ConfigWriter) ResetNMGlobalDNS(ctx context.Context) error {
+	if err := removeFile(w.root.Path(nmGlobalDNSPath)); err != nil {
+		return err
+	}
+	return w.nm.ReloadConfig(ctx)
+}
+
+// renderNMGlobalDNS renders the global DNS sections for cfg. The "*" domain
+// holds the servers used for every name.
+func renderNMGlobalDNS(cfg models.DNSConfig) string {
+	var b strings.Builder
+	b.WriteString(ownedFileHeader)
+	b.WriteString("[global-dns]\n")
+	if len(cfg.Search) > 0 {
+		b.WriteString("searches=" + strings.Join(cfg.Search, ",") + "\n")
+	}
+	if len(cfg.Options) > 0 {
+		b.WriteString("options=" + strings.Join(cfg.Options, ",") + "\n")
+	}
+	b.WriteString("\n[global-dns-domain-*]\n")
+	b.WriteString("servers=" + strings.Join(cfg.DNS.Ordered(), ",") + "\n")
+	return b.String()
+}
+
+// nmConfFiles lists the NetworkManager configuration files in the order
+// NetworkManager merges them
+func (r *ConfigReader) nmConfFiles() ([]string, error) {
+	dirs := make([]string, len(nmConfDirs))
+	for i, dir := range nmConfDirs {
+		dirs[i] = r.root.Path(dir)
+	}
+	return nmConfOrder(r.root.Path(nmConfPath), dirs, filepath.Glob)
+}
+
+// nmConfOrder orders the main NetworkManager configuration file and the
+// conf.d snippets found with glob in dirs as NetworkManager merges them
+func nmConfOrder(mainFile string, dirs []string, glob func(string) ([]string, error)) ([]string, error) {
+	byName := make(map[string]string)
+	for _, dir := range dirs {
+		paths, err := glob(filepath.Join(dir, "*.conf"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			byName[filepath.Base(path)] = path
+		}
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := []string{mainFile}
+	for _, name := range names {
+		files = append(files, byName[name])
+	}
+	return files, nil
+}
+
+// readNMGlobalDNS returns the global DNS configured for NetworkManager and
+// the file setting its servers, or nil when every profile uses its own DNS.
+// Later files override the keys of earlier ones.
+func (r *ConfigReader) readNMGlobalDNS() (*status.InterfaceStatus, string, error) {
+	files, err := r.nmConfFiles()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var servers, searches, options []string
+	source := ""
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		f := parseKeyfile(string(data))
+		if value, ok := f.Get("global-dns-domain-*", "servers"); ok {
+			servers = nmConfList(value)
+			source = strings.TrimPrefix(path, strings.TrimSuffix(r.root.Path("/"), "/"))
+		}
+		if value, ok := f.Get("global-dns", "searches"); ok {
+			searches = nmConfList(value)
+		}
+		if value, ok := f.Get("global-dns", "options"); ok {
+			options = nmConfList(value)
+		}
+	}
+	if len(servers) == 0 {
+		return nil, "", nil
+	}
+
+	global := &status.InterfaceStatus{Name: "Global", IPv4: []string{}, IPv6: []string{}, Search: searches, Options: options}
+	for _, server := range servers {
+		if models.IsIPv6Server(server) {
+			global.IPv6 = append(global.IPv6, server)
+		} else {
+			global.IPv4 = append(global.IPv4, server)
+		}
+	}
+	return global, source, nil
+}
+
+// nmConfList splits a NetworkManager.conf list, separated by commas or
+// semicolons
+func nmConfList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
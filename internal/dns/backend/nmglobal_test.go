@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+func TestNMGlobalDNS(t *testing.T) {
+	ctx := context.Background()
+	root := offlineRoot(t, map[string]string{
+		nmConfPath:                            "[main]\nplugins=keyfile\n",
+		nmKeyfileDir + "/office.nmconnection": officeKeyfile,
+		// A vendor snippet overridden by one of the same name in /etc
+		"/usr/lib/NetworkManager/conf.d/20-dns.conf": "[global-dns-domain-*]\nservers=10.0.0.1\n",
+		"/etc/NetworkManager/conf.d/20-dns.conf":     "[main]\ndns=default\n",
+	})
+	nm := NewNMClient(nil, root)
+	writer := NewConfigWriter(nil, nm, nil, root)
+	reader := NewConfigReader(nil, nm, nil, root)
+
+	info, err := reader.ReadDNSConfig(ctx, models.BackendNetworkManager)
+	require.NoError(t, err)
+	assert.Nil(t, info.Global)
+	assert.Empty(t, info.GlobalSource)
+	assert.False(t, info.Managed)
+
+	require.NoError(t, writer.ApplyNMGlobalDNS(ctx, models.DNSConfig{
+		DNS:     models.DNSServer{IPv4: []string{"9.9.9.9"}, IPv6: []string{"2620:fe::fe"}},
+		Search:  []string{"corp.example", "example.org"},
+		Options: []string{"rotate"},
+	}))
+	assert.Equal(t, ownedFileHeader+`[global-dns]
+searches=corp.example,example.org
+options=rotate
+
+[global-dns-domain-*]
+servers=9.9.9.9,2620:fe::fe
+`, readImageFile(t, root, nmGlobalDNSPath))
+
+	info, err = reader.ReadDNSConfig(ctx, models.BackendNetworkManager)
+	require.NoError(t, err)
+	require.NotNil(t, info.Global)
+	assert.Equal(t, nmGlobalDNSPath, info.GlobalSource)
+	assert.Equal(t, []string{"9.9.9.9"}, info.Global.IPv4)
+	assert.Equal(t, []string{"2620:fe::fe"}, info.Global.IPv6)
+	assert.Equal(t, []string{"corp.example", "example.org"}, info.Global.Search)
+	assert.Equal(t, []string{"rotate"}, info.Global.Options)
+	assert.True(t, info.Global.Managed)
+	assert.True(t, info.Managed)
+
+	err = writer.ApplyNMGlobalDNS(ctx, models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"9.9.9.9#dns.quad9.net"}}})
+	assert.ErrorIs(t, err, ErrUnsupported)
+	err = writer.ApplyNMGlobalDNS(ctx, models.DNSConfig{
+		DNS:  models.DNSServer{IPv4: []string{"9.9.9.9"}},
+		Link: models.LinkSettings{LLMNR: "no"},
+	})
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	require.NoError(t, writer.ResetNMGlobalDNS(ctx))
+	assert.NoFileExists(t, root.Path(nmGlobalDNSPath))
+	info, err = reader.ReadDNSConfig(ctx, models.BackendNetworkManager)
+	require.NoError(t, err)
+	assert.Nil(t, info.Global)
+}
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"regexp"
+	"slices"
 	"strings"
 
 	"gitlab.com/junevm/cdns/internal/dns/models"
@@ -15,11 +17,26 @@ import (
 // ConfigReader reads DNS configuration from different backends
 type ConfigReader struct {
 	sysOps SystemOps
+	nm     NMClient
+	runner CommandRunner
+	root   *Root
+	// resolvedState reads systemd-resolved over D-Bus
+	resolvedState func(ctx context.Context) (resolvedState, error)
+	// leaseDir holds the systemd-networkd DHCP leases
+	leaseDir string
 }
 
-// NewConfigReader creates a new ConfigReader
-func NewConfigReader(sysOps SystemOps) *ConfigReader {
-	return &ConfigReader{sysOps: sysOps}
+// NewConfigReader creates a new ConfigReader. With an offline root, the
+// configuration is read from the files under it.
+func NewConfigReader(sysOps SystemOps, nm NMClient, runner CommandRunner, root *Root) *ConfigReader {
+	return &ConfigReader{
+		sysOps:        sysOps,
+		nm:            nm,
+		runner:        runner,
+		root:          root,
+		resolvedState: readResolvedDBus,
+		leaseDir:      networkdLeaseDir,
+	}
 }
 
 // ReadDNSConfig reads DNS configuration from the specified backend
@@ -28,9 +45,14 @@ func (r *ConfigReader) ReadDNSConfig(ctx context.Context, backend models.Backend
 	case models.BackendNetworkManager:
 		return r.readNetworkManager(ctx)
 	case models.BackendSystemdResolved:
+		if r.root.Offline() {
+			return r.readResolvedConf()
+		}
 		return r.readSystemdResolved(ctx)
 	case models.BackendResolvConf:
 		return r.readResolvConf(ctx)
+	case models.BackendNetplan:
+		return r.readNetplanStatus()
 	default:
 		return nil, fmt.Errorf("unsupported backend: %s", backend)
 	}
@@ -41,80 +63,106 @@ func (r *ConfigReader) readNetworkManager(ctx context.Context) (*status.StatusIn
 	info := &status.StatusInfo{
 		Backend:    models.BackendNetworkManager,
 		Interfaces: []status.InterfaceStatus{},
-		Managed:    true,
 		Warnings:   []string{},
 	}
 
-	// Get list of active connections
-	cmd := exec.CommandContext(ctx, "nmcli", "-t", "-f", "NAME,DEVICE", "connection", "show", "--active")
-	output, err := cmd.Output()
+	profiles, err := r.nm.Profiles(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active connections: %w", err)
 	}
 
-	// Parse active connections
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, ":")
-		if len(parts) < 2 {
-			continue
+	for _, profile := range profiles {
+		device := profile.Device
+		if usesKeyfiles(r.nm) && profile.Type != "loopback" {
+			// Nothing is active without the daemon, so every profile is listed by name
+			device = profile.Name
 		}
-
-		device := parts[1]
 		if device == "" {
 			continue
 		}
 
 		// Get DNS for this connection
-		ipv4, ipv6, err := r.getDNSForConnection(ctx, parts[0])
+		dns, err := r.nm.ReadDNS(ctx, profile)
 		if err != nil {
 			info.Warnings = append(info.Warnings, fmt.Sprintf("failed to get DNS for %s: %v", device, err))
 			continue
 		}
 
-		if len(ipv4) > 0 || len(ipv6) > 0 {
-			info.Interfaces = append(info.Interfaces, status.InterfaceStatus{
-				Name: device,
-				IPv4: ipv4,
-				IPv6: ipv6,
-			})
+		if len(dns.IPv4) > 0 || len(dns.IPv6) > 0 || len(dns.Search) > 0 || len(dns.Options) > 0 {
+			iface := status.InterfaceStatus{
+				Name:       device,
+				IPv4:       dns.IPv4,
+				IPv6:       dns.IPv6,
+				Search:     dns.Search,
+				Options:    dns.Options,
+				Configured: append(append([]string{}, dns.ConfiguredIPv4...), dns.ConfiguredIPv6...),
+			}
+			if !dns.IgnoreAutoDNS {
+				_, iface.DHCP = splitServers(serversOf(iface), iface.Configured)
+			}
+			info.Interfaces = append(info.Interfaces, iface)
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading nmcli output: %w", err)
+	// Global DNS from the configuration files replaces the servers of every profile
+	global, source, err := r.readNMGlobalDNS()
+	if err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("failed to read NetworkManager global DNS: %v", err))
+	} else if global != nil {
+		info.Global = global
+		info.GlobalSource = source
+		// cdns owns the file with 'set --global'
+		info.Global.Managed = source == nmGlobalDNSPath
+		info.Managed = info.Global.Managed
+	}
+
+	// NetworkManager may hand the servers to systemd-resolved, which
+	// picks one of them per link
+	current := map[string]string(nil)
+	if !usesKeyfiles(r.nm) {
+		current = r.resolvedCurrent(ctx)
 	}
+	annotateNetworkManager(info, current)
 
 	return info, nil
 }
 
-// getDNSForConnection gets DNS servers for a specific connection
-func (r *ConfigReader) getDNSForConnection(ctx context.Context, connName string) ([]string, []string, error) {
-	cmd := exec.CommandContext(ctx, "nmcli", "-t", "-f", "IP4.DNS,IP6.DNS", "connection", "show", connName)
-	output, err := cmd.Output()
+// ReadConnections lists the DNS configured on every saved NetworkManager
+// connection profile, including the ones not active on any device
+func (r *ConfigReader) ReadConnections(ctx context.Context, backend models.Backend) ([]status.ConnectionStatus, error) {
+	if backend != models.BackendNetworkManager {
+		return nil, fmt.Errorf("%w: connection profiles are only available with NetworkManager, not %s", ErrUnsupported, backend)
+	}
+
+	profiles, err := r.nm.Profiles(ctx)
 	if err != nil {
-		return nil, nil, err
+		return nil, fmt.Errorf("failed to list connection profiles: %w", err)
 	}
 
-	var ipv4, ipv6 []string
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "IP4.DNS") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 && parts[1] != "" {
-				ipv4 = append(ipv4, strings.TrimSpace(parts[1]))
-			}
-		} else if strings.HasPrefix(line, "IP6.DNS") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 && parts[1] != "" {
-				ipv6 = append(ipv6, strings.TrimSpace(parts[1]))
-			}
+	connections := []status.ConnectionStatus{}
+	for _, profile := range profiles {
+		if profile.Type == "loopback" {
+			continue
+		}
+		dns, err := r.nm.ReadDNS(ctx, profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get DNS for connection %s: %w", profile.Name, err)
 		}
+		connections = append(connections, status.ConnectionStatus{
+			Name:    profile.Name,
+			UUID:    profile.UUID,
+			Type:    profile.Type,
+			Device:  profile.Device,
+			IPv4:    dns.IPv4,
+			IPv6:    dns.IPv6,
+			Search:  dns.Search,
+			Options: dns.Options,
+
+			Configured:    append(append([]string{}, dns.ConfiguredIPv4...), dns.ConfiguredIPv6...),
+			IgnoreAutoDNS: dns.IgnoreAutoDNS,
+		})
 	}
-
-	return ipv4, ipv6, scanner.Err()
+	return connections, nil
 }
 
 // readSystemdResolved reads DNS configuration from systemd-resolved
@@ -122,46 +170,94 @@ func (r *ConfigReader) readSystemdResolved(ctx context.Context) (*status.StatusI
 	info := &status.StatusInfo{
 		Backend:    models.BackendSystemdResolved,
 		Interfaces: []status.InterfaceStatus{},
-		Managed:    true,
 		Warnings:   []string{},
 	}
 
+	// The D-Bus API reports ports and server names unambiguously; the text
+	// output of resolvectl is only parsed when the bus is unavailable
+	if state, err := r.resolvedState(ctx); err == nil {
+		info.Global = state.Global
+		info.Interfaces = append(info.Interfaces, state.Links...)
+		annotateResolved(info, state.Fallback, r.networkdLease)
+		return info, nil
+	}
+
 	// Try resolvectl first, fall back to systemd-resolve
-	var cmd *exec.Cmd
+	var name string
+	var args []string
 	if r.sysOps.CommandExists("resolvectl") {
-		cmd = exec.CommandContext(ctx, "resolvectl", "status")
+		name, args = "resolvectl", []string{"status"}
 	} else if r.sysOps.CommandExists("systemd-resolve") {
-		cmd = exec.CommandContext(ctx, "systemd-resolve", "--status")
+		name, args = "systemd-resolve", []string{"--status"}
 	} else {
 		return nil, fmt.Errorf("neither resolvectl nor systemd-resolve found")
 	}
 
-	output, err := cmd.Output()
+	output, err := r.runner.Run(ctx, name, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get systemd-resolved status: %w", err)
 	}
 
 	// Parse the output
-	info.Interfaces = r.parseSystemdResolvedOutput(string(output))
+	state := r.parseSystemdResolvedOutput(string(output))
+	info.Global = state.Global
+	info.Interfaces = append(info.Interfaces, state.Links...)
+	annotateResolved(info, state.Fallback, r.networkdLease)
 
 	return info, nil
 }
 
-// parseSystemdResolvedOutput parses systemd-resolved status output
-func (r *ConfigReader) parseSystemdResolvedOutput(output string) []status.InterfaceStatus {
-	var interfaces []status.InterfaceStatus
+// resolvedKeyPattern matches a "Key: value" line of resolvectl status output
+var resolvedKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z. ]*:(\s|$)`)
+
+// parseSystemdResolvedOutput parses systemd-resolved status output, the
+// "Global" section and one section per link
+func (r *ConfigReader) parseSystemdResolvedOutput(output string) resolvedState {
+	var state resolvedState
 	var currentInterface *status.InterfaceStatus
+	// inServers is set while reading the DNS Servers list, whose further
+	// entries follow on their own indented lines; inFallback likewise for
+	// the Fallback DNS Servers list
+	inServers := false
+	inFallback := false
+
+	// flush stores the section just read, skipping links without DNS
+	flush := func() {
+		if currentInterface == nil || (len(currentInterface.IPv4) == 0 && len(currentInterface.IPv6) == 0 && len(currentInterface.Search) == 0) {
+			return
+		}
+		if currentInterface == state.Global {
+			return
+		}
+		state.Links = append(state.Links, *currentInterface)
+	}
 
 	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
+		listed := line != "" && line != "Global" && !strings.HasPrefix(line, "Link ") && !resolvedKeyPattern.MatchString(line)
+		continuation := inServers && listed
+		if inFallback && listed {
+			state.Fallback = append(state.Fallback, strings.Fields(line)...)
+			continue
+		}
+		if !continuation {
+			inServers = strings.HasPrefix(line, "DNS Servers:")
+			inFallback = strings.HasPrefix(line, "Fallback DNS Servers:")
+		}
+		if inFallback {
+			state.Fallback = append(state.Fallback, strings.Fields(strings.TrimPrefix(line, "Fallback DNS Servers:"))...)
+			continue
+		}
 
-		// Look for link/interface lines
-		if strings.HasPrefix(line, "Link ") {
+		// Look for the global section and link/interface lines
+		if line == "Global" {
+			flush()
+			currentInterface = &status.InterfaceStatus{Name: "Global", IPv4: []string{}, IPv6: []string{}}
+			state.Global = currentInterface
+		} else if strings.HasPrefix(line, "Link ") {
 			// Save previous interface if exists
-			if currentInterface != nil && (len(currentInterface.IPv4) > 0 || len(currentInterface.IPv6) > 0) {
-				interfaces = append(interfaces, *currentInterface)
-			}
+			flush()
 
 			// Extract interface name
 			parts := strings.Fields(line)
@@ -178,44 +274,110 @@ func (r *ConfigReader) parseSystemdResolvedOutput(output string) []status.Interf
 			// Extract DNS server address
 			parts := strings.SplitN(line, ":", 2)
 			if len(parts) == 2 {
-				addr := strings.TrimSpace(parts[1])
-				if strings.Contains(addr, ":") {
-					currentInterface.IPv6 = append(currentInterface.IPv6, addr)
-				} else {
-					currentInterface.IPv4 = append(currentInterface.IPv4, addr)
-				}
+				addResolvedServers(currentInterface, parts[1])
+				currentInterface.Current = strings.TrimSpace(parts[1])
 			}
 		} else if currentInterface != nil && strings.HasPrefix(line, "DNS Servers:") {
 			// Extract DNS server address
 			parts := strings.SplitN(line, ":", 2)
 			if len(parts) == 2 {
-				addr := strings.TrimSpace(parts[1])
-				if strings.Contains(addr, ":") {
-					currentInterface.IPv6 = append(currentInterface.IPv6, addr)
-				} else {
-					currentInterface.IPv4 = append(currentInterface.IPv4, addr)
+				addResolvedServers(currentInterface, parts[1])
+			}
+		} else if currentInterface != nil && strings.HasPrefix(line, "Protocols:") {
+			// Format: "Protocols: +DefaultRoute +LLMNR -mDNS -DNSOverTLS DNSSEC=no/unsupported"
+			for _, proto := range strings.Fields(strings.TrimPrefix(line, "Protocols:")) {
+				switch {
+				case proto == "+LLMNR" || proto == "-LLMNR" || strings.HasPrefix(proto, "LLMNR="):
+					currentInterface.LLMNR = protocolSetting(proto, "LLMNR")
+				case proto == "+mDNS" || proto == "-mDNS" || strings.HasPrefix(proto, "mDNS="):
+					currentInterface.MulticastDNS = protocolSetting(proto, "mDNS")
+				case strings.HasPrefix(proto, "DNSSEC="):
+					setting, _, _ := strings.Cut(strings.TrimPrefix(proto, "DNSSEC="), "/")
+					currentInterface.DNSSEC = setting
 				}
 			}
-		} else if currentInterface != nil && strings.HasPrefix(line, "- ") {
-			// Additional DNS server in a list
-			addr := strings.TrimPrefix(line, "- ")
-			addr = strings.TrimSpace(addr)
-			if addr != "" {
-				if strings.Contains(addr, ":") {
-					currentInterface.IPv6 = append(currentInterface.IPv6, addr)
-				} else {
-					currentInterface.IPv4 = append(currentInterface.IPv4, addr)
+		} else if currentInterface != nil && strings.HasPrefix(line, "LLMNR setting:") {
+			currentInterface.LLMNR = strings.TrimSpace(strings.TrimPrefix(line, "LLMNR setting:"))
+		} else if currentInterface != nil && strings.HasPrefix(line, "MulticastDNS setting:") {
+			currentInterface.MulticastDNS = strings.TrimSpace(strings.TrimPrefix(line, "MulticastDNS setting:"))
+		} else if currentInterface != nil && strings.HasPrefix(line, "DNSSEC setting:") {
+			currentInterface.DNSSEC = strings.TrimSpace(strings.TrimPrefix(line, "DNSSEC setting:"))
+		} else if currentInterface != nil && strings.HasPrefix(line, "DNS Domain:") {
+			// Extract search domains
+			parts := strings.SplitN(line, ":", 2)
+			for _, domain := range strings.Fields(parts[1]) {
+				if domain != "~." {
+					currentInterface.Search = append(currentInterface.Search, domain)
 				}
 			}
+		} else if currentInterface != nil && continuation {
+			addResolvedServers(currentInterface, line)
+		} else if currentInterface != nil && strings.HasPrefix(line, "- ") {
+			// Additional DNS server in a list
+			addResolvedServers(currentInterface, strings.TrimPrefix(line, "- "))
 		}
 	}
 
 	// Add last interface
-	if currentInterface != nil && (len(currentInterface.IPv4) > 0 || len(currentInterface.IPv6) > 0) {
-		interfaces = append(interfaces, *currentInterface)
+	flush()
+
+	// Drop a global section without servers or domains
+	if g := state.Global; g != nil && len(g.IPv4) == 0 && len(g.IPv6) == 0 && len(g.Search) == 0 {
+		state.Global = nil
 	}
 
-	return interfaces
+	return state
+}
+
+// protocolSetting converts a resolvectl protocol token ("+LLMNR", "-mDNS",
+// "LLMNR=resolve") to its setting value
+func protocolSetting(token, name string) string {
+	switch token {
+	case "+" + name:
+		return "yes"
+	case "-" + name:
+		return "no"
+	default:
+		return strings.TrimPrefix(token, name+"=")
+	}
+}
+
+// ReadLinkSettings reads the current DNSSEC, LLMNR and MulticastDNS settings of an interface
+func (r *ConfigReader) ReadLinkSettings(ctx context.Context, backend models.Backend, iface string) (models.LinkSettings, error) {
+	switch backend {
+	case models.BackendSystemdResolved:
+		if r.root.Offline() {
+			// Without a daemon there are no links to read from
+			return models.LinkSettings{}, nil
+		}
+		var link models.LinkSettings
+		for _, setting := range []struct {
+			verb   string
+			target *string
+		}{{"dnssec", &link.DNSSEC}, {"llmnr", &link.LLMNR}, {"mdns", &link.MulticastDNS}} {
+			verb, target := setting.verb, setting.target
+			// Output format: "Link 2 (eth0): allow-downgrade"
+			out, err := r.runner.Run(ctx, "resolvectl", verb, iface)
+			if err != nil {
+				return models.LinkSettings{}, fmt.Errorf("failed to read %s setting for %s: %w", verb, iface, err)
+			}
+			line := strings.TrimSpace(string(out))
+			*target = strings.TrimSpace(line[strings.LastIndex(line, ":")+1:])
+		}
+		return link, nil
+	case models.BackendNetworkManager:
+		profile, err := r.nm.DeviceProfile(ctx, iface)
+		if err != nil {
+			return models.LinkSettings{}, err
+		}
+		dns, err := r.nm.ReadDNS(ctx, profile)
+		if err != nil {
+			return models.LinkSettings{}, fmt.Errorf("failed to read link settings for %s: %w", iface, err)
+		}
+		return models.LinkSettings{LLMNR: dns.LLMNR, MulticastDNS: dns.MulticastDNS}, nil
+	default:
+		return models.LinkSettings{}, nil
+	}
 }
 
 // readResolvConf reads DNS configuration from /etc/resolv.conf
@@ -223,52 +385,112 @@ func (r *ConfigReader) readResolvConf(ctx context.Context) (*status.StatusInfo,
 	info := &status.StatusInfo{
 		Backend:    models.BackendResolvConf,
 		Interfaces: []status.InterfaceStatus{},
-		Managed:    false,
 		Warnings:   []string{},
 	}
 
-	file, err := os.Open("/etc/resolv.conf")
+	file, err := os.Open(r.root.resolve(resolvConfPath))
+	if r.root.Offline() && os.IsNotExist(err) {
+		info.Warnings = append(info.Warnings, resolvConfPath+" does not exist")
+		return info, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to open /etc/resolv.conf: %w", err)
+		return nil, fmt.Errorf("failed to open %s: %w", resolvConfPath, err)
 	}
 	defer file.Close()
 
-	var ipv4, ipv6 []string
-	scanner := bufio.NewScanner(file)
+	iface, err := parseResolvConf(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", resolvConfPath, err)
+	}
+
+	// Add single "system" interface for resolv.conf
+	if len(iface.IPv4) > 0 || len(iface.IPv6) > 0 || len(iface.Search) > 0 || len(iface.Options) > 0 {
+		iface.Name = "system"
+		info.Interfaces = append(info.Interfaces, iface)
+	}
+	annotateManual(info)
+
+	return info, nil
+}
+
+// addResolvedServers adds the space-separated server addresses from a
+// resolvectl line, which may carry a port, interface or server name
+// (e.g. "[2620:fe::fe]:853#dns.quad9.net"). Servers already listed as the
+// current server are skipped.
+func addResolvedServers(iface *status.InterfaceStatus, value string) {
+	for _, addr := range strings.Fields(value) {
+		if slices.Contains(iface.IPv4, addr) || slices.Contains(iface.IPv6, addr) {
+			continue
+		}
+		if models.IsIPv6Server(addr) {
+			iface.IPv6 = append(iface.IPv6, addr)
+		} else {
+			iface.IPv4 = append(iface.IPv4, addr)
+		}
+	}
+}
+
+// parseResolvConf parses nameserver, search/domain and options lines of a resolv.conf
+func parseResolvConf(in io.Reader) (status.InterfaceStatus, error) {
+	conf, err := parseResolvConfLines(in)
+	if err != nil {
+		return status.InterfaceStatus{}, err
+	}
+
+	iface := status.InterfaceStatus{Search: conf.Search, Options: conf.Options}
+	for _, addr := range conf.Servers {
+		if models.IsIPv6Server(addr) {
+			iface.IPv6 = append(iface.IPv6, addr)
+		} else {
+			iface.IPv4 = append(iface.IPv4, addr)
+		}
+	}
+	return iface, nil
+}
+
+// parseResolvConfLines returns the resolver lines of a resolv.conf
+func parseResolvConfLines(in io.Reader) (models.ResolvConf, error) {
+	var conf models.ResolvConf
+	scanner := bufio.NewScanner(in)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
 		// Skip comments and empty lines
-		if strings.HasPrefix(line, "#") || line == "" {
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || line == "" {
 			continue
 		}
 
-		// Look for nameserver entries
-		if strings.HasPrefix(line, "nameserver") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				addr := fields[1]
-				if strings.Contains(addr, ":") {
-					ipv6 = append(ipv6, addr)
-				} else {
-					ipv4 = append(ipv4, addr)
-				}
-			}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading /etc/resolv.conf: %w", err)
+		switch fields[0] {
+		case "nameserver":
+			conf.Servers = append(conf.Servers, fields[1])
+		case "search", "domain":
+			// The last search or domain line wins
+			conf.Search = append([]string(nil), fields[1:]...)
+		case "options":
+			conf.Options = append(conf.Options, fields[1:]...)
+		}
 	}
 
-	// Add single "system" interface for resolv.conf
-	if len(ipv4) > 0 || len(ipv6) > 0 {
-		info.Interfaces = append(info.Interfaces, status.InterfaceStatus{
-			Name: "system",
-			IPv4: ipv4,
-			IPv6: ipv6,
-		})
+	return conf, scanner.Err()
+}
+
+// ReadResolvConf returns the servers, search domains and options of
+// resolv.conf, so they can be restored after cdns rewrites it
+func (r *ConfigReader) ReadResolvConf() (models.ResolvConf, error) {
+	file, err := os.Open(r.root.resolve(resolvConfPath))
+	if err != nil {
+		return models.ResolvConf{}, fmt.Errorf("failed to open %s: %w", resolvConfPath, err)
 	}
+	defer file.Close()
 
-	return info, nil
+	conf, err := parseResolvConfLines(file)
+	if err != nil {
+		return models.ResolvConf{}, fmt.Errorf("error reading %s: %w", resolvConfPath, err)
+	}
+	return conf, nil
 }
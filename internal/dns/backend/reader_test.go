@@ -0,0 +1,190 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	input := `# Generated by hand
+nameserver 1.1.1.1
+nameserver 2606:4700:4700::1111
+nameserver fe80::1%eth0
+domain old.example
+search corp.example lab.example
+options ndots:5 rotate
+options timeout:2
+`
+	iface, err := parseResolvConf(strings.NewReader(input))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1.1.1.1"}, iface.IPv4)
+	assert.Equal(t, []string{"2606:4700:4700::1111", "fe80::1%eth0"}, iface.IPv6)
+	assert.Equal(t, []string{"corp.example", "lab.example"}, iface.Search)
+	assert.Equal(t, []string{"ndots:5", "rotate", "timeout:2"}, iface.Options)
+}
+
+func TestParseNMConnectionDNS(t *testing.T) {
+	output := `IP4.DNS[1]:10.0.0.53
+IP4.DNS[2]:10.0.0.54
+IP6.DNS[1]:fd00\:\:53
+ipv4.dns-search:corp.example,lab.example
+ipv4.dns-options:ndots\:5,rotate
+`
+	iface, err := parseNMConnectionDNS(output)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"10.0.0.53", "10.0.0.54"}, iface.IPv4)
+	assert.Equal(t, []string{"fd00::53"}, iface.IPv6)
+	assert.Equal(t, []string{"corp.example", "lab.example"}, iface.Search)
+	assert.Equal(t, []string{"ndots:5", "rotate"}, iface.Options)
+}
+
+func TestParseSystemdResolvedOutput_Domains(t *testing.T) {
+	output := `Link 2 (eth0)
+Current DNS Server: 10.0.0.53
+       DNS Servers: 10.0.0.53
+        DNS Domain: corp.example ~.
+`
+	r := &ConfigReader{}
+	interfaces := r.parseSystemdResolvedOutput(output).Links
+	require.Len(t, interfaces, 1)
+	assert.Equal(t, []string{"corp.example"}, interfaces[0].Search)
+}
+
+func TestParseSystemdResolvedOutput_ServerAddresses(t *testing.T) {
+	output := `Link 2 (eth0)
+Current DNS Server: 127.0.0.1:5353
+       DNS Servers: 127.0.0.1:5353 9.9.9.9#dns.quad9.net
+                    [2620:fe::fe]:853#dns.quad9.net
+                    fe80::1%eth0
+`
+	interfaces := (&ConfigReader{}).parseSystemdResolvedOutput(output).Links
+	require.Len(t, interfaces, 1)
+	assert.Equal(t, []string{"127.0.0.1:5353", "9.9.9.9#dns.quad9.net"}, interfaces[0].IPv4)
+	assert.Equal(t, []string{"[2620:fe::fe]:853#dns.quad9.net", "fe80::1%eth0"}, interfaces[0].IPv6)
+}
+
+func TestParseSystemdResolvedOutput_LinkSettings(t *testing.T) {
+	t.Run("protocols line", func(t *testing.T) {
+		output := `Link 3 (wlan0)
+    Current Scopes: DNS LLMNR/IPv4
+         Protocols: +DefaultRoute +LLMNR mDNS=resolve -DNSOverTLS DNSSEC=allow-downgrade/supported
+Current DNS Server: 1.1.1.1
+`
+		interfaces := (&ConfigReader{}).parseSystemdResolvedOutput(output).Links
+		require.Len(t, interfaces, 1)
+		assert.Equal(t, "yes", interfaces[0].LLMNR)
+		assert.Equal(t, "resolve", interfaces[0].MulticastDNS)
+		assert.Equal(t, "allow-downgrade", interfaces[0].DNSSEC)
+	})
+
+	t.Run("legacy setting lines", func(t *testing.T) {
+		output := `Link 2 (eth0)
+      Current Scopes: DNS
+       LLMNR setting: no
+MulticastDNS setting: no
+      DNSSEC setting: yes
+  Current DNS Server: 9.9.9.9
+`
+		interfaces := (&ConfigReader{}).parseSystemdResolvedOutput(output).Links
+		require.Len(t, interfaces, 1)
+		assert.Equal(t, "no", interfaces[0].LLMNR)
+		assert.Equal(t, "no", interfaces[0].MulticastDNS)
+		assert.Equal(t, "yes", interfaces[0].DNSSEC)
+	})
+}
+
+func TestParseNMConnectionDNS_InactiveProfile(t *testing.T) {
+	output := `ipv4.dns:10.1.0.53,10.1.0.54
+ipv6.dns:fd00\:\:53
+ipv4.dns-search:office.example
+ipv4.dns-options:
+`
+	iface, err := parseNMConnectionDNS(output)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"10.1.0.53", "10.1.0.54"}, iface.IPv4)
+	assert.Equal(t, []string{"fd00::53"}, iface.IPv6)
+	assert.Equal(t, []string{"office.example"}, iface.Search)
+	assert.Empty(t, iface.Options)
+}
+
+func TestParseSystemdResolvedOutput_Global(t *testing.T) {
+	output := `Global
+           Protocols: -LLMNR -mDNS -DNSOverTLS DNSSEC=no/unsupported
+    resolv.conf mode: stub
+  Current DNS Server: 9.9.9.9#dns.quad9.net
+         DNS Servers: 9.9.9.9#dns.quad9.net
+                      [2620:fe::fe]:853#dns.quad9.net
+Fallback DNS Servers: 1.1.1.1 8.8.8.8
+          DNS Domain: corp.example
+
+Link 2 (eth0)
+    Current Scopes: DNS
+         Protocols: +DefaultRoute -LLMNR -mDNS -DNSOverTLS DNSSEC=no/unsupported
+Current DNS Server: 10.0.0.53
+       DNS Servers: 10.0.0.53 10.0.0.54
+
+Link 3 (docker0)
+    Current Scopes: none
+`
+	state := (&ConfigReader{}).parseSystemdResolvedOutput(output)
+
+	require.NotNil(t, state.Global)
+	assert.Equal(t, []string{"9.9.9.9#dns.quad9.net"}, state.Global.IPv4)
+	assert.Equal(t, []string{"[2620:fe::fe]:853#dns.quad9.net"}, state.Global.IPv6)
+	assert.Equal(t, []string{"corp.example"}, state.Global.Search)
+	assert.Equal(t, "9.9.9.9#dns.quad9.net", state.Global.Current)
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, state.Fallback)
+
+	require.Len(t, state.Links, 1)
+	assert.Equal(t, "eth0", state.Links[0].Name)
+	assert.Equal(t, []string{"10.0.0.53", "10.0.0.54"}, state.Links[0].IPv4)
+	assert.Equal(t, "10.0.0.53", state.Links[0].Current)
+}
+
+func TestParseSystemdResolvedOutput_FallbackList(t *testing.T) {
+	output := `Global
+Fallback DNS Servers: 1.1.1.1#cloudflare-dns.com
+                      9.9.9.9#dns.quad9.net
+Link 2 (eth0)
+    Current Scopes: none
+`
+	state := (&ConfigReader{}).parseSystemdResolvedOutput(output)
+	assert.Nil(t, state.Global)
+	assert.Empty(t, state.Links)
+	assert.Equal(t, []string{"1.1.1.1#cloudflare-dns.com", "9.9.9.9#dns.quad9.net"}, state.Fallback)
+}
+
+func TestBuildResolvedState(t *testing.T) {
+	servers := []resolvedServer{
+		{Ifindex: 0, Family: 2, Address: []byte{9, 9, 9, 9}, Port: 853, ServerName: "dns.quad9.net"},
+		{Ifindex: 2, Family: 2, Address: []byte{10, 0, 0, 53}, Port: 53},
+		{Ifindex: 2, Family: 10, Address: []byte{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x53}},
+		{Ifindex: 3, Family: 2, Address: []byte{127, 0, 0, 1}, Port: 5353},
+	}
+	domains := []resolvedDomain{
+		{Ifindex: 2, Domain: "corp.example"},
+		{Ifindex: 2, Domain: "internal.example", RouteOnly: true},
+	}
+	names := map[int32]string{2: "eth0", 3: "wlan0"}
+
+	state := buildResolvedState(servers, domains, func(i int32) string { return names[i] })
+
+	require.NotNil(t, state.Global)
+	assert.Equal(t, "Global", state.Global.Name)
+	assert.Equal(t, []string{"9.9.9.9:853#dns.quad9.net"}, state.Global.IPv4)
+
+	require.Len(t, state.Links, 2)
+	assert.Equal(t, "eth0", state.Links[0].Name)
+	assert.Equal(t, []string{"10.0.0.53"}, state.Links[0].IPv4)
+	assert.Equal(t, []string{"fd00::53"}, state.Links[0].IPv6)
+	assert.Equal(t, []string{"corp.example"}, state.Links[0].Search)
+	assert.Equal(t, "wlan0", state.Links[1].Name)
+	assert.Equal(t, []string{"127.0.0.1:5353"}, state.Links[1].IPv4)
+	assert.Equal(t, []int32{2, 3}, state.linkIndexes)
+}
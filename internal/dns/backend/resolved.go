@@ -0,0 +1,231 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+
+	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+)
+
+const (
+	resolve1Dest    = "org.freedesktop.resolve1"
+	resolve1Path    = dbus.ObjectPath("/org/freedesktop/resolve1")
+	resolve1Manager = "org.freedesktop.resolve1.Manager"
+	resolve1Link    = "org.freedesktop.resolve1.Link"
+)
+
+// resolvedState is the DNS configuration of systemd-resolved: the global
+// servers and domains, and those of each link
+type resolvedState struct {
+	Global *status.InterfaceStatus
+	Links  []status.InterfaceStatus
+	// Fallback lists the servers used when no other server is known
+	Fallback []string
+	// linkIndexes holds the interface index of each entry in Links
+	linkIndexes []int32
+}
+
+// resolvedServer is an entry of the Manager DNSEx property, a(iiayqs).
+// Ifindex is 0 for global servers.
+type resolvedServer struct {
+	Ifindex    int32
+	Family     int32
+	Address    []byte
+	Port       uint16
+	ServerName string
+}
+
+// resolvedDomain is an entry of the Manager Domains property, a(isb)
+type resolvedDomain struct {
+	Ifindex   int32
+	Domain    string
+	RouteOnly bool
+}
+
+// readResolvedDBus reads the resolved configuration from its D-Bus API
+func readResolvedDBus(ctx context.Context) (resolvedState, error) {
+	conn, err := dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		return resolvedState{}, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	manager := conn.Object(resolve1Dest, resolve1Path)
+
+	servers, err := readServerList(ctx, manager, resolve1Manager, "DNS")
+	if err != nil {
+		return resolvedState{}, fmt.Errorf("failed to read resolved DNS servers: %w", err)
+	}
+
+	var domains []resolvedDomain
+	if err := storeProperty(ctx, manager, resolve1Manager, "Domains", &domains); err != nil {
+		return resolvedState{}, fmt.Errorf("failed to read resolved domains: %w", err)
+	}
+
+	state := buildResolvedState(servers, domains, interfaceName)
+	if fallback, err := readServerList(ctx, manager, resolve1Manager, "FallbackDNS"); err == nil {
+		for _, server := range fallback {
+			if addr, ok := server.address(); ok {
+				state.Fallback = append(state.Fallback, addr.String())
+			}
+		}
+	}
+
+	// Protocol settings live on the link objects
+	for i := range state.Links {
+		var path dbus.ObjectPath
+		if err := manager.CallWithContext(ctx, resolve1Manager+".GetLink", 0, state.linkIndexes[i]).Store(&path); err != nil {
+			continue
+		}
+		link := conn.Object(resolve1Dest, path)
+		_ = storeProperty(ctx, link, resolve1Link, "DNSSEC", &state.Links[i].DNSSEC)
+		_ = storeProperty(ctx, link, resolve1Link, "LLMNR", &state.Links[i].LLMNR)
+		_ = storeProperty(ctx, link, resolve1Link, "MulticastDNS", &state.Links[i].MulticastDNS)
+		if current, err := readCurrentServer(ctx, link); err == nil {
+			state.Links[i].Current = current
+		}
+	}
+
+	return state, nil
+}
+
+// readServerList reads a server list property such as DNS, using its Ex
+// variant carrying ports and server names where available. The Ex
+// properties were added in systemd 246.
+func readServerList(ctx context.Context, obj dbus.BusObject, iface, name string) ([]resolvedServer, error) {
+	var servers []resolvedServer
+	err := storeProperty(ctx, obj, iface, name+"Ex", &servers)
+	if err == nil {
+		return servers, nil
+	}
+	var legacy []struct {
+		Ifindex int32
+		Family  int32
+		Address []byte
+	}
+	if legacyErr := storeProperty(ctx, obj, iface, name, &legacy); legacyErr != nil {
+		return nil, err
+	}
+	for _, s := range legacy {
+		servers = append(servers, resolvedServer{Ifindex: s.Ifindex, Family: s.Family, Address: s.Address})
+	}
+	return servers, nil
+}
+
+// readCurrentServer reads the server a link is querying, empty when none
+func readCurrentServer(ctx context.Context, link dbus.BusObject) (string, error) {
+	var current struct {
+		Family     int32
+		Address    []byte
+		Port       uint16
+		ServerName string
+	}
+	if err := storeProperty(ctx, link, resolve1Link, "CurrentDNSServerEx", &current); err != nil {
+		var legacy struct {
+			Family  int32
+			Address []byte
+		}
+		if legacyErr := storeProperty(ctx, link, resolve1Link, "CurrentDNSServer", &legacy); legacyErr != nil {
+			return "", err
+		}
+		current.Family, current.Address = legacy.Family, legacy.Address
+	}
+	addr, ok := resolvedServer{Address: current.Address, Port: current.Port, ServerName: current.ServerName}.address()
+	if !ok {
+		return "", nil
+	}
+	return addr.String(), nil
+}
+
+// address converts the server to the notation cdns uses, leaving out the
+// default port
+func (s resolvedServer) address() (models.ServerAddress, bool) {
+	ip, ok := netip.AddrFromSlice(s.Address)
+	if !ok {
+		return models.ServerAddress{}, false
+	}
+	addr := models.ServerAddress{IP: ip.Unmap(), Port: s.Port, SNI: s.ServerName}
+	if addr.Port == 53 {
+		addr.Port = 0
+	}
+	return addr, true
+}
+
+// storeProperty reads a D-Bus property into value
+func storeProperty(ctx context.Context, obj dbus.BusObject, iface, name string, value any) error {
+	var v dbus.Variant
+	if err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, iface, name).Store(&v); err != nil {
+		return err
+	}
+	return dbus.Store([]any{v.Value()}, value)
+}
+
+// buildResolvedState groups servers and search domains by link. Route-only
+// domains ("~example.com") are not search domains and are left out, and
+// links without servers or domains are skipped like in resolvectl status.
+func buildResolvedState(servers []resolvedServer, domains []resolvedDomain, name func(int32) string) resolvedState {
+	var state resolvedState
+	byIndex := make(map[int32]*status.InterfaceStatus)
+	var order []int32
+
+	entry := func(ifindex int32) *status.InterfaceStatus {
+		if iface, ok := byIndex[ifindex]; ok {
+			return iface
+		}
+		iface := &status.InterfaceStatus{IPv4: []string{}, IPv6: []string{}}
+		if ifindex == 0 {
+			iface.Name = "Global"
+		} else {
+			iface.Name = name(ifindex)
+		}
+		byIndex[ifindex] = iface
+		order = append(order, ifindex)
+		return iface
+	}
+
+	for _, s := range servers {
+		addr, ok := s.address()
+		if !ok {
+			continue
+		}
+		iface := entry(s.Ifindex)
+		if addr.Is6() {
+			iface.IPv6 = append(iface.IPv6, addr.String())
+		} else {
+			iface.IPv4 = append(iface.IPv4, addr.String())
+		}
+	}
+	for _, d := range domains {
+		if d.RouteOnly {
+			continue
+		}
+		iface := entry(d.Ifindex)
+		iface.Search = append(iface.Search, d.Domain)
+	}
+
+	slices.Sort(order)
+	for _, ifindex := range order {
+		if ifindex == 0 {
+			state.Global = byIndex[0]
+			continue
+		}
+		state.Links = append(state.Links, *byIndex[ifindex])
+		state.linkIndexes = append(state.linkIndexes, ifindex)
+	}
+	return state
+}
+
+// interfaceName returns the name of the interface with the given index,
+// falling back to the index itself
+func interfaceName(ifindex int32) string {
+	if iface, err := net.InterfaceByIndex(int(ifindex)); err == nil {
+		return iface.Name
+	}
+	return fmt.Sprintf("%d", ifindex)
+}
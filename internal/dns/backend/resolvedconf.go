@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+)
+
+const (
+	// resolvedConfPath is the main systemd-resolved configuration file
+	resolvedConfPath = "/etc/systemd/resolved.conf"
+	// resolvedDropInDir holds drop-ins overriding resolved.conf
+	resolvedDropInDir = "/etc/systemd/resolved.conf.d"
+	// resolvedDropInPath is the drop-in cdns owns. Drop-ins are read in name
+	// order, so the high prefix lets it override most others.
+	resolvedDropInPath = resolvedDropInDir + "/90-cdns.conf"
+)
+
+// ownedFileHeader starts every file cdns writes on its own
+const ownedFileHeader = "# Written by cdns. Remove this file or run 'cdns reset' to restore the defaults.\n"
+
+// OwnedFile is a file cdns writes on its own
+type OwnedFile struct {
+	Path string
+	// Backend is the backend reading the file
+	Backend models.Backend
+}
+
+// OwnedFiles lists the files cdns writes on its own
+var OwnedFiles = []OwnedFile{
+	{Path: resolvedDropInPath, Backend: models.BackendSystemdResolved},
+	{Path: netplanOwnedPath, Backend: models.BackendNetplan},
+	{Path: nmGlobalDNSPath, Backend: models.BackendNetworkManager},
+}
+
+// InUse reports whether the backend reading the file runs on the system,
+// so the file takes effect
+func (f OwnedFile) InUse(sysOps SystemOps) bool {
+	if f.Backend == models.BackendNetplan {
+		return sysOps.CommandExists("netplan")
+	}
+	running, _ := sysOps.ServiceRunning(string(f.Backend))
+	return running
+}
+
+// applyResolvedDropIn writes the global resolved configuration to the cdns
+// drop-in. Without a running daemon there are no links, so the servers,
+// domains and link settings of all configs are merged.
+func (w *ConfigWriter) applyResolvedDropIn(configs []models.DNSConfig) error {
+	path := w.root.Path(resolvedDropInPath)
+	return writeFile(path, []byte(renderResolvedDropIn(configs)), 0644)
+}
+
+// renderResolvedDropIn renders a [Resolve] section for configs
+func renderResolvedDropIn(configs []models.DNSConfig) string {
+	var servers, domains []string
+	var link models.LinkSettings
+	for _, cfg := range configs {
+		for _, server := range cfg.DNS.Ordered() {
+			if !slices.Contains(servers, server) {
+				servers = append(servers, server)
+			}
+		}
+		for _, domain := range cfg.Search {
+			if !slices.Contains(domains, domain) {
+				domains = append(domains, domain)
+			}
+		}
+		if cfg.Link.DNSSEC != "" {
+			link.DNSSEC = cfg.Link.DNSSEC
+		}
+		if cfg.Link.LLMNR != "" {
+			link.LLMNR = cfg.Link.LLMNR
+		}
+		if cfg.Link.MulticastDNS != "" {
+			link.MulticastDNS = cfg.Link.MulticastDNS
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(ownedFileHeader)
+	b.WriteString("[Resolve]\n")
+	if len(servers) > 0 {
+		b.WriteString("DNS=" + strings.Join(servers, " ") + "\n")
+	}
+	if len(domains) > 0 {
+		b.WriteString("Domains=" + strings.Join(domains, " ") + "\n")
+	}
+	if link.DNSSEC != "" {
+		b.WriteString("DNSSEC=" + link.DNSSEC + "\n")
+	}
+	if link.LLMNR != "" {
+		b.WriteString("LLMNR=" + link.LLMNR + "\n")
+	}
+	if link.MulticastDNS != "" {
+		b.WriteString("MulticastDNS=" + link.MulticastDNS + "\n")
+	}
+	return b.String()
+}
+
+// readResolvedConf reads the global resolved configuration from
+// resolved.conf and its drop-ins, as the daemon would when it starts
+func (r *ConfigReader) readResolvedConf() (*status.StatusInfo, error) {
+	info := &status.StatusInfo{
+		Backend:    models.BackendSystemdResolved,
+		Interfaces: []status.InterfaceStatus{},
+		Warnings:   []string{},
+	}
+
+	files := []string{r.root.Path(resolvedConfPath)}
+	dropIns, err := filepath.Glob(filepath.Join(r.root.Path(resolvedDropInDir), "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(dropIns)
+	files = append(files, dropIns...)
+
+	global := &status.InterfaceStatus{Name: "Global", IPv4: []string{}, IPv6: []string{}}
+	for _, path := range files {
+		if err := parseResolvedConf(path, global); err != nil {
+			return nil, err
+		}
+	}
+	if len(global.IPv4)+len(global.IPv6)+len(global.Search) > 0 || global.DNSSEC != "" || global.LLMNR != "" || global.MulticastDNS != "" {
+		info.Global = global
+		info.Global.Configured = serversOf(*global)
+		info.Global.Effective = effectiveServers(info.Global.Configured, status.SourceGlobal)
+		// The drop-in cdns owns is read last, so its settings are in effect
+		info.Global.Managed = slices.Contains(dropIns, r.root.Path(resolvedDropInPath))
+		info.Managed = info.Global.Managed
+	}
+	return info, nil
+}
+
+// parseResolvedConf applies the [Resolve] settings of one file to global.
+// List settings accumulate across files and an empty assignment resets
+// them, like in systemd. A missing file is skipped.
+func parseResolvedConf(path string, global *status.InterfaceStatus) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if name, ok := keyfileSection(line); ok {
+			section = name
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "Resolve" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "DNS":
+			if value == "" {
+				global.IPv4, global.IPv6 = []string{}, []string{}
+			}
+			for _, server := range strings.Fields(value) {
+				if models.IsIPv6Server(server) {
+					global.IPv6 = append(global.IPv6, server)
+				} else {
+					global.IPv4 = append(global.IPv4, server)
+				}
+			}
+		case "Domains":
+			if value == "" {
+				global.Search = nil
+			}
+			for _, domain := range strings.Fields(value) {
+				// Route-only domains are not search domains
+				if !strings.HasPrefix(domain, "~") {
+					global.Search = append(global.Search, domain)
+				}
+			}
+		case "DNSSEC":
+			global.DNSSEC = value
+		case "LLMNR":
+			global.LLMNR = value
+		case "MulticastDNS":
+			global.MulticastDNS = value
+		}
+	}
+	return scanner.Err()
+}
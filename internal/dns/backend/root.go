@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Root is the filesystem cdns configures. By default that is the running
+// system; with --root it is a mounted image or chroot, which is configured
+// offline through its files alone: no command is run and no daemon is
+// contacted.
+type Root struct {
+	dir string
+}
+
+// NewRoot creates a Root for the running system
+func NewRoot() *Root {
+	return &Root{}
+}
+
+// Set points cdns at the filesystem under dir. An empty dir or "/" selects
+// the running system.
+func (r *Root) Set(dir string) error {
+	if dir == "" {
+		r.dir = ""
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid root %s: %w", dir, err)
+	}
+	if abs == "/" {
+		r.dir = ""
+		return nil
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("invalid root: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid root: %s is not a directory", dir)
+	}
+	r.dir = abs
+	return nil
+}
+
+// Offline reports whether cdns works on the files under a root directory
+// rather than on the running system
+func (r *Root) Offline() bool {
+	return r != nil && r.dir != ""
+}
+
+// Dir returns the root directory, "/" for the running system
+func (r *Root) Dir() string {
+	if !r.Offline() {
+		return "/"
+	}
+	return r.dir
+}
+
+// Path returns the location of a system path such as /etc/resolv.conf
+// under the root
+func (r *Root) Path(name string) string {
+	if !r.Offline() {
+		return name
+	}
+	// Cleaning from "/" keeps ".." from leaving the root
+	return filepath.Join(r.dir, filepath.Clean("/"+name))
+}
+
+// resolve returns the location of a system path under the root, following
+// symlinks as if the root were "/". Images often link /etc/resolv.conf to
+// an absolute path, which would otherwise be looked up on the host.
+func (r *Root) resolve(name string) string {
+	if !r.Offline() {
+		return name
+	}
+	for range 40 {
+		target, err := os.Readlink(r.Path(name))
+		if err != nil {
+			break
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		name = target
+	}
+	return r.Path(name)
+}
+
+// writeFile replaces a file atomically, creating its directory if needed.
+// Symlinks are refused: in an image they usually point at absolute paths,
+// which would resolve on the host rather than inside the root.
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("refusing to write %s: it is a symlink", path)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// removeFile deletes a file cdns owns. A missing file is not an error.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
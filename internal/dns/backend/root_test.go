@@ -0,0 +1,276 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+)
+
+// offlineRoot creates an image under a temp directory holding files, keyed
+// by their path inside the image
+func offlineRoot(t *testing.T, files map[string]string) *Root {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	root := NewRoot()
+	require.NoError(t, root.Set(dir))
+	return root
+}
+
+func readImageFile(t *testing.T, root *Root, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(root.Path(name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRoot(t *testing.T) {
+	live := NewRoot()
+	assert.False(t, live.Offline())
+	assert.Equal(t, "/etc/resolv.conf", live.Path("/etc/resolv.conf"))
+	require.NoError(t, live.Set("/"))
+	assert.False(t, live.Offline())
+
+	var unset *Root
+	assert.False(t, unset.Offline())
+
+	root := offlineRoot(t, nil)
+	assert.True(t, root.Offline())
+	assert.Equal(t, filepath.Join(root.Dir(), "etc/resolv.conf"), root.Path("/etc/resolv.conf"))
+	assert.Equal(t, filepath.Join(root.Dir(), "etc/passwd"), root.Path("/../../etc/passwd"))
+
+	assert.Error(t, NewRoot().Set(filepath.Join(root.Dir(), "missing")))
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	assert.ErrorContains(t, NewRoot().Set(file), "is not a directory")
+}
+
+func TestDetectOffline(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		links   map[string]string
+		want    models.Backend
+		wantErr string
+		// rejected lists the backends passed over
+		rejected []models.Backend
+	}{
+		{
+			name:  "netplan",
+			files: map[string]string{"/etc/netplan/50-cloud-init.yaml": "network: {}\n", "/usr/sbin/NetworkManager": ""},
+			want:  models.BackendNetplan,
+		},
+		{
+			name:     "NetworkManager keyfiles",
+			files:    map[string]string{"/etc/NetworkManager/system-connections/.keep": ""},
+			want:     models.BackendNetworkManager,
+			rejected: []models.Backend{models.BackendNetplan},
+		},
+		{
+			name:  "resolv.conf links to the resolved stub",
+			links: map[string]string{"/etc/resolv.conf": "../run/systemd/resolve/stub-resolv.conf"},
+			want:  models.BackendSystemdResolved,
+		},
+		{
+			name:    "resolv.conf links elsewhere",
+			links:   map[string]string{"/etc/resolv.conf": "/run/resolvconf/resolv.conf"},
+			wantErr: "which no supported backend manages",
+		},
+		{
+			name:  "resolved enabled",
+			links: map[string]string{"/etc/systemd/system/multi-user.target.wants/systemd-resolved.service": "/usr/lib/systemd/system/systemd-resolved.service"},
+			want:  models.BackendSystemdResolved,
+		},
+		{
+			name:     "plain image",
+			files:    map[string]string{"/etc/resolv.conf": "nameserver 10.0.0.1\n"},
+			want:     models.BackendResolvConf,
+			rejected: []models.Backend{models.BackendNetplan, models.BackendNetworkManager, models.BackendSystemdResolved},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := offlineRoot(t, tt.files)
+			for name, target := range tt.links {
+				require.NoError(t, os.MkdirAll(filepath.Dir(root.Path(name)), 0755))
+				require.NoError(t, os.Symlink(target, root.Path(name)))
+			}
+
+			chain, err := NewDetector(nil, root).DetectChain()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, chain.Backend)
+			assert.NotEmpty(t, chain.Reason)
+			if tt.rejected != nil {
+				var rejected []models.Backend
+				for _, candidate := range chain.Rejected {
+					rejected = append(rejected, candidate.Backend)
+				}
+				assert.Equal(t, tt.rejected, rejected)
+			}
+		})
+	}
+}
+
+func TestDetectOffline_Override(t *testing.T) {
+	root := offlineRoot(t, map[string]string{
+		"/etc/netplan/50-cloud-init.yaml": "network: {}\n",
+		"/usr/sbin/NetworkManager":        "",
+	})
+	detector := NewDetector(nil, root)
+
+	require.NoError(t, detector.SetOverride("nm", "dns.backend"))
+	chain, err := detector.DetectChain()
+	require.NoError(t, err)
+	assert.Equal(t, models.BackendNetworkManager, chain.Backend)
+	assert.Equal(t, "dns.backend", chain.Override)
+	assert.Equal(t, "chosen with dns.backend; detection skipped", chain.Reason)
+
+	require.NoError(t, detector.SetOverride("netplan", "--backend"))
+	_, err = detector.DetectChain()
+	assert.EqualError(t, err, "--backend netplan cannot be used: netplan is not installed in the image")
+}
+
+func TestOffline_ResolvConf(t *testing.T) {
+	ctx := context.Background()
+	config := []models.DNSConfig{{DNS: models.DNSServer{IPv4: []string{"9.9.9.9"}}, Search: []string{"corp.example"}}}
+
+	t.Run("created when missing", func(t *testing.T) {
+		root := offlineRoot(t, nil)
+		writer := NewConfigWriter(nil, nil, nil, root)
+		require.NoError(t, writer.Apply(ctx, models.BackendResolvConf, config))
+		assert.Equal(t, "search corp.example\nnameserver 9.9.9.9\n", readImageFile(t, root, resolvConfPath))
+
+		info, err := NewConfigReader(nil, nil, nil, root).ReadDNSConfig(ctx, models.BackendResolvConf)
+		require.NoError(t, err)
+		require.Len(t, info.Interfaces, 1)
+		assert.Equal(t, []string{"9.9.9.9"}, info.Interfaces[0].IPv4)
+		assert.Equal(t, []status.EffectiveServer{{Address: "9.9.9.9", Source: status.SourceManual}}, info.Interfaces[0].Effective)
+	})
+
+	t.Run("read through an absolute symlink", func(t *testing.T) {
+		root := offlineRoot(t, map[string]string{"/run/resolvconf/resolv.conf": "nameserver 10.0.0.1\n"})
+		require.NoError(t, os.MkdirAll(root.Path("/etc"), 0755))
+		require.NoError(t, os.Symlink("/run/resolvconf/resolv.conf", root.Path(resolvConfPath)))
+
+		info, err := NewConfigReader(nil, nil, nil, root).ReadDNSConfig(ctx, models.BackendResolvConf)
+		require.NoError(t, err)
+		require.Len(t, info.Interfaces, 1)
+		assert.Equal(t, []string{"10.0.0.1"}, info.Interfaces[0].IPv4)
+
+		err = NewConfigWriter(nil, nil, nil, root).Apply(ctx, models.BackendResolvConf, config)
+		assert.ErrorContains(t, err, "it is a symlink")
+	})
+}
+
+func TestOffline_ResolvedDropIn(t *testing.T) {
+	ctx := context.Background()
+	root := offlineRoot(t, map[string]string{
+		resolvedConfPath:                      "[Resolve]\nDNS=10.0.0.1\nDomains=~.\n",
+		resolvedDropInDir + "/10-vendor.conf": "[Resolve]\nDNSSEC=no\n",
+	})
+
+	writer := NewConfigWriter(nil, nil, nil, root)
+	require.NoError(t, writer.Apply(ctx, models.BackendSystemdResolved, []models.DNSConfig{
+		{
+			Interface: models.NetworkInterface{Name: "system"},
+			DNS:       models.DNSServer{IPv4: []string{"9.9.9.9"}, IPv6: []string{"2620:fe::fe"}},
+			Search:    []string{"corp.example"},
+			Link:      models.LinkSettings{DNSSEC: "allow-downgrade"},
+		},
+	}))
+	assert.Equal(t, ownedFileHeader+`[Resolve]
+DNS=9.9.9.9 2620:fe::fe
+Domains=corp.example
+DNSSEC=allow-downgrade
+`, readImageFile(t, root, resolvedDropInPath))
+
+	info, err := NewConfigReader(nil, nil, nil, root).ReadDNSConfig(ctx, models.BackendSystemdResolved)
+	require.NoError(t, err)
+	require.NotNil(t, info.Global)
+	// resolved.conf and the drop-in both add servers
+	assert.Equal(t, []string{"10.0.0.1", "9.9.9.9"}, info.Global.IPv4)
+	assert.Equal(t, []string{"2620:fe::fe"}, info.Global.IPv6)
+	assert.Equal(t, []string{"corp.example"}, info.Global.Search)
+	assert.Equal(t, "allow-downgrade", info.Global.DNSSEC)
+	assert.True(t, info.Global.Managed)
+	assert.True(t, info.Managed)
+
+	require.NoError(t, writer.ResetToAutomatic(ctx, models.BackendSystemdResolved, nil))
+	assert.NoFileExists(t, root.Path(resolvedDropInPath))
+}
+
+func TestOffline_Netplan(t *testing.T) {
+	ctx := context.Background()
+	root := offlineRoot(t, map[string]string{
+		"/etc/netplan/50-cloud-init.yaml": `network:
+  version: 2
+  ethernets:
+    enp1s0:
+      dhcp4: true
+  wifis:
+    wlp2s0:
+      dhcp4: true
+      nameservers:
+        addresses: [10.0.0.1]
+`,
+	})
+
+	names, err := NetplanInterfaces(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"enp1s0", "wlp2s0"}, names)
+
+	writer := NewConfigWriter(nil, nil, nil, root)
+	require.NoError(t, writer.Apply(ctx, models.BackendNetplan, []models.DNSConfig{{
+		Interface: models.NetworkInterface{Name: "enp1s0"},
+		DNS:       models.DNSServer{IPv4: []string{"9.9.9.9"}, IPv6: []string{"2620:fe::fe"}},
+		Search:    []string{"corp.example"},
+	}}))
+	assert.Equal(t, ownedFileHeader+`network:
+    version: 2
+    ethernets:
+        enp1s0:
+            nameservers:
+                addresses: [9.9.9.9, '2620:fe::fe']
+                search: [corp.example]
+`, readImageFile(t, root, netplanOwnedPath))
+	info, err := os.Stat(root.Path(netplanOwnedPath))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	status, err := NewConfigReader(nil, nil, nil, root).ReadDNSConfig(ctx, models.BackendNetplan)
+	require.NoError(t, err)
+	require.Len(t, status.Interfaces, 2)
+	assert.Equal(t, []string{"9.9.9.9"}, status.Interfaces[0].IPv4)
+	assert.Equal(t, []string{"2620:fe::fe"}, status.Interfaces[0].IPv6)
+	assert.Equal(t, []string{"10.0.0.1"}, status.Interfaces[1].IPv4)
+	assert.True(t, status.Interfaces[0].Managed)
+	assert.False(t, status.Interfaces[1].Managed, "servers set outside the cdns file")
+	assert.True(t, status.Managed)
+
+	// netplan would add the new servers to the ones already set
+	err = writer.Apply(ctx, models.BackendNetplan, []models.DNSConfig{{
+		Interface: models.NetworkInterface{Name: "wlp2s0"},
+		DNS:       models.DNSServer{IPv4: []string{"9.9.9.9"}},
+	}})
+	assert.ErrorIs(t, err, ErrUnsupported)
+	assert.ErrorContains(t, err, "/etc/netplan/50-cloud-init.yaml")
+
+	require.NoError(t, writer.ResetToAutomatic(ctx, models.BackendNetplan, nil))
+	assert.NoFileExists(t, root.Path(netplanOwnedPath))
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandRunner runs external commands such as nmcli, resolvectl and
+// systemctl. Backends never call os/exec directly so that tests can replay
+// recorded transcripts and --trace-commands can report every invocation.
+type CommandRunner interface {
+	// Run runs a command and returns its standard output. A command that
+	// exits with a non-zero status returns a *CommandError.
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// CommandError reports a command that exited with a non-zero status
+type CommandError struct {
+	Name     string
+	Args     []string
+	ExitCode int
+	Stderr   []byte
+}
+
+// Error matches the text of exec.ExitError so messages read the same
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("exit status %d", e.ExitCode)
+}
+
+// commandStderr returns the trimmed standard error of a failed command, for
+// inclusion in error messages
+func commandStderr(err error) string {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return strings.TrimSpace(string(cmdErr.Stderr))
+	}
+	return ""
+}
+
+// ExecRunner runs commands on the host
+type ExecRunner struct {
+	mu    sync.Mutex
+	trace io.Writer
+}
+
+// NewExecRunner creates a runner for host commands
+func NewExecRunner() *ExecRunner {
+	return &ExecRunner{}
+}
+
+// Trace prints every command run from now on to w, with its duration and
+// exit status. A nil writer turns tracing off.
+func (r *ExecRunner) Trace(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace = w
+}
+
+// Run runs a command on the host
+func (r *ExecRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		err = &CommandError{Name: name, Args: args, ExitCode: exitErr.ExitCode(), Stderr: stderr.Bytes()}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.trace != nil {
+		result := "exit 0"
+		var cmdErr *CommandError
+		switch {
+		case errors.As(err, &cmdErr):
+			result = fmt.Sprintf("exit %d", cmdErr.ExitCode)
+		case err != nil:
+			result = err.Error()
+		}
+		fmt.Fprintf(r.trace, "+ %s (%s, %s)\n", FormatCommand(name, args), elapsed.Round(time.Microsecond), result)
+	}
+
+	return stdout.Bytes(), err
+}
+
+// FormatCommand renders a command line, quoting arguments that contain
+// spaces or quotes so it can be parsed back by ParseTranscript
+func FormatCommand(name string, args []string) string {
+	parts := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{name}, args...) {
+		if arg == "" || strings.ContainsAny(arg, " \t\"\\") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
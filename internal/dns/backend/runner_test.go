@@ -0,0 +1,185 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// replayTranscript loads a golden transcript from testdata and fails the
+// test if any of its commands is left unrun
+func replayTranscript(t *testing.T, name string) *ReplayRunner {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	transcript, err := ParseTranscript(string(data))
+	require.NoError(t, err)
+
+	runner := NewReplayRunner(transcript)
+	t.Cleanup(func() {
+		assert.Empty(t, runner.Remaining().String(), "commands left in %s", name)
+	})
+	return runner
+}
+
+func TestTranscript_RoundTrip(t *testing.T) {
+	transcript := Transcript{
+		{Name: "nmcli", Args: []string{"connection", "modify", "id", "Wired: Office", "ipv4.dns", ""}},
+		{Name: "resolvectl", Args: []string{"status"}, Stdout: "Global\n\nLink 2 (eth0)\n"},
+		{Name: "nmcli", Args: []string{"device", "reapply", "eth9"}, Stderr: "Error: Device 'eth9' not found.\n", ExitCode: 10},
+	}
+
+	text := transcript.String()
+	assert.Equal(t, `$ nmcli connection modify id "Wired: Office" ipv4.dns ""
+$ resolvectl status
+| Global
+|
+| Link 2 (eth0)
+$ nmcli device reapply eth9
+! Error: Device 'eth9' not found.
+exit 10
+`, text)
+
+	parsed, err := ParseTranscript(text)
+	require.NoError(t, err)
+	assert.Equal(t, transcript, parsed)
+
+	_, err = ParseTranscript("| orphan output\n")
+	assert.Error(t, err)
+	_, err = ParseTranscript("$ nmcli\nexit soon\n")
+	assert.Error(t, err)
+}
+
+func TestReplayRunner(t *testing.T) {
+	ctx := context.Background()
+	runner := NewReplayRunner(Transcript{
+		{Name: "resolvectl", Args: []string{"revert", "eth0"}},
+		{Name: "resolvectl", Args: []string{"revert", "wlan0"}, Stderr: "Link wlan0 not known\n", ExitCode: 1},
+	})
+
+	_, err := runner.Run(ctx, "resolvectl", "revert", "wlan0")
+	assert.ErrorContains(t, err, `transcript expects "resolvectl revert eth0"`)
+
+	_, err = runner.Run(ctx, "resolvectl", "revert", "eth0")
+	assert.NoError(t, err)
+
+	_, err = runner.Run(ctx, "resolvectl", "revert", "wlan0")
+	var cmdErr *CommandError
+	require.ErrorAs(t, err, &cmdErr)
+	assert.Equal(t, 1, cmdErr.ExitCode)
+	assert.Equal(t, "Link wlan0 not known", commandStderr(err))
+
+	_, err = runner.Run(ctx, "resolvectl", "status")
+	assert.ErrorContains(t, err, "transcript has ended")
+	assert.Empty(t, runner.Remaining())
+}
+
+func TestExecRunner_TraceAndRecord(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh not available")
+	}
+	ctx := context.Background()
+	exec := NewExecRunner()
+	var trace bytes.Buffer
+	exec.Trace(&trace)
+	recorder := NewRecordingRunner(exec)
+
+	out, err := recorder.Run(ctx, "/bin/sh", "-c", "echo out; echo err >&2")
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", string(out))
+
+	_, err = recorder.Run(ctx, "/bin/sh", "-c", "echo failed >&2; exit 3")
+	assert.EqualError(t, err, "exit status 3")
+
+	assert.Equal(t, Transcript{
+		{Name: "/bin/sh", Args: []string{"-c", "echo out; echo err >&2"}, Stdout: "out\n", Stderr: ""},
+		{Name: "/bin/sh", Args: []string{"-c", "echo failed >&2; exit 3"}, Stderr: "failed\n", ExitCode: 3},
+	}, recorder.Transcript())
+
+	assert.Regexp(t, `^\+ /bin/sh -c "echo out; echo err >&2" \(\S+, exit 0\)
+\+ /bin/sh -c "echo failed >&2; exit 3" \(\S+, exit 3\)
+$`, trace.String())
+}
+
+func TestConfigWriter_Transcripts(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		transcript string
+		run        func(w *ConfigWriter) error
+		wantErr    string
+	}{
+		{
+			transcript: "resolved_apply.txt",
+			run: func(w *ConfigWriter) error {
+				return w.Apply(ctx, models.BackendSystemdResolved, []models.DNSConfig{{
+					Interface: models.NetworkInterface{Name: "eth0"},
+					DNS: models.DNSServer{
+						IPv4:       []string{"1.1.1.1", "9.9.9.9:853#dns.quad9.net"},
+						IPv6:       []string{"2606:4700:4700::1111"},
+						PreferIPv6: true,
+					},
+					Search: []string{"corp.example"},
+					Link:   models.LinkSettings{DNSSEC: "allow-downgrade", MulticastDNS: "no"},
+				}})
+			},
+		},
+		{
+			transcript: "resolved_revert.txt",
+			run: func(w *ConfigWriter) error {
+				return w.ResetToAutomatic(ctx, models.BackendSystemdResolved, []string{"eth0", "wlan0"})
+			},
+			wantErr: "failed to revert DNS for wlan0: Failed to revert interface configuration: Link wlan0 not known: exit status 1",
+		},
+		{
+			transcript: "nmcli_apply.txt",
+			run: func(w *ConfigWriter) error {
+				return w.Apply(ctx, models.BackendNetworkManager, []models.DNSConfig{{
+					Interface: models.NetworkInterface{Name: "eth0"},
+					DNS: models.DNSServer{
+						IPv4:       []string{"1.1.1.1"},
+						IPv6:       []string{"2606:4700:4700::1111"},
+						PreferIPv6: true,
+					},
+					Search: []string{"corp.example"},
+				}})
+			},
+		},
+		{
+			transcript: "nmcli_reset.txt",
+			run: func(w *ConfigWriter) error {
+				return w.ResetToAutomatic(ctx, models.BackendNetworkManager, []string{"eth1"})
+			},
+			wantErr: "failed to get connection for eth1: nmcli error: Error: Device 'eth1' not found.: exit status 10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transcript, func(t *testing.T) {
+			runner := replayTranscript(t, tt.transcript)
+			writer := NewConfigWriter(nil, nmcliClient{runner: runner}, runner, nil)
+
+			err := tt.run(writer)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfigReader_LinkSettingsTranscript(t *testing.T) {
+	runner := replayTranscript(t, "resolved_link_settings.txt")
+	reader := NewConfigReader(nil, nmcliClient{runner: runner}, runner, nil)
+
+	link, err := reader.ReadLinkSettings(context.Background(), models.BackendSystemdResolved, "eth0")
+	require.NoError(t, err)
+	assert.Equal(t, models.LinkSettings{DNSSEC: "allow-downgrade", LLMNR: "resolve", MulticastDNS: "no"}, link)
+}
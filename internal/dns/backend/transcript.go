@@ -0,0 +1,206 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CommandRecord is one command of a transcript with its result
+type CommandRecord struct {
+	Name     string
+	Args     []string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Transcript is a sequence of commands and their results. Its text form
+// puts each command on a "$ " line followed by its output:
+//
+//	$ nmcli -t -f DEVICE,STATE device status
+//	| eth0:connected
+//	$ nmcli device reapply eth0
+//	! Error: Device 'eth0' not found.
+//	exit 10
+//
+// "| " lines are standard output, "! " lines standard error, and an
+// "exit N" line gives a non-zero exit status. Lines starting with "#" and
+// blank lines are ignored.
+type Transcript []CommandRecord
+
+// String renders the transcript in its text form
+func (t Transcript) String() string {
+	var b strings.Builder
+	for _, rec := range t {
+		fmt.Fprintf(&b, "$ %s\n", FormatCommand(rec.Name, rec.Args))
+		writeTranscriptLines(&b, "| ", rec.Stdout)
+		writeTranscriptLines(&b, "! ", rec.Stderr)
+		if rec.ExitCode != 0 {
+			fmt.Fprintf(&b, "exit %d\n", rec.ExitCode)
+		}
+	}
+	return b.String()
+}
+
+func writeTranscriptLines(b *strings.Builder, prefix, output string) {
+	if output == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if line == "" {
+			// Keep empty lines free of trailing whitespace
+			b.WriteString(strings.TrimSpace(prefix))
+		} else {
+			b.WriteString(prefix + line)
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// ParseTranscript parses the text form of a transcript
+func ParseTranscript(text string) (Transcript, error) {
+	var t Transcript
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if command, ok := strings.CutPrefix(line, "$ "); ok {
+			words, err := splitCommandLine(command)
+			if err != nil || len(words) == 0 {
+				return nil, fmt.Errorf("transcript line %d: invalid command %q", n, command)
+			}
+			t = append(t, CommandRecord{Name: words[0], Args: words[1:]})
+			continue
+		}
+
+		if len(t) == 0 {
+			return nil, fmt.Errorf("transcript line %d: output before the first command", n)
+		}
+		rec := &t[len(t)-1]
+		switch {
+		case line == "|" || strings.HasPrefix(line, "| "):
+			rec.Stdout += strings.TrimPrefix(strings.TrimPrefix(line, "|"), " ") + "\n"
+		case line == "!" || strings.HasPrefix(line, "! "):
+			rec.Stderr += strings.TrimPrefix(strings.TrimPrefix(line, "!"), " ") + "\n"
+		case strings.HasPrefix(line, "exit "):
+			code, err := strconv.Atoi(strings.TrimPrefix(line, "exit "))
+			if err != nil {
+				return nil, fmt.Errorf("transcript line %d: invalid exit status", n)
+			}
+			rec.ExitCode = code
+		default:
+			return nil, fmt.Errorf("transcript line %d: unexpected %q", n, line)
+		}
+	}
+	return t, scanner.Err()
+}
+
+// splitCommandLine splits a command line on spaces, honouring the double
+// quoted arguments written by FormatCommand
+func splitCommandLine(line string) ([]string, error) {
+	var words []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] != '"' {
+			word, rest, _ := strings.Cut(line, " ")
+			words = append(words, word)
+			line = rest
+			continue
+		}
+		quoted, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return nil, err
+		}
+		word, _ := strconv.Unquote(quoted)
+		words = append(words, word)
+		line = line[len(quoted):]
+	}
+	return words, nil
+}
+
+// RecordingRunner passes commands to another runner and records them with
+// their results, to capture golden transcripts from a real system
+type RecordingRunner struct {
+	next CommandRunner
+
+	mu         sync.Mutex
+	transcript Transcript
+}
+
+// NewRecordingRunner creates a runner recording the commands run by next
+func NewRecordingRunner(next CommandRunner) *RecordingRunner {
+	return &RecordingRunner{next: next}
+}
+
+// Run runs the command with the wrapped runner and records it
+func (r *RecordingRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := r.next.Run(ctx, name, args...)
+
+	rec := CommandRecord{Name: name, Args: slices.Clone(args), Stdout: string(out)}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		rec.ExitCode = cmdErr.ExitCode
+		rec.Stderr = string(cmdErr.Stderr)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transcript = append(r.transcript, rec)
+	return out, err
+}
+
+// Transcript returns the commands recorded so far
+func (r *RecordingRunner) Transcript() Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.transcript)
+}
+
+// ReplayRunner answers commands from a transcript instead of running them.
+// Commands must arrive in transcript order; any other command fails.
+type ReplayRunner struct {
+	mu         sync.Mutex
+	transcript Transcript
+	next       int
+}
+
+// NewReplayRunner creates a runner replaying a transcript
+func NewReplayRunner(transcript Transcript) *ReplayRunner {
+	return &ReplayRunner{transcript: transcript}
+}
+
+// Run returns the recorded result of the next command in the transcript
+func (r *ReplayRunner) Run(_ context.Context, name string, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	got := FormatCommand(name, args)
+	if r.next >= len(r.transcript) {
+		return nil, fmt.Errorf("unexpected command %q: transcript has ended", got)
+	}
+	rec := r.transcript[r.next]
+	if want := FormatCommand(rec.Name, rec.Args); got != want {
+		return nil, fmt.Errorf("unexpected command %q: transcript expects %q", got, want)
+	}
+	r.next++
+
+	if rec.ExitCode != 0 {
+		return []byte(rec.Stdout), &CommandError{Name: name, Args: args, ExitCode: rec.ExitCode, Stderr: []byte(rec.Stderr)}
+	}
+	return []byte(rec.Stdout), nil
+}
+
+// Remaining returns the transcript commands that have not been run yet
+func (r *ReplayRunner) Remaining() Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.transcript[r.next:])
+}
@@ -2,93 +2,188 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"os"
 	"strings"
 
 	"gitlab.com/junevm/cdns/internal/dns/models"
 )
 
+// ErrUnsupported is returned when a backend cannot apply part of a configuration
+var ErrUnsupported = errors.New("not supported by backend")
+
+// resolvConfPath is the location of the system resolver configuration
+const resolvConfPath = "/etc/resolv.conf"
+
 // ConfigWriter handles applying DNS configurations to the system
 type ConfigWriter struct {
 	sysOps SystemOps
+	nm     NMClient
+	runner CommandRunner
+	root   *Root
 }
 
-// NewConfigWriter creates a new ConfigWriter
-func NewConfigWriter(sysOps SystemOps) *ConfigWriter {
-	return &ConfigWriter{sysOps: sysOps}
+// NewConfigWriter creates a new ConfigWriter. With an offline root, files
+// under it are written instead of running commands.
+func NewConfigWriter(sysOps SystemOps, nm NMClient, runner CommandRunner, root *Root) *ConfigWriter {
+	return &ConfigWriter{sysOps: sysOps, nm: nm, runner: runner, root: root}
 }
 
 // Apply applies the DNS configuration using the specified backend
 func (w *ConfigWriter) Apply(ctx context.Context, backend models.Backend, configs []models.DNSConfig) error {
+	// Refuse unsupported settings before touching any interface
+	if err := CheckSupport(backend, configs); err != nil {
+		return err
+	}
+
 	switch backend {
 	case models.BackendNetworkManager:
 		return w.applyNetworkManager(ctx, configs)
 	case models.BackendSystemdResolved:
+		if w.root.Offline() {
+			return w.applyResolvedDropIn(configs)
+		}
 		return w.applySystemdResolved(ctx, configs)
+	case models.BackendResolvConf:
+		return w.applyResolvConf(configs)
+	case models.BackendNetplan:
+		return w.applyNetplan(ctx, configs)
 	default:
 		return fmt.Errorf("unsupported backend for writing: %s", backend)
 	}
 }
 
-func (w *ConfigWriter) applyNetworkManager(ctx context.Context, configs []models.DNSConfig) error {
+// CheckSupport reports whether the backend can apply every setting in configs
+func CheckSupport(backend models.Backend, configs []models.DNSConfig) error {
 	for _, cfg := range configs {
-		if cfg.Interface.Name == "" {
-			continue
+		switch backend {
+		case models.BackendSystemdResolved:
+			// resolved has no equivalent of resolv.conf options
+			if len(cfg.Options) > 0 {
+				return fmt.Errorf("%w: systemd-resolved cannot apply resolver options (%s); only search domains are supported",
+					ErrUnsupported, strings.Join(cfg.Options, " "))
+			}
+		case models.BackendNetworkManager:
+			if cfg.Link.DNSSEC != "" {
+				return fmt.Errorf("%w: NetworkManager has no per-connection DNSSEC setting; configure DNSSEC in systemd-resolved instead",
+					ErrUnsupported)
+			}
+		case models.BackendResolvConf:
+			if !cfg.Link.IsZero() {
+				return fmt.Errorf("%w: resolv.conf cannot apply DNSSEC, LLMNR or MulticastDNS settings", ErrUnsupported)
+			}
+		case models.BackendNetplan:
+			if len(cfg.Options) > 0 {
+				return fmt.Errorf("%w: netplan cannot apply resolver options (%s); only search domains are supported",
+					ErrUnsupported, strings.Join(cfg.Options, " "))
+			}
+			if !cfg.Link.IsZero() {
+				return fmt.Errorf("%w: netplan cannot apply DNSSEC, LLMNR or MulticastDNS settings", ErrUnsupported)
+			}
+		}
+		if err := checkServerAddresses(backend, cfg.DNS.Ordered()); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// Get active connection name
-		connName, err := w.getNMConnection(ctx, cfg.Interface.Name)
+// checkServerAddresses refuses server ports, interfaces and server names the
+// backend cannot express. systemd-resolved accepts all of them.
+func checkServerAddresses(backend models.Backend, servers []string) error {
+	for _, server := range servers {
+		addr, err := models.ParseServerAddress(server)
 		if err != nil {
-			// Fallback: If we can't get connection, try device modify (transient)
-			// This might happen if device is unmanaged or something weird.
-			// Ideally we log this.
-			return fmt.Errorf("failed to get active connection for %s: %w", cfg.Interface.Name, err)
-		}
-
-		// Set IPv4 DNS
-		if len(cfg.DNS.IPv4) > 0 {
-			dnsStr := strings.Join(cfg.DNS.IPv4, " ")
-			// Modify connection for persistence
-			// ipv4.ignore-auto-dns yes ensures DHCP doesn't override it
-			cmd := exec.CommandContext(ctx, "nmcli", "connection", "modify", connName, "ipv4.dns", dnsStr, "ipv4.ignore-auto-dns", "yes")
-			if output, err := cmd.CombinedOutput(); err != nil {
-				return fmt.Errorf("failed to set IPv4 DNS for %s (conn: %s): %s: %w", cfg.Interface.Name, connName, strings.TrimSpace(string(output)), err)
+			return err
+		}
+		switch backend {
+		case models.BackendNetworkManager, models.BackendNetplan:
+			if !addr.IsPlain() {
+				return fmt.Errorf("%w: %s only accepts plain IP addresses, not %s; use systemd-resolved for ports, interfaces or DNS-over-TLS server names",
+					ErrUnsupported, backend, server)
 			}
+		case models.BackendResolvConf:
+			// glibc understands an interface on link-local IPv6 servers but
+			// always queries port 53 without TLS
+			if addr.Port != 0 || addr.SNI != "" {
+				return fmt.Errorf("%w: resolv.conf cannot set a port or DNS-over-TLS server name (%s); use systemd-resolved instead",
+					ErrUnsupported, server)
+			}
+			if addr.Zone != "" && !addr.Is6() {
+				return fmt.Errorf("%w: resolv.conf only accepts an interface on IPv6 servers (%s)", ErrUnsupported, server)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *ConfigWriter) applyNetworkManager(ctx context.Context, configs []models.DNSConfig) error {
+	for _, cfg := range configs {
+		if cfg.Interface.Name == "" && cfg.Interface.Connection == "" && cfg.Interface.ConnectionUUID == "" {
+			continue
 		}
 
-		// Set IPv6 DNS
-		if len(cfg.DNS.IPv6) > 0 {
-			dnsStr := strings.Join(cfg.DNS.IPv6, " ")
-			cmd := exec.CommandContext(ctx, "nmcli", "connection", "modify", connName, "ipv6.dns", dnsStr, "ipv6.ignore-auto-dns", "yes")
-			if output, err := cmd.CombinedOutput(); err != nil {
-				return fmt.Errorf("failed to set IPv6 DNS for %s (conn: %s): %s: %w", cfg.Interface.Name, connName, strings.TrimSpace(string(output)), err)
+		// Use the explicit connection profile, or the one active on the device
+		profile := NMProfile{Name: cfg.Interface.Connection, UUID: cfg.Interface.ConnectionUUID}
+		if profile.Name == "" && profile.UUID == "" {
+			var err error
+			profile, err = w.nm.DeviceProfile(ctx, cfg.Interface.Name)
+			if err != nil {
+				return fmt.Errorf("failed to get active connection for %s: %w", cfg.Interface.Name, err)
 			}
 		}
 
+		if err := w.nm.UpdateDNS(ctx, profile, nmUpdateFor(cfg)); err != nil {
+			return fmt.Errorf("failed to set DNS for %s (conn: %s): %w", cfg.Interface.Label(), profile.label(), err)
+		}
+
+		// Inactive profiles pick up the change the next time they are activated
+		if cfg.Interface.Name == "" {
+			continue
+		}
+
 		// Reapply changes to the device (runtime)
 		// This makes the changes effective immediately without interface bounce usually
-		cmd := exec.CommandContext(ctx, "nmcli", "device", "reapply", cfg.Interface.Name)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to reapply configuration on device %s: %s: %w", cfg.Interface.Name, string(output), err)
+		if err := w.nm.Reapply(ctx, cfg.Interface.Name); err != nil {
+			return fmt.Errorf("failed to reapply configuration on device %s: %w", cfg.Interface.Name, err)
 		}
 	}
 	return nil
 }
 
-func (w *ConfigWriter) getNMConnection(ctx context.Context, iface string) (string, error) {
-	// usage: nmcli -g GENERAL.CONNECTION device show <iface>
-	// -g prints just the value
-	cmd := exec.CommandContext(ctx, "nmcli", "-g", "GENERAL.CONNECTION", "device", "show", iface)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("nmcli error: %s: %w", strings.TrimSpace(string(out)), err)
+// nmUpdateFor converts a DNS configuration into a profile update. Automatic
+// DNS is ignored for each family given servers, so DHCP does not add its own.
+// A family excluded with an empty list loses the servers already on the
+// profile, and ignores automatic ones too.
+func nmUpdateFor(cfg models.DNSConfig) NMDNSUpdate {
+	yes := true
+	var update NMDNSUpdate
+	if cfg.DNS.IPv4 != nil {
+		update.IPv4DNS = cfg.DNS.IPv4
+		update.IPv4IgnoreAuto = &yes
+	}
+	// NetworkManager orders servers by dns-priority, lower first, with 100
+	// as the default for non-VPN connections. Both are written with the
+	// IPv6 list, so a preference from an earlier set does not stick.
+	if cfg.DNS.IPv6 != nil {
+		update.IPv6DNS = cfg.DNS.IPv6
+		update.IPv6IgnoreAuto = &yes
+		ipv4, ipv6 := int32(0), int32(0)
+		if cfg.DNS.PreferIPv6 && len(cfg.DNS.IPv4) > 0 && len(cfg.DNS.IPv6) > 0 {
+			ipv4, ipv6 = 100, 99
+		}
+		update.IPv4Priority, update.IPv6Priority = &ipv4, &ipv6
+	}
+	if len(cfg.Search) > 0 {
+		update.Search = cfg.Search
 	}
-	res := strings.TrimSpace(string(out))
-	if res == "" {
-		return "", fmt.Errorf("no active connection found for interface %s", iface)
+	if len(cfg.Options) > 0 {
+		update.Options = cfg.Options
 	}
-	return res, nil
+	update.LLMNR = cfg.Link.LLMNR
+	update.MulticastDNS = cfg.Link.MulticastDNS
+	return update
 }
 
 func (w *ConfigWriter) applySystemdResolved(ctx context.Context, configs []models.DNSConfig) error {
@@ -97,58 +192,205 @@ func (w *ConfigWriter) applySystemdResolved(ctx context.Context, configs []model
 			continue
 		}
 
-		allDNS := append(cfg.DNS.IPv4, cfg.DNS.IPv6...)
-		if len(allDNS) == 0 {
-			continue
+		// note: resolvectl is transient.
+		var commands [][]string
+		if allDNS := cfg.DNS.Ordered(); len(allDNS) > 0 {
+			commands = append(commands, append([]string{"dns", cfg.Interface.Name}, allDNS...))
+		}
+		if len(cfg.Search) > 0 {
+			commands = append(commands, append([]string{"domain", cfg.Interface.Name}, cfg.Search...))
+		}
+		if cfg.Link.DNSSEC != "" {
+			commands = append(commands, []string{"dnssec", cfg.Interface.Name, cfg.Link.DNSSEC})
 		}
+		if cfg.Link.LLMNR != "" {
+			commands = append(commands, []string{"llmnr", cfg.Interface.Name, cfg.Link.LLMNR})
+		}
+		if cfg.Link.MulticastDNS != "" {
+			commands = append(commands, []string{"mdns", cfg.Interface.Name, cfg.Link.MulticastDNS})
+		}
+
+		for _, args := range commands {
+			if _, err := w.runner.Run(ctx, "resolvectl", args...); err != nil {
+				return fmt.Errorf("failed to set %s for %s via resolvectl: %s: %w", args[0], cfg.Interface.Name, commandStderr(err), err)
+			}
+		}
+	}
+	return nil
+}
 
-		args := []string{"dns", cfg.Interface.Name}
-		args = append(args, allDNS...)
+// applyResolvConf rewrites /etc/resolv.conf. The file is system-wide, so the
+// servers, search domains and options of all configs are merged.
+func (w *ConfigWriter) applyResolvConf(configs []models.DNSConfig) error {
+	path := w.root.Path(resolvConfPath)
 
-		// note: resolvectl is transient.
-		cmd := exec.CommandContext(ctx, "resolvectl", args...)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to set DNS for %s via resolvectl: %s: %w", cfg.Interface.Name, strings.TrimSpace(string(output)), err)
+	// An image may not have a resolv.conf yet
+	if w.root.Offline() {
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", path, err)
 		}
+		return writeFile(path, []byte(renderResolvConf(string(existing), mergeResolvConf(configs))), 0644)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	// Rewritten in place: containers often bind-mount resolv.conf
+	content := renderResolvConf(string(existing), mergeResolvConf(configs))
+	if err := os.WriteFile(path, []byte(content), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// RestoreResolvConf writes back the resolver lines recorded before cdns
+// first rewrote resolv.conf. Search and options lines the recording lacks
+// are removed.
+func (w *ConfigWriter) RestoreResolvConf(previous models.ResolvConf) error {
+	if previous.Search == nil {
+		previous.Search = []string{}
+	}
+	if previous.Options == nil {
+		previous.Options = []string{}
+	}
+
+	path := w.root.Path(resolvConfPath)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(renderResolvConf(string(existing), previous)), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
 	}
 	return nil
 }
 
+// mergeResolvConf combines the servers, search domains and options of all
+// configs into the lines of one resolv.conf. Search and options stay nil
+// when no config sets them, so the existing lines are kept.
+func mergeResolvConf(configs []models.DNSConfig) models.ResolvConf {
+	var conf models.ResolvConf
+	seen := make(map[string]bool)
+	add := func(list *[]string, values []string) {
+		for _, v := range values {
+			if !seen[v] {
+				seen[v] = true
+				*list = append(*list, v)
+			}
+		}
+	}
+	for _, cfg := range configs {
+		add(&conf.Servers, cfg.DNS.Ordered())
+		add(&conf.Search, cfg.Search)
+		add(&conf.Options, cfg.Options)
+	}
+	return conf
+}
+
+// renderResolvConf replaces the nameserver lines of an existing resolv.conf,
+// and its search and options lines unless conf leaves them nil, keeping
+// comments and any other directives in place
+func renderResolvConf(existing string, conf models.ResolvConf) string {
+	var kept []string
+	keepSearch := conf.Search == nil
+	keepOptions := conf.Options == nil
+	for _, line := range strings.Split(strings.TrimRight(existing, "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			switch fields[0] {
+			case "nameserver":
+				continue
+			case "search", "domain":
+				if !keepSearch {
+					continue
+				}
+			case "options":
+				if !keepOptions {
+					continue
+				}
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	var b strings.Builder
+	for _, line := range kept {
+		if line == "" && b.Len() == 0 {
+			continue
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(conf.Search) > 0 {
+		b.WriteString("search " + strings.Join(conf.Search, " ") + "\n")
+	}
+	if len(conf.Options) > 0 {
+		b.WriteString("options " + strings.Join(conf.Options, " ") + "\n")
+	}
+	for _, server := range conf.Servers {
+		b.WriteString("nameserver " + server + "\n")
+	}
+	return b.String()
+}
+
 // ResetToAutomatic resets the DNS configuration for the specified interfaces to automatic (DHCP)
 func (w *ConfigWriter) ResetToAutomatic(ctx context.Context, backend models.Backend, interfaces []string) error {
 	switch backend {
 	case models.BackendNetworkManager:
 		return w.resetNetworkManager(ctx, interfaces)
 	case models.BackendSystemdResolved:
+		if w.root.Offline() {
+			return removeFile(w.root.Path(resolvedDropInPath))
+		}
 		return w.resetSystemdResolved(ctx, interfaces)
+	case models.BackendNetplan:
+		return w.resetNetplan(ctx)
 	default:
 		return fmt.Errorf("unsupported backend for reset: %s", backend)
 	}
 }
 
 func (w *ConfigWriter) resetNetworkManager(ctx context.Context, interfaces []string) error {
-	for _, iface := range interfaces {
-		connName, err := w.getNMConnection(ctx, iface)
-		if err != nil {
-			return fmt.Errorf("failed to get connection for %s: %w", iface, err)
-		}
+	no, priority := false, int32(0)
+	reset := NMDNSUpdate{
+		IPv4DNS:        []string{},
+		IPv6DNS:        []string{},
+		IPv4IgnoreAuto: &no,
+		IPv6IgnoreAuto: &no,
+		Search:         []string{},
+		Options:        []string{},
+		IPv4Priority:   &priority,
+		IPv6Priority:   &priority,
+	}
 
-		// Reset IPv4
-		cmd := exec.CommandContext(ctx, "nmcli", "connection", "modify", connName, "ipv4.dns", "", "ipv4.ignore-auto-dns", "no")
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to reset IPv4 DNS for %s: %s: %w", iface, strings.TrimSpace(string(output)), err)
+	for _, iface := range interfaces {
+		// Without the daemon nothing is active and status lists the profiles by name
+		profile := NMProfile{Name: iface}
+		if !usesKeyfiles(w.nm) {
+			var err error
+			profile, err = w.nm.DeviceProfile(ctx, iface)
+			if err != nil {
+				return fmt.Errorf("failed to get connection for %s: %w", iface, err)
+			}
 		}
 
-		// Reset IPv6
-		cmd = exec.CommandContext(ctx, "nmcli", "connection", "modify", connName, "ipv6.dns", "", "ipv6.ignore-auto-dns", "no")
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to reset IPv6 DNS for %s: %s: %w", iface, strings.TrimSpace(string(output)), err)
+		if err := w.nm.UpdateDNS(ctx, profile, reset); err != nil {
+			return fmt.Errorf("failed to reset DNS for %s: %w", iface, err)
 		}
 
-		// Reapply
-		cmd = exec.CommandContext(ctx, "nmcli", "device", "reapply", iface)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to reapply configuration on %s: %s: %w", iface, strings.TrimSpace(string(output)), err)
+		if err := w.nm.Reapply(ctx, iface); err != nil {
+			return fmt.Errorf("failed to reapply configuration on %s: %w", iface, err)
 		}
 	}
 	return nil
@@ -157,9 +399,8 @@ func (w *ConfigWriter) resetNetworkManager(ctx context.Context, interfaces []str
 func (w *ConfigWriter) resetSystemdResolved(ctx context.Context, interfaces []string) error {
 	for _, iface := range interfaces {
 		// resolvectl revert <interface> resets interface-specific DNS settings
-		cmd := exec.CommandContext(ctx, "resolvectl", "revert", iface)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to revert DNS for %s: %s: %w", iface, strings.TrimSpace(string(output)), err)
+		if _, err := w.runner.Run(ctx, "resolvectl", "revert", iface); err != nil {
+			return fmt.Errorf("failed to revert DNS for %s: %s: %w", iface, commandStderr(err), err)
 		}
 	}
 	return nil
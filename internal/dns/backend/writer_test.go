@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderResolvConf(t *testing.T) {
+	existing := `# Static resolver configuration
+nameserver 192.168.1.1
+search home.lan
+options edns0
+sortlist 130.155.160.0/255.255.240.0
+`
+
+	t.Run("replaces servers and keeps unrelated lines", func(t *testing.T) {
+		configs := []models.DNSConfig{{DNS: models.DNSServer{IPv4: []string{"1.1.1.1"}, IPv6: []string{"2606:4700:4700::1111"}}}}
+
+		got := renderResolvConf(existing, mergeResolvConf(configs))
+		assert.Equal(t, `# Static resolver configuration
+search home.lan
+options edns0
+sortlist 130.155.160.0/255.255.240.0
+nameserver 1.1.1.1
+nameserver 2606:4700:4700::1111
+`, got)
+	})
+
+	t.Run("lists IPv6 servers first when preferred", func(t *testing.T) {
+		configs := []models.DNSConfig{{DNS: models.DNSServer{IPv4: []string{"1.1.1.1"}, IPv6: []string{"2606:4700:4700::1111"}, PreferIPv6: true}}}
+
+		got := renderResolvConf("", mergeResolvConf(configs))
+		assert.Equal(t, "nameserver 2606:4700:4700::1111\nnameserver 1.1.1.1\n", got)
+	})
+
+	t.Run("replaces search and options when given", func(t *testing.T) {
+		configs := []models.DNSConfig{
+			{DNS: models.DNSServer{IPv4: []string{"10.0.0.53"}}, Search: []string{"corp.example"}, Options: []string{"ndots:5"}},
+			{DNS: models.DNSServer{IPv4: []string{"10.0.0.53"}}, Search: []string{"corp.example"}, Options: []string{"ndots:5"}},
+		}
+
+		got := renderResolvConf(existing, mergeResolvConf(configs))
+		assert.Equal(t, `# Static resolver configuration
+sortlist 130.155.160.0/255.255.240.0
+search corp.example
+options ndots:5
+nameserver 10.0.0.53
+`, got)
+	})
+}
+
+func TestConfigWriter_RestoreResolvConf(t *testing.T) {
+	original := `# Static resolver configuration
+nameserver 192.168.1.1
+nameserver fd00::1
+nameserver 192.168.1.2
+sortlist 130.155.160.0/255.255.240.0
+`
+	root := offlineRoot(t, map[string]string{"etc/resolv.conf": original})
+	reader := NewConfigReader(nil, nil, nil, root)
+	writer := NewConfigWriter(nil, nil, nil, root)
+
+	previous, err := reader.ReadResolvConf()
+	require.NoError(t, err)
+	assert.Equal(t, models.ResolvConf{Servers: []string{"192.168.1.1", "fd00::1", "192.168.1.2"}}, previous)
+
+	require.NoError(t, writer.Apply(context.Background(), models.BackendResolvConf, []models.DNSConfig{{
+		DNS:     models.DNSServer{IPv4: []string{"10.0.0.53"}},
+		Search:  []string{"corp.example"},
+		Options: []string{"ndots:2"},
+	}}))
+
+	// Servers come back in their order, and the added search and options go
+	require.NoError(t, writer.RestoreResolvConf(previous))
+	data, err := os.ReadFile(root.Path(resolvConfPath))
+	require.NoError(t, err)
+	assert.Equal(t, `# Static resolver configuration
+sortlist 130.155.160.0/255.255.240.0
+nameserver 192.168.1.1
+nameserver fd00::1
+nameserver 192.168.1.2
+`, string(data))
+}
+
+func TestConfigWriter_NetworkManagerPreferIPv6(t *testing.T) {
+	ctx := context.Background()
+	root := offlineRoot(t, nil)
+	dir := root.Path(nmKeyfileDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	path := writeKeyfile(t, dir, "office.nmconnection", officeKeyfile)
+
+	writer := NewConfigWriter(nil, NewNMClient(nil, root), nil, root)
+	set := func(preferIPv6 bool) string {
+		require.NoError(t, writer.Apply(ctx, models.BackendNetworkManager, []models.DNSConfig{{
+			Interface: models.NetworkInterface{Connection: "Wired Office"},
+			DNS:       models.DNSServer{IPv4: []string{"1.1.1.1"}, IPv6: []string{"2606:4700:4700::1111"}, PreferIPv6: preferIPv6},
+		}}))
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	content := set(true)
+	assert.Contains(t, content, "dns-priority=100\n")
+	assert.Contains(t, content, "dns-priority=99\n")
+
+	// A later set without the preference drops it again
+	assert.NotContains(t, set(false), "dns-priority")
+}
+
+func TestCheckSupport(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend models.Backend
+		config  models.DNSConfig
+		wantErr bool
+	}{
+		{name: "resolved accepts link settings", backend: models.BackendSystemdResolved, config: models.DNSConfig{Link: models.LinkSettings{DNSSEC: "yes", LLMNR: "no"}}},
+		{name: "resolved refuses options", backend: models.BackendSystemdResolved, config: models.DNSConfig{Options: []string{"ndots:5"}}, wantErr: true},
+		{name: "NetworkManager accepts LLMNR and mDNS", backend: models.BackendNetworkManager, config: models.DNSConfig{Link: models.LinkSettings{LLMNR: "no", MulticastDNS: "resolve"}}},
+		{name: "NetworkManager refuses DNSSEC", backend: models.BackendNetworkManager, config: models.DNSConfig{Link: models.LinkSettings{DNSSEC: "yes"}}, wantErr: true},
+		{name: "resolv.conf refuses link settings", backend: models.BackendResolvConf, config: models.DNSConfig{Link: models.LinkSettings{MulticastDNS: "yes"}}, wantErr: true},
+		{name: "resolv.conf accepts options", backend: models.BackendResolvConf, config: models.DNSConfig{Options: []string{"rotate"}}},
+		{name: "resolved accepts port, interface and server name", backend: models.BackendSystemdResolved, config: models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"127.0.0.1:5353", "9.9.9.9#dns.quad9.net"}, IPv6: []string{"fe80::1%eth0"}}}},
+		{name: "NetworkManager refuses port", backend: models.BackendNetworkManager, config: models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"127.0.0.1:5353"}}}, wantErr: true},
+		{name: "NetworkManager refuses server name", backend: models.BackendNetworkManager, config: models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"9.9.9.9#dns.quad9.net"}}}, wantErr: true},
+		{name: "NetworkManager accepts plain addresses", backend: models.BackendNetworkManager, config: models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"9.9.9.9"}, IPv6: []string{"2620:fe::fe"}}}},
+		{name: "resolv.conf accepts link-local interface", backend: models.BackendResolvConf, config: models.DNSConfig{DNS: models.DNSServer{IPv6: []string{"fe80::1%eth0"}}}},
+		{name: "resolv.conf refuses port", backend: models.BackendResolvConf, config: models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"127.0.0.1:5353"}}}, wantErr: true},
+		{name: "resolv.conf refuses server name", backend: models.BackendResolvConf, config: models.DNSConfig{DNS: models.DNSServer{IPv6: []string{"[2620:fe::fe]:853#dns.quad9.net"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSupport(tt.backend, []models.DNSConfig{tt.config})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnsupported)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigWriter_NetworkManagerExcludedFamily(t *testing.T) {
+	ctx := context.Background()
+	root := offlineRoot(t, nil)
+	dir := root.Path(nmKeyfileDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	// The profile already has IPv6 servers from an earlier run
+	keyfile := strings.Replace(officeKeyfile, "[ipv6]\n", "[ipv6]\ndns=2606:4700:4700::1111;\nignore-auto-dns=true\n", 1)
+	writeKeyfile(t, dir, "office.nmconnection", keyfile)
+
+	nm := NewNMClient(nil, root)
+	writer := NewConfigWriter(nil, nm, nil, root)
+
+	// IPv6 left out by --family ipv4 is cleared
+	require.NoError(t, writer.Apply(ctx, models.BackendNetworkManager, []models.DNSConfig{{
+		Interface: models.NetworkInterface{Connection: "Wired Office"},
+		DNS:       models.DNSServer{IPv4: []string{"9.9.9.9"}, IPv6: []string{}},
+	}}))
+	dns, err := nm.ReadDNS(ctx, NMProfile{Name: "Wired Office"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9.9.9.9"}, dns.IPv4)
+	assert.Empty(t, dns.IPv6)
+
+	update := nmUpdateFor(models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"9.9.9.9"}, IPv6: []string{}}})
+	assert.NotNil(t, update.IPv6DNS)
+	require.NotNil(t, update.IPv6IgnoreAuto)
+	assert.True(t, *update.IPv6IgnoreAuto)
+
+	// A family not given at all is left alone
+	update = nmUpdateFor(models.DNSConfig{DNS: models.DNSServer{IPv4: []string{"9.9.9.9"}}})
+	assert.Nil(t, update.IPv6DNS)
+	assert.Nil(t, update.IPv6IgnoreAuto)
+}
@@ -0,0 +1,380 @@
+package discovery
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind classifies a network interface by what backs it
+type Kind string
+
+const (
+	// KindPhysical is a wired interface backed by real hardware
+	KindPhysical Kind = "physical"
+	// KindWireless is a Wi-Fi interface
+	KindWireless Kind = "wireless"
+	// KindBridge is a software bridge (docker0, virbr0, br-*)
+	KindBridge Kind = "bridge"
+	// KindVeth is one end of a virtual ethernet pair, typically a container
+	KindVeth Kind = "veth"
+	// KindTun is a tun/tap device (OpenVPN, tailscale0)
+	KindTun Kind = "tun"
+	// KindWireGuard is a WireGuard tunnel
+	KindWireGuard Kind = "wireguard"
+	// KindLoopback is the loopback interface
+	KindLoopback Kind = "loopback"
+	// KindVirtual is any other software device (vlan, bond, dummy, ...)
+	KindVirtual Kind = "virtual"
+)
+
+// arphrdLoopback and arphrdNone are the ARPHRD_* values of /sys/class/net/<if>/type
+const (
+	arphrdLoopback = "772"
+	arphrdNone     = "65534"
+)
+
+// rtfUp is the RTF_UP route flag
+const rtfUp = 0x1
+
+// ifaScopeGlobal and ifaFDADFailed are the /proc/net/if_inet6 global scope
+// and duplicate-address-detection failure flag
+const (
+	ifaScopeGlobal = "00"
+	ifaFDADFailed  = 0x08
+)
+
+// Interface describes a network interface as seen by the kernel
+type Interface struct {
+	Name         string
+	Kind         Kind
+	OperState    string // "up", "down", "unknown", ...
+	DefaultRoute bool   // carries an IPv4 or IPv6 default route
+}
+
+// IsUp reports whether the interface can carry traffic. Tunnels usually
+// report an "unknown" operstate while working.
+func (i Interface) IsUp() bool {
+	return i.OperState == "up" || i.OperState == "unknown"
+}
+
+// IsVirtual reports whether the interface is a bridge, veth pair or tunnel.
+// Their DNS belongs to containers and VPN clients, so they are not
+// configured unless asked for by name or pattern.
+func (i Interface) IsVirtual() bool {
+	switch i.Kind {
+	case KindBridge, KindVeth, KindTun, KindWireGuard:
+		return true
+	}
+	return false
+}
+
+// Discoverer reads interface information from sysfs and procfs, without
+// relying on NetworkManager or any other userspace daemon
+type Discoverer struct {
+	sysClassNet string
+	procNet     string
+}
+
+// NewDiscoverer creates a Discoverer for the running system
+func NewDiscoverer() *Discoverer {
+	return NewDiscovererAt("/")
+}
+
+// NewDiscovererAt creates a Discoverer that reads sys/ and proc/ below root
+func NewDiscovererAt(root string) *Discoverer {
+	return &Discoverer{
+		sysClassNet: filepath.Join(root, "sys", "class", "net"),
+		procNet:     filepath.Join(root, "proc", "net"),
+	}
+}
+
+// Interfaces lists every network interface, sorted by name
+func (d *Discoverer) Interfaces() ([]Interface, error) {
+	entries, err := os.ReadDir(d.sysClassNet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	defaults, err := d.DefaultRouteInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	hasDefault := make(map[string]bool, len(defaults))
+	for _, name := range defaults {
+		hasDefault[name] = true
+	}
+
+	var interfaces []Interface
+	for _, entry := range entries {
+		name := entry.Name()
+		interfaces = append(interfaces, Interface{
+			Name:         name,
+			Kind:         d.classify(name),
+			OperState:    d.readAttr(name, "operstate"),
+			DefaultRoute: hasDefault[name],
+		})
+	}
+
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Name < interfaces[j].Name })
+	return interfaces, nil
+}
+
+// DefaultRouteInterfaces returns the interfaces carrying an IPv4 or IPv6
+// default route, ordered by route metric
+func (d *Discoverer) DefaultRouteInterfaces() ([]string, error) {
+	type route struct {
+		iface  string
+		metric int64
+	}
+	var routes []route
+
+	v4, err := d.readProcNet("route")
+	if err != nil {
+		return nil, err
+	}
+	for i, fields := range v4 {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if i == 0 || len(fields) < 8 {
+			continue
+		}
+		flags, _ := strconv.ParseInt(fields[3], 16, 64)
+		if fields[1] == "00000000" && fields[7] == "00000000" && flags&rtfUp != 0 {
+			metric, _ := strconv.ParseInt(fields[6], 10, 64)
+			routes = append(routes, route{fields[0], metric})
+		}
+	}
+
+	v6, err := d.readProcNet("ipv6_route")
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range v6 {
+		// dest destlen src srclen nexthop metric refcnt use flags iface
+		if len(fields) < 10 || fields[9] == "lo" {
+			continue
+		}
+		flags, _ := strconv.ParseInt(fields[8], 16, 64)
+		if strings.Trim(fields[0], "0") == "" && fields[1] == "00" && flags&rtfUp != 0 {
+			metric, _ := strconv.ParseInt(fields[5], 16, 64)
+			routes = append(routes, route{fields[9], metric})
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].metric < routes[j].metric })
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, r := range routes {
+		if !seen[r.iface] {
+			seen[r.iface] = true
+			names = append(names, r.iface)
+		}
+	}
+	return names, nil
+}
+
+// HasGlobalIPv6 reports whether an interface has a globally routable IPv6
+// address. Link-local and unique local (fc00::/7) addresses do not count,
+// since neither reaches public resolvers. Without IPv6 support it reports false.
+func (d *Discoverer) HasGlobalIPv6(name string) (bool, error) {
+	lines, err := d.readProcNet("if_inet6")
+	if err != nil {
+		return false, err
+	}
+	for _, fields := range lines {
+		// address ifindex prefixlen scope flags name
+		if len(fields) < 6 || len(fields[0]) != 32 || fields[5] != name {
+			continue
+		}
+		flags, _ := strconv.ParseInt(fields[4], 16, 64)
+		if fields[3] != ifaScopeGlobal || flags&ifaFDADFailed != 0 {
+			continue
+		}
+		if prefix := strings.ToLower(fields[0][:2]); prefix == "fc" || prefix == "fd" {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Subnets returns the networks an interface reaches directly or through a
+// specific route: its IPv6 address prefixes and its IPv4 and IPv6 routes,
+// default routes excepted
+func (d *Discoverer) Subnets(name string) ([]netip.Prefix, error) {
+	var subnets []netip.Prefix
+
+	v4, err := d.readProcNet("route")
+	if err != nil {
+		return nil, err
+	}
+	for i, fields := range v4 {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if i == 0 || len(fields) < 8 || fields[0] != name || fields[7] == "00000000" {
+			continue
+		}
+		flags, _ := strconv.ParseInt(fields[3], 16, 64)
+		dest, okDest := procRouteAddr(fields[1])
+		mask, okMask := procRouteAddr(fields[7])
+		if !okDest || !okMask || flags&rtfUp == 0 {
+			continue
+		}
+		ones := 0
+		for _, b := range mask.AsSlice() {
+			ones += bits.OnesCount8(b)
+		}
+		subnets = append(subnets, netip.PrefixFrom(dest, ones).Masked())
+	}
+
+	v6, err := d.readProcNet("ipv6_route")
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range v6 {
+		// dest destlen src srclen nexthop metric refcnt use flags iface
+		if len(fields) < 10 || fields[9] != name || fields[1] == "00" {
+			continue
+		}
+		flags, _ := strconv.ParseInt(fields[8], 16, 64)
+		if prefix, ok := procIPv6Prefix(fields[0], fields[1]); ok && flags&rtfUp != 0 {
+			subnets = append(subnets, prefix)
+		}
+	}
+
+	addrs, err := d.readProcNet("if_inet6")
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range addrs {
+		// address ifindex prefixlen scope flags name
+		if len(fields) < 6 || fields[5] != name {
+			continue
+		}
+		if prefix, ok := procIPv6Prefix(fields[0], fields[2]); ok {
+			subnets = append(subnets, prefix)
+		}
+	}
+	return subnets, nil
+}
+
+// procRouteAddr decodes an IPv4 address of /proc/net/route, printed as a
+// number in host byte order
+func procRouteAddr(field string) (netip.Addr, bool) {
+	value, err := strconv.ParseUint(field, 16, 32)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	var b [4]byte
+	binary.NativeEndian.PutUint32(b[:], uint32(value))
+	return netip.AddrFrom4(b), true
+}
+
+// procIPv6Prefix decodes a 32-digit hex IPv6 address and a hex prefix length
+// of /proc/net/ipv6_route and /proc/net/if_inet6
+func procIPv6Prefix(addrField, lenField string) (netip.Prefix, bool) {
+	raw, err := hex.DecodeString(addrField)
+	if err != nil || len(raw) != 16 {
+		return netip.Prefix{}, false
+	}
+	length, err := strconv.ParseUint(lenField, 16, 8)
+	if err != nil || length > 128 {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(netip.AddrFrom16([16]byte(raw)), int(length)).Masked(), true
+}
+
+// classify determines the Kind of an interface from its sysfs attributes
+func (d *Discoverer) classify(name string) Kind {
+	if d.readAttr(name, "type") == arphrdLoopback {
+		return KindLoopback
+	}
+
+	switch d.devType(name) {
+	case "wlan":
+		return KindWireless
+	case "bridge":
+		return KindBridge
+	case "wireguard":
+		return KindWireGuard
+	case "":
+	default:
+		return KindVirtual // vlan, bond, macvlan, ...
+	}
+
+	switch {
+	case d.exists(name, "wireless"), d.exists(name, "phy80211"):
+		return KindWireless
+	case d.exists(name, "bridge"):
+		return KindBridge
+	case d.exists(name, "tun_flags"):
+		return KindTun
+	case d.exists(name, "device"):
+		return KindPhysical
+	case d.readAttr(name, "type") == arphrdNone:
+		return KindTun
+	case d.readAttr(name, "iflink") != d.readAttr(name, "ifindex"):
+		// Software devices linked to a peer: veth pairs
+		return KindVeth
+	default:
+		return KindVirtual
+	}
+}
+
+// devType returns the DEVTYPE from the interface's uevent file
+func (d *Discoverer) devType(name string) string {
+	data, err := os.ReadFile(filepath.Join(d.sysClassNet, name, "uevent"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "DEVTYPE="); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// readAttr reads a single-line sysfs attribute of an interface
+func (d *Discoverer) readAttr(name, attr string) string {
+	data, err := os.ReadFile(filepath.Join(d.sysClassNet, name, attr))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// exists reports whether an interface has the given sysfs entry
+func (d *Discoverer) exists(name, entry string) bool {
+	_, err := os.Stat(filepath.Join(d.sysClassNet, name, entry))
+	return err == nil
+}
+
+// readProcNet returns the whitespace-separated fields of each line of a
+// /proc/net file. A missing file (e.g. IPv6 disabled) yields no lines.
+func (d *Discoverer) readProcNet(file string) ([][]string, error) {
+	f, err := os.Open(filepath.Join(d.procNet, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing table: %w", err)
+	}
+	defer f.Close()
+
+	var lines [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, strings.Fields(scanner.Text()))
+	}
+	return lines, scanner.Err()
+}
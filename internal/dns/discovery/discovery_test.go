@@ -0,0 +1,203 @@
+package discovery
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRoot builds a minimal sysfs/procfs tree under a temporary directory
+type fakeRoot struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeRoot(t *testing.T) *fakeRoot {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sys", "class", "net"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "proc", "net"), 0755))
+	return &fakeRoot{t: t, root: root}
+}
+
+// iface creates an interface directory with the given attribute files.
+// Attributes with an empty value are created as directories.
+func (f *fakeRoot) iface(name string, attrs map[string]string) {
+	dir := filepath.Join(f.root, "sys", "class", "net", name)
+	require.NoError(f.t, os.MkdirAll(dir, 0755))
+	for attr, value := range attrs {
+		path := filepath.Join(dir, attr)
+		if value == "" {
+			require.NoError(f.t, os.MkdirAll(path, 0755))
+			continue
+		}
+		require.NoError(f.t, os.WriteFile(path, []byte(value+"\n"), 0644))
+	}
+}
+
+func (f *fakeRoot) procNet(file, content string) {
+	require.NoError(f.t, os.WriteFile(filepath.Join(f.root, "proc", "net", file), []byte(content), 0644))
+}
+
+const routeHeader = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+
+func TestDiscoverer_Classify(t *testing.T) {
+	f := newFakeRoot(t)
+	f.iface("lo", map[string]string{"type": "772", "operstate": "unknown"})
+	f.iface("eth0", map[string]string{"type": "1", "operstate": "up", "device": "", "ifindex": "2", "iflink": "2"})
+	f.iface("wlan0", map[string]string{"type": "1", "operstate": "up", "device": "", "uevent": "DEVTYPE=wlan\nINTERFACE=wlan0"})
+	f.iface("wlp3s0", map[string]string{"type": "1", "operstate": "down", "device": "", "phy80211": ""})
+	f.iface("docker0", map[string]string{"type": "1", "operstate": "down", "bridge": "", "uevent": "DEVTYPE=bridge"})
+	f.iface("veth12ab", map[string]string{"type": "1", "operstate": "up", "ifindex": "7", "iflink": "6"})
+	f.iface("tun0", map[string]string{"type": "65534", "operstate": "unknown", "tun_flags": "0x1001"})
+	f.iface("wg0", map[string]string{"type": "65534", "operstate": "unknown", "uevent": "DEVTYPE=wireguard"})
+	f.iface("eth0.10", map[string]string{"type": "1", "operstate": "up", "uevent": "DEVTYPE=vlan"})
+	f.iface("dummy0", map[string]string{"type": "1", "operstate": "down", "ifindex": "9", "iflink": "9"})
+
+	ifaces, err := NewDiscovererAt(f.root).Interfaces()
+	require.NoError(t, err)
+
+	kinds := make(map[string]Kind)
+	for _, iface := range ifaces {
+		kinds[iface.Name] = iface.Kind
+	}
+	assert.Equal(t, map[string]Kind{
+		"lo":       KindLoopback,
+		"eth0":     KindPhysical,
+		"wlan0":    KindWireless,
+		"wlp3s0":   KindWireless,
+		"docker0":  KindBridge,
+		"veth12ab": KindVeth,
+		"tun0":     KindTun,
+		"wg0":      KindWireGuard,
+		"eth0.10":  KindVirtual,
+		"dummy0":   KindVirtual,
+	}, kinds)
+}
+
+func TestDiscoverer_DefaultRouteInterfaces(t *testing.T) {
+	f := newFakeRoot(t)
+	f.procNet("route", routeHeader+
+		"eth0\t000010AC\t00000000\t0001\t0\t0\t100\t0000FFFF\t0\t0\t0\n"+
+		"wlan0\t00000000\t0100A8C0\t0003\t0\t0\t600\t00000000\t0\t0\t0\n"+
+		"eth0\t00000000\t010010AC\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"+
+		"docker0\t00000000\t00000000\t0000\t0\t0\t0\t00000000\t0\t0\t0\n")
+	f.procNet("ipv6_route",
+		"fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001 eth0\n"+
+			"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 wg0\n"+
+			"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200 lo\n")
+
+	names, err := NewDiscovererAt(f.root).DefaultRouteInterfaces()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eth0", "wlan0", "wg0"}, names)
+}
+
+func TestDiscoverer_DefaultRouteInterfaces_NoProcFiles(t *testing.T) {
+	f := newFakeRoot(t)
+
+	names, err := NewDiscovererAt(f.root).DefaultRouteInterfaces()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestDiscoverer_MissingSysfs(t *testing.T) {
+	_, err := NewDiscovererAt(t.TempDir()).Interfaces()
+	assert.Error(t, err)
+}
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"wl*", "wlan0", true},
+		{"wl*", "eth0", false},
+		{"veth?", "veth0", true},
+		{"veth?", "veth12", false},
+		{"en[op]*", "enp3s0", true},
+		{"/^(eth|en)[0-9a-z]+$/", "enp3s0", true},
+		{"/eth[0-9]/", "eth0", true},
+		{"/eth[0-9]/", "veth0", false}, // regexes are anchored to the whole name
+		{"docker0", "docker0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			p, err := ParsePattern(tt.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.Match(tt.name))
+			assert.Equal(t, tt.pattern, p.String())
+		})
+	}
+
+	for _, bad := range []string{"", "wl[", "/eth(/"} {
+		_, err := ParsePattern(bad)
+		assert.ErrorIs(t, err, ErrInvalidPattern, bad)
+	}
+
+	assert.True(t, IsPattern("wl*"))
+	assert.True(t, IsPattern("/eth/"))
+	assert.False(t, IsPattern("eth0"))
+	assert.False(t, IsPattern("br-123abc"))
+}
+
+func TestDiscoverer_Subnets(t *testing.T) {
+	f := newFakeRoot(t)
+	f.procNet("route", routeHeader+
+		"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"+
+		"eth0\t0000000A\t0101A8C0\t0003\t0\t0\t100\t000000FF\t0\t0\t0\n"+
+		"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"+
+		"wlan0\t0000A8C0\t00000000\t0001\t0\t0\t600\t0000FFFF\t0\t0\t0\n")
+	f.procNet("ipv6_route",
+		"fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001 eth0\n"+
+			"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 eth0\n")
+	f.procNet("if_inet6", "fd000000000000000000000000000002 04 40 00 80     eth0\n")
+
+	subnets, err := NewDiscovererAt(f.root).Subnets("eth0")
+	require.NoError(t, err)
+	// The default routes are left out
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.168.1.0/24"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fe80::/64"),
+		netip.MustParsePrefix("fd00::/64"),
+	}, subnets)
+
+	subnets, err = NewDiscovererAt(f.root).Subnets("tun0")
+	require.NoError(t, err)
+	assert.Empty(t, subnets)
+}
+
+func TestDiscoverer_HasGlobalIPv6(t *testing.T) {
+	f := newFakeRoot(t)
+	f.procNet("if_inet6", `00000000000000000000000000000001 01 80 10 80       lo
+fd000000000000000000000000000002 04 40 00 80     eth0
+fe8000000000000000fc00fffe000001 04 40 20 80     eth0
+20010db8000000000000000000000042 05 40 00 80    wlan0
+fe800000000000000000000000000042 05 40 20 80    wlan0
+20010db8000000000000000000000043 06 40 00 88     eth1
+`)
+	d := NewDiscovererAt(f.root)
+
+	for name, want := range map[string]bool{
+		"wlan0": true,  // global unicast
+		"eth0":  false, // unique local and link-local only
+		"eth1":  false, // duplicate address detection failed
+		"lo":    false,
+		"tun0":  false,
+	} {
+		got, err := d.HasGlobalIPv6(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+
+	t.Run("IPv6 disabled", func(t *testing.T) {
+		got, err := NewDiscovererAt(newFakeRoot(t).root).HasGlobalIPv6("eth0")
+		require.NoError(t, err)
+		assert.False(t, got)
+	})
+}
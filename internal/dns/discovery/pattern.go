@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidPattern is returned when an interface pattern cannot be parsed
+var ErrInvalidPattern = errors.New("invalid interface pattern")
+
+// Pattern matches interface names. Patterns are shell globs ("wl*",
+// "veth?", "en[po]*") unless wrapped in slashes, in which case the body is a
+// regular expression anchored to the whole name ("/^(eth|en)[0-9]+$/").
+type Pattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// IsPattern reports whether s is a glob or regular expression rather than a literal interface name
+func IsPattern(s string) bool {
+	return isRegex(s) || strings.ContainsAny(s, "*?[")
+}
+
+// ParsePattern parses a glob or /regex/ interface pattern
+func ParsePattern(s string) (Pattern, error) {
+	if s == "" {
+		return Pattern{}, fmt.Errorf("%w: pattern cannot be empty", ErrInvalidPattern)
+	}
+
+	if isRegex(s) {
+		re, err := regexp.Compile("^(?:" + s[1:len(s)-1] + ")$")
+		if err != nil {
+			return Pattern{}, fmt.Errorf("%w: %s: %v", ErrInvalidPattern, s, err)
+		}
+		return Pattern{raw: s, re: re}, nil
+	}
+
+	if _, err := path.Match(s, ""); err != nil {
+		return Pattern{}, fmt.Errorf("%w: %s: %v", ErrInvalidPattern, s, err)
+	}
+	return Pattern{raw: s}, nil
+}
+
+// ParsePatterns parses a list of patterns, skipping blank entries
+func ParsePatterns(list []string) ([]Pattern, error) {
+	var patterns []Pattern
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		p, err := ParsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// Match reports whether name matches the pattern
+func (p Pattern) Match(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	ok, _ := path.Match(p.raw, name)
+	return ok
+}
+
+// String returns the pattern as written
+func (p Pattern) String() string {
+	return p.raw
+}
+
+// isRegex reports whether s uses the /regex/ form
+func isRegex(s string) bool {
+	return len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/")
+}
@@ -0,0 +1,38 @@
+package models
+
+// ResolutionChain describes how the system resolves names: which daemon
+// owns /etc/resolv.conf, what NetworkManager does with DNS, who answers
+// the queries, and from that which backend cdns writes to
+type ResolutionChain struct {
+	// Backend is the backend cdns writes to, empty when none fits
+	Backend Backend `json:"backend"`
+	Reason  string  `json:"reason"`
+	// Override names the flag or configuration key that chose Backend,
+	// empty when it was detected
+	Override string `json:"override,omitempty"`
+	// Rejected lists the backends considered before Backend and why each
+	// was passed over
+	Rejected []Candidate `json:"rejected,omitempty"`
+
+	// ResolvConfTarget is where /etc/resolv.conf links to, empty when it
+	// is a regular file or missing
+	ResolvConfTarget string `json:"resolv_conf_target,omitempty"`
+	// ResolvConfOwner names the daemon writing /etc/resolv.conf, empty when
+	// nothing manages it
+	ResolvConfOwner string `json:"resolv_conf_owner,omitempty"`
+
+	// NMDNSMode is the dns= setting of a running NetworkManager: "default",
+	// "none", "systemd-resolved" or "dnsmasq"
+	NMDNSMode string `json:"nm_dns_mode,omitempty"`
+	// NMRcManager is the rc-manager= setting of a running NetworkManager
+	NMRcManager string `json:"nm_rc_manager,omitempty"`
+
+	// AnsweredBy names what answers the queries of applications
+	AnsweredBy string `json:"answered_by,omitempty"`
+}
+
+// Candidate is a backend passed over during detection
+type Candidate struct {
+	Backend Backend `json:"backend"`
+	Reason  string  `json:"reason"`
+}
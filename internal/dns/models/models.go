@@ -1,5 +1,10 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Backend represents the DNS management system type
 type Backend string
 
@@ -14,21 +19,106 @@ const (
 	BackendNetplan Backend = "netplan"
 )
 
+// Backends lists every supported backend, in order of detection
+var Backends = []Backend{BackendNetworkManager, BackendSystemdResolved, BackendResolvConf, BackendNetplan}
+
+// backendAliases maps the lowercase names accepted for a backend
+var backendAliases = map[string]Backend{
+	"networkmanager":   BackendNetworkManager,
+	"nm":               BackendNetworkManager,
+	"systemd-resolved": BackendSystemdResolved,
+	"resolved":         BackendSystemdResolved,
+	"resolv.conf":      BackendResolvConf,
+	"netplan":          BackendNetplan,
+}
+
+// ParseBackend returns the backend called name, ignoring case. Short forms
+// such as "nm" and "resolved" are accepted too.
+func ParseBackend(name string) (Backend, error) {
+	if backend, ok := backendAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return backend, nil
+	}
+	return "", fmt.Errorf("unknown backend %q (expected networkmanager, systemd-resolved, resolv.conf or netplan)", name)
+}
+
 // DNSServer holds DNS server addresses
 type DNSServer struct {
+	// IPv4 and IPv6 list the servers of each family. nil leaves a family as
+	// it is where the backend keeps the families apart; an empty list
+	// removes its servers.
 	IPv4        []string
 	IPv6        []string
 	Description string
+	// DNSSEC reports whether the resolvers validate DNSSEC
+	DNSSEC bool
+	// PreferIPv6 lists the IPv6 servers ahead of the IPv4 ones
+	PreferIPv6 bool
+}
+
+// Ordered returns all server addresses in order of preference
+func (d DNSServer) Ordered() []string {
+	first, second := d.IPv4, d.IPv6
+	if d.PreferIPv6 {
+		first, second = second, first
+	}
+	return append(append([]string{}, first...), second...)
+}
+
+// LinkSettings holds per-link resolver protocol settings, using
+// systemd-resolved values. Empty fields are left unchanged.
+type LinkSettings struct {
+	DNSSEC       string `json:"dnssec,omitempty"` // "yes", "allow-downgrade" or "no"
+	LLMNR        string `json:"llmnr,omitempty"`  // "yes", "resolve" or "no"
+	MulticastDNS string `json:"mdns,omitempty"`   // "yes", "resolve" or "no"
+}
+
+// IsZero reports whether no link setting is set
+func (l LinkSettings) IsZero() bool {
+	return l == LinkSettings{}
 }
 
 // NetworkInterface represents a network interface configuration
 type NetworkInterface struct {
 	Name    string
 	Backend Backend
+	// Connection names a NetworkManager connection profile to modify directly.
+	// Name may be empty when the profile is not active on any device.
+	Connection string
+	// ConnectionUUID identifies the profile unambiguously; when set it is
+	// used instead of Connection to address the profile
+	ConnectionUUID string
+}
+
+// Label returns a human-readable name for the interface or connection profile
+func (n NetworkInterface) Label() string {
+	switch {
+	case n.Connection != "" && n.Name != "":
+		return n.Name + " (" + n.Connection + ")"
+	case n.Connection != "":
+		return n.Connection + " (profile)"
+	case n.ConnectionUUID != "":
+		return n.ConnectionUUID + " (profile)"
+	default:
+		return n.Name
+	}
 }
 
 // DNSConfig represents a complete DNS configuration for an interface
 type DNSConfig struct {
 	Interface NetworkInterface
 	DNS       DNSServer
+	// Search lists the search domains, in order of preference
+	Search []string
+	// Options holds resolver options in resolv.conf form (e.g. "ndots:5", "rotate")
+	Options []string
+	// Link holds the DNSSEC, LLMNR and MulticastDNS settings for the interface
+	Link LinkSettings
+}
+
+// ResolvConf holds the resolver lines of a resolv.conf, servers in the order
+// the file lists them
+type ResolvConf struct {
+	Servers []string `json:"servers,omitempty"`
+	Search  []string `json:"search,omitempty"`
+	Options []string `json:"options,omitempty"`
 }
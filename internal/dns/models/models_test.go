@@ -111,6 +111,37 @@ func TestBackend(t *testing.T) {
 	}
 }
 
+func TestParseBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    models.Backend
+		wantErr bool
+	}{
+		{name: "networkmanager", want: models.BackendNetworkManager},
+		{name: "NetworkManager", want: models.BackendNetworkManager},
+		{name: "nm", want: models.BackendNetworkManager},
+		{name: "systemd-resolved", want: models.BackendSystemdResolved},
+		{name: "resolved", want: models.BackendSystemdResolved},
+		{name: " resolv.conf ", want: models.BackendResolvConf},
+		{name: "resolvconf", wantErr: true},
+		{name: "netplan", want: models.BackendNetplan},
+		{name: "", wantErr: true},
+		{name: "dnsmasq", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.ParseBackend(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBackend(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBackend(%q) = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestNetworkInterface(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -203,3 +234,18 @@ func TestDNSConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSServer_Ordered(t *testing.T) {
+	server := models.DNSServer{IPv4: []string{"1.1.1.1"}, IPv6: []string{"2606:4700:4700::1111"}}
+
+	got := server.Ordered()
+	if len(got) != 2 || got[0] != "1.1.1.1" || got[1] != "2606:4700:4700::1111" {
+		t.Errorf("expected IPv4 first, got %v", got)
+	}
+
+	server.PreferIPv6 = true
+	got = server.Ordered()
+	if len(got) != 2 || got[0] != "2606:4700:4700::1111" || got[1] != "1.1.1.1" {
+		t.Errorf("expected IPv6 first, got %v", got)
+	}
+}
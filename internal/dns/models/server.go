@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidServerAddress is returned when a DNS server address cannot be parsed
+var ErrInvalidServerAddress = errors.New("invalid DNS server address")
+
+// ServerAddress is a DNS server address in systemd-resolved syntax:
+// ADDRESS[:PORT][%IFNAME][#SERVER_NAME]. IPv6 addresses with a port are
+// written in brackets, e.g. [2620:fe::fe]:853#dns.quad9.net.
+type ServerAddress struct {
+	IP   netip.Addr // without zone
+	Port uint16     // 0 means the default port
+	Zone string     // interface the server is reached through, e.g. for fe80::/10
+	SNI  string     // TLS server name for DNS-over-TLS
+}
+
+// ParseServerAddress parses a DNS server address with optional port, zone and
+// server name. Besides the resolved syntax it accepts the zone inside the
+// brackets ([fe80::1%eth0]:53) as written by most other tools.
+func ParseServerAddress(s string) (ServerAddress, error) {
+	var addr ServerAddress
+	rest := strings.TrimSpace(s)
+	if rest == "" {
+		return addr, fmt.Errorf("%w: empty address", ErrInvalidServerAddress)
+	}
+
+	if host, sni, ok := strings.Cut(rest, "#"); ok {
+		if !validServerName(sni) {
+			return addr, fmt.Errorf("%w: %q: invalid server name %q", ErrInvalidServerAddress, s, sni)
+		}
+		rest, addr.SNI = host, sni
+	}
+
+	var host, port string
+	if strings.HasPrefix(rest, "[") {
+		inner, after, ok := strings.Cut(rest[1:], "]")
+		if !ok {
+			return addr, fmt.Errorf("%w: %q: missing ]", ErrInvalidServerAddress, s)
+		}
+		host = inner
+		if after != "" {
+			if !strings.HasPrefix(after, ":") {
+				return addr, fmt.Errorf("%w: %q: expected :port after ]", ErrInvalidServerAddress, s)
+			}
+			port = after[1:]
+			if p, zone, ok := strings.Cut(port, "%"); ok {
+				port = p
+				host += "%" + zone
+			}
+		}
+	} else {
+		// A single colon separates an IPv4 address from its port; more
+		// colons belong to an IPv6 address written without brackets
+		h, zone, hasZone := strings.Cut(rest, "%")
+		if strings.Count(h, ":") == 1 {
+			h, port, _ = strings.Cut(h, ":")
+		}
+		host = h
+		if hasZone {
+			host += "%" + zone
+		}
+	}
+
+	if h, zone, ok := strings.Cut(host, "%"); ok {
+		if !validZone(zone) {
+			return addr, fmt.Errorf("%w: %q: invalid interface %q", ErrInvalidServerAddress, s, zone)
+		}
+		host, addr.Zone = h, zone
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || ip.Zone() != "" {
+		return addr, fmt.Errorf("%w: %q", ErrInvalidServerAddress, s)
+	}
+	addr.IP = ip.Unmap()
+
+	if port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || n == 0 {
+			return addr, fmt.Errorf("%w: %q: invalid port %q", ErrInvalidServerAddress, s, port)
+		}
+		addr.Port = uint16(n)
+	}
+
+	return addr, nil
+}
+
+// String formats the address in systemd-resolved syntax
+func (a ServerAddress) String() string {
+	var b strings.Builder
+	if a.Port != 0 && a.IP.Is6() {
+		b.WriteString("[" + a.IP.String() + "]")
+	} else {
+		b.WriteString(a.IP.String())
+	}
+	if a.Port != 0 {
+		b.WriteString(":" + strconv.Itoa(int(a.Port)))
+	}
+	if a.Zone != "" {
+		b.WriteString("%" + a.Zone)
+	}
+	if a.SNI != "" {
+		b.WriteString("#" + a.SNI)
+	}
+	return b.String()
+}
+
+// Is6 reports whether the server is an IPv6 address
+func (a ServerAddress) Is6() bool {
+	return a.IP.Is6()
+}
+
+// IsPlain reports whether the address is a bare IP without port, zone or server name
+func (a ServerAddress) IsPlain() bool {
+	return a.Port == 0 && a.Zone == "" && a.SNI == ""
+}
+
+// IsIPv6Server reports whether a server address string is IPv6. Strings that
+// do not parse are classified by the presence of a colon, as before.
+func IsIPv6Server(s string) bool {
+	if addr, err := ParseServerAddress(s); err == nil {
+		return addr.Is6()
+	}
+	return strings.Contains(s, ":")
+}
+
+// validZone checks an interface name used as an address zone
+func validZone(zone string) bool {
+	if zone == "" || len(zone) > 15 {
+		return false
+	}
+	return !strings.ContainsAny(zone, "/:#[] \t")
+}
+
+// validServerName checks a DNS-over-TLS server name
+func validServerName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
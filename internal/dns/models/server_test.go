@@ -0,0 +1,77 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+func TestParseServerAddress(t *testing.T) {
+	tests := []struct {
+		input  string
+		ip     string
+		port   uint16
+		zone   string
+		sni    string
+		is6    bool
+		output string
+	}{
+		{input: "1.1.1.1", ip: "1.1.1.1", output: "1.1.1.1"},
+		{input: "127.0.0.1:5353", ip: "127.0.0.1", port: 5353, output: "127.0.0.1:5353"},
+		{input: "9.9.9.9#dns.quad9.net", ip: "9.9.9.9", sni: "dns.quad9.net", output: "9.9.9.9#dns.quad9.net"},
+		{input: "1.1.1.1:853#cloudflare-dns.com", ip: "1.1.1.1", port: 853, sni: "cloudflare-dns.com", output: "1.1.1.1:853#cloudflare-dns.com"},
+		{input: "2620:fe::fe", ip: "2620:fe::fe", is6: true, output: "2620:fe::fe"},
+		{input: "fe80::1%eth0", ip: "fe80::1", zone: "eth0", is6: true, output: "fe80::1%eth0"},
+		{input: "[2620:fe::fe]:853#dns.quad9.net", ip: "2620:fe::fe", port: 853, sni: "dns.quad9.net", is6: true, output: "[2620:fe::fe]:853#dns.quad9.net"},
+		{input: "[fe80::1]:53%eth0", ip: "fe80::1", port: 53, zone: "eth0", is6: true, output: "[fe80::1]:53%eth0"},
+		{input: "[fe80::1%eth0]:53", ip: "fe80::1", port: 53, zone: "eth0", is6: true, output: "[fe80::1]:53%eth0"},
+		{input: "[::1]", ip: "::1", is6: true, output: "::1"},
+		{input: "::ffff:1.2.3.4", ip: "1.2.3.4", output: "1.2.3.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			addr, err := models.ParseServerAddress(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.ip, addr.IP.String())
+			assert.Equal(t, tt.port, addr.Port)
+			assert.Equal(t, tt.zone, addr.Zone)
+			assert.Equal(t, tt.sni, addr.SNI)
+			assert.Equal(t, tt.is6, addr.Is6())
+			assert.Equal(t, tt.output, addr.String())
+			assert.Equal(t, tt.port == 0 && tt.zone == "" && tt.sni == "", addr.IsPlain())
+		})
+	}
+}
+
+func TestParseServerAddress_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"google.com",
+		"1.1.1.1:0",
+		"1.1.1.1:70000",
+		"1.1.1.1:dns",
+		"[2620:fe::fe",
+		"[2620:fe::fe]853",
+		"9.9.9.9#",
+		"9.9.9.9#bad name",
+		"fe80::1%",
+		"fe80::1%eth/0",
+		"2620:fe::fe:853x",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := models.ParseServerAddress(input)
+			assert.ErrorIs(t, err, models.ErrInvalidServerAddress)
+		})
+	}
+}
+
+func TestIsIPv6Server(t *testing.T) {
+	assert.False(t, models.IsIPv6Server("1.1.1.1:5353#dns.example"))
+	assert.True(t, models.IsIPv6Server("[2620:fe::fe]:853"))
+	assert.True(t, models.IsIPv6Server("fe80::1%eth0"))
+	assert.False(t, models.IsIPv6Server("8.8.8.8"))
+}
@@ -8,6 +8,7 @@ func Cloudflare() models.DNSServer {
 		IPv4:        []string{"1.1.1.1", "1.0.0.1"},
 		IPv6:        []string{"2606:4700:4700::1111", "2606:4700:4700::1001"},
 		Description: "Fast & privacy-focused",
+		DNSSEC:      true,
 	}
 }
 
@@ -17,6 +18,7 @@ func Google() models.DNSServer {
 		IPv4:        []string{"8.8.8.8", "8.8.4.4"},
 		IPv6:        []string{"2001:4860:4860::8888", "2001:4860:4860::8844"},
 		Description: "Reliable & widely used",
+		DNSSEC:      true,
 	}
 }
 
@@ -26,6 +28,7 @@ func Quad9() models.DNSServer {
 		IPv4:        []string{"9.9.9.9", "149.112.112.112"},
 		IPv6:        []string{"2620:fe::fe", "2620:fe::9"},
 		Description: "Security-focused with threat intelligence",
+		DNSSEC:      true,
 	}
 }
 
@@ -0,0 +1,110 @@
+// Package probe checks whether DNS resolvers answer queries
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// Timeout is the default wait for a resolver to answer
+const Timeout = 2 * time.Second
+
+// UDPProber checks resolvers by asking them for the root name servers
+type UDPProber struct {
+	timeout time.Duration
+}
+
+// NewUDPProber creates a prober waiting up to timeout for each answer
+func NewUDPProber(timeout time.Duration) UDPProber {
+	return UDPProber{timeout: timeout}
+}
+
+// Skipped reports whether Probe leaves a server alone: DNS-over-TLS servers
+// do not answer plain queries
+func Skipped(server string) bool {
+	addr, err := models.ParseServerAddress(server)
+	return err == nil && (addr.SNI != "" || addr.Port == 853)
+}
+
+// Probe sends a query to server and waits for an answer. NXDOMAIN counts as
+// an answer; a refusal or server failure does not. DNS-over-TLS servers do
+// not answer plain queries and are not probed.
+func (p UDPProber) Probe(ctx context.Context, server string) error {
+	addr, err := models.ParseServerAddress(server)
+	if err != nil {
+		return err
+	}
+	if Skipped(server) {
+		return nil
+	}
+	port := addr.Port
+	if port == 0 {
+		port = 53
+	}
+	ip := addr.IP
+	if addr.Zone != "" {
+		ip = ip.WithZone(addr.Zone)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", netip.AddrPortFrom(ip, port).String())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	id := uint16(rand.Uint32())
+	if _, err := conn.Write(rootQuery(id)); err != nil {
+		return err
+	}
+	buf := make([]byte, 512)
+	for {
+		n, err := conn.Read(buf)
+		var netErr net.Error
+		switch {
+		case errors.As(err, &netErr) && netErr.Timeout():
+			return fmt.Errorf("no answer within %s", p.timeout)
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return errors.New("connection refused")
+		case err != nil:
+			return err
+		}
+		// Skip anything but the response to our query
+		if n < 12 || binary.BigEndian.Uint16(buf) != id || buf[2]&0x80 == 0 {
+			continue
+		}
+		switch rcode := buf[3] & 0x0f; rcode {
+		case 0, 3: // NOERROR, NXDOMAIN
+			return nil
+		case 5:
+			return errors.New("query refused")
+		default:
+			return fmt.Errorf("server failure (rcode %d)", rcode)
+		}
+	}
+}
+
+// rootQuery builds a recursive query for the NS records of the root zone
+func rootQuery(id uint16) []byte {
+	q := make([]byte, 17)
+	binary.BigEndian.PutUint16(q[0:], id)
+	q[2] = 0x01                           // recursion desired
+	binary.BigEndian.PutUint16(q[4:], 1)  // one question
+	binary.BigEndian.PutUint16(q[13:], 2) // q[12] is the root name; type NS
+	binary.BigEndian.PutUint16(q[15:], 1) // class IN
+	return q
+}
@@ -0,0 +1,58 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startResolver answers every query with rcode until the test ends
+func startResolver(t *testing.T, rcode byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := append([]byte{}, buf[:n]...)
+			resp[2] |= 0x80 // response
+			resp[3] = 0x80 | rcode
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUDPProber(t *testing.T) {
+	ctx := context.Background()
+	prober := NewUDPProber(500 * time.Millisecond)
+
+	assert.NoError(t, prober.Probe(ctx, startResolver(t, 0)))
+	assert.NoError(t, prober.Probe(ctx, startResolver(t, 3)), "NXDOMAIN is an answer")
+	assert.EqualError(t, prober.Probe(ctx, startResolver(t, 5)), "query refused")
+	assert.EqualError(t, prober.Probe(ctx, startResolver(t, 2)), "server failure (rcode 2)")
+
+	// A closed port
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := conn.LocalAddr().String()
+	conn.Close()
+	assert.Error(t, prober.Probe(ctx, closed))
+
+	// DNS-over-TLS servers are not probed
+	assert.NoError(t, prober.Probe(ctx, "[2001:db8::1]:853#dns.example"))
+	assert.True(t, Skipped("[2001:db8::1]:853#dns.example"))
+	assert.True(t, Skipped("9.9.9.9:853"))
+	assert.False(t, Skipped("9.9.9.9"))
+	assert.Error(t, prober.Probe(ctx, "not-an-address"))
+}
@@ -0,0 +1,101 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// DefaultPath is where cdns records the changes it made to the system
+const DefaultPath = "/var/lib/cdns/state.json"
+
+// Snapshot records the configuration cdns applied and the settings it replaced,
+// so that status can report it and reset can roll it back
+type Snapshot struct {
+	Backend    models.Backend             `json:"backend"`
+	Scope      string                     `json:"scope,omitempty"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+	Interfaces map[string]InterfaceRecord `json:"interfaces"`
+	// PreviousResolvConf holds the resolver lines of resolv.conf before cdns
+	// first rewrote it
+	PreviousResolvConf *models.ResolvConf `json:"previous_resolv_conf,omitempty"`
+}
+
+// InterfaceRecord describes what cdns changed on a single interface
+type InterfaceRecord struct {
+	Servers []string `json:"servers,omitempty"`
+	// Link holds the link settings cdns applied
+	Link models.LinkSettings `json:"link"`
+	// PreviousLink holds the link settings in place before cdns changed them
+	PreviousLink models.LinkSettings `json:"previous_link"`
+}
+
+// Store persists snapshots as JSON on disk
+type Store struct {
+	path string
+}
+
+// NewStore creates a Store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the location of the state file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load reads the snapshot from disk. A missing state file yields an empty snapshot.
+func (s *Store) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Snapshot{Interfaces: map[string]InterfaceRecord{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", s.path, err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", s.path, err)
+	}
+	if snap.Interfaces == nil {
+		snap.Interfaces = map[string]InterfaceRecord{}
+	}
+	return &snap, nil
+}
+
+// Save writes the snapshot to disk atomically
+func (s *Store) Save(snap *Snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the state file. Clearing a missing state file is not an error.
+func (s *Store) Clear() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove state file %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package state_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store := state.NewStore(filepath.Join(t.TempDir(), "cdns", "state.json"))
+
+	t.Run("missing file loads empty snapshot", func(t *testing.T) {
+		snap, err := store.Load()
+		require.NoError(t, err)
+		assert.Empty(t, snap.Interfaces)
+	})
+
+	t.Run("save and load round trip", func(t *testing.T) {
+		snap := &state.Snapshot{
+			Backend:   models.BackendSystemdResolved,
+			UpdatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+			Interfaces: map[string]state.InterfaceRecord{
+				"eth0": {
+					Servers:      []string{"1.1.1.1"},
+					Link:         models.LinkSettings{DNSSEC: "yes"},
+					PreviousLink: models.LinkSettings{DNSSEC: "allow-downgrade"},
+				},
+			},
+		}
+		require.NoError(t, store.Save(snap))
+
+		loaded, err := store.Load()
+		require.NoError(t, err)
+		assert.Equal(t, snap, loaded)
+	})
+
+	t.Run("clear removes the file", func(t *testing.T) {
+		require.NoError(t, store.Clear())
+		require.NoError(t, store.Clear())
+
+		snap, err := store.Load()
+		require.NoError(t, err)
+		assert.Empty(t, snap.Interfaces)
+	})
+}
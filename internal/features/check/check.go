@@ -0,0 +1,455 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/presets"
+	"gitlab.com/junevm/cdns/internal/features/status"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+)
+
+// Module provides the check feature as an Fx module
+var Module = fx.Module("check",
+	fx.Provide(NewService),
+	fx.Provide(NewCommand),
+	fx.Invoke(RegisterCommand),
+)
+
+// State is the outcome of a check, named as monitoring plugins name it
+type State string
+
+const (
+	// StateOK means every interface uses the expected servers
+	StateOK State = "OK"
+	// StateCritical means an interface drifted from the expected servers
+	StateCritical State = "CRITICAL"
+	// StateUnknown means the configuration could not be checked
+	StateUnknown State = "UNKNOWN"
+)
+
+// Exit codes of 'cdns check', following the Nagios plugin guidelines
+const (
+	ExitOK       = 0
+	ExitCritical = 2
+	ExitUnknown  = 3
+)
+
+// ExitCode returns the exit status reporting the state
+func (s State) ExitCode() int {
+	switch s {
+	case StateOK:
+		return ExitOK
+	case StateCritical:
+		return ExitCritical
+	default:
+		return ExitUnknown
+	}
+}
+
+// Expectation describes the DNS configuration a system should have
+type Expectation struct {
+	// Preset names a built-in or custom preset
+	Preset string
+	// Servers lists the expected servers, instead of a preset
+	Servers []string
+	// Interfaces limits the check to interfaces matching these names or
+	// patterns
+	Interfaces []string
+}
+
+// InterfaceResult is the outcome of the check on one interface
+type InterfaceResult struct {
+	Name    string                   `json:"name"`
+	Servers []status.EffectiveServer `json:"servers"`
+	// Match tells how closely the servers match the expected ones
+	Match string `json:"match"`
+	OK    bool   `json:"ok"`
+	// Missing lists the expected servers not in use
+	Missing []string `json:"missing,omitempty"`
+	// Unexpected lists the servers in use that were not expected
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+// Result is the outcome of a check
+type Result struct {
+	State    State          `json:"state"`
+	ExitCode int            `json:"exit_code"`
+	Summary  string         `json:"summary"`
+	Backend  models.Backend `json:"backend,omitempty"`
+	Preset   string         `json:"preset,omitempty"`
+	Expected []string       `json:"expected,omitempty"`
+	// Interfaces lists the interfaces checked
+	Interfaces []InterfaceResult `json:"interfaces"`
+}
+
+// Drifted returns the interfaces not using the expected servers
+func (r *Result) Drifted() []InterfaceResult {
+	var drifted []InterfaceResult
+	for _, iface := range r.Interfaces {
+		if !iface.OK {
+			drifted = append(drifted, iface)
+		}
+	}
+	return drifted
+}
+
+// StatusReader reads the live DNS configuration
+type StatusReader interface {
+	GetStatus(ctx context.Context) (*status.StatusInfo, error)
+}
+
+// Service handles the business logic for check feature
+type Service struct {
+	config *config.Config
+	logger *slog.Logger
+	status StatusReader
+}
+
+// NewService creates a new check service
+func NewService(cfg *config.Config, logger *slog.Logger, statusService *status.Service) *Service {
+	return &Service{
+		config: cfg,
+		logger: logger,
+		status: statusService,
+	}
+}
+
+// Check compares the live DNS configuration with the expected one. Problems
+// keeping the check from running are reported as an unknown state.
+func (s *Service) Check(ctx context.Context, exp Expectation) *Result {
+	result := &Result{Preset: strings.ToLower(exp.Preset), Interfaces: []InterfaceResult{}}
+
+	expected, err := s.expectedServers(exp)
+	if err != nil {
+		return result.unknown(err)
+	}
+	result.Expected = expected
+
+	patterns, err := discovery.ParsePatterns(exp.Interfaces)
+	if err != nil {
+		return result.unknown(err)
+	}
+	exclude, err := discovery.ParsePatterns(s.config.DNS.ExcludeInterfaces)
+	if err != nil {
+		return result.unknown(fmt.Errorf("invalid dns.exclude_interfaces: %w", err))
+	}
+
+	info, err := s.status.GetStatus(ctx)
+	if err != nil {
+		return result.unknown(err)
+	}
+	result.Backend = info.Backend
+
+	for _, iface := range selectInterfaces(info, patterns, exclude) {
+		result.Interfaces = append(result.Interfaces, compare(iface, expected))
+	}
+	// A literal name must be present
+	for _, name := range exp.Interfaces {
+		if discovery.IsPattern(name) || slices.ContainsFunc(result.Interfaces, func(r InterfaceResult) bool { return r.Name == name }) {
+			continue
+		}
+		result.Interfaces = append(result.Interfaces, InterfaceResult{
+			Name:    name,
+			Servers: []status.EffectiveServer{},
+			Match:   status.MatchUnknown,
+			Missing: expected,
+		})
+	}
+
+	if len(result.Interfaces) == 0 {
+		return result.unknown(fmt.Errorf("no interface has DNS servers to check"))
+	}
+
+	s.logger.Debug("checked DNS configuration", slog.Int("interfaces", len(result.Interfaces)), slog.Int("drifted", len(result.Drifted())))
+	result.summarize()
+	return result
+}
+
+// expectedServers resolves the expectation to a list of servers
+func (s *Service) expectedServers(exp Expectation) ([]string, error) {
+	switch {
+	case exp.Preset != "" && len(exp.Servers) > 0:
+		return nil, fmt.Errorf("--expect and --expect-servers cannot be combined")
+	case exp.Preset != "":
+		name := strings.ToLower(exp.Preset)
+		if ips, ok := s.config.DNS.CustomPresets[name]; ok {
+			return ips, nil
+		}
+		if preset, ok := presets.Get(name); ok {
+			return append(append([]string{}, preset.IPv4...), preset.IPv6...), nil
+		}
+		return nil, fmt.Errorf("unknown preset %q", exp.Preset)
+	case len(exp.Servers) > 0:
+		for _, server := range exp.Servers {
+			if _, err := models.ParseServerAddress(server); err != nil {
+				return nil, err
+			}
+		}
+		return exp.Servers, nil
+	default:
+		return nil, fmt.Errorf("nothing to check: use --expect or --expect-servers")
+	}
+}
+
+// selectInterfaces picks the interfaces to check. Interfaces named with
+// patterns are all checked; otherwise every interface with servers is,
+// except the excluded ones. The global servers are checked by name, or when
+// no interface has servers of its own.
+func selectInterfaces(info *status.StatusInfo, patterns, exclude []discovery.Pattern) []status.InterfaceStatus {
+	all := info.Interfaces
+	if info.Global != nil {
+		all = append([]status.InterfaceStatus{*info.Global}, all...)
+	}
+
+	var selected []status.InterfaceStatus
+	if len(patterns) > 0 {
+		for _, iface := range all {
+			if slices.ContainsFunc(patterns, func(p discovery.Pattern) bool { return p.Match(iface.Name) }) {
+				selected = append(selected, iface)
+			}
+		}
+		return selected
+	}
+
+	for _, iface := range info.Interfaces {
+		excluded := slices.ContainsFunc(exclude, func(p discovery.Pattern) bool { return p.Match(iface.Name) })
+		if !excluded && len(status.EffectiveAddresses(iface)) > 0 {
+			selected = append(selected, iface)
+		}
+	}
+	if len(selected) == 0 && info.Global != nil && len(status.EffectiveAddresses(*info.Global)) > 0 {
+		selected = append(selected, *info.Global)
+	}
+	return selected
+}
+
+// compare checks the servers of one interface against the expected ones
+func compare(iface status.InterfaceStatus, expected []string) InterfaceResult {
+	servers := iface.Effective
+	if len(servers) == 0 {
+		for _, address := range status.EffectiveAddresses(iface) {
+			servers = append(servers, status.EffectiveServer{Address: address, Source: status.SourceManual})
+		}
+	}
+	if servers == nil {
+		servers = []status.EffectiveServer{}
+	}
+
+	addresses := status.EffectiveAddresses(iface)
+	result := InterfaceResult{
+		Name:    iface.Name,
+		Servers: servers,
+		Match:   status.CompareServers(addresses, expected),
+	}
+	result.OK = result.Match == status.MatchExact
+	if result.OK {
+		return result
+	}
+
+	for _, server := range expected {
+		if status.CompareServers(addresses, []string{server}) == status.MatchUnknown {
+			result.Missing = append(result.Missing, server)
+		}
+	}
+	for _, server := range addresses {
+		if status.CompareServers([]string{server}, expected) == status.MatchUnknown {
+			result.Unexpected = append(result.Unexpected, server)
+		}
+	}
+	return result
+}
+
+// unknown records why the check could not run
+func (r *Result) unknown(err error) *Result {
+	r.State = StateUnknown
+	r.ExitCode = StateUnknown.ExitCode()
+	r.Summary = err.Error()
+	return r
+}
+
+// summarize sets the state and a one-line summary of the result
+func (r *Result) summarize() {
+	want := r.Preset
+	if want == "" {
+		want = strings.Join(r.Expected, ", ")
+	}
+
+	drifted := r.Drifted()
+	switch {
+	case len(drifted) == 0:
+		names := make([]string, 0, len(r.Interfaces))
+		for _, iface := range r.Interfaces {
+			names = append(names, iface.Name)
+		}
+		r.State = StateOK
+		verb := "uses"
+		if len(names) > 1 {
+			verb = "use"
+		}
+		r.Summary = fmt.Sprintf("%s %s %s", strings.Join(names, ", "), verb, want)
+	case len(drifted) == 1 && len(r.Interfaces) == 1:
+		r.State = StateCritical
+		r.Summary = fmt.Sprintf("%s drifted from %s", drifted[0].Name, want)
+	default:
+		names := make([]string, 0, len(drifted))
+		for _, iface := range drifted {
+			names = append(names, iface.Name)
+		}
+		r.State = StateCritical
+		r.Summary = fmt.Sprintf("%d of %d interfaces drifted from %s: %s", len(drifted), len(r.Interfaces), want, strings.Join(names, ", "))
+	}
+	r.ExitCode = r.State.ExitCode()
+}
+
+// FormatResult formats the result as JSON, YAML or, for table and plain
+// output, as monitoring plugin output
+func (s *Service) FormatResult(result *Result, format ui.Format) (string, error) {
+	return ui.Render(format, result, func(bool) string {
+		return formatPlugin(result)
+	})
+}
+
+// formatPlugin renders a status line with performance data, then one line
+// per interface
+func formatPlugin(result *Result) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("DNS %s - %s", result.State, result.Summary))
+	if result.State != StateUnknown {
+		output.WriteString(fmt.Sprintf(" | interfaces=%d;;;0 drifted=%d;;1;0", len(result.Interfaces), len(result.Drifted())))
+	}
+	for _, iface := range result.Interfaces {
+		output.WriteString("\n" + formatInterface(iface))
+	}
+	return output.String()
+}
+
+// formatInterface describes the servers of one interface in a single line
+func formatInterface(iface InterfaceResult) string {
+	var servers []string
+	for _, server := range iface.Servers {
+		label := server.Address
+		if server.Source != status.SourceManual {
+			label += " (" + server.Source + ")"
+		}
+		servers = append(servers, label)
+	}
+
+	line := fmt.Sprintf("%s: ", iface.Name)
+	if len(servers) == 0 {
+		line += "no DNS servers"
+	} else {
+		line += "uses " + strings.Join(servers, ", ")
+	}
+	if iface.OK {
+		return line
+	}
+	if len(iface.Missing) > 0 {
+		line += "; missing " + strings.Join(iface.Missing, ", ")
+	}
+	if len(iface.Unexpected) > 0 {
+		line += "; unexpected " + strings.Join(iface.Unexpected, ", ")
+	}
+	return line
+}
+
+// CommandResult wraps the check command
+type CommandResult struct {
+	fx.Out
+
+	Cmd *cobra.Command `name:"check"`
+}
+
+// NewCommand creates the check cobra command
+func NewCommand(s *Service) CommandResult {
+	var exp Expectation
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check that the system uses the expected DNS servers",
+		Long: `Compare the live DNS configuration with the expected preset or servers,
+for monitoring. Output follows the Nagios/Icinga plugin format, or JSON
+or YAML with --output. Exit status:
+
+  0  OK: every interface checked uses the expected servers
+  2  CRITICAL: an interface drifted, e.g. DHCP or another tool replaced them
+  3  UNKNOWN: the configuration could not be read, or the expectation or
+     another flag is invalid
+
+Interfaces in dns.exclude_interfaces and interfaces without servers are
+skipped unless named with --interface.`,
+		Example: `  cdns check --expect cloudflare
+  cdns check --expect-servers 1.1.1.1,1.0.0.1 --interface eth0
+  cdns check --expect quad9 --json`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.NoArgs(cmd, args); err != nil {
+				return usageError(cmd, err)
+			}
+			return nil
+		},
+		// Runs the root hook itself so that a bad --output, --root or
+		// --backend is reported as UNKNOWN too
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if root := cmd.Root(); root != cmd && root.PersistentPreRunE != nil {
+				if err := root.PersistentPreRunE(cmd, args); err != nil {
+					return usageError(cmd, err)
+				}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return usageError(cmd, err)
+			}
+			result := s.Check(cmd.Context(), exp)
+			output, err := s.FormatResult(result, format)
+			if err != nil {
+				return usageError(cmd, err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), output)
+
+			if result.ExitCode != ExitOK {
+				return fmt.Errorf("exit:%d", result.ExitCode)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&exp.Preset, "expect", "", "Preset the system should use")
+	cmd.Flags().StringSliceVar(&exp.Servers, "expect-servers", nil, "Servers the system should use (comma-separated)")
+	cmd.Flags().StringSliceVarP(&exp.Interfaces, "interface", "i", nil, "Interfaces to check: names, globs or /regex/ (default: all with DNS servers)")
+	cmd.Flags().Bool("json", false, "Output in JSON format, same as --output json")
+	cmd.SetFlagErrorFunc(usageError)
+	return CommandResult{Cmd: cmd}
+}
+
+// usageError reports a command line the check cannot run with as UNKNOWN,
+// which the plugin guidelines reserve for usage errors
+func usageError(cmd *cobra.Command, err error) error {
+	fmt.Fprintln(cmd.OutOrStdout(), formatPlugin((&Result{}).unknown(err)))
+	cmd.SilenceUsage = true
+	return fmt.Errorf("exit:%d", ExitUnknown)
+}
+
+// RegisterCommandParams holds dependencies for command registration
+type RegisterCommandParams struct {
+	fx.In
+
+	Root *cobra.Command
+	Cmd  *cobra.Command `name:"check"`
+}
+
+// RegisterCommand registers the command with root
+func RegisterCommand(p RegisterCommandParams) {
+	p.Root.AddCommand(p.Cmd)
+}
@@ -0,0 +1,275 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStatusReader mocks the status service
+type MockStatusReader struct {
+	mock.Mock
+}
+
+func (m *MockStatusReader) GetStatus(ctx context.Context) (*status.StatusInfo, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*status.StatusInfo), args.Error(1)
+}
+
+func newTestService(info *status.StatusInfo, err error) *Service {
+	reader := &MockStatusReader{}
+	reader.On("GetStatus", mock.Anything).Return(info, err)
+
+	cfg := &config.Config{}
+	cfg.DNS.ExcludeInterfaces = config.DefaultExcludeInterfaces
+	cfg.DNS.CustomPresets = map[string][]string{"office": {"10.0.0.53", "10.0.0.54"}}
+	return &Service{
+		config: cfg,
+		logger: slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		status: reader,
+	}
+}
+
+func TestService_Check(t *testing.T) {
+	cloudflare := status.InterfaceStatus{
+		Name:      "eth0",
+		IPv4:      []string{"1.1.1.1", "1.0.0.1"},
+		IPv6:      []string{},
+		Effective: []status.EffectiveServer{{Address: "1.1.1.1", Source: status.SourceManual}, {Address: "1.0.0.1", Source: status.SourceManual}},
+	}
+	dhcp := status.InterfaceStatus{
+		Name:      "wlan0",
+		IPv4:      []string{"1.1.1.1", "192.168.1.1"},
+		IPv6:      []string{},
+		Effective: []status.EffectiveServer{{Address: "1.1.1.1", Source: status.SourceManual}, {Address: "192.168.1.1", Source: status.SourceDHCP}},
+	}
+	tailscale := status.InterfaceStatus{Name: "tailscale0", IPv4: []string{"100.100.100.100"}, IPv6: []string{}}
+	empty := status.InterfaceStatus{Name: "eth1", IPv4: []string{}, IPv6: []string{}}
+
+	tests := []struct {
+		name        string
+		info        *status.StatusInfo
+		err         error
+		exp         Expectation
+		wantState   State
+		wantSummary string
+		wantChecked []string
+	}{
+		{
+			name:        "preset in use",
+			info:        &status.StatusInfo{Backend: models.BackendNetworkManager, Interfaces: []status.InterfaceStatus{cloudflare, tailscale, empty}},
+			exp:         Expectation{Preset: "Cloudflare"},
+			wantState:   StateOK,
+			wantSummary: "eth0 uses cloudflare",
+			wantChecked: []string{"eth0"},
+		},
+		{
+			name:        "servers in use",
+			info:        &status.StatusInfo{Backend: models.BackendNetworkManager, Interfaces: []status.InterfaceStatus{cloudflare}},
+			exp:         Expectation{Servers: []string{"1.0.0.1", "1.1.1.1"}},
+			wantState:   StateOK,
+			wantSummary: "eth0 uses 1.0.0.1, 1.1.1.1",
+			wantChecked: []string{"eth0"},
+		},
+		{
+			name:        "DHCP added a server",
+			info:        &status.StatusInfo{Backend: models.BackendSystemdResolved, Interfaces: []status.InterfaceStatus{cloudflare, dhcp}},
+			exp:         Expectation{Preset: "cloudflare"},
+			wantState:   StateCritical,
+			wantSummary: "1 of 2 interfaces drifted from cloudflare: wlan0",
+			wantChecked: []string{"eth0", "wlan0"},
+		},
+		{
+			name:        "single interface drifted",
+			info:        &status.StatusInfo{Backend: models.BackendNetworkManager, Interfaces: []status.InterfaceStatus{cloudflare}},
+			exp:         Expectation{Preset: "office"},
+			wantState:   StateCritical,
+			wantSummary: "eth0 drifted from office",
+			wantChecked: []string{"eth0"},
+		},
+		{
+			name:        "named interface without servers",
+			info:        &status.StatusInfo{Backend: models.BackendNetworkManager, Interfaces: []status.InterfaceStatus{cloudflare, empty}},
+			exp:         Expectation{Preset: "cloudflare", Interfaces: []string{"eth*"}},
+			wantState:   StateCritical,
+			wantSummary: "1 of 2 interfaces drifted from cloudflare: eth1",
+			wantChecked: []string{"eth0", "eth1"},
+		},
+		{
+			name:        "named interface missing",
+			info:        &status.StatusInfo{Backend: models.BackendNetworkManager, Interfaces: []status.InterfaceStatus{cloudflare}},
+			exp:         Expectation{Preset: "cloudflare", Interfaces: []string{"eth0", "eth9"}},
+			wantState:   StateCritical,
+			wantSummary: "1 of 2 interfaces drifted from cloudflare: eth9",
+			wantChecked: []string{"eth0", "eth9"},
+		},
+		{
+			name: "global servers only",
+			info: &status.StatusInfo{
+				Backend:    models.BackendSystemdResolved,
+				Interfaces: []status.InterfaceStatus{empty},
+				Global:     &status.InterfaceStatus{Name: "Global", IPv4: []string{"9.9.9.9", "149.112.112.112"}, IPv6: []string{}},
+			},
+			exp:         Expectation{Preset: "quad9"},
+			wantState:   StateOK,
+			wantSummary: "Global uses quad9",
+			wantChecked: []string{"Global"},
+		},
+		{
+			name:        "unknown preset",
+			exp:         Expectation{Preset: "nope"},
+			wantState:   StateUnknown,
+			wantSummary: `unknown preset "nope"`,
+		},
+		{
+			name:        "invalid server",
+			exp:         Expectation{Servers: []string{"1.1.1.1:99999"}},
+			wantState:   StateUnknown,
+			wantSummary: "invalid DNS server address",
+		},
+		{
+			name:        "no expectation",
+			wantState:   StateUnknown,
+			wantSummary: "nothing to check",
+		},
+		{
+			name:        "both expectations",
+			exp:         Expectation{Preset: "cloudflare", Servers: []string{"1.1.1.1"}},
+			wantState:   StateUnknown,
+			wantSummary: "cannot be combined",
+		},
+		{
+			name:        "status failure",
+			err:         errors.New("failed to detect DNS backend"),
+			exp:         Expectation{Preset: "cloudflare"},
+			wantState:   StateUnknown,
+			wantSummary: "failed to detect DNS backend",
+		},
+		{
+			name:        "nothing to compare",
+			info:        &status.StatusInfo{Backend: models.BackendNetworkManager, Interfaces: []status.InterfaceStatus{empty, tailscale}},
+			exp:         Expectation{Preset: "cloudflare"},
+			wantState:   StateUnknown,
+			wantSummary: "no interface has DNS servers to check",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(tt.info, tt.err)
+			result := svc.Check(context.Background(), tt.exp)
+
+			assert.Equal(t, tt.wantState, result.State)
+			assert.Equal(t, tt.wantState.ExitCode(), result.ExitCode)
+			assert.Contains(t, result.Summary, tt.wantSummary)
+
+			var checked []string
+			for _, iface := range result.Interfaces {
+				checked = append(checked, iface.Name)
+			}
+			assert.Equal(t, tt.wantChecked, checked)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	expected := []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111", "2606:4700:4700::1001"}
+
+	// A link without IPv6 gets only the IPv4 servers
+	ipv4 := compare(status.InterfaceStatus{Name: "eth0", IPv4: []string{"1.0.0.1", "1.1.1.1"}}, expected)
+	assert.True(t, ipv4.OK)
+	assert.Equal(t, status.MatchExact, ipv4.Match)
+	assert.Empty(t, ipv4.Missing)
+
+	drifted := compare(status.InterfaceStatus{Name: "eth0", IPv4: []string{"1.1.1.1", "192.168.1.1"}}, expected)
+	assert.False(t, drifted.OK)
+	assert.Equal(t, status.MatchPartial, drifted.Match)
+	assert.Equal(t, []string{"1.0.0.1", "2606:4700:4700::1111", "2606:4700:4700::1001"}, drifted.Missing)
+	assert.Equal(t, []string{"192.168.1.1"}, drifted.Unexpected)
+}
+
+func TestService_FormatResult(t *testing.T) {
+	result := &Result{
+		State:    StateCritical,
+		ExitCode: ExitCritical,
+		Summary:  "1 of 2 interfaces drifted from cloudflare: wlan0",
+		Backend:  models.BackendSystemdResolved,
+		Preset:   "cloudflare",
+		Expected: []string{"1.1.1.1", "1.0.0.1"},
+		Interfaces: []InterfaceResult{
+			{Name: "eth0", Servers: []status.EffectiveServer{{Address: "1.1.1.1", Source: status.SourceManual}, {Address: "1.0.0.1", Source: status.SourceManual}}, Match: status.MatchExact, OK: true},
+			{
+				Name:       "wlan0",
+				Servers:    []status.EffectiveServer{{Address: "192.168.1.1", Source: status.SourceDHCP}},
+				Match:      status.MatchUnknown,
+				Missing:    []string{"1.1.1.1", "1.0.0.1"},
+				Unexpected: []string{"192.168.1.1"},
+			},
+		},
+	}
+	svc := newTestService(nil, nil)
+
+	output, err := svc.FormatResult(result, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Equal(t, `DNS CRITICAL - 1 of 2 interfaces drifted from cloudflare: wlan0 | interfaces=2;;;0 drifted=1;;1;0
+eth0: uses 1.1.1.1, 1.0.0.1
+wlan0: uses 192.168.1.1 (dhcp); missing 1.1.1.1, 1.0.0.1; unexpected 192.168.1.1`, output)
+
+	output, err = svc.FormatResult(&Result{State: StateUnknown, ExitCode: ExitUnknown, Summary: `unknown preset "nope"`}, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Equal(t, `DNS UNKNOWN - unknown preset "nope"`, output)
+
+	jsonOutput, err := svc.FormatResult(result, ui.FormatJSON)
+	require.NoError(t, err)
+	var parsed Result
+	require.NoError(t, json.Unmarshal([]byte(jsonOutput), &parsed))
+	assert.Equal(t, *result, parsed)
+	assert.Contains(t, jsonOutput, `"exit_code": 2`)
+}
+
+func TestNewCommand_UsageErrors(t *testing.T) {
+	for name, args := range map[string][]string{
+		"bad output":    {"check", "--expect", "cloudflare", "--output", "xml"},
+		"json conflict": {"check", "--expect", "cloudflare", "--json", "--output", "yaml"},
+		"unknown flag":  {"check", "--expect-server", "1.1.1.1"},
+		"extra args":    {"check", "cloudflare"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := &cobra.Command{
+				Use:           "cdns",
+				SilenceErrors: true,
+				PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+					_, err := ui.OutputFormat(cmd)
+					return err
+				},
+			}
+			root.PersistentFlags().String("output", "table", "")
+			root.AddCommand(NewCommand(&Service{}).Cmd)
+			var out bytes.Buffer
+			root.SetOut(&out)
+			root.SetArgs(args)
+
+			err := root.Execute()
+			assert.EqualError(t, err, "exit:3")
+			assert.True(t, strings.HasPrefix(out.String(), "DNS UNKNOWN - "), out.String())
+		})
+	}
+}
@@ -0,0 +1,582 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/probe"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+	"gitlab.com/junevm/cdns/internal/features/status"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+)
+
+// Module provides the doctor feature as an Fx module
+var Module = fx.Module("doctor",
+	fx.Provide(NewService),
+	fx.Provide(NewCommand),
+	fx.Invoke(RegisterCommand),
+)
+
+// Severity ranks a finding
+type Severity string
+
+const (
+	// SeverityError marks a problem that breaks name resolution
+	SeverityError Severity = "error"
+	// SeverityWarning marks a problem that may break it or bypass cdns
+	SeverityWarning Severity = "warning"
+	// SeverityInfo marks something worth knowing
+	SeverityInfo Severity = "info"
+)
+
+// Finding is a problem found by a check, with the commands fixing it
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Fix      []string `json:"fix,omitempty"`
+}
+
+// Report holds the findings of every check
+type Report struct {
+	Backend  models.Backend `json:"backend"`
+	Findings []Finding      `json:"findings"`
+}
+
+// HasErrors reports whether a finding is an error
+func (r *Report) HasErrors() bool {
+	return slices.ContainsFunc(r.Findings, func(f Finding) bool { return f.Severity == SeverityError })
+}
+
+func (r *Report) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+}
+
+// Detector follows the resolution chain of the system
+type Detector interface {
+	DetectChain() (*models.ResolutionChain, error)
+}
+
+// Reader reads the DNS configuration of a backend
+type Reader interface {
+	ReadDNSConfig(ctx context.Context, backend models.Backend) (*status.StatusInfo, error)
+	ReadConnections(ctx context.Context, backend models.Backend) ([]status.ConnectionStatus, error)
+}
+
+// Links inspects the network interfaces of the system
+type Links interface {
+	Interfaces() ([]discovery.Interface, error)
+	HasGlobalIPv6(name string) (bool, error)
+}
+
+// Prober checks whether a resolver answers queries
+type Prober interface {
+	Probe(ctx context.Context, server string) error
+}
+
+// StateReader gives access to the record of changes made by cdns
+type StateReader interface {
+	Load() (*state.Snapshot, error)
+	Path() string
+}
+
+// Service handles the business logic for doctor feature
+type Service struct {
+	config   *config.Config
+	logger   *slog.Logger
+	styles   *ui.Styles
+	detector Detector
+	reader   Reader
+	sysOps   backend.SystemOps
+	runner   backend.CommandRunner
+	links    Links
+	prober   Prober
+	state    StateReader
+	root     *backend.Root
+}
+
+// NewService creates a new doctor service
+func NewService(cfg *config.Config, logger *slog.Logger, sysOps backend.SystemOps, nm backend.NMClient, runner backend.CommandRunner, root *backend.Root, detector *backend.Detector, store *state.Store) *Service {
+	return &Service{
+		config:   cfg,
+		logger:   logger,
+		styles:   ui.NewStyles(),
+		detector: detector,
+		reader:   backend.NewConfigReader(sysOps, nm, runner, root),
+		sysOps:   sysOps,
+		runner:   runner,
+		links:    discovery.NewDiscoverer(),
+		prober:   probe.NewUDPProber(probe.Timeout),
+		state:    store,
+		root:     root,
+	}
+}
+
+// Diagnose runs every check against the running system
+func (s *Service) Diagnose(ctx context.Context) (*Report, error) {
+	if s.root.Offline() {
+		return nil, fmt.Errorf("doctor checks the running system and cannot be used with --root")
+	}
+
+	report := &Report{Findings: []Finding{}}
+	chain, err := s.detector.DetectChain()
+	if chain == nil {
+		chain = &models.ResolutionChain{}
+	}
+	if err != nil {
+		report.add(Finding{Check: "backend", Severity: SeverityError, Message: err.Error()})
+	}
+	report.Backend = chain.Backend
+
+	s.checkManagers(report, chain)
+	s.checkImmutable(ctx, report, chain)
+	s.checkOwnedFiles(report, chain.Backend)
+	if chain.Backend == "" {
+		return report, nil
+	}
+
+	info, err := s.reader.ReadDNSConfig(ctx, chain.Backend)
+	if err != nil {
+		report.add(Finding{Check: "backend", Severity: SeverityError, Message: fmt.Sprintf("failed to read DNS configuration: %v", err)})
+		return report, nil
+	}
+	s.checkResolvConf(ctx, report, chain, info)
+	if chain.Backend == models.BackendNetworkManager {
+		s.checkConnections(ctx, report)
+	}
+	s.checkIPv6(report, info)
+	s.checkResolvers(ctx, report, info)
+	return report, nil
+}
+
+// checkManagers looks for daemons fighting over /etc/resolv.conf
+func (s *Service) checkManagers(report *Report, chain *models.ResolutionChain) {
+	running := func(service string) bool {
+		ok, _ := s.sysOps.ServiceRunning(service)
+		return ok
+	}
+	nm, resolved := running("NetworkManager"), running("systemd-resolved")
+
+	if running("resolvconf") && (nm || resolved) {
+		var others []string
+		if nm {
+			others = append(others, "NetworkManager")
+		}
+		if resolved {
+			others = append(others, "systemd-resolved")
+		}
+		report.add(Finding{
+			Check:    "managers",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("resolvconf is active alongside %s; each rewrites /etc/resolv.conf", strings.Join(others, " and ")),
+			Fix:      []string{"sudo systemctl disable --now resolvconf"},
+		})
+	}
+
+	// NetworkManager and systemd-resolved cooperate only when NetworkManager
+	// hands its DNS over
+	if nm && resolved && chain.NMDNSMode == "default" {
+		report.add(Finding{
+			Check:    "managers",
+			Severity: SeverityWarning,
+			Message:  "NetworkManager writes /etc/resolv.conf itself (dns=default) while systemd-resolved is running; each overrides the other",
+			Fix: []string{
+				`printf '[main]\ndns=systemd-resolved\n' | sudo tee /etc/NetworkManager/conf.d/90-dns.conf`,
+				"sudo systemctl reload NetworkManager",
+			},
+		})
+	}
+}
+
+// checkImmutable looks for an /etc/resolv.conf made immutable with chattr +i,
+// which no backend can update
+func (s *Service) checkImmutable(ctx context.Context, report *Report, chain *models.ResolutionChain) {
+	path := "/etc/resolv.conf"
+	if target := chain.ResolvConfTarget; target != "" {
+		path = target
+		if !filepath.IsAbs(target) {
+			path = filepath.Join("/etc", target)
+		}
+	}
+
+	output, err := s.runner.Run(ctx, "lsattr", "-d", path)
+	if err != nil {
+		// lsattr is missing or the filesystem has no attributes
+		s.logger.Debug("failed to read file attributes", slog.String("path", path), slog.Any("error", err))
+		return
+	}
+	if fields := strings.Fields(string(output)); len(fields) > 0 && strings.Contains(fields[0], "i") {
+		report.add(Finding{
+			Check:    "resolv.conf",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("%s is immutable (chattr +i), so no backend can update it", path),
+			Fix:      []string{"sudo chattr -i " + path},
+		})
+	}
+}
+
+// checkOwnedFiles looks for files cdns wrote that no longer take effect
+func (s *Service) checkOwnedFiles(report *Report, b models.Backend) {
+	for _, file := range backend.OwnedFiles {
+		if !s.sysOps.FileExists(file.Path) {
+			continue
+		}
+		switch {
+		case !file.InUse(s.sysOps):
+			report.add(Finding{
+				Check:    "stale files",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s is left over: %s is not in use", file.Path, file.Backend),
+				Fix:      []string{"sudo rm " + file.Path},
+			})
+		case file.Backend == models.BackendNetworkManager:
+			report.add(Finding{
+				Check:    "stale files",
+				Severity: SeverityInfo,
+				Message:  fmt.Sprintf("NetworkManager global DNS in %s overrides the servers of every connection", file.Path),
+				Fix:      []string{"sudo cdns reset --global"},
+			})
+		}
+	}
+
+	if s.state == nil || b == "" {
+		return
+	}
+	snap, err := s.state.Load()
+	if err != nil {
+		s.logger.Debug("failed to load state", slog.Any("error", err))
+		return
+	}
+	if snap.Backend != "" && snap.Backend != b {
+		report.add(Finding{
+			Check:    "stale files",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("cdns last changed DNS through %s but the backend is now %s, so 'cdns reset' cannot undo that change", snap.Backend, b),
+			Fix:      []string{"sudo rm " + s.state.Path()},
+		})
+	}
+}
+
+// checkResolvConf compares /etc/resolv.conf with what the backend reports
+func (s *Service) checkResolvConf(ctx context.Context, report *Report, chain *models.ResolutionChain, info *status.StatusInfo) {
+	viaResolved := chain.Backend == models.BackendSystemdResolved ||
+		(chain.Backend == models.BackendNetworkManager && chain.NMDNSMode == "systemd-resolved")
+	viaNM := chain.Backend == models.BackendNetworkManager && chain.NMDNSMode == "default"
+	if !viaResolved && !viaNM {
+		return
+	}
+
+	conf, err := s.reader.ReadDNSConfig(ctx, models.BackendResolvConf)
+	if err != nil {
+		s.logger.Debug("failed to read resolv.conf", slog.Any("error", err))
+		return
+	}
+	listed := statusServers(conf)
+
+	if viaResolved {
+		if chain.ResolvConfOwner == "systemd-resolved" || slices.Contains(listed, "127.0.0.53") {
+			return
+		}
+		report.add(Finding{
+			Check:    "resolv.conf",
+			Severity: SeverityWarning,
+			Message:  "/etc/resolv.conf does not point to systemd-resolved, so applications bypass the servers it manages",
+			Fix:      []string{"sudo ln -sf ../run/systemd/resolve/stub-resolv.conf /etc/resolv.conf"},
+		})
+		return
+	}
+
+	known := statusServers(info)
+	var unknown []string
+	for _, server := range listed {
+		if !slices.Contains(known, server) {
+			unknown = append(unknown, server)
+		}
+	}
+	if len(known) > 0 && len(unknown) > 0 {
+		report.add(Finding{
+			Check:    "resolv.conf",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("/etc/resolv.conf lists %s, which NetworkManager does not use", strings.Join(unknown, ", ")),
+			Fix:      []string{"sudo nmcli general reload dns-rc"},
+		})
+	}
+}
+
+// checkConnections looks for NetworkManager profiles whose servers come
+// from DHCP, on their own or mixed with the configured ones
+func (s *Service) checkConnections(ctx context.Context, report *Report) {
+	connections, err := s.reader.ReadConnections(ctx, models.BackendNetworkManager)
+	if err != nil {
+		s.logger.Debug("failed to read connection profiles", slog.Any("error", err))
+		return
+	}
+	for _, conn := range connections {
+		if conn.IgnoreAutoDNS {
+			continue
+		}
+		name := shellQuote(conn.Name)
+		switch {
+		case len(conn.Configured) > 0:
+			report.add(Finding{
+				Check:    "dhcp dns",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s sets %s but still takes servers from DHCP (ignore-auto-dns is off)", conn.Name, strings.Join(conn.Configured, ", ")),
+				Fix:      []string{fmt.Sprintf("sudo nmcli connection modify %s ipv4.ignore-auto-dns yes ipv6.ignore-auto-dns yes", name)},
+			})
+		case conn.Device != "":
+			report.add(Finding{
+				Check:    "dhcp dns",
+				Severity: SeverityInfo,
+				Message:  fmt.Sprintf("%s takes its servers from DHCP only", conn.Name),
+				Fix:      []string{fmt.Sprintf("sudo cdns set <preset> --connection %s", name)},
+			})
+		}
+	}
+}
+
+// checkIPv6 looks for IPv6 resolvers on links without a global IPv6
+// address, which cannot reach them
+func (s *Service) checkIPv6(report *Report, info *status.StatusInfo) {
+	all, err := s.links.Interfaces()
+	if err != nil {
+		s.logger.Debug("failed to list interfaces", slog.Any("error", err))
+		return
+	}
+	for _, iface := range info.Interfaces {
+		if !slices.ContainsFunc(all, func(link discovery.Interface) bool { return link.Name == iface.Name }) {
+			continue
+		}
+		var unreachable []string
+		for _, server := range iface.IPv6 {
+			// Link-local and unique local servers are reached without one
+			addr, err := models.ParseServerAddress(server)
+			if err == nil && addr.IP.IsGlobalUnicast() && !addr.IP.IsPrivate() {
+				unreachable = append(unreachable, server)
+			}
+		}
+		if len(unreachable) == 0 {
+			continue
+		}
+		if global, err := s.links.HasGlobalIPv6(iface.Name); err != nil || global {
+			continue
+		}
+
+		finding := Finding{
+			Check:    "ipv6",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("%s has no global IPv6 address, so queries to %s fail", iface.Name, strings.Join(unreachable, ", ")),
+		}
+		if len(iface.IPv4) > 0 {
+			finding.Fix = []string{fmt.Sprintf("sudo cdns set %s --interface %s", strings.Join(iface.IPv4, " "), iface.Name)}
+		}
+		report.add(finding)
+	}
+}
+
+// checkResolvers queries every configured server at once
+func (s *Service) checkResolvers(ctx context.Context, report *Report, info *status.StatusInfo) {
+	servers := statusServers(info)
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.prober.Probe(ctx, server)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		report.add(Finding{
+			Check:    "resolvers",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("%s does not answer: %v", servers[i], err),
+			Fix:      []string{"sudo cdns reset", "cdns list"},
+		})
+	}
+}
+
+// statusServers lists the servers of a status once each, global ones first
+func statusServers(info *status.StatusInfo) []string {
+	interfaces := info.Interfaces
+	if info.Global != nil {
+		interfaces = append([]status.InterfaceStatus{*info.Global}, interfaces...)
+	}
+	var servers []string
+	for _, iface := range interfaces {
+		for _, server := range append(append([]string{}, iface.IPv4...), iface.IPv6...) {
+			if !slices.Contains(servers, server) {
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers
+}
+
+// shellQuote quotes a value for the shell when it needs it
+func shellQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n'\"\\$`!*?[]{}()<>|&;#~") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// FormatReport formats the report for output
+func (s *Service) FormatReport(report *Report, format ui.Format) (string, error) {
+	return ui.Render(format, report, func(plain bool) string {
+		if plain {
+			return formatPlain(report)
+		}
+		return s.formatHuman(report)
+	})
+}
+
+// formatPlain lists one finding per line: severity, check and finding
+func formatPlain(report *Report) string {
+	rows := make([][]string, 0, len(report.Findings))
+	for _, f := range report.Findings {
+		rows = append(rows, []string{string(f.Severity), f.Check, f.Message})
+	}
+	return ui.PlainRows(rows)
+}
+
+// formatHuman renders the findings as a table
+func (s *Service) formatHuman(report *Report) string {
+	var output strings.Builder
+	output.WriteString("\n" + s.styles.Header.Render("DNS Diagnostics") + "\n\n")
+	if report.Backend != "" {
+		output.WriteString(fmt.Sprintf("  Backend: %s\n\n", s.styles.RenderInfo(string(report.Backend))))
+	}
+	if len(report.Findings) == 0 {
+		output.WriteString("  " + s.styles.RenderSuccess("No problems found") + "\n")
+		return output.String()
+	}
+
+	termWidth, _, _ := ui.GetTerminalSize()
+	if termWidth <= 0 {
+		termWidth = 80
+	}
+	// Severity (11) + Check (15) + Borders/Padding (13) leave the rest
+	// to the finding and its fix
+	textWidth := (termWidth - 39) / 2
+	if textWidth < 24 {
+		textWidth = 24
+	}
+
+	var rows [][]string
+	for _, f := range report.Findings {
+		rows = append(rows, []string{s.renderSeverity(f.Severity), f.Check, f.Message, strings.Join(f.Fix, "\n")})
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("63"))).
+		Headers("SEVERITY", "CHECK", "FINDING", "FIX").
+		Rows(rows...)
+
+	t.StyleFunc(func(row, col int) lipgloss.Style {
+		style := lipgloss.NewStyle().Padding(0, 1)
+		if col >= 2 {
+			style = style.Width(textWidth)
+		}
+		switch {
+		case row == 0:
+			return style.Bold(true).Foreground(lipgloss.Color("205")).Align(lipgloss.Center)
+		case col == 3:
+			return style.Foreground(lipgloss.Color("86"))
+		default:
+			return style
+		}
+	})
+
+	output.WriteString(t.Render())
+	output.WriteString("\n")
+	return output.String()
+}
+
+// renderSeverity colors a severity
+func (s *Service) renderSeverity(severity Severity) string {
+	switch severity {
+	case SeverityError:
+		return s.styles.Error.Render("● error")
+	case SeverityWarning:
+		return s.styles.Warning.Render("● warning")
+	default:
+		return s.styles.Info.Render("● info")
+	}
+}
+
+// CommandResult wraps the doctor command
+type CommandResult struct {
+	fx.Out
+
+	Cmd *cobra.Command `name:"doctor"`
+}
+
+// NewCommand creates the doctor cobra command
+func NewCommand(s *Service) CommandResult {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose common DNS problems",
+		Long: `Check the DNS setup for common problems: conflicting managers, an
+/etc/resolv.conf that is immutable or out of step with the backend, stale
+files written by cdns, servers mixed with DHCP ones, IPv6 servers on links
+without IPv6, and resolvers that do not answer. Each finding comes with the
+commands that fix it. Exits with status 1 when an error is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			report, err := s.Diagnose(cmd.Context())
+			if err != nil {
+				return err
+			}
+			output, err := s.FormatReport(report, format)
+			if err != nil {
+				return err
+			}
+			fmt.Println(output)
+
+			if report.HasErrors() {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("exit:1")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("json", false, "Output in JSON format, same as --output json")
+	return CommandResult{Cmd: cmd}
+}
+
+// RegisterCommandParams holds dependencies for command registration
+type RegisterCommandParams struct {
+	fx.In
+
+	Root *cobra.Command
+	Cmd  *cobra.Command `name:"doctor"`
+}
+
+// RegisterCommand registers the command with root
+func RegisterCommand(p RegisterCommandParams) {
+	p.Root.AddCommand(p.Cmd)
+}
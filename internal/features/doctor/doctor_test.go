@@ -0,0 +1,298 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+	"gitlab.com/junevm/cdns/internal/features/status"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDetector is a mock of doctor.Detector
+type MockDetector struct {
+	mock.Mock
+}
+
+func (m *MockDetector) DetectChain() (*models.ResolutionChain, error) {
+	args := m.Called()
+	chain, _ := args.Get(0).(*models.ResolutionChain)
+	return chain, args.Error(1)
+}
+
+// MockReader is a mock of doctor.Reader
+type MockReader struct {
+	mock.Mock
+}
+
+func (m *MockReader) ReadDNSConfig(ctx context.Context, backend models.Backend) (*status.StatusInfo, error) {
+	args := m.Called(ctx, backend)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*status.StatusInfo), args.Error(1)
+}
+
+func (m *MockReader) ReadConnections(ctx context.Context, backend models.Backend) ([]status.ConnectionStatus, error) {
+	args := m.Called(ctx, backend)
+	connections, _ := args.Get(0).([]status.ConnectionStatus)
+	return connections, args.Error(1)
+}
+
+// fakeSystem implements backend.SystemOps over lists of running services,
+// installed commands and existing files
+type fakeSystem struct {
+	running  []string
+	commands []string
+	files    []string
+}
+
+func (f fakeSystem) CommandExists(cmd string) bool { return slices.Contains(f.commands, cmd) }
+func (f fakeSystem) ServiceRunning(service string) (bool, error) {
+	return slices.Contains(f.running, service), nil
+}
+func (f fakeSystem) FileExists(path string) bool             { return slices.Contains(f.files, path) }
+func (f fakeSystem) IsRegularFile(path string) (bool, error) { return f.FileExists(path), nil }
+func (f fakeSystem) IsSymlink(string) (bool, error)          { return false, nil }
+func (f fakeSystem) ReadLink(string) (string, error)         { return "", os.ErrInvalid }
+func (f fakeSystem) ReadFile(string) ([]byte, error)         { return nil, os.ErrNotExist }
+func (f fakeSystem) Glob(pattern string) ([]string, error)   { return nil, nil }
+
+// fakeLinks reports the interfaces in global as having a global IPv6 address
+type fakeLinks struct {
+	names  []string
+	global []string
+}
+
+func (f fakeLinks) Interfaces() ([]discovery.Interface, error) {
+	var ifaces []discovery.Interface
+	for _, name := range f.names {
+		ifaces = append(ifaces, discovery.Interface{Name: name})
+	}
+	return ifaces, nil
+}
+
+func (f fakeLinks) HasGlobalIPv6(name string) (bool, error) {
+	return slices.Contains(f.global, name), nil
+}
+
+// fakeProber fails the servers in failing
+type fakeProber map[string]error
+
+func (f fakeProber) Probe(_ context.Context, server string) error {
+	return f[server]
+}
+
+func replay(t *testing.T, text string) *backend.ReplayRunner {
+	t.Helper()
+	transcript, err := backend.ParseTranscript(text)
+	require.NoError(t, err)
+	return backend.NewReplayRunner(transcript)
+}
+
+func newTestService(t *testing.T, detector Detector, reader Reader, sys fakeSystem, runner backend.CommandRunner, links Links, prober Prober, store StateReader) *Service {
+	return &Service{
+		logger:   slog.Default(),
+		styles:   ui.NewStyles(),
+		detector: detector,
+		reader:   reader,
+		sysOps:   sys,
+		runner:   runner,
+		links:    links,
+		prober:   prober,
+		state:    store,
+	}
+}
+
+func TestService_Diagnose(t *testing.T) {
+	ctx := mock.Anything
+
+	t.Run("broken system", func(t *testing.T) {
+		detector := &MockDetector{}
+		detector.On("DetectChain").Return(&models.ResolutionChain{
+			Backend:     models.BackendNetworkManager,
+			NMDNSMode:   "default",
+			NMRcManager: "symlink",
+		}, nil)
+
+		reader := &MockReader{}
+		reader.On("ReadDNSConfig", ctx, models.BackendNetworkManager).Return(&status.StatusInfo{
+			Backend: models.BackendNetworkManager,
+			Interfaces: []status.InterfaceStatus{
+				{Name: "eth0", IPv4: []string{"9.9.9.9"}, IPv6: []string{"2620:fe::fe", "fe80::1%eth0"}},
+				{Name: "wlan0", IPv4: []string{"192.168.1.1"}},
+			},
+		}, nil)
+		reader.On("ReadDNSConfig", ctx, models.BackendResolvConf).Return(&status.StatusInfo{
+			Interfaces: []status.InterfaceStatus{{Name: "system", IPv4: []string{"9.9.9.9", "8.8.8.8"}}},
+		}, nil)
+		reader.On("ReadConnections", ctx, models.BackendNetworkManager).Return([]status.ConnectionStatus{
+			{Name: "Office LAN", Device: "eth0", IPv4: []string{"9.9.9.9"}, Configured: []string{"9.9.9.9"}},
+			{Name: "Home", Device: "wlan0", IPv4: []string{"192.168.1.1"}},
+			{Name: "Cafe", IPv4: []string{"1.1.1.1"}, Configured: []string{"1.1.1.1"}, IgnoreAutoDNS: true},
+			{Name: "Spare"},
+		}, nil)
+
+		sys := fakeSystem{
+			running: []string{"NetworkManager", "systemd-resolved", "resolvconf"},
+			files: []string{
+				"/etc/systemd/resolved.conf.d/90-cdns.conf",
+				"/etc/netplan/90-cdns.yaml",
+				"/etc/NetworkManager/conf.d/90-cdns-global-dns.conf",
+			},
+		}
+		runner := replay(t, "$ lsattr -d /etc/resolv.conf\n| ----i---------e------- /etc/resolv.conf\n")
+
+		statePath := filepath.Join(t.TempDir(), "state.json")
+		store := state.NewStore(statePath)
+		require.NoError(t, store.Save(&state.Snapshot{Backend: models.BackendSystemdResolved}))
+
+		svc := newTestService(t, detector, reader, sys, runner,
+			fakeLinks{names: []string{"eth0", "wlan0"}},
+			fakeProber{"192.168.1.1": errors.New("no answer within 2s")},
+			store)
+
+		report, err := svc.Diagnose(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, models.BackendNetworkManager, report.Backend)
+		assert.Equal(t, []Finding{
+			{Check: "managers", Severity: SeverityWarning,
+				Message: "resolvconf is active alongside NetworkManager and systemd-resolved; each rewrites /etc/resolv.conf",
+				Fix:     []string{"sudo systemctl disable --now resolvconf"}},
+			{Check: "managers", Severity: SeverityWarning,
+				Message: "NetworkManager writes /etc/resolv.conf itself (dns=default) while systemd-resolved is running; each overrides the other",
+				Fix: []string{
+					`printf '[main]\ndns=systemd-resolved\n' | sudo tee /etc/NetworkManager/conf.d/90-dns.conf`,
+					"sudo systemctl reload NetworkManager",
+				}},
+			{Check: "resolv.conf", Severity: SeverityError,
+				Message: "/etc/resolv.conf is immutable (chattr +i), so no backend can update it",
+				Fix:     []string{"sudo chattr -i /etc/resolv.conf"}},
+			{Check: "stale files", Severity: SeverityWarning,
+				Message: "/etc/netplan/90-cdns.yaml is left over: netplan is not in use",
+				Fix:     []string{"sudo rm /etc/netplan/90-cdns.yaml"}},
+			{Check: "stale files", Severity: SeverityInfo,
+				Message: "NetworkManager global DNS in /etc/NetworkManager/conf.d/90-cdns-global-dns.conf overrides the servers of every connection",
+				Fix:     []string{"sudo cdns reset --global"}},
+			{Check: "stale files", Severity: SeverityWarning,
+				Message: "cdns last changed DNS through systemd-resolved but the backend is now NetworkManager, so 'cdns reset' cannot undo that change",
+				Fix:     []string{"sudo rm " + statePath}},
+			{Check: "resolv.conf", Severity: SeverityWarning,
+				Message: "/etc/resolv.conf lists 8.8.8.8, which NetworkManager does not use",
+				Fix:     []string{"sudo nmcli general reload dns-rc"}},
+			{Check: "dhcp dns", Severity: SeverityWarning,
+				Message: "Office LAN sets 9.9.9.9 but still takes servers from DHCP (ignore-auto-dns is off)",
+				Fix:     []string{"sudo nmcli connection modify 'Office LAN' ipv4.ignore-auto-dns yes ipv6.ignore-auto-dns yes"}},
+			{Check: "dhcp dns", Severity: SeverityInfo,
+				Message: "Home takes its servers from DHCP only",
+				Fix:     []string{"sudo cdns set <preset> --connection Home"}},
+			{Check: "ipv6", Severity: SeverityWarning,
+				Message: "eth0 has no global IPv6 address, so queries to 2620:fe::fe fail",
+				Fix:     []string{"sudo cdns set 9.9.9.9 --interface eth0"}},
+			{Check: "resolvers", Severity: SeverityError,
+				Message: "192.168.1.1 does not answer: no answer within 2s",
+				Fix:     []string{"sudo cdns reset", "cdns list"}},
+		}, report.Findings)
+		assert.True(t, report.HasErrors())
+		assert.Empty(t, runner.Remaining())
+	})
+
+	t.Run("healthy systemd-resolved", func(t *testing.T) {
+		detector := &MockDetector{}
+		detector.On("DetectChain").Return(&models.ResolutionChain{
+			Backend:          models.BackendSystemdResolved,
+			ResolvConfTarget: "../run/systemd/resolve/stub-resolv.conf",
+			ResolvConfOwner:  "systemd-resolved",
+		}, nil)
+
+		reader := &MockReader{}
+		reader.On("ReadDNSConfig", ctx, models.BackendSystemdResolved).Return(&status.StatusInfo{
+			Global:     &status.InterfaceStatus{Name: "Global", IPv4: []string{"9.9.9.9#dns.quad9.net"}},
+			Interfaces: []status.InterfaceStatus{{Name: "eth0", IPv6: []string{"2620:fe::fe"}}},
+		}, nil)
+		reader.On("ReadDNSConfig", ctx, models.BackendResolvConf).Return(&status.StatusInfo{
+			Interfaces: []status.InterfaceStatus{{Name: "system", IPv4: []string{"127.0.0.53"}}},
+		}, nil)
+
+		sys := fakeSystem{running: []string{"systemd-resolved"}, files: []string{"/etc/systemd/resolved.conf.d/90-cdns.conf"}}
+		// The link target is checked, and tmpfs has no attributes
+		runner := replay(t, "$ lsattr -d /run/systemd/resolve/stub-resolv.conf\n! lsattr: Operation not supported While reading flags on /run/systemd/resolve/stub-resolv.conf\nexit 1\n")
+
+		svc := newTestService(t, detector, reader, sys, runner,
+			fakeLinks{names: []string{"eth0"}, global: []string{"eth0"}}, fakeProber{}, nil)
+
+		report, err := svc.Diagnose(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, report.Findings)
+		assert.False(t, report.HasErrors())
+		reader.AssertNotCalled(t, "ReadConnections", mock.Anything, mock.Anything)
+	})
+
+	t.Run("no backend", func(t *testing.T) {
+		detector := &MockDetector{}
+		detector.On("DetectChain").Return(&models.ResolutionChain{
+			ResolvConfTarget: "../run/resolvconf/resolv.conf",
+			ResolvConfOwner:  "resolvconf",
+		}, errors.New("/etc/resolv.conf links to ../run/resolvconf/resolv.conf and is generated by resolvconf"))
+
+		svc := newTestService(t, detector, &MockReader{}, fakeSystem{}, replay(t, "$ lsattr -d /etc/run/resolvconf/resolv.conf\nexit 1\n"),
+			fakeLinks{}, fakeProber{}, nil)
+
+		report, err := svc.Diagnose(context.Background())
+		require.NoError(t, err)
+		require.Len(t, report.Findings, 1)
+		assert.Equal(t, SeverityError, report.Findings[0].Severity)
+		assert.Contains(t, report.Findings[0].Message, "generated by resolvconf")
+	})
+
+	t.Run("offline root", func(t *testing.T) {
+		root := backend.NewRoot()
+		require.NoError(t, root.Set(t.TempDir()))
+		svc := &Service{root: root}
+
+		_, err := svc.Diagnose(context.Background())
+		assert.ErrorContains(t, err, "running system")
+	})
+}
+
+func TestService_FormatReport(t *testing.T) {
+	svc := &Service{styles: ui.NewStyles()}
+	report := &Report{
+		Backend: models.BackendNetworkManager,
+		Findings: []Finding{{
+			Check:    "resolv.conf",
+			Severity: SeverityError,
+			Message:  "/etc/resolv.conf is immutable",
+			Fix:      []string{"sudo chattr -i /etc/resolv.conf"},
+		}},
+	}
+
+	output, err := svc.FormatReport(report, ui.FormatTable)
+	require.NoError(t, err)
+	for _, want := range []string{"SEVERITY", "FIX", "error", "immutable", "chattr -i"} {
+		assert.Contains(t, output, want)
+	}
+
+	output, err = svc.FormatReport(report, ui.FormatJSON)
+	require.NoError(t, err)
+	var parsed Report
+	require.NoError(t, json.Unmarshal([]byte(output), &parsed))
+	assert.Equal(t, *report, parsed)
+
+	output, err = svc.FormatReport(&Report{Findings: []Finding{}}, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Contains(t, output, "No problems found")
+}
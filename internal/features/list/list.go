@@ -41,10 +41,10 @@ func NewService(cfg *config.Config, logger *slog.Logger) *Service {
 
 // PresetItem represents a listed DNS preset
 type PresetItem struct {
-	Name    string
-	Slug    string
-	Servers string
-	Type    string
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	Servers string `json:"servers"`
+	Type    string `json:"type"`
 }
 
 // ListPresets retrieves all available presets, sorted by name
@@ -141,17 +141,34 @@ func (s *Service) ListPresets() []PresetItem {
 	return items
 }
 
-// PrintTable displays the presets in a formatted table
-func (s *Service) PrintTable() error {
+// FormatPresets formats the presets as a table, tab-separated rows, JSON
+// or YAML
+func (s *Service) FormatPresets(items []PresetItem, format ui.Format) (string, error) {
+	return ui.Render(format, items, func(plain bool) string {
+		if plain {
+			return formatPlain(items)
+		}
+		return s.formatTable(items)
+	})
+}
+
+// formatPlain lists one preset per line: name, command ID, source, servers
+func formatPlain(items []PresetItem) string {
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, []string{item.Name, item.Slug, item.Type, item.Servers})
+	}
+	return ui.PlainRows(rows)
+}
+
+// formatTable renders the presets in a table with a footer naming the config
+func (s *Service) formatTable(items []PresetItem) string {
 	termWidth, _, _ := ui.GetTerminalSize()
 	// Fallback to 80 if cannot detect width or it's too small
 	if termWidth <= 0 || termWidth < 40 {
 		termWidth = 80
 	}
 
-	// Dynamic column assignment
-	items := s.ListPresets()
-
 	// Column Widths
 	nameWidth := 20
 	slugWidth := 25
@@ -205,17 +222,14 @@ func (s *Service) PrintTable() error {
 		return style
 	})
 
-	fmt.Println(t.Render())
-
 	// Add footer info
 	configPath := s.config.LoadedFrom
 	if configPath == "" {
 		configPath = "defaults (no config file found)"
 	}
-	fmt.Printf("\n%s\n", s.styles.RenderInfo(fmt.Sprintf("Config: %s", configPath)))
-	fmt.Printf("%s\n", s.styles.RenderDim("Use 'cdns set <name>' to apply a preset."))
-
-	return nil
+	return fmt.Sprintf("%s\n\n%s\n%s", t.Render(),
+		s.styles.RenderInfo(fmt.Sprintf("Config: %s", configPath)),
+		s.styles.RenderDim("Use 'cdns set <name>' to apply a preset."))
 }
 
 // CommandParams holds dependencies for the list command
@@ -236,10 +250,17 @@ func NewCommand(params CommandParams) CommandResult {
 		Use:   "list",
 		Short: "List DNS presets",
 		Long:  `List all available DNS presets.`,
-		Run: func(cmd *cobra.Command, args []string) {
-			if err := params.Service.PrintTable(); err != nil {
-				fmt.Println("Error listing presets:", err)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			output, err := params.Service.FormatPresets(params.Service.ListPresets(), format)
+			if err != nil {
+				return fmt.Errorf("failed to list presets: %w", err)
 			}
+			fmt.Println(output)
+			return nil
 		},
 	}
 
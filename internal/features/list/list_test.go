@@ -1,13 +1,16 @@
 package list
 
 import (
+	"encoding/json"
 	"log/slog"
 	"sort"
 	"testing"
 
 	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/ui"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // ListPresets returns a list of DNS presets combined from built-ins and custom config
@@ -71,3 +74,27 @@ func TestListPresets_Sorting(t *testing.T) {
 	// This might fail initially if implementation doesn't sort, which is fine for TDD loop.
 	assert.True(t, isSorted, "Presets should be sorted by name")
 }
+
+func TestService_FormatPresets(t *testing.T) {
+	svc := NewService(&config.Config{}, slog.Default())
+	items := []PresetItem{
+		{Name: "Cloudflare", Slug: "cloudflare", Servers: "1.1.1.1, 1.0.0.1", Type: "Built-in"},
+		{Name: "office", Slug: "office", Servers: "10.0.0.53", Type: "Custom"},
+	}
+
+	output, err := svc.FormatPresets(items, ui.FormatPlain)
+	require.NoError(t, err)
+	assert.Equal(t, "Cloudflare\tcloudflare\tBuilt-in\t1.1.1.1, 1.0.0.1\noffice\toffice\tCustom\t10.0.0.53", output)
+
+	output, err = svc.FormatPresets(items, ui.FormatJSON)
+	require.NoError(t, err)
+	var parsed []PresetItem
+	require.NoError(t, json.Unmarshal([]byte(output), &parsed))
+	assert.Equal(t, items, parsed)
+	assert.Contains(t, output, `"slug": "cloudflare"`)
+
+	output, err = svc.FormatPresets(items, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Contains(t, output, "COMMAND ID")
+	assert.Contains(t, output, "Config: defaults (no config file found)")
+}
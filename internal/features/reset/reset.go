@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sort"
 
 	"gitlab.com/junevm/cdns/internal/config"
 	"gitlab.com/junevm/cdns/internal/dns/backend"
 	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/state"
 	"gitlab.com/junevm/cdns/internal/features/status"
 	"gitlab.com/junevm/cdns/internal/ui"
 
@@ -34,7 +36,16 @@ type Reader interface {
 
 // DNSWriter matches the interface needed to apply DNS settings
 type DNSWriter interface {
+	Apply(ctx context.Context, backend models.Backend, configs []models.DNSConfig) error
 	ResetToAutomatic(ctx context.Context, backend models.Backend, interfaces []string) error
+	ResetNMGlobalDNS(ctx context.Context) error
+	RestoreResolvConf(previous models.ResolvConf) error
+}
+
+// StateStore gives access to the record of changes made by cdns
+type StateStore interface {
+	Load() (*state.Snapshot, error)
+	Clear() error
 }
 
 // Service handles the business logic for reset feature
@@ -45,57 +56,217 @@ type Service struct {
 	detector Detector
 	reader   Reader
 	writer   DNSWriter
+	state    StateStore
+	root     *backend.Root
 }
 
 // NewService creates a new reset service
-func NewService(cfg *config.Config, logger *slog.Logger, sysOps backend.SystemOps) *Service {
+func NewService(cfg *config.Config, logger *slog.Logger, sysOps backend.SystemOps, nm backend.NMClient, runner backend.CommandRunner, root *backend.Root, detector *backend.Detector, store *state.Store) *Service {
 	return &Service{
 		config:   cfg,
 		logger:   logger,
 		styles:   ui.NewStyles(),
-		detector: backend.NewDetector(sysOps),
-		reader:   backend.NewConfigReader(sysOps),
-		writer:   backend.NewConfigWriter(sysOps),
+		detector: detector,
+		reader:   backend.NewConfigReader(sysOps, nm, runner, root),
+		writer:   backend.NewConfigWriter(sysOps, nm, runner, root),
+		state:    store,
+		root:     root,
 	}
 }
 
+// Result describes what a reset run restored
+type Result struct {
+	Backend models.Backend `json:"backend"`
+	// Changed is false when there was nothing to reset
+	Changed bool `json:"changed"`
+	// Global is set when the NetworkManager global DNS was removed
+	Global bool `json:"global,omitempty"`
+	// Interfaces lists the interfaces returned to automatic DNS. netplan,
+	// and systemd-resolved offline, are reset as a whole and list none.
+	Interfaces []string `json:"interfaces"`
+	// RestoredLinks lists the interfaces whose DNSSEC, LLMNR or
+	// MulticastDNS settings were put back
+	RestoredLinks []string `json:"restored_links,omitempty"`
+}
+
 // Reset restores the system default DNS configuration (Automatic/DHCP)
-func (s *Service) Reset(ctx context.Context) error {
+func (s *Service) Reset(ctx context.Context) (*Result, error) {
 	s.logger.Debug("resetting DNS configuration to system default")
 
 	// Detect backend
 	b, err := s.detector.Detect()
 	if err != nil {
-		return fmt.Errorf("failed to detect DNS backend: %w", err)
+		return nil, fmt.Errorf("failed to detect DNS backend: %w", err)
 	}
+	result := &Result{Backend: b, Interfaces: []string{}}
 
-	// Read current status to get active interfaces
-	statusInfo, err := s.reader.ReadDNSConfig(ctx, b)
-	if err != nil {
-		return fmt.Errorf("failed to read current configuration: %w", err)
+	if b == models.BackendResolvConf {
+		return s.resetResolvConf()
 	}
 
+	// netplan, and resolved offline, are reset by removing the file cdns
+	// owns, whatever interfaces it configured
 	var interfaces []string
-	for _, iface := range statusInfo.Interfaces {
-		interfaces = append(interfaces, iface.Name)
-	}
+	if b != models.BackendNetplan && !(b == models.BackendSystemdResolved && s.root.Offline()) {
+		// Read current status to get active interfaces
+		statusInfo, err := s.reader.ReadDNSConfig(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read current configuration: %w", err)
+		}
 
-	if len(interfaces) == 0 {
-		fmt.Printf("\n%s\n", s.styles.RenderWarning("No active interfaces found to reset."))
-		return nil
+		for _, iface := range statusInfo.Interfaces {
+			interfaces = append(interfaces, iface.Name)
+		}
+
+		if len(interfaces) == 0 {
+			return result, nil
+		}
 	}
 
 	// Apply configuration
 	if err := s.writer.ResetToAutomatic(ctx, b, interfaces); err != nil {
-		return fmt.Errorf("failed to reset configuration: %w", err)
+		return nil, fmt.Errorf("failed to reset configuration: %w", err)
 	}
+	result.Changed = true
+	result.Interfaces = append(result.Interfaces, interfaces...)
+
+	restored, err := s.restoreLinkSettings(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	result.RestoredLinks = restored
 
 	s.logger.Debug("successfully reset DNS configuration",
 		slog.String("backend", string(b)))
 
-	fmt.Printf("\n%s\n", s.styles.RenderSuccess("DNS configuration reset to system default (Automatic/DHCP)"))
+	return result, nil
+}
+
+// resetResolvConf puts back the resolv.conf lines recorded before cdns
+// first rewrote the file: it has no automatic configuration to return to.
+// Without a recording there is nothing to reset.
+func (s *Service) resetResolvConf() (*Result, error) {
+	result := &Result{Backend: models.BackendResolvConf, Interfaces: []string{}}
+	if s.root.Offline() {
+		return nil, fmt.Errorf("%w: resolv.conf is restored from the state recorded on the host, not offline", backend.ErrUnsupported)
+	}
+	if s.state == nil {
+		return result, nil
+	}
+
+	snap, err := s.state.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	if snap.Backend != models.BackendResolvConf || snap.PreviousResolvConf == nil {
+		return result, nil
+	}
+
+	if err := s.writer.RestoreResolvConf(*snap.PreviousResolvConf); err != nil {
+		return nil, fmt.Errorf("failed to reset configuration: %w", err)
+	}
+	if err := s.state.Clear(); err != nil {
+		s.logger.Warn("failed to clear state", slog.Any("error", err))
+	}
+	result.Changed = true
+	result.Interfaces = append(result.Interfaces, "system")
+
+	s.logger.Debug("restored resolv.conf")
+	return result, nil
+}
+
+// restoreLinkSettings puts back the DNSSEC, LLMNR and MulticastDNS settings
+// recorded before cdns changed them, then forgets the snapshot. Offline the
+// snapshot describes the host, so it is left alone. The interfaces whose
+// settings were restored are returned.
+func (s *Service) restoreLinkSettings(ctx context.Context, b models.Backend) ([]string, error) {
+	if s.state == nil || s.root.Offline() {
+		return nil, nil
+	}
+
+	snap, err := s.state.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var restored []string
+	if snap.Backend == b {
+		var configs []models.DNSConfig
+		for iface, rec := range snap.Interfaces {
+			// Only restore the settings cdns actually changed
+			var restore models.LinkSettings
+			if rec.Link.DNSSEC != "" {
+				restore.DNSSEC = rec.PreviousLink.DNSSEC
+			}
+			if rec.Link.LLMNR != "" {
+				restore.LLMNR = rec.PreviousLink.LLMNR
+			}
+			if rec.Link.MulticastDNS != "" {
+				restore.MulticastDNS = rec.PreviousLink.MulticastDNS
+			}
+			if restore.IsZero() {
+				continue
+			}
+			configs = append(configs, models.DNSConfig{
+				Interface: models.NetworkInterface{Name: iface, Backend: b},
+				Link:      restore,
+			})
+		}
+
+		if len(configs) > 0 {
+			if err := s.writer.Apply(ctx, b, configs); err != nil {
+				return nil, fmt.Errorf("failed to restore link settings: %w", err)
+			}
+		}
+		for _, cfg := range configs {
+			restored = append(restored, cfg.Interface.Name)
+		}
+		sort.Strings(restored)
+	}
+
+	if err := s.state.Clear(); err != nil {
+		s.logger.Warn("failed to clear state", slog.Any("error", err))
+	}
+	return restored, nil
+}
+
+// ResetGlobal removes the NetworkManager global DNS written by 'set --global',
+// so every connection uses its own servers again
+func (s *Service) ResetGlobal(ctx context.Context) (*Result, error) {
+	b, err := s.detector.Detect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect DNS backend: %w", err)
+	}
+	if b != models.BackendNetworkManager {
+		return nil, fmt.Errorf("%w: --global requires NetworkManager, not %s", backend.ErrUnsupported, b)
+	}
+
+	if err := s.writer.ResetNMGlobalDNS(ctx); err != nil {
+		return nil, fmt.Errorf("failed to reset global DNS: %w", err)
+	}
+
+	s.logger.Debug("successfully removed global DNS")
+
+	return &Result{Backend: b, Changed: true, Global: true, Interfaces: []string{}}, nil
+}
 
-	return nil
+// FormatResult formats the reset result for output
+func (s *Service) FormatResult(result *Result, format ui.Format) (string, error) {
+	return ui.Render(format, result, func(plain bool) string {
+		var message string
+		switch {
+		case !result.Changed:
+			message = s.styles.RenderWarning("No active interfaces found to reset.")
+		case result.Global:
+			message = s.styles.RenderSuccess("Global DNS removed, connections use their own DNS again")
+		default:
+			message = s.styles.RenderSuccess("DNS configuration reset to system default (Automatic/DHCP)")
+		}
+		if plain {
+			return message
+		}
+		return "\n" + message
+	})
 }
 
 // CommandResult wraps the reset command
@@ -107,13 +278,33 @@ type CommandResult struct {
 
 // NewCommand creates the reset cobra command
 func NewCommand(s *Service) CommandResult {
+	var global bool
 	cmd := &cobra.Command{
 		Use:   "reset",
 		Short: "Restore previous DNS configuration",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return s.Reset(cmd.Context())
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			var result *Result
+			if global {
+				result, err = s.ResetGlobal(cmd.Context())
+			} else {
+				result, err = s.Reset(cmd.Context())
+			}
+			if err != nil {
+				return err
+			}
+			output, err := s.FormatResult(result, format)
+			if err != nil {
+				return err
+			}
+			fmt.Println(output)
+			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&global, "global", false, "remove the NetworkManager global DNS set with 'set --global'")
 	return CommandResult{Cmd: cmd}
 }
 
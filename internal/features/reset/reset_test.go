@@ -6,11 +6,13 @@ import (
 	"testing"
 
 	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/state"
 	"gitlab.com/junevm/cdns/internal/features/status"
 	"gitlab.com/junevm/cdns/internal/ui"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockDetector is a mock of reset.Detector
@@ -41,11 +43,41 @@ type MockDNSWriter struct {
 	mock.Mock
 }
 
+func (m *MockDNSWriter) Apply(ctx context.Context, backend models.Backend, configs []models.DNSConfig) error {
+	args := m.Called(ctx, backend, configs)
+	return args.Error(0)
+}
+
 func (m *MockDNSWriter) ResetToAutomatic(ctx context.Context, backend models.Backend, interfaces []string) error {
 	args := m.Called(ctx, backend, interfaces)
 	return args.Error(0)
 }
 
+func (m *MockDNSWriter) ResetNMGlobalDNS(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
+func (m *MockDNSWriter) RestoreResolvConf(previous models.ResolvConf) error {
+	return m.Called(previous).Error(0)
+}
+
+// MockStateStore is a mock of reset.StateStore
+type MockStateStore struct {
+	mock.Mock
+}
+
+func (m *MockStateStore) Load() (*state.Snapshot, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*state.Snapshot), args.Error(1)
+}
+
+func (m *MockStateStore) Clear() error {
+	return m.Called().Error(0)
+}
+
 func TestResetService_Reset(t *testing.T) {
 	t.Run("successful reset", func(t *testing.T) {
 		backend := models.BackendNetworkManager
@@ -75,8 +107,10 @@ func TestResetService_Reset(t *testing.T) {
 			styles:   ui.NewStyles(),
 		}
 
-		err := svc.Reset(context.Background())
+		result, err := svc.Reset(context.Background())
 		assert.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Equal(t, []string{"eth0", "wlan0"}, result.Interfaces)
 		mockDetector.AssertExpectations(t)
 		mockReader.AssertExpectations(t)
 		mockWriter.AssertExpectations(t)
@@ -106,10 +140,148 @@ func TestResetService_Reset(t *testing.T) {
 			styles:   ui.NewStyles(),
 		}
 
-		err := svc.Reset(context.Background())
+		result, err := svc.Reset(context.Background())
 		assert.NoError(t, err)
+		assert.False(t, result.Changed)
+		assert.Empty(t, result.Interfaces)
 		mockDetector.AssertExpectations(t)
 		mockReader.AssertExpectations(t)
 		mockWriter.AssertExpectations(t)
 	})
+	t.Run("restores recorded link settings", func(t *testing.T) {
+		backend := models.BackendSystemdResolved
+		statusInfo := &status.StatusInfo{
+			Backend:    backend,
+			Interfaces: []status.InterfaceStatus{{Name: "eth0"}, {Name: "wlan0"}},
+		}
+		snapshot := &state.Snapshot{
+			Backend: backend,
+			Interfaces: map[string]state.InterfaceRecord{
+				"eth0": {
+					Link:         models.LinkSettings{DNSSEC: "yes"},
+					PreviousLink: models.LinkSettings{DNSSEC: "allow-downgrade", LLMNR: "yes"},
+				},
+				"wlan0": {Servers: []string{"1.1.1.1"}},
+			},
+		}
+
+		mockDetector := new(MockDetector)
+		mockDetector.On("Detect").Return(backend, nil)
+
+		mockReader := new(MockReader)
+		mockReader.On("ReadDNSConfig", mock.Anything, backend).Return(statusInfo, nil)
+
+		mockWriter := new(MockDNSWriter)
+		mockWriter.On("ResetToAutomatic", mock.Anything, backend, []string{"eth0", "wlan0"}).Return(nil)
+		mockWriter.On("Apply", mock.Anything, backend, []models.DNSConfig{{
+			Interface: models.NetworkInterface{Name: "eth0", Backend: backend},
+			Link:      models.LinkSettings{DNSSEC: "allow-downgrade"},
+		}}).Return(nil)
+
+		mockState := new(MockStateStore)
+		mockState.On("Load").Return(snapshot, nil)
+		mockState.On("Clear").Return(nil)
+
+		svc := &Service{
+			detector: mockDetector,
+			reader:   mockReader,
+			writer:   mockWriter,
+			state:    mockState,
+			logger:   slog.Default(),
+			styles:   ui.NewStyles(),
+		}
+
+		result, err := svc.Reset(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"eth0"}, result.RestoredLinks)
+		mockWriter.AssertExpectations(t)
+		mockState.AssertExpectations(t)
+	})
+
+	t.Run("restores the recorded resolv.conf", func(t *testing.T) {
+		backend := models.BackendResolvConf
+		previous := models.ResolvConf{Servers: []string{"192.168.1.1"}, Search: []string{"home.lan"}}
+
+		mockDetector := new(MockDetector)
+		mockDetector.On("Detect").Return(backend, nil)
+
+		mockWriter := new(MockDNSWriter)
+		mockWriter.On("RestoreResolvConf", previous).Return(nil)
+
+		mockState := new(MockStateStore)
+		mockState.On("Load").Return(&state.Snapshot{Backend: backend, PreviousResolvConf: &previous}, nil)
+		mockState.On("Clear").Return(nil)
+
+		svc := &Service{
+			detector: mockDetector,
+			reader:   new(MockReader),
+			writer:   mockWriter,
+			state:    mockState,
+			logger:   slog.Default(),
+			styles:   ui.NewStyles(),
+		}
+
+		result, err := svc.Reset(context.Background())
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Equal(t, []string{"system"}, result.Interfaces)
+		mockWriter.AssertExpectations(t)
+		mockState.AssertExpectations(t)
+
+		// Without a recording there is nothing to put back
+		mockState = new(MockStateStore)
+		mockState.On("Load").Return(&state.Snapshot{Interfaces: map[string]state.InterfaceRecord{}}, nil)
+		svc.state = mockState
+		svc.writer = new(MockDNSWriter)
+		result, err = svc.Reset(context.Background())
+		require.NoError(t, err)
+		assert.False(t, result.Changed)
+	})
+}
+
+func TestResetService_ResetGlobal(t *testing.T) {
+	t.Run("removes NetworkManager global DNS", func(t *testing.T) {
+		mockDetector := new(MockDetector)
+		mockDetector.On("Detect").Return(models.BackendNetworkManager, nil)
+		mockWriter := new(MockDNSWriter)
+		mockWriter.On("ResetNMGlobalDNS", mock.Anything).Return(nil)
+
+		svc := &Service{detector: mockDetector, writer: mockWriter, logger: slog.Default(), styles: ui.NewStyles()}
+		result, err := svc.ResetGlobal(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, result.Global)
+		mockWriter.AssertExpectations(t)
+	})
+
+	t.Run("requires NetworkManager", func(t *testing.T) {
+		mockDetector := new(MockDetector)
+		mockDetector.On("Detect").Return(models.BackendSystemdResolved, nil)
+		mockWriter := new(MockDNSWriter)
+
+		svc := &Service{detector: mockDetector, writer: mockWriter, logger: slog.Default(), styles: ui.NewStyles()}
+		_, err := svc.ResetGlobal(context.Background())
+		assert.ErrorContains(t, err, "--global requires NetworkManager")
+		mockWriter.AssertExpectations(t)
+	})
+}
+
+func TestResetService_FormatResult(t *testing.T) {
+	svc := &Service{logger: slog.Default(), styles: ui.NewStyles()}
+	result := &Result{Backend: models.BackendNetworkManager, Changed: true, Interfaces: []string{"eth0", "wlan0"}}
+
+	output, err := svc.FormatResult(result, ui.FormatPlain)
+	require.NoError(t, err)
+	assert.Contains(t, output, "DNS configuration reset to system default")
+
+	output, err = svc.FormatResult(&Result{Backend: models.BackendNetworkManager, Interfaces: []string{}}, ui.FormatPlain)
+	require.NoError(t, err)
+	assert.Contains(t, output, "No active interfaces found to reset.")
+
+	output, err = svc.FormatResult(result, ui.FormatYAML)
+	require.NoError(t, err)
+	assert.Equal(t, `backend: NetworkManager
+changed: true
+interfaces:
+  - eth0
+  - wlan0`, output)
 }
@@ -0,0 +1,155 @@
+package set
+
+import (
+	"fmt"
+
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+)
+
+// PlanCommandResult wraps the plan command for Fx
+type PlanCommandResult struct {
+	fx.Out
+
+	Cmd *cobra.Command `name:"plan"`
+}
+
+// NewPlanCommand creates the 'plan' command
+func NewPlanCommand(params struct {
+	fx.In
+	Service *Service
+}) PlanCommandResult {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "plan -f <file>",
+		Short: "Show how DNS differs from a desired state file",
+		Long: `Compare the DNS settings declared in a desired state file with the live
+configuration and show what 'cdns apply' would change. Nothing is written.
+
+Examples:
+  # Show the changes
+  cdns plan -f dns.yaml
+
+  # Changes as JSON
+  cdns plan -f dns.yaml --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			plan, err := loadPlan(cmd, params.Service, file)
+			if err == nil {
+				var output string
+				if output, err = params.Service.FormatPlan(plan, format); err == nil {
+					fmt.Println(output)
+				}
+			}
+			return exitError(cmd, err)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "desired state file (YAML)")
+	_ = cmd.MarkFlagRequired("file")
+
+	return PlanCommandResult{Cmd: cmd}
+}
+
+// ApplyCommandResult wraps the apply command for Fx
+type ApplyCommandResult struct {
+	fx.Out
+
+	Cmd *cobra.Command `name:"apply"`
+}
+
+// NewApplyCommand creates the 'apply' command
+func NewApplyCommand(params struct {
+	fx.In
+	Service *Service
+}) ApplyCommandResult {
+	var file string
+	var opts SetOptions
+
+	cmd := &cobra.Command{
+		Use:   "apply -f <file>",
+		Short: "Apply a desired state file",
+		Long: `Bring DNS in line with a desired state file, writing only the interfaces
+whose settings differ. When everything already matches nothing is written
+and apply exits 0, so it can run on every provisioning pass.
+
+The file lists the backend, which may be left out to detect it, and the
+servers or preset of each interface, with optional search domains and
+resolver options:
+
+  backend: networkmanager
+  interfaces:
+    eth0:
+      servers: [10.0.0.53, 10.0.0.54]
+      search: [corp.example]
+      options: [ndots:2]
+    wlan0:
+      preset: cloudflare
+
+Examples:
+  # Review, then apply
+  cdns plan -f dns.yaml
+  cdns apply -f dns.yaml
+
+  # Without confirmation, e.g. from a provisioning tool
+  cdns apply -f dns.yaml --yes --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			opts.Output = format
+
+			plan, err := loadPlan(cmd, params.Service, file)
+			if err == nil && plan.Changed && !params.Service.Offline() {
+				err = EnsurePrivileges()
+			}
+			if err == nil {
+				err = params.Service.ApplyPlan(cmd.Context(), plan, opts)
+			}
+			return exitError(cmd, err)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "desired state file (YAML)")
+	cmd.Flags().BoolVar(&opts.Yes, "yes", false, "skip confirmation prompts")
+	_ = cmd.MarkFlagRequired("file")
+
+	return ApplyCommandResult{Cmd: cmd}
+}
+
+// loadPlan reads the desired state file and plans it. The backend in the
+// file is used unless --backend was given.
+func loadPlan(cmd *cobra.Command, service *Service, file string) (*Plan, error) {
+	desired, err := LoadDesiredState(file)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.Flags().Changed("backend") {
+		if err := service.useBackend(desired, file); err != nil {
+			return nil, err
+		}
+	}
+	return service.PlanState(cmd.Context(), desired)
+}
+
+// exitError prints err and turns it into the exit code main reports
+func exitError(cmd *cobra.Command, err error) error {
+	if err == nil {
+		return nil
+	}
+	if err != ErrUserCancelled {
+		fmt.Fprintln(cmd.ErrOrStderr(), ui.NewStyles().RenderError(err.Error()))
+	}
+	cmd.SilenceUsage = true
+	return fmt.Errorf("exit:%d", ExitCodeFromError(err))
+}
@@ -0,0 +1,33 @@
+package set
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+)
+
+// ValidateConfig checks the dns settings set reads from the config: the
+// interface patterns and the interface_map entries. It runs at startup so a
+// broken config fails every command, not just the first one using it.
+func ValidateConfig(cfg *config.Config) error {
+	for _, key := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"dns.default_interfaces", cfg.DNS.DefaultInterfaces},
+		{"dns.exclude_interfaces", cfg.DNS.ExcludeInterfaces},
+	} {
+		if _, err := discovery.ParsePatterns(key.patterns); err != nil {
+			return fmt.Errorf("invalid %s: %w", key.name, err)
+		}
+	}
+
+	for _, entry := range cfg.DNS.InterfaceMap {
+		if iface, value, ok := strings.Cut(entry, "="); !ok || strings.TrimSpace(iface) == "" || strings.TrimSpace(value) == "" {
+			return fmt.Errorf("invalid dns.interface_map entry: %q (expected iface=preset or iface=ip[,ip...])", entry)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package set
+
+import (
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cfg := &config.Config{DNS: config.DNSConfig{
+		ExcludeInterfaces: []string{"docker*", "/^veth/"},
+		InterfaceMap:      []string{"wlan0=cloudflare", "eth0=10.0.0.53,10.0.0.54"},
+	}}
+	assert.NoError(t, ValidateConfig(cfg))
+
+	cfg.DNS.ExcludeInterfaces = []string{"wl["}
+	assert.ErrorContains(t, ValidateConfig(cfg), "dns.exclude_interfaces")
+
+	cfg.DNS.ExcludeInterfaces = nil
+	cfg.DNS.DefaultInterfaces = []string{"/[/"}
+	assert.ErrorContains(t, ValidateConfig(cfg), "dns.default_interfaces")
+
+	cfg.DNS.DefaultInterfaces = nil
+	cfg.DNS.InterfaceMap = []string{"wlan0"}
+	assert.ErrorContains(t, ValidateConfig(cfg), "dns.interface_map")
+}
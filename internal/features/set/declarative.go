@@ -0,0 +1,501 @@
+package set
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidDesiredState is returned when a desired state file cannot be read
+// as one or declares settings set would refuse
+var ErrInvalidDesiredState = errors.New("invalid desired state")
+
+// OutcomeUnchanged marks an interface that already matches the desired state
+const OutcomeUnchanged = "unchanged"
+
+// Settings compared between the desired state and the live configuration
+const (
+	SettingServers = "servers"
+	SettingSearch  = "search"
+	SettingOptions = "options"
+)
+
+// DesiredState is the DNS configuration a machine should have, as declared
+// in the file given to 'plan -f' and 'apply -f'
+type DesiredState struct {
+	// Backend selects the backend like --backend; empty keeps detection
+	Backend string `yaml:"backend"`
+	// Interfaces maps interface names to their settings. Interfaces not
+	// listed are left alone.
+	Interfaces map[string]DesiredInterface `yaml:"interfaces"`
+}
+
+// DesiredInterface declares the DNS of one interface: a preset or servers,
+// and optionally search domains and resolver options. Search domains and
+// options left out are not managed.
+type DesiredInterface struct {
+	Preset  string   `yaml:"preset"`
+	Servers []string `yaml:"servers"`
+	Search  []string `yaml:"search"`
+	Options []string `yaml:"options"`
+}
+
+// LoadDesiredState reads a desired state file, refusing unknown keys
+func LoadDesiredState(path string) (*DesiredState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read desired state: %w", err)
+	}
+
+	var desired DesiredState
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&desired); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("validation failed: %w: %s: %v", ErrInvalidDesiredState, path, err)
+	}
+	if len(desired.Interfaces) == 0 {
+		return nil, fmt.Errorf("validation failed: %w: %s lists no interfaces", ErrInvalidDesiredState, path)
+	}
+	return &desired, nil
+}
+
+// useBackend makes detection return the backend named in the desired state
+func (s *Service) useBackend(desired *DesiredState, path string) error {
+	if desired.Backend == "" {
+		return nil
+	}
+	if err := s.detector.SetOverride(desired.Backend, path); err != nil {
+		return fmt.Errorf("validation failed: %w: %w", ErrInvalidDesiredState, err)
+	}
+	return nil
+}
+
+// Plan is the difference between a desired state and the live configuration
+type Plan struct {
+	Backend models.Backend `json:"backend"`
+	// Changed reports whether any interface differs from the desired state
+	Changed    bool            `json:"changed"`
+	Warnings   []string        `json:"warnings,omitempty"`
+	Interfaces []InterfacePlan `json:"interfaces"`
+}
+
+// InterfacePlan lists the settings of one interface that differ from the
+// desired state
+type InterfacePlan struct {
+	Name    string   `json:"name"`
+	Preset  string   `json:"preset,omitempty"`
+	Outcome string   `json:"outcome"` // unchanged, planned, applied or failed
+	Changes []Change `json:"changes,omitempty"`
+	Error   string   `json:"error,omitempty"`
+
+	config models.DNSConfig // what apply writes
+}
+
+// Change is one setting of an interface that differs from the desired state
+type Change struct {
+	Setting string   `json:"setting"` // servers, search or options
+	Current []string `json:"current"`
+	Desired []string `json:"desired"`
+}
+
+// PlanState validates the desired state as set validates its flags, then
+// compares it with the live configuration read from the backend
+func (s *Service) PlanState(ctx context.Context, desired *DesiredState) (*Plan, error) {
+	names := slices.Sorted(maps.Keys(desired.Interfaces))
+	configs := make([]models.DNSConfig, 0, len(names))
+	presetNames := make(map[string]string, len(names))
+	var servers []string
+	for _, name := range names {
+		cfg, presetName, err := s.desiredConfig(name, desired.Interfaces[name])
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, cfg)
+		presetNames[name] = presetName
+		servers = append(servers, cfg.DNS.Ordered()...)
+	}
+
+	backendObj, err := s.detector.Detect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect DNS backend: %w", err)
+	}
+	s.logger.Debug("detected backend", slog.String("backend", string(backendObj)))
+
+	for i := range configs {
+		configs[i].Interface.Backend = backendObj
+	}
+	if err := s.checkPerInterface(backendObj, len(configs), "the desired state"); err != nil {
+		return nil, err
+	}
+	if err := s.checkDesiredInterfaces(ctx, backendObj, names); err != nil {
+		return nil, err
+	}
+	if err := backend.CheckSupport(backendObj, configs); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	warnings, err := s.checkAddressWarnings(slices.Compact(slices.Sorted(slices.Values(servers))), configs, SetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := s.reader.ReadDNSConfig(ctx, backendObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current configuration: %w", err)
+	}
+
+	plan := &Plan{Backend: backendObj, Warnings: warnings, Interfaces: make([]InterfacePlan, 0, len(configs))}
+	for _, cfg := range configs {
+		name := cfg.Interface.Name
+		changes := diffConfig(s.liveInterface(ctx, live, backendObj, name), cfg)
+		outcome := OutcomeUnchanged
+		if len(changes) > 0 {
+			outcome = OutcomePlanned
+			plan.Changed = true
+		}
+		plan.Interfaces = append(plan.Interfaces, InterfacePlan{
+			Name:    name,
+			Preset:  presetNames[name],
+			Outcome: outcome,
+			Changes: changes,
+			config:  cfg,
+		})
+	}
+	return plan, nil
+}
+
+// checkDesiredInterfaces makes sure every interface the state names exists,
+// so a typo fails the plan instead of the write. Offline, NetworkManager
+// needs a profile bound to the interface and netplan a definition of it;
+// resolv.conf and systemd-resolved have one system-wide list.
+func (s *Service) checkDesiredInterfaces(ctx context.Context, backendObj models.Backend, names []string) error {
+	var known []string
+	switch {
+	case !s.Offline():
+		if s.discovery == nil {
+			return nil
+		}
+		ifaces, err := s.discovery.Interfaces()
+		if err != nil {
+			return err
+		}
+		for _, iface := range ifaces {
+			known = append(known, iface.Name)
+		}
+
+	case backendObj == models.BackendNetworkManager:
+		for _, name := range names {
+			if _, err := s.nm.DeviceProfile(ctx, name); err != nil {
+				return fmt.Errorf("validation failed: %w: no profile in %s is bound to %s", ErrConnectionNotFound, s.root.Dir(), name)
+			}
+		}
+		return nil
+
+	case backendObj == models.BackendNetplan:
+		defined, err := backend.NetplanInterfaces(s.root)
+		if err != nil {
+			return err
+		}
+		known = defined
+
+	default:
+		return nil
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !slices.Contains(known, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("validation failed: %w: %s", ErrNoMatchingInterfaces, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// desiredConfig validates the declared settings of one interface and turns
+// them into the configuration to write. Servers are written as declared,
+// whatever the address family of the interface, and a family the state
+// leaves out is cleared.
+func (s *Service) desiredConfig(name string, desired DesiredInterface) (models.DNSConfig, string, error) {
+	if discovery.IsPattern(name) {
+		return models.DNSConfig{}, "", fmt.Errorf("validation failed: %w: %q (interfaces need a name, not a pattern)", ErrInvalidDesiredState, name)
+	}
+	if err := ValidateInterfaceName(name); err != nil {
+		return models.DNSConfig{}, "", fmt.Errorf("validation failed: %w", err)
+	}
+
+	var servers []string
+	var presetName string
+	switch {
+	case desired.Preset != "" && len(desired.Servers) > 0:
+		return models.DNSConfig{}, "", fmt.Errorf("validation failed: %w: %s: preset and servers cannot be combined", ErrInvalidDesiredState, name)
+	case desired.Preset != "":
+		preset, ok := s.lookupPreset(desired.Preset)
+		if !ok {
+			return models.DNSConfig{}, "", fmt.Errorf("validation failed: %s: %w: %s (use 'cdns list' to see all available presets)", name, ErrInvalidPresetName, strings.ToLower(desired.Preset))
+		}
+		servers, presetName = preset.servers, preset.name
+	case len(desired.Servers) > 0:
+		if err := ValidateDNSAddresses(desired.Servers); err != nil {
+			return models.DNSConfig{}, "", fmt.Errorf("validation failed: %s: %w", name, err)
+		}
+		servers = desired.Servers
+	default:
+		return models.DNSConfig{}, "", fmt.Errorf("validation failed: %w: %s needs a preset or servers", ErrInvalidDesiredState, name)
+	}
+
+	opts := SetOptions{Family: FamilyBoth, Search: desired.Search, ResolverOptions: desired.Options}
+	resolverOptions, err := validateResolverSettings(opts)
+	if err != nil {
+		return models.DNSConfig{}, "", fmt.Errorf("%s: %w", name, err)
+	}
+	ipv4, ipv6 := SeparateIPv4AndIPv6(servers)
+	dns, _, err := s.serversFor(models.NetworkInterface{Name: name}, ipv4, ipv6, opts)
+	if err != nil {
+		return models.DNSConfig{}, "", fmt.Errorf("%s: %w", name, err)
+	}
+	if dns.IPv4 == nil {
+		dns.IPv4 = []string{}
+	}
+	if dns.IPv6 == nil {
+		dns.IPv6 = []string{}
+	}
+
+	return models.DNSConfig{
+		Interface: models.NetworkInterface{Name: name},
+		DNS:       dns,
+		Search:    desired.Search,
+		Options:   resolverOptions,
+	}, presetName, nil
+}
+
+// liveInterface returns the live settings of the named interface.
+// resolv.conf, and systemd-resolved offline, keep one list for the whole
+// system, which stands for whichever interface the state names. Offline
+// NetworkManager lists profiles, found through the interface they are
+// bound to.
+func (s *Service) liveInterface(ctx context.Context, info *status.StatusInfo, backendObj models.Backend, name string) status.InterfaceStatus {
+	listed := name
+	if backendObj == models.BackendNetworkManager && s.Offline() {
+		if profile, err := s.nm.DeviceProfile(ctx, name); err == nil {
+			listed = profile.Name
+		}
+	}
+	for _, iface := range info.Interfaces {
+		if iface.Name == listed {
+			iface.Name = name
+			return iface
+		}
+	}
+	switch {
+	case backendObj == models.BackendResolvConf && len(info.Interfaces) == 1:
+		return info.Interfaces[0]
+	case backendObj == models.BackendSystemdResolved && s.Offline() && info.Global != nil:
+		return *info.Global
+	}
+	return status.InterfaceStatus{Name: name}
+}
+
+// diffConfig lists the settings of cfg that differ from the live ones.
+// Servers are compared with those set by hand, not learned from DHCP;
+// search domains and options only when the state declares them.
+// systemd-resolved keeps one list per link, so a server also found in the
+// DHCP lease still counts as set.
+func diffConfig(live status.InterfaceStatus, cfg models.DNSConfig) []Change {
+	current := live.Configured
+	if current == nil || cfg.Interface.Backend == models.BackendSystemdResolved {
+		current = append(append([]string{}, live.IPv4...), live.IPv6...)
+	}
+
+	var changes []Change
+	if desired := cfg.DNS.Ordered(); !slices.Equal(canonicalServers(current), canonicalServers(desired)) {
+		changes = append(changes, Change{Setting: SettingServers, Current: current, Desired: desired})
+	}
+	if len(cfg.Search) > 0 && !slices.EqualFunc(live.Search, cfg.Search, strings.EqualFold) {
+		changes = append(changes, Change{Setting: SettingSearch, Current: append([]string{}, live.Search...), Desired: cfg.Search})
+	}
+	if len(cfg.Options) > 0 && !slices.Equal(live.Options, cfg.Options) {
+		changes = append(changes, Change{Setting: SettingOptions, Current: append([]string{}, live.Options...), Desired: cfg.Options})
+	}
+	return changes
+}
+
+// canonicalServers writes each server the same way, so 2606:4700:4700:0::1111
+// and 2606:4700:4700::1111 compare equal
+func canonicalServers(servers []string) []string {
+	canonical := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if addr, err := models.ParseServerAddress(server); err == nil {
+			server = addr.String()
+		}
+		canonical = append(canonical, server)
+	}
+	return canonical
+}
+
+// ApplyPlan writes the interfaces that differ from the desired state, each
+// on its own, and leaves the others untouched. A plan without changes
+// writes nothing. With table output the plan is shown and, on a terminal,
+// confirmed first.
+func (s *Service) ApplyPlan(ctx context.Context, plan *Plan, opts SetOptions) error {
+	if err := s.checkOutput(opts); err != nil {
+		return err
+	}
+	if !plan.Changed {
+		if opts.Output.Structured() {
+			return s.printPlan(plan, opts)
+		}
+		fmt.Println(s.styles.RenderSuccess("No changes, DNS matches the desired state."))
+		return nil
+	}
+
+	if !opts.Output.Structured() {
+		s.showPlan(plan)
+		if !opts.Yes && s.IsInteractive() {
+			confirmed, err := s.promptYesNo(SetOptions{})
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				return ErrUserCancelled
+			}
+		}
+	}
+
+	previous := s.capturePrevious(ctx, plan.Backend, nil, models.LinkSettings{})
+	var applied []models.DNSConfig
+	failed, changed := 0, 0
+	for i := range plan.Interfaces {
+		iface := &plan.Interfaces[i]
+		if iface.Outcome == OutcomeUnchanged {
+			continue
+		}
+		changed++
+		if err := s.writer.Apply(ctx, plan.Backend, []models.DNSConfig{iface.config}); err != nil {
+			failed++
+			s.logger.Debug("failed to apply DNS", slog.String("interface", iface.Name), slog.Any("error", err))
+			iface.Outcome, iface.Error = OutcomeFailed, err.Error()
+			if !opts.Output.Structured() {
+				fmt.Printf("%s %s: %s\n", s.styles.Error.Render("✗"), s.styles.RenderBold(iface.Name), err)
+			}
+			continue
+		}
+		applied = append(applied, iface.config)
+		iface.Outcome = OutcomeApplied
+		if !opts.Output.Structured() {
+			fmt.Printf("%s %s: %s\n", s.styles.Success.Render("✔"), s.styles.RenderBold(iface.Name), s.styles.RenderInfo(strings.Join(iface.config.DNS.Ordered(), ", ")))
+		}
+	}
+
+	if len(applied) > 0 {
+		s.recordSnapshot(plan.Backend, ScopeExplicit, applied, previous)
+	}
+	if opts.Output.Structured() {
+		if err := s.printPlan(plan, opts); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d interfaces failed", ErrPartialFailure, failed, changed)
+	}
+	return nil
+}
+
+// FormatPlan formats the plan as text, tab-separated rows, JSON or YAML
+func (s *Service) FormatPlan(plan *Plan, format ui.Format) (string, error) {
+	return ui.Render(format, plan, func(plain bool) string {
+		if plain {
+			return formatPlanPlain(plan)
+		}
+		return s.formatPlan(plan)
+	})
+}
+
+// printPlan prints the plan as JSON or YAML
+func (s *Service) printPlan(plan *Plan, opts SetOptions) error {
+	output, err := s.FormatPlan(plan, opts.Output)
+	if err != nil {
+		return err
+	}
+	fmt.Println(output)
+	return nil
+}
+
+// showPlan prints the plan the way --dry-run shows a set run
+func (s *Service) showPlan(plan *Plan) {
+	fmt.Println(s.formatPlan(plan))
+}
+
+// formatPlan renders each interface with its changes, marking those that
+// already match
+func (s *Service) formatPlan(plan *Plan) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Backend: %s\n", s.styles.RenderInfo(string(plan.Backend))))
+	output.WriteString("Interfaces:\n")
+	for _, iface := range plan.Interfaces {
+		label := s.styles.RenderBold(iface.Name)
+		if iface.Preset != "" {
+			label += " " + s.styles.RenderDim("("+iface.Preset+")")
+		}
+		if len(iface.Changes) == 0 {
+			output.WriteString(fmt.Sprintf("  = %s %s\n", label, s.styles.RenderDim("unchanged")))
+			continue
+		}
+		output.WriteString(fmt.Sprintf("  ~ %s\n", label))
+		for _, change := range iface.Changes {
+			output.WriteString(fmt.Sprintf("      %s: %s → %s\n", change.Setting, listOrNone(change.Current), s.styles.RenderInfo(listOrNone(change.Desired))))
+		}
+	}
+	for _, warning := range plan.Warnings {
+		output.WriteString(fmt.Sprintf("  %s\n", s.styles.RenderWarning(warning)))
+	}
+	if !plan.Changed {
+		output.WriteString("\n" + s.styles.RenderSuccess("No changes, DNS matches the desired state."))
+	}
+	return strings.TrimSuffix(output.String(), "\n")
+}
+
+// formatPlanPlain lists one change per line: interface, setting, current
+// and desired values, comma-separated. Unchanged interfaces have no line.
+func formatPlanPlain(plan *Plan) string {
+	var rows [][]string
+	for _, iface := range plan.Interfaces {
+		for _, change := range iface.Changes {
+			rows = append(rows, []string{iface.Name, change.Setting, listOrDash(change.Current), listOrDash(change.Desired)})
+		}
+	}
+	return ui.PlainRows(rows)
+}
+
+// listOrNone joins values for display, "none" standing for an empty list
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+// listOrDash joins values with commas, "-" standing for an empty list
+func listOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,186 @@
+package set
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/features/status"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDesiredState(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	desired, err := LoadDesiredState(write("dns.yaml", `backend: resolv.conf
+interfaces:
+  eth0:
+    servers: [10.0.0.53]
+    search: [corp.example]
+  wlan0:
+    preset: cloudflare
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "resolv.conf", desired.Backend)
+	assert.Equal(t, DesiredInterface{Servers: []string{"10.0.0.53"}, Search: []string{"corp.example"}}, desired.Interfaces["eth0"])
+	assert.Equal(t, "cloudflare", desired.Interfaces["wlan0"].Preset)
+
+	_, err = LoadDesiredState(write("typo.yaml", "interfaces:\n  eth0:\n    server: [10.0.0.53]\n"))
+	assert.ErrorIs(t, err, ErrInvalidDesiredState)
+	assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+
+	_, err = LoadDesiredState(write("empty.yaml", ""))
+	assert.ErrorIs(t, err, ErrInvalidDesiredState)
+}
+
+func TestService_PlanState_Validation(t *testing.T) {
+	s := &Service{logger: slog.Default(), styles: ui.NewStyles()}
+
+	for name, iface := range map[string]DesiredInterface{
+		"both":    {Preset: "cloudflare", Servers: []string{"1.1.1.1"}},
+		"neither": {Search: []string{"corp.example"}},
+		"preset":  {Preset: "nope"},
+		"servers": {Servers: []string{"1.1.1.300"}},
+		"search":  {Servers: []string{"1.1.1.1"}, Search: []string{"-bad-"}},
+	} {
+		_, err := s.PlanState(t.Context(), &DesiredState{Interfaces: map[string]DesiredInterface{"eth0": iface}})
+		assert.Error(t, err, name)
+		assert.Equal(t, ExitValidationError, ExitCodeFromError(err), name)
+	}
+
+	_, err := s.PlanState(t.Context(), &DesiredState{Interfaces: map[string]DesiredInterface{"eth*": {Preset: "cloudflare"}}})
+	assert.ErrorIs(t, err, ErrInvalidDesiredState)
+}
+
+func TestService_ApplyPlan(t *testing.T) {
+	dir := t.TempDir()
+	root := backend.NewRoot()
+	require.NoError(t, root.Set(dir))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "etc"), 0o755))
+	resolvConf := filepath.Join(dir, "etc/resolv.conf")
+	require.NoError(t, os.WriteFile(resolvConf, []byte("nameserver 192.168.1.1\n"), 0o644))
+
+	nm := backend.NewNMClient(nil, root)
+	s := &Service{
+		logger:   slog.Default(),
+		styles:   ui.NewStyles(),
+		root:     root,
+		reader:   backend.NewConfigReader(nil, nm, nil, root),
+		writer:   backend.NewConfigWriter(nil, nm, nil, root),
+		detector: backend.NewDetector(nil, root),
+	}
+	desired := &DesiredState{
+		Backend: "resolv.conf",
+		Interfaces: map[string]DesiredInterface{
+			"eth0": {Servers: []string{"10.0.0.53", "2606:4700:4700::1111"}, Search: []string{"corp.example"}},
+		},
+	}
+	require.NoError(t, s.useBackend(desired, "dns.yaml"))
+
+	plan, err := s.PlanState(t.Context(), desired)
+	require.NoError(t, err)
+	assert.Equal(t, models.BackendResolvConf, plan.Backend)
+	assert.True(t, plan.Changed)
+	require.Len(t, plan.Interfaces, 1)
+	assert.Equal(t, OutcomePlanned, plan.Interfaces[0].Outcome)
+	assert.Equal(t, []Change{
+		{Setting: SettingServers, Current: []string{"192.168.1.1"}, Desired: []string{"10.0.0.53", "2606:4700:4700::1111"}},
+		{Setting: SettingSearch, Current: []string{}, Desired: []string{"corp.example"}},
+	}, plan.Interfaces[0].Changes)
+
+	output, err := s.FormatPlan(plan, ui.FormatPlain)
+	require.NoError(t, err)
+	assert.Equal(t, "eth0\tservers\t192.168.1.1\t10.0.0.53,2606:4700:4700::1111\neth0\tsearch\t-\tcorp.example", output)
+
+	require.NoError(t, s.ApplyPlan(t.Context(), plan, SetOptions{Yes: true}))
+	assert.Equal(t, OutcomeApplied, plan.Interfaces[0].Outcome)
+	data, err := os.ReadFile(resolvConf)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "nameserver 10.0.0.53\n")
+	assert.Contains(t, string(data), "search corp.example\n")
+
+	// The second run finds nothing to change and writes nothing
+	plan, err = s.PlanState(t.Context(), desired)
+	require.NoError(t, err)
+	assert.False(t, plan.Changed)
+	assert.Equal(t, OutcomeUnchanged, plan.Interfaces[0].Outcome)
+
+	s.writer = nil
+	assert.NoError(t, s.ApplyPlan(t.Context(), plan, SetOptions{Yes: true}))
+}
+
+func TestService_ApplyPlan_NetworkManagerOffline(t *testing.T) {
+	dir := t.TempDir()
+	root := backend.NewRoot()
+	require.NoError(t, root.Set(dir))
+	connections := filepath.Join(dir, "etc/NetworkManager/system-connections")
+	require.NoError(t, os.MkdirAll(connections, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(connections, "office.nmconnection"), []byte(`[connection]
+id=Office
+uuid=5f3c3a2e-6a0e-4f4b-9d55-3c1d2a7b9e10
+type=ethernet
+interface-name=eth0
+
+[ipv4]
+method=auto
+dns=192.168.1.1;
+
+[ipv6]
+method=auto
+dns=2606:4700:4700::1111;
+ignore-auto-dns=true
+`), 0o600))
+
+	nm := backend.NewNMClient(nil, root)
+	s := &Service{
+		logger:   slog.Default(),
+		styles:   ui.NewStyles(),
+		root:     root,
+		nm:       nm,
+		reader:   backend.NewConfigReader(nil, nm, nil, root),
+		writer:   backend.NewConfigWriter(nil, nm, nil, root),
+		detector: backend.NewDetector(nil, root),
+	}
+
+	// A typo fails the plan rather than the write
+	typo := &DesiredState{Backend: "networkmanager", Interfaces: map[string]DesiredInterface{"eht0": {Servers: []string{"10.0.0.53"}}}}
+	require.NoError(t, s.useBackend(typo, "dns.yaml"))
+	_, err := s.PlanState(t.Context(), typo)
+	assert.ErrorIs(t, err, ErrConnectionNotFound)
+	assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+
+	// IPv6 servers the state leaves out are removed, so it converges
+	desired := &DesiredState{Backend: "networkmanager", Interfaces: map[string]DesiredInterface{"eth0": {Servers: []string{"10.0.0.53"}}}}
+	plan, err := s.PlanState(t.Context(), desired)
+	require.NoError(t, err)
+	require.True(t, plan.Changed)
+	require.NoError(t, s.ApplyPlan(t.Context(), plan, SetOptions{Yes: true}))
+
+	plan, err = s.PlanState(t.Context(), desired)
+	require.NoError(t, err)
+	assert.False(t, plan.Changed, "%+v", plan.Interfaces)
+}
+
+func TestDiffConfig_SystemdResolvedLease(t *testing.T) {
+	// The server set by apply is also in the DHCP lease of the link
+	live := status.InterfaceStatus{Name: "eth0", IPv4: []string{"10.0.0.53"}, IPv6: []string{}, DHCP: []string{"10.0.0.53"}}
+	cfg := models.DNSConfig{
+		Interface: models.NetworkInterface{Name: "eth0", Backend: models.BackendSystemdResolved},
+		DNS:       models.DNSServer{IPv4: []string{"10.0.0.53"}, IPv6: []string{}},
+	}
+	assert.Empty(t, diffConfig(live, cfg))
+
+	cfg.DNS.IPv4 = []string{"1.1.1.1"}
+	assert.Equal(t, []Change{{Setting: SettingServers, Current: []string{"10.0.0.53"}, Desired: []string{"1.1.1.1"}}}, diffConfig(live, cfg))
+}
@@ -0,0 +1,90 @@
+package set
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+const (
+	// FamilyAuto uses IPv6 servers only on interfaces with a global IPv6 address
+	FamilyAuto = "auto"
+	// FamilyIPv4 uses only IPv4 servers
+	FamilyIPv4 = "ipv4"
+	// FamilyIPv6 uses only IPv6 servers
+	FamilyIPv6 = "ipv6"
+	// FamilyBoth uses IPv4 and IPv6 servers
+	FamilyBoth = "both"
+)
+
+// family returns the normalized --family value, defaulting to auto
+func (o SetOptions) family() string {
+	if o.Family == "" {
+		return FamilyAuto
+	}
+	return strings.ToLower(o.Family)
+}
+
+// serversFor selects the servers to configure on a target according to
+// --family and --prefer. The returned note explains servers left out.
+func (s *Service) serversFor(target models.NetworkInterface, ipv4, ipv6 []string, opts SetOptions) (models.DNSServer, string, error) {
+	server := models.DNSServer{
+		IPv4:       ipv4,
+		IPv6:       ipv6,
+		PreferIPv6: strings.EqualFold(opts.Prefer, FamilyIPv6),
+	}
+
+	switch opts.family() {
+	case FamilyIPv4:
+		if len(ipv4) == 0 {
+			return models.DNSServer{}, "", fmt.Errorf("validation failed: %w: --family ipv4 but no IPv4 servers given", ErrInvalidFamily)
+		}
+		server.IPv6 = []string{}
+	case FamilyIPv6:
+		if len(ipv6) == 0 {
+			return models.DNSServer{}, "", fmt.Errorf("validation failed: %w: --family ipv6 but no IPv6 servers given", ErrInvalidFamily)
+		}
+		server.IPv4 = []string{}
+	case FamilyAuto:
+		// Inactive profiles and offline images have no addresses to check,
+		// and IPv6-only lists are kept as given
+		if target.Name == "" || s.root.Offline() || len(ipv6) == 0 || len(ipv4) == 0 || s.discovery == nil {
+			break
+		}
+		global, err := s.discovery.HasGlobalIPv6(target.Name)
+		if err != nil {
+			s.logger.Warn("failed to check IPv6 connectivity, keeping IPv6 servers", slog.String("interface", target.Name), slog.Any("error", err))
+			break
+		}
+		if !global {
+			server.IPv6 = []string{}
+			return server, "IPv4 only, no global IPv6 address", nil
+		}
+	}
+
+	return server, "", nil
+}
+
+// orderedAddresses lists the servers for display, following --prefer and
+// dropping a family excluded with --family. Auto mode is decided per
+// interface and reported with each target instead.
+func orderedAddresses(ipv4, ipv6 []string, opts SetOptions) []string {
+	switch opts.family() {
+	case FamilyIPv4:
+		ipv6 = nil
+	case FamilyIPv6:
+		ipv4 = nil
+	}
+	return models.DNSServer{IPv4: ipv4, IPv6: ipv6, PreferIPv6: strings.EqualFold(opts.Prefer, FamilyIPv6)}.Ordered()
+}
+
+// printAddressFamily prints the --family and --prefer selection for dry-run
+func (s *Service) printAddressFamily(opts SetOptions) {
+	family := opts.family()
+	if strings.EqualFold(opts.Prefer, FamilyIPv6) {
+		family += ", IPv6 first"
+	}
+	fmt.Printf("Address family: %s\n", s.styles.RenderInfo(family))
+}
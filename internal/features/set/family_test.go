@@ -0,0 +1,91 @@
+package set
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ServersFor(t *testing.T) {
+	root := fakeSysfs(t, map[string]string{"eth0": "up", "wlan0": "up"}, "eth0")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "proc", "net", "if_inet6"), []byte(
+		"20010db8000000000000000000000042 03 40 00 80    wlan0\n"+
+			"fe800000000000000000000000000042 02 40 20 80     eth0\n"), 0644))
+	s := &Service{logger: slog.Default(), discovery: discovery.NewDiscovererAt(root)}
+
+	ipv4 := []string{"1.1.1.1"}
+	ipv6 := []string{"2606:4700:4700::1111"}
+	eth0 := models.NetworkInterface{Name: "eth0"}
+	wlan0 := models.NetworkInterface{Name: "wlan0"}
+
+	t.Run("auto drops IPv6 without a global address", func(t *testing.T) {
+		server, note, err := s.serversFor(eth0, ipv4, ipv6, SetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ipv4, server.IPv4)
+		// Empty, not nil, so NetworkManager drops IPv6 servers already set
+		assert.Equal(t, []string{}, server.IPv6)
+		assert.NotEmpty(t, note)
+	})
+
+	t.Run("auto keeps IPv6 with a global address", func(t *testing.T) {
+		server, note, err := s.serversFor(wlan0, ipv4, ipv6, SetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ipv6, server.IPv6)
+		assert.Empty(t, note)
+	})
+
+	t.Run("auto keeps IPv6 for inactive profiles", func(t *testing.T) {
+		server, _, err := s.serversFor(models.NetworkInterface{Connection: "Office WiFi"}, ipv4, ipv6, SetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ipv6, server.IPv6)
+	})
+
+	t.Run("auto keeps IPv6-only lists", func(t *testing.T) {
+		server, _, err := s.serversFor(eth0, nil, ipv6, SetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ipv6, server.IPv6)
+	})
+
+	t.Run("both with IPv6 preferred", func(t *testing.T) {
+		server, _, err := s.serversFor(eth0, ipv4, ipv6, SetOptions{Family: FamilyBoth, Prefer: FamilyIPv6})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2606:4700:4700::1111", "1.1.1.1"}, server.Ordered())
+	})
+
+	t.Run("ipv4 only", func(t *testing.T) {
+		server, _, err := s.serversFor(wlan0, ipv4, ipv6, SetOptions{Family: FamilyIPv4})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1.1.1.1"}, server.Ordered())
+	})
+
+	t.Run("ipv6 only without IPv6 servers", func(t *testing.T) {
+		_, _, err := s.serversFor(wlan0, ipv4, nil, SetOptions{Family: FamilyIPv6})
+		assert.ErrorIs(t, err, ErrInvalidFamily)
+		assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+	})
+}
+
+func TestOrderedAddresses(t *testing.T) {
+	ipv4 := []string{"8.8.8.8"}
+	ipv6 := []string{"2001:4860:4860::8888"}
+
+	assert.Equal(t, []string{"8.8.8.8", "2001:4860:4860::8888"}, orderedAddresses(ipv4, ipv6, SetOptions{}))
+	assert.Equal(t, []string{"2001:4860:4860::8888", "8.8.8.8"}, orderedAddresses(ipv4, ipv6, SetOptions{Prefer: "IPv6"}))
+	assert.Equal(t, []string{"2001:4860:4860::8888"}, orderedAddresses(ipv4, ipv6, SetOptions{Family: FamilyIPv6}))
+}
+
+func TestValidateAddressFamily(t *testing.T) {
+	for _, family := range []string{"auto", "ipv4", "ipv6", "both", "IPv4"} {
+		assert.NoError(t, ValidateAddressFamily(family, ""), family)
+	}
+	assert.NoError(t, ValidateAddressFamily("both", "ipv6"))
+	assert.ErrorIs(t, ValidateAddressFamily("inet6", ""), ErrInvalidFamily)
+	assert.ErrorIs(t, ValidateAddressFamily("both", "both"), ErrInvalidFamily)
+}
@@ -0,0 +1,105 @@
+package set
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// setGlobal applies the servers as NetworkManager global DNS, which every
+// connection, present and future, uses instead of its own servers
+func (s *Service) setGlobal(ctx context.Context, backendObj models.Backend, dnsAddresses, resolverOptions []string, opts SetOptions) error {
+	if backendObj != models.BackendNetworkManager {
+		return fmt.Errorf("validation failed: %w: --global requires NetworkManager, not %s", backend.ErrUnsupported, backendObj)
+	}
+	if len(opts.Interfaces) > 0 || opts.hasConnections() {
+		return fmt.Errorf("validation failed: %w: --global applies to every connection and cannot be combined with --interface or --connection", ErrInvalidScope)
+	}
+
+	ipv4, ipv6 := SeparateIPv4AndIPv6(dnsAddresses)
+	// There is no interface to check IPv6 connectivity on
+	servers, _, err := s.serversFor(models.NetworkInterface{}, ipv4, ipv6, opts)
+	if err != nil {
+		return err
+	}
+	cfg := models.DNSConfig{
+		Interface: models.NetworkInterface{Backend: backendObj},
+		DNS:       servers,
+		Search:    opts.Search,
+		Options:   resolverOptions,
+		Link:      opts.Link,
+	}
+	if !cfg.Link.IsZero() {
+		return fmt.Errorf("validation failed: %w: NetworkManager global DNS has no DNSSEC, LLMNR or MulticastDNS setting", backend.ErrUnsupported)
+	}
+
+	dnsAddresses = orderedAddresses(ipv4, ipv6, opts)
+	warnings, err := s.checkAddressWarnings(dnsAddresses, nil, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun && opts.Output.Structured() {
+		return s.printResult(newGlobalResult(backendObj, dnsAddresses, warnings, opts), opts)
+	}
+	if opts.DryRun {
+		fmt.Printf("%s\n\n", s.styles.RenderBold("Dry-run mode: No changes will be applied"))
+		fmt.Printf("Backend: %s\n", s.styles.RenderInfo(string(backendObj)))
+		fmt.Printf("Scope: %s\n", s.styles.RenderInfo("global, every connection"))
+		s.printAddressFamily(opts)
+		fmt.Printf("DNS servers to set:\n")
+		for _, dns := range dnsAddresses {
+			fmt.Printf("  - %s\n", s.styles.RenderInfo(dns))
+		}
+		s.printAddressWarnings(warnings)
+		s.printResolverSettings(opts)
+		return nil
+	}
+
+	if !opts.Yes && s.IsInteractive() {
+		confirmed, err := s.confirmChange(dnsAddresses, warnings, []string{"every connection (global)"}, opts)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return ErrUserCancelled
+		}
+	}
+
+	if err := s.writer.ApplyNMGlobalDNS(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to apply DNS: %w", err)
+	}
+
+	s.logger.Debug("global DNS applied", slog.Any("dns", dnsAddresses))
+
+	if opts.Output.Structured() {
+		return s.printResult(newGlobalResult(backendObj, dnsAddresses, warnings, opts), opts)
+	}
+	if s.IsInteractive() {
+		fmt.Printf("%s Applied DNS (%s) to every connection.\n",
+			s.styles.Success.Render("✔"),
+			s.styles.RenderInfo(strings.Join(dnsAddresses, ", ")))
+	} else if opts.PresetName != "" {
+		fmt.Printf("Global DNS set to %s\n", opts.PresetName)
+	} else {
+		fmt.Printf("Global DNS set to %s\n", strings.Join(dnsAddresses, ", "))
+	}
+	return nil
+}
+
+// newGlobalResult describes a --global run, which names no interface
+func newGlobalResult(backendObj models.Backend, dnsAddresses, warnings []string, opts SetOptions) *Result {
+	return &Result{
+		Backend:    backendObj,
+		Scope:      "global",
+		Preset:     opts.PresetName,
+		Servers:    dnsAddresses,
+		DryRun:     opts.DryRun,
+		Warnings:   warnings,
+		Interfaces: []InterfaceOutcome{},
+	}
+}
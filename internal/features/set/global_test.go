@@ -0,0 +1,54 @@
+package set
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_SetGlobal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	root := backend.NewRoot()
+	require.NoError(t, root.Set(dir))
+	nm := backend.NewNMClient(nil, root)
+	s := &Service{
+		logger: slog.Default(),
+		styles: ui.NewStyles(),
+		root:   root,
+		writer: backend.NewConfigWriter(nil, nm, nil, root),
+	}
+
+	t.Run("requires NetworkManager", func(t *testing.T) {
+		err := s.setGlobal(ctx, models.BackendSystemdResolved, []string{"9.9.9.9"}, nil, SetOptions{Global: true})
+		assert.ErrorIs(t, err, backend.ErrUnsupported)
+		assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+	})
+
+	t.Run("conflicts with interface", func(t *testing.T) {
+		err := s.setGlobal(ctx, models.BackendNetworkManager, []string{"9.9.9.9"}, nil, SetOptions{Global: true, Interfaces: []string{"eth0"}})
+		assert.ErrorIs(t, err, ErrInvalidScope)
+	})
+
+	t.Run("refuses link settings", func(t *testing.T) {
+		err := s.setGlobal(ctx, models.BackendNetworkManager, []string{"9.9.9.9"}, nil, SetOptions{Global: true, Link: models.LinkSettings{MulticastDNS: "no"}})
+		assert.ErrorIs(t, err, backend.ErrUnsupported)
+	})
+
+	t.Run("writes the conf.d file", func(t *testing.T) {
+		err := s.setGlobal(ctx, models.BackendNetworkManager, []string{"9.9.9.9", "2620:fe::fe"}, nil, SetOptions{Global: true, Yes: true, Family: FamilyIPv4})
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(dir, "etc/NetworkManager/conf.d/90-cdns-global-dns.conf"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "[global-dns-domain-*]\nservers=9.9.9.9\n")
+	})
+}
@@ -0,0 +1,257 @@
+package set
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// ErrInvalidMapping is returned when a --map entry is malformed
+var ErrInvalidMapping = errors.New("invalid interface mapping")
+
+// interfaceAssignment is a single --map entry: the DNS servers for one interface
+type interfaceAssignment struct {
+	Interface string
+	Source    string // preset display name, empty for custom addresses
+	Servers   []string
+	Note      string // why servers were left out, e.g. by --family
+}
+
+// describe renders the assignment for dry-run, confirmation and results
+func (a interfaceAssignment) describe() string {
+	desc := strings.Join(a.Servers, ", ")
+	if a.Source != "" {
+		desc = a.Source + " (" + desc + ")"
+	}
+	if a.Note != "" {
+		desc += " [" + a.Note + "]"
+	}
+	return desc
+}
+
+// parseAssignments validates --map entries of the form iface=preset or
+// iface=ip[,ip...] and resolves presets to their addresses
+func (s *Service) parseAssignments(entries []string) ([]interfaceAssignment, error) {
+	var assignments []interfaceAssignment
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		iface, value, ok := strings.Cut(entry, "=")
+		iface, value = strings.TrimSpace(iface), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("validation failed: %w: %q (expected iface=preset or iface=ip[,ip...])", ErrInvalidMapping, entry)
+		}
+		if discovery.IsPattern(iface) {
+			return nil, fmt.Errorf("validation failed: %w: %q (map entries need an interface name, not a pattern)", ErrInvalidMapping, entry)
+		}
+		if err := ValidateInterfaceName(iface); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		if seen[iface] {
+			return nil, fmt.Errorf("validation failed: %w: %s is mapped more than once", ErrInvalidMapping, iface)
+		}
+		seen[iface] = true
+
+		if preset, ok := s.lookupPreset(value); ok {
+			assignments = append(assignments, interfaceAssignment{Interface: iface, Source: preset.name, Servers: preset.servers})
+			continue
+		}
+
+		var servers []string
+		for _, addr := range strings.Split(value, ",") {
+			servers = append(servers, strings.TrimSpace(addr))
+		}
+		if err := ValidateDNSAddresses(servers); err != nil {
+			return nil, fmt.Errorf("validation failed: %s: %w (not a known preset either)", iface, err)
+		}
+		assignments = append(assignments, interfaceAssignment{Interface: iface, Servers: servers})
+	}
+
+	if len(assignments) == 0 {
+		return nil, fmt.Errorf("validation failed: %w: no entries", ErrInvalidMapping)
+	}
+	return assignments, nil
+}
+
+// SetMap applies different DNS servers to each interface in one run. Every
+// entry is validated before anything is changed; each interface is then
+// applied on its own and reported individually.
+func (s *Service) SetMap(ctx context.Context, entries []string, opts SetOptions) error {
+	if err := s.checkOutput(opts); err != nil {
+		return err
+	}
+	if len(opts.Interfaces) > 0 || opts.hasConnections() || opts.scope() == ScopeAll || opts.Global {
+		return fmt.Errorf("validation failed: %w: --map names its interfaces and cannot be combined with --interface, --connection, --scope all or --global", ErrInvalidMapping)
+	}
+
+	assignments, err := s.parseAssignments(entries)
+	if err != nil {
+		return err
+	}
+	resolverOptions, err := validateResolverSettings(opts)
+	if err != nil {
+		return err
+	}
+	if err := ValidateAddressFamily(opts.family(), opts.Prefer); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	backendObj, err := s.detector.Detect()
+	if err != nil {
+		return fmt.Errorf("failed to detect DNS backend: %w", err)
+	}
+	s.logger.Debug("detected backend", slog.String("backend", string(backendObj)))
+
+	if err := s.checkPerInterface(backendObj, len(assignments), "--map"); err != nil {
+		return err
+	}
+
+	configs := make([]models.DNSConfig, 0, len(assignments))
+	targets := make([]models.NetworkInterface, 0, len(assignments))
+	for i, a := range assignments {
+		target := models.NetworkInterface{Name: a.Interface, Backend: backendObj}
+		ipv4, ipv6 := SeparateIPv4AndIPv6(a.Servers)
+		servers, note, err := s.serversFor(target, ipv4, ipv6, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.Interface, err)
+		}
+		assignments[i].Servers = servers.Ordered()
+		assignments[i].Note = note
+		configs = append(configs, models.DNSConfig{
+			Interface: target,
+			DNS:       servers,
+			Search:    opts.Search,
+			Options:   resolverOptions,
+			Link:      opts.Link,
+		})
+		targets = append(targets, target)
+	}
+
+	if err := backend.CheckSupport(backendObj, configs); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	var servers []string
+	for _, a := range assignments {
+		servers = append(servers, a.Servers...)
+	}
+	warnings, err := s.checkAddressWarnings(slices.Compact(slices.Sorted(slices.Values(servers))), configs, opts)
+	if err != nil {
+		return err
+	}
+
+	result := &Result{
+		Backend:    backendObj,
+		Scope:      ScopeExplicit,
+		DryRun:     opts.DryRun,
+		Warnings:   warnings,
+		Interfaces: make([]InterfaceOutcome, 0, len(assignments)),
+	}
+	for _, a := range assignments {
+		result.Interfaces = append(result.Interfaces, InterfaceOutcome{Name: a.Interface, Servers: a.Servers, Outcome: OutcomePlanned, Reason: a.Note})
+	}
+
+	if opts.DryRun {
+		if opts.Output.Structured() {
+			return s.printResult(result, opts)
+		}
+		s.showMapDryRun(backendObj, assignments, warnings, opts)
+		return nil
+	}
+
+	if !opts.Yes && s.IsInteractive() {
+		confirmed, err := s.confirmMapping(assignments, warnings, opts)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return ErrUserCancelled
+		}
+	}
+
+	previous := s.capturePrevious(ctx, backendObj, targets, opts.Link)
+
+	// Apply each interface separately so one failure does not hide the others
+	var applied []models.DNSConfig
+	failed := 0
+	for i, cfg := range configs {
+		if err := s.writer.Apply(ctx, backendObj, []models.DNSConfig{cfg}); err != nil {
+			failed++
+			s.logger.Debug("failed to apply DNS", slog.String("interface", cfg.Interface.Name), slog.Any("error", err))
+			result.Interfaces[i].Outcome = OutcomeFailed
+			result.Interfaces[i].Error = err.Error()
+			if !opts.Output.Structured() {
+				fmt.Printf("%s %s: %s\n", s.styles.Error.Render("✗"), s.styles.RenderBold(cfg.Interface.Name), err)
+			}
+			continue
+		}
+		applied = append(applied, cfg)
+		result.Interfaces[i].Outcome = OutcomeApplied
+		if !opts.Output.Structured() {
+			fmt.Printf("%s %s: %s\n", s.styles.Success.Render("✔"), s.styles.RenderBold(cfg.Interface.Name), s.styles.RenderInfo(assignments[i].describe()))
+		}
+	}
+
+	if len(applied) > 0 {
+		s.recordSnapshot(backendObj, ScopeExplicit, applied, previous)
+	}
+
+	if opts.Output.Structured() {
+		if err := s.printResult(result, opts); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d interfaces failed", ErrPartialFailure, failed, len(configs))
+	}
+	return nil
+}
+
+// checkPerInterface refuses different servers for count interfaces on
+// backends that keep a single server list for the whole system
+func (s *Service) checkPerInterface(backendObj models.Backend, count int, what string) error {
+	if count <= 1 {
+		return nil
+	}
+	// resolv.conf holds one server list for the whole system
+	if backendObj == models.BackendResolvConf {
+		return fmt.Errorf("validation failed: %w: resolv.conf has a single global server list, %s needs NetworkManager or systemd-resolved", backend.ErrUnsupported, what)
+	}
+	// Offline there are no links, resolved is configured globally
+	if backendObj == models.BackendSystemdResolved && s.Offline() {
+		return fmt.Errorf("validation failed: %w: offline, systemd-resolved has a single global server list, %s needs NetworkManager or netplan", backend.ErrUnsupported, what)
+	}
+	return nil
+}
+
+// showMapDryRun prints the combined plan for a --map run
+func (s *Service) showMapDryRun(backendObj models.Backend, assignments []interfaceAssignment, warnings []string, opts SetOptions) {
+	fmt.Printf("%s\n\n", s.styles.RenderBold("Dry-run mode: No changes will be applied"))
+	fmt.Printf("Backend: %s\n", s.styles.RenderInfo(string(backendObj)))
+	s.printAddressFamily(opts)
+	s.printResolverSettings(opts)
+
+	fmt.Printf("DNS per interface:\n")
+	for _, a := range assignments {
+		fmt.Printf("  - %s: %s\n", s.styles.RenderBold(a.Interface), s.styles.RenderInfo(a.describe()))
+	}
+	s.printAddressWarnings(warnings)
+}
+
+// confirmMapping prompts the user to confirm a --map run
+func (s *Service) confirmMapping(assignments []interfaceAssignment, warnings []string, opts SetOptions) (bool, error) {
+	fmt.Printf("\n%s\n", s.styles.RenderWarning("This will change DNS settings:"))
+	for _, a := range assignments {
+		fmt.Printf("  %s: %s\n", s.styles.RenderBold(a.Interface), s.styles.RenderInfo(a.describe()))
+	}
+	s.printAddressWarnings(warnings)
+	return s.promptYesNo(opts)
+}
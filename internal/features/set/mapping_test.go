@@ -0,0 +1,79 @@
+package set
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ParseAssignments(t *testing.T) {
+	s := &Service{
+		logger: slog.Default(),
+		config: &config.Config{DNS: config.DNSConfig{CustomPresets: map[string][]string{"office": {"10.0.0.53"}}}},
+	}
+
+	t.Run("presets and addresses", func(t *testing.T) {
+		assignments, err := s.parseAssignments([]string{"wlan0=cloudflare", "eth0=10.0.0.53, 10.0.0.54", "enp3s0=office"})
+		require.NoError(t, err)
+		require.Len(t, assignments, 3)
+
+		assert.Equal(t, "wlan0", assignments[0].Interface)
+		assert.Equal(t, "Cloudflare", assignments[0].Source)
+		assert.Contains(t, assignments[0].Servers, "1.1.1.1")
+
+		assert.Equal(t, interfaceAssignment{Interface: "eth0", Servers: []string{"10.0.0.53", "10.0.0.54"}}, assignments[1])
+		assert.Equal(t, "10.0.0.53, 10.0.0.54", assignments[1].describe())
+
+		assert.Equal(t, interfaceAssignment{Interface: "enp3s0", Source: "OFFICE", Servers: []string{"10.0.0.53"}}, assignments[2])
+	})
+
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr error
+	}{
+		{name: "missing value", entries: []string{"eth0"}, wantErr: ErrInvalidMapping},
+		{name: "empty value", entries: []string{"eth0="}, wantErr: ErrInvalidMapping},
+		{name: "pattern", entries: []string{"wl*=cloudflare"}, wantErr: ErrInvalidMapping},
+		{name: "duplicate interface", entries: []string{"eth0=google", "eth0=quad9"}, wantErr: ErrInvalidMapping},
+		{name: "invalid interface", entries: []string{"eth@0=google"}, wantErr: ErrInvalidInterfaceName},
+		{name: "unknown preset or bad address", entries: []string{"eth0=nope"}, wantErr: ErrInvalidDNSAddress},
+		{name: "no entries", entries: nil, wantErr: ErrInvalidMapping},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.parseAssignments(tt.entries)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+		})
+	}
+}
+
+func TestService_SetMap_Conflicts(t *testing.T) {
+	s := &Service{logger: slog.Default()}
+	ctx := context.Background()
+	entries := []string{"eth0=google"}
+
+	for name, opts := range map[string]SetOptions{
+		"interface":  {Interfaces: []string{"wlan0"}},
+		"connection": {Connections: []string{"Office WiFi"}},
+		"scope all":  {Scope: ScopeAll},
+		"global":     {Global: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := s.SetMap(ctx, entries, opts)
+			assert.ErrorIs(t, err, ErrInvalidMapping)
+		})
+	}
+
+	t.Run("with positional servers", func(t *testing.T) {
+		err := s.SmartSet(ctx, []string{"1.1.1.1"}, SetOptions{Map: entries})
+		assert.ErrorIs(t, err, ErrInvalidMapping)
+	})
+}
@@ -16,6 +16,9 @@ var Module = fx.Module("set",
 	fx.Provide(NewSetCommand),
 	fx.Provide(NewPresetCommand),
 	fx.Provide(NewCustomCommand),
+	fx.Provide(NewPlanCommand),
+	fx.Provide(NewApplyCommand),
+	fx.Invoke(ValidateConfig),
 	fx.Invoke(RegisterCommands),
 )
 
@@ -49,23 +52,52 @@ Examples:
   # Set custom IPs
   cdns set 1.1.1.1 8.8.8.8
 
+  # Local resolver on a custom port, or DNS-over-TLS (systemd-resolved)
+  cdns set 127.0.0.1:5353
+  cdns set 9.9.9.9#dns.quad9.net
+
   # Set specific interface
-  cdns set cloudflare --interface eth0`,
+  cdns set cloudflare --interface eth0
+
+  # Only IPv4 resolvers, or IPv6 ones first
+  cdns set cloudflare --family ipv4
+  cdns set google --prefer ipv6
+
+  # Different DNS per interface
+  cdns set --map wlan0=cloudflare --map eth0=10.0.0.53,10.0.0.54
+
+  # Set search domains and resolver options
+  cdns set 10.0.0.53 --search corp.example --ndots 5 --option rotate
+
+  # Enforce DNSSEC and disable LLMNR (systemd-resolved)
+  cdns set quad9 --dnssec=yes --llmnr=no`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			opts.Output = format
+
 			// Merge persistent flags from root
 			if !opts.Verbose {
 				val, _ := cmd.Flags().GetBool("verbose")
 				opts.Verbose = val
 			}
 
-			// Ensure privileges upfront for better UX (unless dry-run)
-			if !opts.DryRun {
+			// dns.interface_map from the config only applies when no servers are given
+			if len(args) > 0 && !cmd.Flags().Changed("map") {
+				opts.Map = nil
+			}
+			applyScopeDefaults(cmd, &opts)
+
+			// Ensure privileges upfront for better UX (unless dry-run or offline: an image may be writable without root)
+			if !opts.DryRun && !params.Service.Offline() {
 				if err := EnsurePrivileges(); err != nil {
 					return err
 				}
 			}
 
-			err := params.Service.SmartSet(cmd.Context(), args, opts)
+			err = params.Service.SmartSet(cmd.Context(), args, opts)
 			if err != nil {
 				// Get exit code
 				exitCode := ExitCodeFromError(err)
@@ -94,17 +126,61 @@ Examples:
 		defaultScope = "active"
 	}
 
-	cmd.Flags().StringSliceVarP(&opts.Interfaces, "interface", "i", defaultInterfaces, "specify interface(s) (repeatable)")
+	cmd.Flags().StringSliceVarP(&opts.Interfaces, "interface", "i", defaultInterfaces, "interface name(s) or glob/regex pattern(s), e.g. 'wl*' (repeatable)")
+	cmd.Flags().StringSliceVar(&opts.Exclude, "exclude", nil, "interface pattern(s) to leave untouched, e.g. 'docker*,veth*' (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.Connections, "connection", nil, "NetworkManager connection profile to modify, active or not (repeatable)")
+	cmd.Flags().StringSliceVar(&opts.ConnectionUUIDs, "connection-uuid", nil, "NetworkManager connection profile UUID to modify (repeatable)")
 	cmd.Flags().StringVar(&opts.Scope, "scope", "active", "interface scope: active, all, or explicit")
 	cmd.Flags().Lookup("scope").DefValue = defaultScope
 	opts.Scope = defaultScope // Ensure initialized with config value
 
+	cmd.Flags().BoolVar(&opts.Global, "global", false, "NetworkManager global DNS, used by every connection instead of its own servers")
+	cmd.Flags().StringArrayVar(&opts.Map, "map", params.Config.DNS.InterfaceMap, "per-interface DNS, iface=preset or iface=ip[,ip...] (repeatable)")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "preview changes without applying")
 	cmd.Flags().BoolVar(&opts.Yes, "yes", false, "skip confirmation prompts")
+	bindFamilyFlags(cmd, &opts, params.Config)
+	bindResolverFlags(cmd, &opts)
 
 	return SetCommandResult{Cmd: cmd}
 }
 
+// bindFamilyFlags registers the address family flags shared by 'set',
+// 'set preset' and 'set custom', defaulting to dns.address_family
+func bindFamilyFlags(cmd *cobra.Command, opts *SetOptions, cfg *config.Config) {
+	defaultFamily := FamilyAuto
+	if cfg != nil && cfg.DNS.AddressFamily != "" {
+		defaultFamily = cfg.DNS.AddressFamily
+	}
+	cmd.Flags().StringVar(&opts.Family, "family", defaultFamily, "server address family: auto, ipv4, ipv6, or both")
+	cmd.Flags().StringVar(&opts.Prefer, "prefer", FamilyIPv4, "address family to list first: ipv4 or ipv6")
+}
+
+// bindResolverFlags registers the search domain, resolver option and link
+// setting flags shared by 'set', 'set preset' and 'set custom'
+func bindResolverFlags(cmd *cobra.Command, opts *SetOptions) {
+	cmd.Flags().StringSliceVar(&opts.Search, "search", nil, "search domain(s) (repeatable)")
+	cmd.Flags().StringVar(&opts.Ndots, "ndots", "", "resolver ndots option (0-15)")
+	cmd.Flags().StringArrayVar(&opts.ResolverOptions, "option", nil, "resolver option, e.g. rotate, edns0, timeout:2 (repeatable)")
+	cmd.Flags().StringVar(&opts.Link.DNSSEC, "dnssec", "", "per-link DNSSEC: yes, allow-downgrade, or no")
+	cmd.Flags().StringVar(&opts.Link.LLMNR, "llmnr", "", "per-link LLMNR: yes, resolve, or no")
+	cmd.Flags().StringVar(&opts.Link.MulticastDNS, "mdns", "", "per-link MulticastDNS: yes, resolve, or no")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "fail on address warnings (loopback, private, link-local without interface)")
+}
+
+// applyScopeDefaults stops dns.default_scope and dns.default_interfaces
+// from conflicting with each other or with the flags: an explicit --interface
+// or --map replaces a default scope of all, and the default interfaces are
+// dropped under --scope all or --map. Only flags given together are refused.
+func applyScopeDefaults(cmd *cobra.Command, opts *SetOptions) {
+	interfaceSet := cmd.Flags().Changed("interface")
+	if (interfaceSet || len(opts.Map) > 0) && !cmd.Flags().Changed("scope") && opts.scope() == ScopeAll {
+		opts.Scope = ScopeActive
+	}
+	if !interfaceSet && (opts.scope() == ScopeAll || len(opts.Map) > 0) {
+		opts.Interfaces = nil
+	}
+}
+
 // RegisterCommandsParams holds dependencies for command registration
 type RegisterCommandsParams struct {
 	fx.In
@@ -113,6 +189,8 @@ type RegisterCommandsParams struct {
 	SetCmd    *cobra.Command `name:"set"`
 	PresetCmd *cobra.Command `name:"set_preset"`
 	CustomCmd *cobra.Command `name:"set_custom"`
+	PlanCmd   *cobra.Command `name:"plan"`
+	ApplyCmd  *cobra.Command `name:"apply"`
 }
 
 // RegisterCommands registers all set commands
@@ -123,6 +201,8 @@ func RegisterCommands(params RegisterCommandsParams) {
 
 	// Add set command to root
 	params.RootCmd.AddCommand(params.SetCmd)
+	params.RootCmd.AddCommand(params.PlanCmd)
+	params.RootCmd.AddCommand(params.ApplyCmd)
 
 	// Make set the default action when no subcommand is provided
 	// This makes 'cdns' equivalent to 'cdns set' (Interactive TUI)
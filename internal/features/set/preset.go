@@ -52,17 +52,24 @@ Examples:
   cdns set preset opendns --yes`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			opts.Output = format
+			applyScopeDefaults(cmd, &opts)
+
 			presetName := args[0]
 
-			// Ensure privileges upfront (unless dry-run)
-			if !opts.DryRun {
+			// Ensure privileges upfront (unless dry-run or offline: an image may be writable without root)
+			if !opts.DryRun && !params.Service.Offline() {
 				if err := EnsurePrivileges(); err != nil {
 					return err
 				}
 			}
 
 			// Execute set preset
-			err := params.Service.SetPreset(cmd.Context(), presetName, opts)
+			err = params.Service.SetPreset(cmd.Context(), presetName, opts)
 			if err != nil {
 				// Get exit code
 				exitCode := ExitCodeFromError(err)
@@ -91,10 +98,16 @@ Examples:
 		defaultScope = "active"
 	}
 
-	cmd.Flags().StringSliceVar(&opts.Interfaces, "interface", defaultInterfaces, "specify interface(s) (repeatable)")
+	cmd.Flags().StringSliceVar(&opts.Interfaces, "interface", defaultInterfaces, "interface name(s) or glob/regex pattern(s), e.g. 'wl*' (repeatable)")
+	cmd.Flags().StringSliceVar(&opts.Exclude, "exclude", nil, "interface pattern(s) to leave untouched, e.g. 'docker*,veth*' (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.Connections, "connection", nil, "NetworkManager connection profile to modify, active or not (repeatable)")
+	cmd.Flags().StringSliceVar(&opts.ConnectionUUIDs, "connection-uuid", nil, "NetworkManager connection profile UUID to modify (repeatable)")
 	cmd.Flags().StringVar(&opts.Scope, "scope", defaultScope, "interface scope: active, all, or explicit")
+	cmd.Flags().BoolVar(&opts.Global, "global", false, "NetworkManager global DNS, used by every connection instead of its own servers")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "preview changes without applying")
 	cmd.Flags().BoolVar(&opts.Yes, "yes", false, "skip confirmation prompts")
+	bindFamilyFlags(cmd, &opts, params.Config)
+	bindResolverFlags(cmd, &opts)
 
 	return PresetCommandResult{Cmd: cmd}
 }
@@ -51,7 +51,8 @@ func EnsurePrivileges() error {
 	// We reconstruct the command carefully:
 	// 1. Start with the executable path
 	// 2. Add existing arguments (flags, etc.)
-	// 3. Ensure the 'set' command is present if missing (e.g. running from menu)
+	// 3. Ensure the 'set' command is present if missing (e.g. running from menu),
+	//    unless 'apply' is the command being run
 
 	newArgs := []string{executable}
 
@@ -62,7 +63,7 @@ func EnsurePrivileges() error {
 	setCommandPresent := false
 	// Start checking from index 1 to skip executable name
 	for _, arg := range args[1:] {
-		if arg == "set" {
+		if arg == "set" || arg == "apply" {
 			setCommandPresent = true
 			break
 		}
@@ -0,0 +1,90 @@
+package set
+
+import (
+	"errors"
+	"fmt"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/ui"
+)
+
+// ErrConfirmationRequired is returned when set would have to prompt while
+// printing JSON or YAML
+var ErrConfirmationRequired = errors.New("confirmation required")
+
+// Outcomes of a set run for one interface
+const (
+	OutcomeApplied = "applied"
+	OutcomePlanned = "planned" // --dry-run
+	OutcomeFailed  = "failed"
+)
+
+// Result describes what a set run applied, or would apply with --dry-run.
+// It is printed instead of the usual messages with --output json or yaml.
+type Result struct {
+	Backend models.Backend `json:"backend"`
+	Scope   string         `json:"scope"`
+	Preset  string         `json:"preset,omitempty"`
+	// Servers lists the servers requested, empty for --map where each
+	// interface has its own
+	Servers  []string `json:"servers,omitempty"`
+	DryRun   bool     `json:"dry_run"`
+	Warnings []string `json:"warnings,omitempty"`
+
+	Interfaces []InterfaceOutcome `json:"interfaces"`
+	Excluded   []exclusion        `json:"excluded,omitempty"`
+}
+
+// InterfaceOutcome is the result of a set run for one interface or
+// connection profile
+type InterfaceOutcome struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+	Outcome string   `json:"outcome"`
+	// Reason tells why the interface was selected, or why servers were left out
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// newResult starts the result of a run over configs, each with outcome
+func newResult(backendObj models.Backend, dnsAddresses, warnings []string, configs []models.DNSConfig, selection targetSelection, outcome string, opts SetOptions) *Result {
+	result := &Result{
+		Backend:    backendObj,
+		Scope:      opts.scope(),
+		Preset:     opts.PresetName,
+		Servers:    dnsAddresses,
+		DryRun:     opts.DryRun,
+		Warnings:   warnings,
+		Interfaces: make([]InterfaceOutcome, 0, len(configs)),
+		Excluded:   selection.Excluded,
+	}
+	for _, cfg := range configs {
+		label := cfg.Interface.Label()
+		result.Interfaces = append(result.Interfaces, InterfaceOutcome{
+			Name:    label,
+			Servers: cfg.DNS.Ordered(),
+			Outcome: outcome,
+			Reason:  selection.Reasons[label],
+		})
+	}
+	return result
+}
+
+// checkOutput refuses JSON and YAML output when set would prompt for
+// confirmation on the same stdout
+func (s *Service) checkOutput(opts SetOptions) error {
+	if opts.Output.Structured() && !opts.Yes && !opts.DryRun && s.IsInteractive() {
+		return fmt.Errorf("validation failed: %w: --output %s cannot prompt, add --yes or --dry-run", ErrConfirmationRequired, opts.Output)
+	}
+	return nil
+}
+
+// printResult prints the result as JSON or YAML
+func (s *Service) printResult(result *Result, opts SetOptions) error {
+	output, err := ui.Encode(opts.Output, result)
+	if err != nil {
+		return err
+	}
+	fmt.Println(output)
+	return nil
+}
@@ -0,0 +1,49 @@
+package set
+
+import (
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResult(t *testing.T) {
+	eth0 := models.NetworkInterface{Name: "eth0", Backend: models.BackendSystemdResolved}
+	wlan0 := models.NetworkInterface{Name: "wlan0", Backend: models.BackendSystemdResolved}
+	configs := []models.DNSConfig{
+		{Interface: eth0, DNS: models.DNSServer{IPv4: []string{"1.1.1.1", "1.0.0.1"}}},
+		{Interface: wlan0, DNS: models.DNSServer{IPv4: []string{"1.1.1.1", "1.0.0.1"}, IPv6: []string{"2606:4700:4700::1111"}}},
+	}
+	selection := targetSelection{
+		Reasons:  map[string]string{"eth0": "default route; IPv4 only, no global IPv6 address", "wlan0": "active"},
+		Excluded: []exclusion{{Name: "docker0", Reason: "matches dns.exclude_interfaces"}},
+	}
+	opts := SetOptions{PresetName: "Cloudflare", DryRun: true, Output: ui.FormatJSON}
+
+	result := newResult(models.BackendSystemdResolved, []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111"}, nil, configs, selection, OutcomePlanned, opts)
+
+	assert.Equal(t, ScopeActive, result.Scope)
+	assert.True(t, result.DryRun)
+	require.Len(t, result.Interfaces, 2)
+	assert.Equal(t, InterfaceOutcome{Name: "eth0", Servers: []string{"1.1.1.1", "1.0.0.1"}, Outcome: OutcomePlanned, Reason: "default route; IPv4 only, no global IPv6 address"}, result.Interfaces[0])
+	assert.Equal(t, []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111"}, result.Interfaces[1].Servers)
+
+	output, err := ui.Encode(ui.FormatYAML, result)
+	require.NoError(t, err)
+	assert.Contains(t, output, "preset: Cloudflare\n")
+	assert.Contains(t, output, "excluded:\n  - name: docker0\n    reason: matches dns.exclude_interfaces")
+}
+
+func TestService_CheckOutput(t *testing.T) {
+	s := &Service{}
+
+	// Tests do not run on a terminal, so nothing prompts
+	assert.NoError(t, s.checkOutput(SetOptions{Output: ui.FormatJSON}))
+
+	err := s.SmartSet(t.Context(), nil, SetOptions{Output: ui.FormatYAML})
+	assert.ErrorIs(t, err, ErrNoDNSAddresses)
+	assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+}
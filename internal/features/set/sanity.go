@@ -0,0 +1,136 @@
+package set
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+// ErrAddressWarning is returned with --strict when a DNS address draws a warning
+var ErrAddressWarning = errors.New("DNS address needs attention")
+
+// documentationPrefixes are reserved for examples and never route to a server
+var documentationPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("3fff::/20"),
+}
+
+var broadcastIPv4 = netip.AddrFrom4([4]byte{255, 255, 255, 255})
+
+// unusableReason explains why an address can never be a DNS server, or
+// returns an empty string for addresses that can
+func unusableReason(addr models.ServerAddress) string {
+	ip := addr.IP
+	switch {
+	case ip.IsUnspecified():
+		return "unspecified address"
+	case ip == broadcastIPv4:
+		return "broadcast address"
+	case ip.IsMulticast():
+		return "multicast address"
+	}
+	for _, prefix := range documentationPrefixes {
+		if prefix.Contains(ip) {
+			return "documentation range " + prefix.String()
+		}
+	}
+	return ""
+}
+
+// addressWarnings returns a warning for each address that is valid but
+// often a mistake: loopback servers, private servers off the subnets of the
+// interfaces using them and link-local servers without an interface.
+// subnets holds the networks of the interfaces in configs that could be
+// read; without them every private server draws a warning. Invalid
+// addresses are skipped; validation reports them.
+func addressWarnings(addresses []string, configs []models.DNSConfig, subnets map[string][]netip.Prefix) []string {
+	var warnings []string
+	for _, address := range addresses {
+		addr, err := models.ParseServerAddress(address)
+		if err != nil {
+			continue
+		}
+		ip := addr.IP
+		switch {
+		case ip.IsLoopback():
+			warnings = append(warnings, fmt.Sprintf("%s is a loopback address; is a local resolver listening on it?", address))
+		case ip.IsPrivate():
+			checked, off := offSubnet(ip, configs, subnets)
+			switch {
+			case !checked:
+				warnings = append(warnings, fmt.Sprintf("%s is a private address; is it reachable from the target interfaces' subnets?", address))
+			case len(off) > 0:
+				warnings = append(warnings, fmt.Sprintf("%s is a private address outside the subnets of %s; is it reachable from there?", address, strings.Join(off, ", ")))
+			}
+		case ip.Is6() && ip.IsLinkLocalUnicast() && addr.Zone == "":
+			warnings = append(warnings, fmt.Sprintf("%s is link-local without an interface; add one, e.g. %s%%eth0", address, ip))
+		}
+	}
+	return warnings
+}
+
+// offSubnet lists the interfaces given ip as a server whose subnets do not
+// contain it. checked is false when none of them had its subnets read.
+func offSubnet(ip netip.Addr, configs []models.DNSConfig, subnets map[string][]netip.Prefix) (checked bool, off []string) {
+	for _, cfg := range configs {
+		prefixes, ok := subnets[cfg.Interface.Name]
+		if !ok || !slices.ContainsFunc(cfg.DNS.Ordered(), func(server string) bool {
+			addr, err := models.ParseServerAddress(server)
+			return err == nil && addr.IP == ip
+		}) {
+			continue
+		}
+		checked = true
+		if !slices.ContainsFunc(prefixes, func(prefix netip.Prefix) bool { return prefix.Contains(ip) }) {
+			off = append(off, cfg.Interface.Name)
+		}
+	}
+	return checked, off
+}
+
+// targetSubnets reads the subnets of the interfaces in configs. Inactive
+// profiles and offline images have none to read.
+func (s *Service) targetSubnets(configs []models.DNSConfig) map[string][]netip.Prefix {
+	subnets := make(map[string][]netip.Prefix)
+	if s.discovery == nil || s.root.Offline() {
+		return subnets
+	}
+	for _, cfg := range configs {
+		name := cfg.Interface.Name
+		if name == "" {
+			continue
+		}
+		prefixes, err := s.discovery.Subnets(name)
+		if err != nil {
+			s.logger.Warn("failed to read interface subnets", slog.String("interface", name), slog.Any("error", err))
+			continue
+		}
+		subnets[name] = prefixes
+	}
+	return subnets
+}
+
+// checkAddressWarnings returns the warnings for addresses used by configs,
+// or fails with ErrAddressWarning when --strict is set and there are any
+func (s *Service) checkAddressWarnings(addresses []string, configs []models.DNSConfig, opts SetOptions) ([]string, error) {
+	warnings := addressWarnings(addresses, configs, s.targetSubnets(configs))
+	if opts.Strict && len(warnings) > 0 {
+		return nil, fmt.Errorf("validation failed: %w (--strict): %s", ErrAddressWarning, strings.Join(warnings, "; "))
+	}
+	return warnings, nil
+}
+
+// printAddressWarnings prints address warnings for dry-run and confirmation
+func (s *Service) printAddressWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Printf("  %s\n", s.styles.RenderWarning(warning))
+	}
+}
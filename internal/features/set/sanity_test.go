@@ -0,0 +1,98 @@
+package set
+
+import (
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressWarnings(t *testing.T) {
+	tests := []struct {
+		address string
+		warning string
+	}{
+		{address: "127.0.0.1", warning: "loopback"},
+		{address: "127.0.0.1:5353", warning: "loopback"},
+		{address: "::1", warning: "loopback"},
+		{address: "10.0.0.53", warning: "private"},
+		{address: "192.168.1.1", warning: "private"},
+		{address: "fd00::53", warning: "private"},
+		{address: "fe80::1", warning: "link-local without an interface"},
+		{address: "fe80::1%eth0"},
+		{address: "1.1.1.1"},
+		{address: "2606:4700:4700::1111"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			warnings := addressWarnings([]string{tt.address}, nil, nil)
+			if tt.warning == "" {
+				assert.Empty(t, warnings)
+				return
+			}
+			require.Len(t, warnings, 1)
+			assert.Contains(t, warnings[0], tt.warning)
+		})
+	}
+}
+
+func TestAddressWarnings_Subnets(t *testing.T) {
+	configs := []models.DNSConfig{
+		{Interface: models.NetworkInterface{Name: "eth0"}, DNS: models.DNSServer{IPv4: []string{"192.168.1.53", "10.0.0.53"}}},
+		{Interface: models.NetworkInterface{Name: "wlan0"}, DNS: models.DNSServer{IPv4: []string{"10.0.0.53"}}},
+	}
+	subnets := map[string][]netip.Prefix{
+		"eth0":  {netip.MustParsePrefix("192.168.1.0/24")},
+		"wlan0": {netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	// On the subnet of every interface using it: no warning
+	assert.Empty(t, addressWarnings([]string{"192.168.1.53"}, configs, subnets))
+
+	warnings := addressWarnings([]string{"10.0.0.53"}, configs, subnets)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "outside the subnets of eth0")
+
+	// Unknown subnets keep the generic warning
+	warnings = addressWarnings([]string{"192.168.1.53"}, configs, nil)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "is it reachable from the target interfaces' subnets?")
+}
+
+func TestService_CheckAddressWarnings_Subnets(t *testing.T) {
+	root := fakeSysfs(t, map[string]string{"eth0": "up"}, "eth0")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "proc", "net", "route"), []byte(
+		"Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"+
+			"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"), 0644))
+	s := &Service{logger: slog.Default(), discovery: discovery.NewDiscovererAt(root)}
+	configs := []models.DNSConfig{{Interface: models.NetworkInterface{Name: "eth0"}, DNS: models.DNSServer{IPv4: []string{"192.168.1.1"}}}}
+
+	warnings, err := s.checkAddressWarnings([]string{"192.168.1.1"}, configs, SetOptions{Strict: true})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	configs[0].DNS.IPv4 = []string{"10.0.0.53"}
+	_, err = s.checkAddressWarnings([]string{"10.0.0.53"}, configs, SetOptions{Strict: true})
+	assert.ErrorIs(t, err, ErrAddressWarning)
+}
+
+func TestCheckAddressWarnings_Strict(t *testing.T) {
+	warnings, err := (&Service{}).checkAddressWarnings([]string{"1.1.1.1", "127.0.0.1"}, nil, SetOptions{})
+	require.NoError(t, err)
+	assert.Len(t, warnings, 1)
+
+	_, err = (&Service{}).checkAddressWarnings([]string{"1.1.1.1", "127.0.0.1"}, nil, SetOptions{Strict: true})
+	assert.ErrorIs(t, err, ErrAddressWarning)
+	assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+
+	_, err = (&Service{}).checkAddressWarnings([]string{"1.1.1.1"}, nil, SetOptions{Strict: true})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,532 @@
+package set
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+)
+
+const (
+	// ScopeActive targets the given interfaces, or the connected ones
+	ScopeActive = "active"
+	// ScopeAll targets every managed interface, including inactive NetworkManager profiles
+	ScopeAll = "all"
+	// ScopeExplicit targets only the interfaces or profiles named on the command line
+	ScopeExplicit = "explicit"
+)
+
+// hasConnections reports whether NetworkManager profiles were named with --connection or --connection-uuid
+func (o SetOptions) hasConnections() bool {
+	return len(o.Connections) > 0 || len(o.ConnectionUUIDs) > 0
+}
+
+// scope returns the normalized --scope value, defaulting to active
+func (o SetOptions) scope() string {
+	if o.Scope == "" {
+		return ScopeActive
+	}
+	return strings.ToLower(o.Scope)
+}
+
+// exclusion records an interface left out of the targets and why
+type exclusion struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// targetSelection is the outcome of matching --scope, --interface and
+// --exclude against the interfaces present on the system
+type targetSelection struct {
+	Targets  []models.NetworkInterface
+	Reasons  map[string]string // why each target was chosen, keyed by Label()
+	Excluded []exclusion
+}
+
+// add appends a target unless it is already selected
+func (sel *targetSelection) add(target models.NetworkInterface, reason string) {
+	label := target.Label()
+	if _, ok := sel.Reasons[label]; ok {
+		return
+	}
+	if sel.Reasons == nil {
+		sel.Reasons = make(map[string]string)
+	}
+	sel.Targets = append(sel.Targets, target)
+	sel.Reasons[label] = reason
+}
+
+// note appends a remark to the reason a target was chosen
+func (sel *targetSelection) note(target models.NetworkInterface, note string) {
+	label := target.Label()
+	if reason := sel.Reasons[label]; reason != "" {
+		note = reason + "; " + note
+	}
+	if sel.Reasons == nil {
+		sel.Reasons = make(map[string]string)
+	}
+	sel.Reasons[label] = note
+}
+
+// exclude records an interface that was left out
+func (sel *targetSelection) exclude(name, reason string) {
+	sel.Excluded = append(sel.Excluded, exclusion{Name: name, Reason: reason})
+}
+
+// describeExcluded lists the excluded interfaces for error messages
+func (sel *targetSelection) describeExcluded() string {
+	if len(sel.Excluded) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(sel.Excluded))
+	for _, e := range sel.Excluded {
+		parts = append(parts, e.Name+": "+e.Reason)
+	}
+	return " (excluded " + strings.Join(parts, "; ") + ")"
+}
+
+// resolveTargets determines the interfaces (or NetworkManager connection
+// profiles) to configure according to --scope, --interface and --exclude
+func (s *Service) resolveTargets(ctx context.Context, backendObj models.Backend, opts SetOptions) (targetSelection, error) {
+	excludes, err := s.excludePatterns(opts)
+	if err != nil {
+		return targetSelection{}, err
+	}
+
+	if s.root.Offline() {
+		return s.selectOffline(ctx, excludes, backendObj, opts)
+	}
+
+	if opts.hasConnections() {
+		if opts.scope() == ScopeAll {
+			return targetSelection{}, fmt.Errorf("validation failed: %w: --scope all cannot be combined with --connection", ErrInvalidScope)
+		}
+		sel, err := s.selectConnections(ctx, backendObj, opts)
+		if err != nil || len(opts.Interfaces) == 0 {
+			return sel, err
+		}
+		named, err := s.selectNamed(opts.Interfaces, excludes, backendObj, opts.scope() == ScopeActive)
+		if err != nil {
+			return targetSelection{}, err
+		}
+		for _, target := range named.Targets {
+			sel.add(target, named.Reasons[target.Label()])
+		}
+		sel.Excluded = append(sel.Excluded, named.Excluded...)
+		return sel, nil
+	}
+
+	switch opts.scope() {
+	case ScopeExplicit:
+		if len(opts.Interfaces) == 0 {
+			return targetSelection{}, fmt.Errorf("validation failed: %w: --scope explicit requires --interface", ErrInvalidScope)
+		}
+		return s.selectNamed(opts.Interfaces, excludes, backendObj, false)
+
+	case ScopeActive:
+		if len(opts.Interfaces) > 0 {
+			return s.selectNamed(opts.Interfaces, excludes, backendObj, true)
+		}
+		return s.selectActive(excludes, backendObj)
+
+	case ScopeAll:
+		if len(opts.Interfaces) > 0 {
+			return targetSelection{}, fmt.Errorf("validation failed: %w: --scope all cannot be combined with --interface", ErrInvalidScope)
+		}
+		return s.selectAll(ctx, excludes, backendObj)
+
+	default:
+		return targetSelection{}, fmt.Errorf("validation failed: %w: %s (must be active, all, or explicit)", ErrInvalidScope, opts.Scope)
+	}
+}
+
+// excludePatterns combines dns.exclude_interfaces from the config with --exclude
+func (s *Service) excludePatterns(opts SetOptions) ([]discovery.Pattern, error) {
+	var raw []string
+	if s.config != nil {
+		raw = append(raw, s.config.DNS.ExcludeInterfaces...)
+	}
+	raw = append(raw, opts.Exclude...)
+
+	patterns, err := discovery.ParsePatterns(raw)
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w: %w", ErrInvalidInterfaceName, err)
+	}
+	return patterns, nil
+}
+
+// excludeReason explains why an interface is left out of the targets, or
+// returns "" to keep it. Virtual interfaces are only skipped when they were
+// not asked for by pattern.
+func excludeReason(iface discovery.Interface, excludes []discovery.Pattern, skipVirtual bool) string {
+	for _, p := range excludes {
+		if p.Match(iface.Name) {
+			return fmt.Sprintf("matches exclude pattern %q", p.String())
+		}
+	}
+	if skipVirtual && iface.IsVirtual() {
+		return fmt.Sprintf("virtual interface (%s)", iface.Kind)
+	}
+	return ""
+}
+
+// selectNamed resolves --interface values. Literal names are used as given;
+// patterns are matched against the interfaces present, restricted to the
+// ones that are up when upOnly is set.
+func (s *Service) selectNamed(names []string, excludes []discovery.Pattern, backendObj models.Backend, upOnly bool) (targetSelection, error) {
+	var sel targetSelection
+	var patterns []discovery.Pattern
+	for _, name := range names {
+		if !discovery.IsPattern(name) {
+			sel.add(models.NetworkInterface{Name: name, Backend: backendObj}, "named with --interface")
+			continue
+		}
+		p, err := discovery.ParsePattern(name)
+		if err != nil {
+			return targetSelection{}, fmt.Errorf("validation failed: %w: %w", ErrInvalidInterfaceName, err)
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return sel, nil
+	}
+
+	ifaces, err := s.discovery.Interfaces()
+	if err != nil {
+		return targetSelection{}, err
+	}
+	for _, iface := range ifaces {
+		if iface.Kind == discovery.KindLoopback {
+			continue
+		}
+		for _, p := range patterns {
+			if !p.Match(iface.Name) {
+				continue
+			}
+			if upOnly && !iface.IsUp() {
+				sel.exclude(iface.Name, fmt.Sprintf("matches %q but is %s", p.String(), iface.OperState))
+			} else if reason := excludeReason(iface, excludes, false); reason != "" {
+				sel.exclude(iface.Name, reason)
+			} else {
+				sel.add(models.NetworkInterface{Name: iface.Name, Backend: backendObj}, fmt.Sprintf("matches %q", p.String()))
+			}
+			break
+		}
+	}
+
+	if len(sel.Targets) == 0 {
+		return sel, fmt.Errorf("%w: %s%s", ErrNoMatchingInterfaces, strings.Join(names, ", "), sel.describeExcluded())
+	}
+	return sel, nil
+}
+
+// selectActive picks the interfaces carrying the default route or, if none
+// of the remaining ones does, every interface that is up. Loopback, virtual
+// and excluded interfaces are left out.
+func (s *Service) selectActive(excludes []discovery.Pattern, backendObj models.Backend) (targetSelection, error) {
+	ifaces, err := s.discovery.Interfaces()
+	if err != nil {
+		return targetSelection{}, err
+	}
+
+	var sel targetSelection
+	var candidates []discovery.Interface
+	hasDefaultRoute := false
+	for _, iface := range ifaces {
+		if iface.Kind == discovery.KindLoopback || !iface.IsUp() {
+			continue
+		}
+		if reason := excludeReason(iface, excludes, true); reason != "" {
+			sel.exclude(iface.Name, reason)
+			continue
+		}
+		candidates = append(candidates, iface)
+		hasDefaultRoute = hasDefaultRoute || iface.DefaultRoute
+	}
+
+	for _, iface := range candidates {
+		target := models.NetworkInterface{Name: iface.Name, Backend: backendObj}
+		switch {
+		case iface.DefaultRoute:
+			sel.add(target, "default route")
+		case hasDefaultRoute:
+			sel.exclude(iface.Name, "no default route")
+		default:
+			sel.add(target, "up")
+		}
+	}
+
+	if len(sel.Targets) == 0 {
+		if len(sel.Excluded) > 0 {
+			return sel, fmt.Errorf("%w%s", ErrNoActiveInterfaces, sel.describeExcluded())
+		}
+		return sel, ErrNoActiveInterfaces
+	}
+	s.logger.Debug("detected active interfaces", slog.Any("interfaces", sel.Targets))
+	return sel, nil
+}
+
+// selectAll returns every managed interface. For NetworkManager this also
+// includes saved connection profiles that are not active on any device.
+func (s *Service) selectAll(ctx context.Context, excludes []discovery.Pattern, backendObj models.Backend) (targetSelection, error) {
+	var sel targetSelection
+	ifaces, err := s.discovery.Interfaces()
+
+	if backendObj != models.BackendNetworkManager {
+		if err != nil {
+			return targetSelection{}, err
+		}
+		for _, iface := range ifaces {
+			if iface.Kind == discovery.KindLoopback {
+				continue
+			}
+			if reason := excludeReason(iface, excludes, true); reason != "" {
+				sel.exclude(iface.Name, reason)
+				continue
+			}
+			sel.add(models.NetworkInterface{Name: iface.Name, Backend: backendObj}, "")
+		}
+		return sel, nil
+	}
+
+	// Without sysfs, connected devices are still configured but not classified
+	if err != nil {
+		s.logger.Warn("failed to classify interfaces", slog.Any("error", err))
+	}
+
+	// Connected devices are configured through their active connection
+	connected, err := s.nm.ConnectedDevices(ctx)
+	if err != nil {
+		return targetSelection{}, err
+	}
+	known := make(map[string]discovery.Interface, len(ifaces))
+	for _, iface := range ifaces {
+		known[iface.Name] = iface
+	}
+	for _, name := range connected {
+		iface, ok := known[name]
+		if !ok {
+			iface = discovery.Interface{Name: name}
+		}
+		if reason := excludeReason(iface, excludes, true); reason != "" {
+			sel.exclude(name, reason)
+			continue
+		}
+		sel.add(models.NetworkInterface{Name: name, Backend: backendObj}, "connected")
+	}
+
+	// Inactive profiles are modified directly
+	profiles, err := s.nm.Profiles(ctx)
+	if err != nil {
+		return targetSelection{}, err
+	}
+	for _, profile := range inactiveProfiles(profiles, backendObj) {
+		sel.add(profile, "inactive profile")
+	}
+
+	return sel, nil
+}
+
+// selectOffline picks the targets inside a --root image. Its interfaces do
+// not exist on this machine, so nothing is active and --scope active and all
+// select the same: every interface the configuration files define. Settings
+// of systemd-resolved and resolv.conf are global offline, so they get a
+// single system-wide target.
+func (s *Service) selectOffline(ctx context.Context, excludes []discovery.Pattern, backendObj models.Backend, opts SetOptions) (targetSelection, error) {
+	switch opts.scope() {
+	case ScopeActive, ScopeAll:
+	case ScopeExplicit:
+		if len(opts.Interfaces) == 0 && !opts.hasConnections() {
+			return targetSelection{}, fmt.Errorf("validation failed: %w: --scope explicit requires --interface", ErrInvalidScope)
+		}
+	default:
+		return targetSelection{}, fmt.Errorf("validation failed: %w: %s (must be active, all, or explicit)", ErrInvalidScope, opts.Scope)
+	}
+
+	var sel targetSelection
+	if opts.hasConnections() {
+		var err error
+		if sel, err = s.selectConnections(ctx, backendObj, opts); err != nil {
+			return targetSelection{}, err
+		}
+	}
+
+	var defined []string
+	switch backendObj {
+	case models.BackendNetworkManager:
+		// Profiles are bound to interfaces by name, which needs no lookup
+		if len(opts.Interfaces) > 0 || opts.hasConnections() {
+			break
+		}
+		profiles, err := s.nm.Profiles(ctx)
+		if err != nil {
+			return targetSelection{}, err
+		}
+		for _, profile := range inactiveProfiles(profiles, backendObj) {
+			if reason := excludeReason(discovery.Interface{Name: profile.Connection}, excludes, false); reason != "" {
+				sel.exclude(profile.Connection, reason)
+				continue
+			}
+			sel.add(profile, "profile in "+s.root.Dir())
+		}
+		if len(sel.Targets) == 0 {
+			return sel, fmt.Errorf("%w: no connection profiles in %s%s", ErrNoMatchingInterfaces, s.root.Dir(), sel.describeExcluded())
+		}
+		return sel, nil
+
+	case models.BackendNetplan:
+		names, err := backend.NetplanInterfaces(s.root)
+		if err != nil {
+			return targetSelection{}, err
+		}
+		defined = names
+
+	default:
+		sel.add(models.NetworkInterface{Name: "system", Backend: backendObj}, "system-wide configuration")
+		return sel, nil
+	}
+
+	named, err := selectDefined(opts.Interfaces, defined, excludes, backendObj)
+	if err != nil {
+		return targetSelection{}, err
+	}
+	for _, target := range named.Targets {
+		sel.add(target, named.Reasons[target.Label()])
+	}
+	sel.Excluded = append(sel.Excluded, named.Excluded...)
+	return sel, nil
+}
+
+// selectDefined matches --interface values against interface names read
+// from configuration files. Without --interface every defined interface is
+// selected.
+func selectDefined(names, defined []string, excludes []discovery.Pattern, backendObj models.Backend) (targetSelection, error) {
+	var sel targetSelection
+	var patterns []discovery.Pattern
+	for _, name := range names {
+		if !discovery.IsPattern(name) {
+			sel.add(models.NetworkInterface{Name: name, Backend: backendObj}, "named with --interface")
+			continue
+		}
+		p, err := discovery.ParsePattern(name)
+		if err != nil {
+			return targetSelection{}, fmt.Errorf("validation failed: %w: %w", ErrInvalidInterfaceName, err)
+		}
+		patterns = append(patterns, p)
+	}
+	if len(names) > 0 && len(patterns) == 0 {
+		return sel, nil
+	}
+
+	for _, name := range defined {
+		reason := "defined in the configuration"
+		if len(patterns) > 0 {
+			matched := false
+			for _, p := range patterns {
+				if p.Match(name) {
+					matched = true
+					reason = fmt.Sprintf("matches %q", p.String())
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		if why := excludeReason(discovery.Interface{Name: name}, excludes, false); why != "" {
+			sel.exclude(name, why)
+			continue
+		}
+		sel.add(models.NetworkInterface{Name: name, Backend: backendObj}, reason)
+	}
+
+	if len(sel.Targets) == 0 {
+		if len(names) == 0 {
+			return sel, fmt.Errorf("%w: no interfaces are defined%s", ErrNoMatchingInterfaces, sel.describeExcluded())
+		}
+		return sel, fmt.Errorf("%w: %s%s", ErrNoMatchingInterfaces, strings.Join(names, ", "), sel.describeExcluded())
+	}
+	return sel, nil
+}
+
+// inactiveProfiles returns the connection profiles not bound to a device
+func inactiveProfiles(profiles []backend.NMProfile, backendObj models.Backend) []models.NetworkInterface {
+	var inactive []models.NetworkInterface
+	for _, profile := range profiles {
+		// Skip profiles already covered by a device, and ones without DNS
+		if profile.Device != "" || profile.Type == "loopback" {
+			continue
+		}
+		inactive = append(inactive, models.NetworkInterface{Backend: backendObj, Connection: profile.Name, ConnectionUUID: profile.UUID})
+	}
+	return inactive
+}
+
+// selectConnections resolves --connection and --connection-uuid against the
+// saved NetworkManager profiles. Profiles active on a device keep the device
+// name so the change is reapplied; inactive ones are only modified.
+func (s *Service) selectConnections(ctx context.Context, backendObj models.Backend, opts SetOptions) (targetSelection, error) {
+	if backendObj != models.BackendNetworkManager {
+		return targetSelection{}, fmt.Errorf("validation failed: %w: --connection and --connection-uuid require NetworkManager, not %s", backend.ErrUnsupported, backendObj)
+	}
+
+	profiles, err := s.nm.Profiles(ctx)
+	if err != nil {
+		return targetSelection{}, err
+	}
+	return matchProfiles(profiles, opts.Connections, opts.ConnectionUUIDs, backendObj)
+}
+
+// matchProfiles selects the profiles named by connection names and UUIDs
+func matchProfiles(profiles []backend.NMProfile, names, uuids []string, backendObj models.Backend) (targetSelection, error) {
+	var sel targetSelection
+	addProfile := func(profile backend.NMProfile) {
+		reason := "inactive profile"
+		if profile.Device != "" {
+			reason = "profile active on " + profile.Device
+		}
+		sel.add(models.NetworkInterface{
+			Name:           profile.Device,
+			Backend:        backendObj,
+			Connection:     profile.Name,
+			ConnectionUUID: profile.UUID,
+		}, reason)
+	}
+
+	for _, name := range names {
+		var matches []backend.NMProfile
+		for _, profile := range profiles {
+			if profile.Name == name {
+				matches = append(matches, profile)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return targetSelection{}, fmt.Errorf("validation failed: %w: %s", ErrConnectionNotFound, name)
+		case 1:
+			addProfile(matches[0])
+		default:
+			return targetSelection{}, fmt.Errorf("validation failed: %w: %d profiles are named %q, use --connection-uuid", ErrInvalidConnection, len(matches), name)
+		}
+	}
+
+	for _, uuid := range uuids {
+		found := false
+		for _, profile := range profiles {
+			if strings.EqualFold(profile.UUID, uuid) {
+				addProfile(profile)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return targetSelection{}, fmt.Errorf("validation failed: %w: %s", ErrConnectionNotFound, uuid)
+		}
+	}
+
+	return sel, nil
+}
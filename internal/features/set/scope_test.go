@@ -0,0 +1,317 @@
+package set
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ResolveTargets(t *testing.T) {
+	s := &Service{logger: slog.Default()}
+	ctx := context.Background()
+
+	t.Run("explicit requires interface", func(t *testing.T) {
+		_, err := s.resolveTargets(ctx, models.BackendNetworkManager, SetOptions{Scope: ScopeExplicit})
+		assert.ErrorIs(t, err, ErrInvalidScope)
+	})
+
+	t.Run("explicit uses given interfaces", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Scope: ScopeExplicit, Interfaces: []string{"wlan0"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{{Name: "wlan0", Backend: models.BackendSystemdResolved}}, sel.Targets)
+	})
+
+	t.Run("active uses given interfaces", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendNetworkManager, SetOptions{Interfaces: []string{"eth0", "wlan0"}})
+		require.NoError(t, err)
+		assert.Len(t, sel.Targets, 2)
+	})
+
+	t.Run("active detects default route interfaces", func(t *testing.T) {
+		root := fakeSysfs(t, map[string]string{"eth0": "up", "wlan0": "up"}, "wlan0")
+
+		s := &Service{logger: slog.Default(), discovery: discovery.NewDiscovererAt(root)}
+		sel, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{{Name: "wlan0", Backend: models.BackendSystemdResolved}}, sel.Targets)
+		assert.Equal(t, []exclusion{{Name: "eth0", Reason: "no default route"}}, sel.Excluded)
+	})
+
+	t.Run("active without any interface up", func(t *testing.T) {
+		root := fakeSysfs(t, nil, "")
+
+		s := &Service{logger: slog.Default(), discovery: discovery.NewDiscovererAt(root)}
+		_, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{})
+		assert.ErrorIs(t, err, ErrNoActiveInterfaces)
+	})
+
+	t.Run("all conflicts with interface", func(t *testing.T) {
+		_, err := s.resolveTargets(ctx, models.BackendNetworkManager, SetOptions{Scope: ScopeAll, Interfaces: []string{"eth0"}})
+		assert.ErrorIs(t, err, ErrInvalidScope)
+	})
+
+	t.Run("unknown scope", func(t *testing.T) {
+		_, err := s.resolveTargets(ctx, models.BackendNetworkManager, SetOptions{Scope: "everything"})
+		assert.ErrorIs(t, err, ErrInvalidScope)
+		assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+	})
+}
+
+func TestInactiveProfiles(t *testing.T) {
+	profiles := []backend.NMProfile{
+		{Name: "Wired connection 1", UUID: "3f2c0a52-8c1e-4a3e-9d6a-0b1c2d3e4f50", Type: "802-3-ethernet", Device: "eth0"},
+		{Name: "Office: HQ", UUID: "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff", Type: "802-11-wireless"},
+		{Name: "lo", UUID: "9a8b7c6d-2222-4e5f-a0b1-112233445566", Type: "loopback"},
+	}
+
+	assert.Equal(t, []models.NetworkInterface{
+		{Backend: models.BackendNetworkManager, Connection: "Office: HQ", ConnectionUUID: "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff"},
+	}, inactiveProfiles(profiles, models.BackendNetworkManager))
+}
+
+func TestMatchProfiles(t *testing.T) {
+	profiles := []backend.NMProfile{
+		{Name: "Home", UUID: "3f2c0a52-8c1e-4a3e-9d6a-0b1c2d3e4f50", Type: "802-11-wireless", Device: "wlan0"},
+		{Name: "Office WiFi", UUID: "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff", Type: "802-11-wireless"},
+		{Name: "VPN", UUID: "11111111-2222-4333-8444-555555555555", Type: "vpn"},
+		{Name: "VPN", UUID: "66666666-7777-4888-8999-000000000000", Type: "vpn"},
+	}
+	nm := models.BackendNetworkManager
+
+	t.Run("inactive profile by name", func(t *testing.T) {
+		sel, err := matchProfiles(profiles, []string{"Office WiFi"}, nil, nm)
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{
+			{Backend: nm, Connection: "Office WiFi", ConnectionUUID: "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff"},
+		}, sel.Targets)
+		assert.Equal(t, "inactive profile", sel.Reasons["Office WiFi (profile)"])
+	})
+
+	t.Run("active profile keeps its device", func(t *testing.T) {
+		sel, err := matchProfiles(profiles, nil, []string{"3F2C0A52-8C1E-4A3E-9D6A-0B1C2D3E4F50"}, nm)
+		require.NoError(t, err)
+		require.Len(t, sel.Targets, 1)
+		assert.Equal(t, "wlan0", sel.Targets[0].Name)
+		assert.Equal(t, "profile active on wlan0", sel.Reasons["wlan0 (Home)"])
+	})
+
+	t.Run("ambiguous name", func(t *testing.T) {
+		_, err := matchProfiles(profiles, []string{"VPN"}, nil, nm)
+		assert.ErrorIs(t, err, ErrInvalidConnection)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := matchProfiles(profiles, []string{"Cafe"}, nil, nm)
+		assert.ErrorIs(t, err, ErrConnectionNotFound)
+		assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+	})
+}
+
+func TestService_ResolveTargets_Connections(t *testing.T) {
+	s := &Service{logger: slog.Default()}
+	ctx := context.Background()
+
+	t.Run("requires NetworkManager", func(t *testing.T) {
+		_, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Connections: []string{"Office WiFi"}})
+		assert.ErrorIs(t, err, backend.ErrUnsupported)
+	})
+
+	t.Run("conflicts with scope all", func(t *testing.T) {
+		_, err := s.resolveTargets(ctx, models.BackendNetworkManager, SetOptions{Scope: ScopeAll, Connections: []string{"Office WiFi"}})
+		assert.ErrorIs(t, err, ErrInvalidScope)
+	})
+}
+
+func TestService_ResolveTargets_Patterns(t *testing.T) {
+	ctx := context.Background()
+	root := fakeSysfs(t, map[string]string{
+		"enp3s0":  "up",
+		"wlan0":   "up",
+		"wlan1":   "down",
+		"docker0": "up",
+		"veth9f":  "up",
+	}, "enp3s0")
+	// Mark docker0 as a bridge so it is classified as virtual
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sys", "class", "net", "docker0", "bridge"), 0755))
+
+	s := &Service{
+		logger:    slog.Default(),
+		discovery: discovery.NewDiscovererAt(root),
+		config:    &config.Config{DNS: config.DNSConfig{ExcludeInterfaces: []string{"veth*"}}},
+	}
+
+	t.Run("glob matches interfaces that are up", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Interfaces: []string{"wl*"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{{Name: "wlan0", Backend: models.BackendSystemdResolved}}, sel.Targets)
+		assert.Equal(t, `matches "wl*"`, sel.Reasons["wlan0"])
+		assert.Equal(t, []exclusion{{Name: "wlan1", Reason: `matches "wl*" but is down`}}, sel.Excluded)
+	})
+
+	t.Run("explicit scope includes interfaces that are down", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Scope: ScopeExplicit, Interfaces: []string{"/wlan[0-9]/"}})
+		require.NoError(t, err)
+		assert.Len(t, sel.Targets, 2)
+	})
+
+	t.Run("exclude flag and config patterns", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Interfaces: []string{"*"}, Exclude: []string{"wl*"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{
+			{Name: "docker0", Backend: models.BackendSystemdResolved},
+			{Name: "enp3s0", Backend: models.BackendSystemdResolved},
+		}, sel.Targets)
+		assert.Contains(t, sel.Excluded, exclusion{Name: "veth9f", Reason: `matches exclude pattern "veth*"`})
+		assert.Contains(t, sel.Excluded, exclusion{Name: "wlan0", Reason: `matches exclude pattern "wl*"`})
+	})
+
+	t.Run("all scope skips virtual interfaces", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Scope: ScopeAll})
+		require.NoError(t, err)
+		assert.Len(t, sel.Targets, 3)
+		assert.Contains(t, sel.Excluded, exclusion{Name: "docker0", Reason: "virtual interface (bridge)"})
+	})
+
+	t.Run("pattern without matches", func(t *testing.T) {
+		_, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Interfaces: []string{"tailscale*"}})
+		assert.ErrorIs(t, err, ErrNoMatchingInterfaces)
+	})
+
+	t.Run("invalid exclude pattern", func(t *testing.T) {
+		_, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Exclude: []string{"wl["}})
+		assert.ErrorIs(t, err, ErrInvalidInterfaceName)
+		assert.Equal(t, ExitValidationError, ExitCodeFromError(err))
+	})
+}
+
+func TestService_ResolveTargets_Offline(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	netplan := filepath.Join(dir, "etc", "netplan")
+	require.NoError(t, os.MkdirAll(netplan, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(netplan, "50-cloud-init.yaml"), []byte(`network:
+  ethernets:
+    enp1s0: {dhcp4: true}
+    enp2s0: {dhcp4: true}
+  wifis:
+    wlp3s0: {dhcp4: true}
+`), 0644))
+	keyfiles := filepath.Join(dir, "etc", "NetworkManager", "system-connections")
+	require.NoError(t, os.MkdirAll(keyfiles, 0755))
+	for name, content := range map[string]string{
+		"office.nmconnection": "[connection]\nid=Office\nuuid=0b9e4d5c-8c59-4a55-9f3c-b7b0f1d0a6a1\ntype=ethernet\n",
+		"lo.nmconnection":     "[connection]\nid=lo\ntype=loopback\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(keyfiles, name), []byte(content), 0600))
+	}
+
+	root := backend.NewRoot()
+	require.NoError(t, root.Set(dir))
+	// The host sysfs must not be consulted for an image
+	s := &Service{logger: slog.Default(), root: root, nm: backend.NewNMClient(nil, root)}
+
+	t.Run("netplan defined interfaces", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendNetplan, SetOptions{Exclude: []string{"wl*"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{
+			{Name: "enp1s0", Backend: models.BackendNetplan},
+			{Name: "enp2s0", Backend: models.BackendNetplan},
+		}, sel.Targets)
+		assert.Equal(t, []exclusion{{Name: "wlp3s0", Reason: `matches exclude pattern "wl*"`}}, sel.Excluded)
+	})
+
+	t.Run("netplan patterns", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendNetplan, SetOptions{Interfaces: []string{"enp2*"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{{Name: "enp2s0", Backend: models.BackendNetplan}}, sel.Targets)
+
+		_, err = s.resolveTargets(ctx, models.BackendNetplan, SetOptions{Interfaces: []string{"eth*"}})
+		assert.ErrorIs(t, err, ErrNoMatchingInterfaces)
+	})
+
+	t.Run("NetworkManager profiles", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendNetworkManager, SetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{{
+			Backend:        models.BackendNetworkManager,
+			Connection:     "Office",
+			ConnectionUUID: "0b9e4d5c-8c59-4a55-9f3c-b7b0f1d0a6a1",
+		}}, sel.Targets)
+
+		sel, err = s.resolveTargets(ctx, models.BackendNetworkManager, SetOptions{Connections: []string{"Office"}, Interfaces: []string{"eth0"}})
+		require.NoError(t, err)
+		assert.Len(t, sel.Targets, 2)
+	})
+
+	t.Run("system-wide backends", func(t *testing.T) {
+		sel, err := s.resolveTargets(ctx, models.BackendSystemdResolved, SetOptions{Scope: ScopeAll})
+		require.NoError(t, err)
+		assert.Equal(t, []models.NetworkInterface{{Name: "system", Backend: models.BackendSystemdResolved}}, sel.Targets)
+	})
+}
+
+// fakeSysfs creates a sysfs/procfs tree with the given interfaces and their
+// operstate, and an IPv4 default route through defaultRoute if set
+func fakeSysfs(t *testing.T, ifaces map[string]string, defaultRoute string) string {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sys", "class", "net"), 0755))
+	for name, operstate := range ifaces {
+		dir := filepath.Join(root, "sys", "class", "net", name)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "device"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "operstate"), []byte(operstate+"\n"), 0644))
+	}
+
+	routes := "Iface\tDestination\tGateway\tFlags\tRefCnt\tUse\tMetric\tMask\n"
+	if defaultRoute != "" {
+		routes += defaultRoute + "\t00000000\t0100A8C0\t0003\t0\t0\t600\t00000000\n"
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "proc", "net"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "proc", "net", "route"), []byte(routes), 0644))
+	return root
+}
+
+func TestApplyScopeDefaults(t *testing.T) {
+	// Flags defaulting to dns.default_scope: all and dns.default_interfaces: [eth0]
+	run := func(args ...string) SetOptions {
+		var opts SetOptions
+		cmd := &cobra.Command{Use: "set"}
+		cmd.Flags().StringSliceVar(&opts.Interfaces, "interface", []string{"eth0"}, "")
+		cmd.Flags().StringVar(&opts.Scope, "scope", ScopeAll, "")
+		cmd.Flags().StringArrayVar(&opts.Map, "map", nil, "")
+		require.NoError(t, cmd.Flags().Parse(args))
+		applyScopeDefaults(cmd, &opts)
+		return opts
+	}
+
+	opts := run()
+	assert.Equal(t, ScopeAll, opts.Scope)
+	assert.Empty(t, opts.Interfaces)
+
+	opts = run("--interface", "wlan0")
+	assert.Equal(t, ScopeActive, opts.Scope)
+	assert.Equal(t, []string{"wlan0"}, opts.Interfaces)
+
+	opts = run("--scope", "explicit")
+	assert.Equal(t, ScopeExplicit, opts.Scope)
+	assert.Equal(t, []string{"eth0"}, opts.Interfaces)
+
+	opts = run("--map", "wlan0=cloudflare")
+	assert.Equal(t, ScopeActive, opts.Scope)
+	assert.Empty(t, opts.Interfaces)
+
+	// Flags given together still conflict
+	opts = run("--scope", "all", "--interface", "wlan0")
+	_, err := (&Service{logger: slog.Default()}).resolveTargets(context.Background(), models.BackendNetworkManager, opts)
+	assert.ErrorIs(t, err, ErrInvalidScope)
+}
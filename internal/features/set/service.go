@@ -7,13 +7,16 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
+	"sort"
 	"strings"
+	"time"
 
 	"gitlab.com/junevm/cdns/internal/config"
 	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
 	"gitlab.com/junevm/cdns/internal/dns/models"
 	"gitlab.com/junevm/cdns/internal/dns/presets"
+	"gitlab.com/junevm/cdns/internal/dns/state"
 	"gitlab.com/junevm/cdns/internal/logger"
 	"gitlab.com/junevm/cdns/internal/ui"
 
@@ -26,6 +29,15 @@ var (
 
 	// ErrUserCancelled is returned when user cancels the operation
 	ErrUserCancelled = errors.New("operation cancelled by user")
+
+	// ErrNoActiveInterfaces is returned when no interface could be detected and none was given
+	ErrNoActiveInterfaces = errors.New("no active network interfaces found, use --interface to select one")
+
+	// ErrPartialFailure is returned when DNS could only be applied to some of the interfaces
+	ErrPartialFailure = errors.New("DNS was not applied to every interface")
+
+	// ErrNoMatchingInterfaces is returned when no interface matches the --interface patterns
+	ErrNoMatchingInterfaces = errors.New("no interface matches")
 )
 
 // ExitCode represents command exit codes
@@ -40,12 +52,53 @@ const (
 
 // SetOptions holds options for the set operation
 type SetOptions struct {
-	Interfaces []string
-	Scope      string // "active", "all", "explicit"
-	DryRun     bool
-	Yes        bool // Skip confirmation
-	Verbose    bool // Show verbose logs
-	PresetName string
+	Interfaces []string // Interface names or glob/regex patterns
+	Exclude    []string // Patterns of interfaces never to touch
+	Scope      string   // "active", "all", "explicit"
+
+	Map []string // Per-interface assignments, "iface=preset" or "iface=ip[,ip...]"
+
+	Connections     []string // NetworkManager connection profiles, by name
+	ConnectionUUIDs []string // NetworkManager connection profiles, by UUID
+	Global          bool     // NetworkManager global DNS for every connection
+	DryRun          bool
+	Yes             bool // Skip confirmation
+	Verbose         bool // Show verbose logs
+	PresetName      string
+
+	Family string // Address family of the servers to use: "auto", "ipv4", "ipv6" or "both"
+	Prefer string // Family listed first: "ipv4" (default) or "ipv6"
+
+	Search          []string // Search domains
+	Ndots           string   // ndots resolver option, empty leaves it unset
+	ResolverOptions []string // Additional resolver options (e.g. "rotate", "timeout:2")
+
+	Link models.LinkSettings // Per-link DNSSEC, LLMNR and MulticastDNS settings
+
+	Strict bool // Fail on address warnings instead of printing them
+
+	Output ui.Format // --output; json and yaml print a Result instead of messages
+
+	suggestDNSSEC bool // Preset validates DNSSEC, hint at --dnssec=yes
+}
+
+// resolverOptions returns the requested resolver options with --ndots folded in
+func (o SetOptions) resolverOptions() []string {
+	var options []string
+	for _, opt := range o.ResolverOptions {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		if o.Ndots != "" && strings.HasPrefix(opt, "ndots:") {
+			continue // --ndots takes precedence
+		}
+		options = append(options, opt)
+	}
+	if o.Ndots != "" {
+		options = append(options, "ndots:"+o.Ndots)
+	}
+	return options
 }
 
 // Detector interface for backend detection
@@ -55,26 +108,40 @@ type Detector interface {
 
 // Service handles set operations
 type Service struct {
-	config   *config.Config
-	logger   *slog.Logger
-	detector *backend.Detector
-	writer   *backend.ConfigWriter
-	reader   *backend.ConfigReader
-	styles   *ui.Styles
+	config    *config.Config
+	logger    *slog.Logger
+	detector  *backend.Detector
+	writer    *backend.ConfigWriter
+	reader    *backend.ConfigReader
+	nm        backend.NMClient
+	root      *backend.Root
+	discovery *discovery.Discoverer
+	state     *state.Store
+	styles    *ui.Styles
 }
 
 // NewService creates a new set service
-func NewService(cfg *config.Config, logger *slog.Logger, sysOps backend.SystemOps) *Service {
+func NewService(cfg *config.Config, logger *slog.Logger, sysOps backend.SystemOps, nm backend.NMClient, runner backend.CommandRunner, root *backend.Root, detector *backend.Detector, store *state.Store) *Service {
 	return &Service{
-		config:   cfg,
-		logger:   logger,
-		detector: backend.NewDetector(sysOps),
-		writer:   backend.NewConfigWriter(sysOps),
-		reader:   backend.NewConfigReader(sysOps),
-		styles:   ui.NewStyles(),
+		config:    cfg,
+		logger:    logger,
+		detector:  detector,
+		writer:    backend.NewConfigWriter(sysOps, nm, runner, root),
+		reader:    backend.NewConfigReader(sysOps, nm, runner, root),
+		nm:        nm,
+		root:      root,
+		discovery: discovery.NewDiscoverer(),
+		state:     store,
+		styles:    ui.NewStyles(),
 	}
 }
 
+// Offline reports whether set configures a --root image rather than the
+// running system
+func (s *Service) Offline() bool {
+	return s.root.Offline()
+}
+
 // IsInteractive checks if the stdout is a terminal
 func (s *Service) IsInteractive() bool {
 	return ui.IsTTY()
@@ -86,28 +153,18 @@ func (s *Service) RunInteractiveSet(ctx context.Context, opts SetOptions) error
 		return errors.New("interactive mode requires a terminal")
 	}
 
-	// check if nmcli is available
-	if _, err := exec.LookPath("nmcli"); err != nil {
-		fmt.Printf("%s %s\n\n", s.styles.Error.Render("Error:"), "NetworkManager (nmcli) is not installed.")
-		fmt.Println("This tool requires nmcli to manage DNS settings.")
-		fmt.Println("Please install it using your package manager:")
-		fmt.Println("  Fedora/RHEL: sudo dnf install NetworkManager")
-		fmt.Println("  Ubuntu/Debian: sudo apt install network-manager")
-		fmt.Println("  Arch: sudo pacman -S networkmanager")
-		return fmt.Errorf("nmcli not found")
-	}
-
-	// fetch available interfaces
-	var ifaces []string
-	// Use backend reader to get interfaces (we can improve this later to use proper structured data)
-	// For now, let's use a simple detection similar to what was inline before, but better
-	detected, err := s.detectInterfaces(ctx)
+	// List the interfaces that are up, default-route ones first
+	var ifaces []discovery.Interface
+	all, err := s.discovery.Interfaces()
 	if err != nil {
 		s.logger.Warn("failed to detect interfaces", slog.Any("error", err))
-		// continue with empty list or fallback
-	} else {
-		ifaces = detected
 	}
+	for _, iface := range all {
+		if iface.Kind != discovery.KindLoopback && iface.IsUp() {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	sort.SliceStable(ifaces, func(i, j int) bool { return ifaces[i].DefaultRoute && !ifaces[j].DefaultRoute })
 
 	// Initialize TUI model
 	m := newModel(s.config, ifaces)
@@ -156,7 +213,17 @@ func (s *Service) SmartSet(ctx context.Context, args []string, opts SetOptions)
 		s.logger.Debug("verbose logging enabled")
 	}
 
+	if len(opts.Map) > 0 {
+		if len(args) > 0 {
+			return fmt.Errorf("validation failed: %w: --map cannot be combined with a preset or DNS servers", ErrInvalidMapping)
+		}
+		return s.SetMap(ctx, opts.Map, opts)
+	}
+
 	if len(args) == 0 {
+		if opts.Output.Structured() {
+			return fmt.Errorf("validation failed: %w: interactive mode has no --output %s, give a preset or servers", ErrNoDNSAddresses, opts.Output)
+		}
 		return s.RunInteractiveSet(ctx, opts)
 	}
 
@@ -173,35 +240,16 @@ func (s *Service) SmartSet(ctx context.Context, args []string, opts SetOptions)
 	}
 
 	// Check if args are valid IPs (Custom DNS)
-	if err := ValidateDNSAddresses(args); err == nil {
+	err := ValidateDNSAddresses(args)
+	if err == nil {
 		return s.SetCustom(ctx, args, opts)
 	}
-
-	return fmt.Errorf("invalid argument '%s': not a known preset or valid IP address", args[0])
-}
-
-// detectInterfaces returns a list of active network interfaces
-func (s *Service) detectInterfaces(ctx context.Context) ([]string, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("context is nil")
-	}
-
-	// Attempt to detect connected interfaces via nmcli
-	cmd := exec.CommandContext(ctx, "nmcli", "-t", "-f", "DEVICE,STATE", "device", "status")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("nmcli error: %s: %w", strings.TrimSpace(string(output)), err)
+	// Report why an address was rejected rather than treating it as a preset name
+	if _, parseErr := models.ParseServerAddress(args[0]); parseErr == nil {
+		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	var interfaces []string
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	for scanner.Scan() {
-		parts := strings.Split(scanner.Text(), ":")
-		if len(parts) >= 2 && parts[1] == "connected" {
-			interfaces = append(interfaces, parts[0])
-		}
-	}
-	return interfaces, nil
+	return fmt.Errorf("invalid argument '%s': not a known preset or valid IP address", args[0])
 }
 
 func CapitalizePresetName(name string) string {
@@ -220,26 +268,44 @@ func CapitalizePresetName(name string) string {
 
 // SetPreset applies a DNS preset
 func (s *Service) SetPreset(ctx context.Context, presetName string, opts SetOptions) error {
+	preset, ok := s.lookupPreset(presetName)
+	if !ok {
+		return fmt.Errorf("validation failed: %w: %s", ErrInvalidPresetName, strings.ToLower(presetName))
+	}
+
+	opts.PresetName = preset.name
+	opts.suggestDNSSEC = preset.dnssec
+	return s.setDNS(ctx, preset.servers, opts)
+}
+
+// resolvedPreset is a preset's display name and server addresses
+type resolvedPreset struct {
+	name    string
+	servers []string
+	dnssec  bool
+}
+
+// lookupPreset finds a preset by name, checking custom presets from the
+// config before the built-in ones
+func (s *Service) lookupPreset(presetName string) (resolvedPreset, bool) {
 	presetName = strings.ToLower(presetName)
 
 	// 1. Check custom presets from config first
 	if s.config != nil && s.config.DNS.CustomPresets != nil {
 		if ips, ok := s.config.DNS.CustomPresets[presetName]; ok {
 			s.logger.Debug("using custom preset from config", slog.String("name", presetName))
-			opts.PresetName = strings.ToUpper(presetName)
-			return s.setDNS(ctx, ips, opts)
+			return resolvedPreset{name: strings.ToUpper(presetName), servers: ips}, true
 		}
 	}
 
 	// 2. Check built-in presets
 	if preset, ok := presets.Get(presetName); ok {
 		// Combine IPv4 and IPv6 addresses
-		dnsAddresses := append(preset.IPv4, preset.IPv6...)
-		opts.PresetName = CapitalizePresetName(presetName)
-		return s.setDNS(ctx, dnsAddresses, opts)
+		servers := append(append([]string{}, preset.IPv4...), preset.IPv6...)
+		return resolvedPreset{name: CapitalizePresetName(presetName), servers: servers, dnssec: preset.DNSSEC}, true
 	}
 
-	return fmt.Errorf("validation failed: %w: %s", ErrInvalidPresetName, presetName)
+	return resolvedPreset{}, false
 }
 
 // SetCustom applies custom DNS servers
@@ -254,12 +320,34 @@ func (s *Service) SetCustom(ctx context.Context, dnsAddresses []string, opts Set
 
 // setDNS is the internal method that applies DNS settings
 func (s *Service) setDNS(ctx context.Context, dnsAddresses []string, opts SetOptions) error {
-	// Validate interface names if provided
-	for _, iface := range opts.Interfaces {
+	if err := s.checkOutput(opts); err != nil {
+		return err
+	}
+
+	// Validate interface names and patterns if provided
+	for _, iface := range append(opts.Interfaces, opts.Exclude...) {
 		if err := ValidateInterfaceName(iface); err != nil {
 			return fmt.Errorf("validation failed: %w", err)
 		}
 	}
+	for _, name := range opts.Connections {
+		if err := ValidateConnectionName(name); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	for _, uuid := range opts.ConnectionUUIDs {
+		if err := ValidateConnectionUUID(uuid); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
+	resolverOptions, err := validateResolverSettings(opts)
+	if err != nil {
+		return err
+	}
+	if err := ValidateAddressFamily(opts.family(), opts.Prefer); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
 
 	// Detect backend
 	backendObj, err := s.detector.Detect()
@@ -269,45 +357,74 @@ func (s *Service) setDNS(ctx context.Context, dnsAddresses []string, opts SetOpt
 
 	s.logger.Debug("detected backend", slog.String("backend", string(backendObj)))
 
-	// Identify target interfaces
-	var targetInterfaces []string
-	if len(opts.Interfaces) > 0 {
-		targetInterfaces = opts.Interfaces
-	} else {
-		// Auto-detect active interfaces
-		detected, err := s.detectInterfaces(ctx)
-		if err != nil {
-			s.logger.Warn("failed to detect active interfaces", slog.Any("error", err))
-		}
-		if len(detected) > 0 {
-			targetInterfaces = detected
-		} else {
-			// Fallback (only if absolutely no detection possible)
-			targetInterfaces = []string{"eth0"}
-		}
+	if opts.Global {
+		return s.setGlobal(ctx, backendObj, dnsAddresses, resolverOptions, opts)
+	}
+
+	// Identify target interfaces according to --scope, --interface and --exclude
+	selection, err := s.resolveTargets(ctx, backendObj, opts)
+	if err != nil {
+		return err
+	}
+	targets := selection.Targets
+	for _, excluded := range selection.Excluded {
+		s.logger.Debug("interface excluded", slog.String("interface", excluded.Name), slog.String("reason", excluded.Reason))
+	}
+
+	targetInterfaces := make([]string, 0, len(targets))
+	for _, target := range targets {
+		targetInterfaces = append(targetInterfaces, target.Label())
 	}
 
 	// Prepare config for all target interfaces
-	appliedConfigs := make([]models.DNSConfig, 0, len(targetInterfaces))
+	appliedConfigs := make([]models.DNSConfig, 0, len(targets))
 
 	// Clean and separate addresses
 	ipv4, ipv6 := SeparateIPv4AndIPv6(dnsAddresses)
 
-	for _, iface := range targetInterfaces {
+	for _, target := range targets {
+		servers, note, err := s.serversFor(target, ipv4, ipv6, opts)
+		if err != nil {
+			return err
+		}
+		if note != "" {
+			selection.note(target, note)
+		}
 		appliedConfigs = append(appliedConfigs, models.DNSConfig{
-			Interface: models.NetworkInterface{Name: iface, Backend: backendObj},
-			DNS:       models.DNSServer{IPv4: ipv4, IPv6: ipv6},
+			Interface: target,
+			DNS:       servers,
+			Search:    opts.Search,
+			Options:   resolverOptions,
+			Link:      opts.Link,
 		})
 	}
 
+	if err := backend.CheckSupport(backendObj, appliedConfigs); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	// Report the servers in the order and families requested
+	dnsAddresses = orderedAddresses(ipv4, ipv6, opts)
+
+	warnings, err := s.checkAddressWarnings(dnsAddresses, appliedConfigs, opts)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		s.logger.Debug("address warning", slog.String("warning", warning))
+	}
+
 	// Dry-run mode: show what would change and exit
 	if opts.DryRun {
-		return s.showDryRun(backendObj, dnsAddresses, appliedConfigs, opts)
+		if opts.Output.Structured() {
+			return s.printResult(newResult(backendObj, dnsAddresses, warnings, appliedConfigs, selection, OutcomePlanned, opts), opts)
+		}
+		return s.showDryRun(backendObj, dnsAddresses, warnings, appliedConfigs, selection, opts)
 	}
 
 	// Confirmation prompt (only if interactive and not suppressed by --yes)
 	if !opts.Yes && s.IsInteractive() {
-		confirmed, err := s.confirmChange(dnsAddresses, targetInterfaces, opts)
+		confirmed, err := s.confirmChange(dnsAddresses, warnings, targetInterfaces, opts)
 		if err != nil {
 			return err
 		}
@@ -316,17 +433,26 @@ func (s *Service) setDNS(ctx context.Context, dnsAddresses []string, opts SetOpt
 		}
 	}
 
+	// Capture the settings we are about to replace so reset can restore them
+	previous := s.capturePrevious(ctx, backendObj, targets, opts.Link)
+
 	// Apply DNS changes via backend
 	// KISS: No "Applying..." spinner mess unless logic is slow. nmcli is fast.
 	if err := s.writer.Apply(ctx, backendObj, appliedConfigs); err != nil {
 		return fmt.Errorf("failed to apply DNS: %w", err)
 	}
 
+	s.recordSnapshot(backendObj, opts.scope(), appliedConfigs, previous)
+
 	s.logger.Debug("DNS settings applied",
 		slog.Any("dns", dnsAddresses),
 		slog.Any("interfaces", targetInterfaces),
 		slog.String("backend", string(backendObj)))
 
+	if opts.Output.Structured() {
+		return s.printResult(newResult(backendObj, dnsAddresses, warnings, appliedConfigs, selection, OutcomeApplied, opts), opts)
+	}
+
 	// Minimal feedback
 	if s.IsInteractive() {
 		fmt.Printf("%s Applied DNS (%s) to %s.\n",
@@ -342,31 +468,226 @@ func (s *Service) setDNS(ctx context.Context, dnsAddresses []string, opts SetOpt
 		}
 	}
 
+	if s.IsInteractive() {
+		s.printDNSSECHint(backendObj, opts)
+	}
+
 	return nil
 }
 
+// validateResolverSettings validates the search domains, resolver options
+// and link settings, returning the resolver options to apply
+func validateResolverSettings(opts SetOptions) ([]string, error) {
+	for _, domain := range opts.Search {
+		if err := ValidateSearchDomain(domain); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	resolverOptions := opts.resolverOptions()
+	for _, opt := range resolverOptions {
+		if err := ValidateResolverOption(opt); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	if err := ValidateLinkSettings(opts.Link); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	return resolverOptions, nil
+}
+
+// previousSettings holds what a set is about to replace, so reset can
+// restore it
+type previousSettings struct {
+	// links holds the link settings of each target, when set changes them
+	links map[string]models.LinkSettings
+	// resolvConf holds the resolver lines of resolv.conf before it is rewritten
+	resolvConf *models.ResolvConf
+}
+
+// capturePrevious reads the settings about to be replaced so reset can
+// restore them. Link settings are only read when set changes them, and
+// nothing is read offline where no snapshot is kept.
+func (s *Service) capturePrevious(ctx context.Context, backendObj models.Backend, targets []models.NetworkInterface, link models.LinkSettings) previousSettings {
+	previous := previousSettings{links: make(map[string]models.LinkSettings)}
+	if s.Offline() {
+		return previous
+	}
+	if backendObj == models.BackendResolvConf {
+		if conf, err := s.reader.ReadResolvConf(); err != nil {
+			s.logger.Warn("failed to read current resolv.conf", slog.Any("error", err))
+		} else {
+			previous.resolvConf = &conf
+		}
+	}
+	if link.IsZero() {
+		return previous
+	}
+	for _, target := range targets {
+		if target.Name == "" {
+			continue // inactive profiles have no live link settings
+		}
+		current, err := s.reader.ReadLinkSettings(ctx, backendObj, target.Name)
+		if err != nil {
+			s.logger.Warn("failed to read current link settings", slog.String("interface", target.Name), slog.Any("error", err))
+			continue
+		}
+		previous.links[target.Name] = current
+	}
+	return previous
+}
+
+// printDNSSECHint suggests --dnssec=yes when the preset validates DNSSEC and
+// the backend can enforce it per link
+func (s *Service) printDNSSECHint(backendObj models.Backend, opts SetOptions) {
+	if !opts.suggestDNSSEC || opts.Link.DNSSEC != "" || backendObj != models.BackendSystemdResolved {
+		return
+	}
+	fmt.Printf("%s\n", s.styles.RenderDim(fmt.Sprintf("Tip: %s validates DNSSEC; add --dnssec=yes to enforce validation on these links.", opts.PresetName)))
+}
+
+// recordSnapshot stores what was applied so status can report it and reset
+// can roll it back. Failures are logged, the DNS change itself succeeded.
+// Offline nothing is stored: the state file belongs to the host, not to the
+// image.
+func (s *Service) recordSnapshot(backendObj models.Backend, scope string, configs []models.DNSConfig, previous previousSettings) {
+	if s.state == nil || s.Offline() {
+		return
+	}
+
+	snap, err := s.state.Load()
+	if err != nil {
+		s.logger.Warn("failed to load state, starting a new snapshot", slog.Any("error", err))
+		snap = &state.Snapshot{}
+	}
+	if snap.Backend != backendObj || snap.Interfaces == nil {
+		snap.Interfaces = map[string]state.InterfaceRecord{}
+		snap.PreviousResolvConf = nil
+	}
+	// Keep the original resolv.conf so repeated sets still roll back to it
+	if snap.PreviousResolvConf == nil {
+		snap.PreviousResolvConf = previous.resolvConf
+	}
+	snap.Backend = backendObj
+	snap.Scope = scope
+	snap.UpdatedAt = time.Now().UTC()
+
+	for _, cfg := range configs {
+		key := cfg.Interface.Name
+		if key == "" {
+			key = cfg.Interface.Connection
+		}
+		rec := snap.Interfaces[key]
+		rec.Servers = append(append([]string{}, cfg.DNS.IPv4...), cfg.DNS.IPv6...)
+		// Keep the oldest previous value so repeated sets still roll back to the original
+		if prev, ok := previous.links[key]; ok && rec.Link.IsZero() {
+			rec.PreviousLink = prev
+		}
+		rec.Link = mergeLinkSettings(rec.Link, cfg.Link)
+		snap.Interfaces[key] = rec
+	}
+
+	if err := s.state.Save(snap); err != nil {
+		s.logger.Warn("failed to save state", slog.String("path", s.state.Path()), slog.Any("error", err))
+	}
+}
+
+// mergeLinkSettings overlays the non-empty fields of update onto base
+func mergeLinkSettings(base, update models.LinkSettings) models.LinkSettings {
+	if update.DNSSEC != "" {
+		base.DNSSEC = update.DNSSEC
+	}
+	if update.LLMNR != "" {
+		base.LLMNR = update.LLMNR
+	}
+	if update.MulticastDNS != "" {
+		base.MulticastDNS = update.MulticastDNS
+	}
+	return base
+}
+
 // showDryRun displays what would change without applying
-func (s *Service) showDryRun(backend models.Backend, dnsAddresses []string, configs []models.DNSConfig, opts SetOptions) error {
+func (s *Service) showDryRun(backend models.Backend, dnsAddresses, warnings []string, configs []models.DNSConfig, selection targetSelection, opts SetOptions) error {
 	fmt.Printf("%s\n\n", s.styles.RenderBold("Dry-run mode: No changes will be applied"))
 	fmt.Printf("Backend: %s\n", s.styles.RenderInfo(string(backend)))
+	fmt.Printf("Scope: %s\n", s.styles.RenderInfo(opts.scope()))
+	s.printAddressFamily(opts)
 	fmt.Printf("DNS servers to set:\n")
 	for _, dns := range dnsAddresses {
 		fmt.Printf("  - %s\n", s.styles.RenderInfo(dns))
 	}
+	s.printAddressWarnings(warnings)
+
+	s.printResolverSettings(opts)
 
 	fmt.Printf("Target Interfaces:\n")
 	for _, cfg := range configs {
-		fmt.Printf("  - %s\n", s.styles.RenderBold(cfg.Interface.Name))
+		label := cfg.Interface.Label()
+		if reason := selection.Reasons[label]; reason != "" {
+			fmt.Printf("  - %s %s\n", s.styles.RenderBold(label), s.styles.RenderDim("("+reason+")"))
+		} else {
+			fmt.Printf("  - %s\n", s.styles.RenderBold(label))
+		}
 	}
+	if len(selection.Excluded) > 0 {
+		fmt.Printf("Excluded Interfaces:\n")
+		for _, excluded := range selection.Excluded {
+			fmt.Printf("  - %s %s\n", excluded.Name, s.styles.RenderDim("("+excluded.Reason+")"))
+		}
+	}
+
+	s.printDNSSECHint(backend, opts)
 
 	return nil
 }
 
+// printResolverSettings prints the search domains, resolver options and link settings for dry-run
+func (s *Service) printResolverSettings(opts SetOptions) {
+	if len(opts.Search) > 0 {
+		fmt.Printf("Search domains: %s\n", s.styles.RenderInfo(strings.Join(opts.Search, ", ")))
+	}
+	if options := opts.resolverOptions(); len(options) > 0 {
+		fmt.Printf("Resolver options: %s\n", s.styles.RenderInfo(strings.Join(options, " ")))
+	}
+	if !opts.Link.IsZero() {
+		fmt.Printf("Link settings: %s\n", s.styles.RenderInfo(formatLinkSettings(opts.Link)))
+	}
+}
+
+// formatLinkSettings renders the non-empty link settings as flag assignments
+func formatLinkSettings(link models.LinkSettings) string {
+	var parts []string
+	if link.DNSSEC != "" {
+		parts = append(parts, "dnssec="+link.DNSSEC)
+	}
+	if link.LLMNR != "" {
+		parts = append(parts, "llmnr="+link.LLMNR)
+	}
+	if link.MulticastDNS != "" {
+		parts = append(parts, "mdns="+link.MulticastDNS)
+	}
+	return strings.Join(parts, " ")
+}
+
 // confirmChange prompts user to confirm the change
-func (s *Service) confirmChange(dnsAddresses []string, interfaces []string, opts SetOptions) (bool, error) {
+func (s *Service) confirmChange(dnsAddresses, warnings, interfaces []string, opts SetOptions) (bool, error) {
 	fmt.Printf("\n%s\n", s.styles.RenderWarning("This will change DNS settings:"))
 	fmt.Printf("  DNS: %s\n", s.styles.RenderInfo(strings.Join(dnsAddresses, ", ")))
+	s.printAddressWarnings(warnings)
 	fmt.Printf("  Interfaces: %s\n", s.styles.RenderBold(strings.Join(interfaces, ", ")))
+	return s.promptYesNo(opts)
+}
+
+// promptYesNo lists the resolver settings being changed and asks for confirmation
+func (s *Service) promptYesNo(opts SetOptions) (bool, error) {
+	if len(opts.Search) > 0 {
+		fmt.Printf("  Search: %s\n", s.styles.RenderInfo(strings.Join(opts.Search, ", ")))
+	}
+	if options := opts.resolverOptions(); len(options) > 0 {
+		fmt.Printf("  Options: %s\n", s.styles.RenderInfo(strings.Join(options, " ")))
+	}
+	if !opts.Link.IsZero() {
+		fmt.Printf("  Link: %s\n", s.styles.RenderInfo(formatLinkSettings(opts.Link)))
+	}
 
 	fmt.Printf("\nContinue? [%s/%s]: ", s.styles.RenderBold("y"), "N")
 
@@ -391,13 +712,29 @@ func ExitCodeFromError(err error) ExitCode {
 		return ExitPermissionError
 	case errors.Is(err, ErrInvalidDNSAddress),
 		errors.Is(err, ErrNoDNSAddresses),
+		errors.Is(err, ErrConfirmationRequired),
+		errors.Is(err, ErrInvalidDesiredState),
+		errors.Is(err, ErrAddressWarning),
 		errors.Is(err, ErrInvalidPresetName),
 		errors.Is(err, ErrEmptyPresetName),
 		errors.Is(err, ErrInvalidInterfaceName),
-		errors.Is(err, ErrEmptyInterfaceName):
+		errors.Is(err, ErrEmptyInterfaceName),
+		errors.Is(err, ErrInvalidSearchDomain),
+		errors.Is(err, ErrInvalidResolverOption),
+		errors.Is(err, ErrInvalidLinkSetting),
+		errors.Is(err, ErrInvalidScope),
+		errors.Is(err, ErrNoActiveInterfaces),
+		errors.Is(err, ErrNoMatchingInterfaces),
+		errors.Is(err, ErrInvalidConnection),
+		errors.Is(err, ErrInvalidMapping),
+		errors.Is(err, ErrInvalidFamily),
+		errors.Is(err, ErrConnectionNotFound),
+		errors.Is(err, backend.ErrUnsupported):
 		return ExitValidationError
+	case errors.Is(err, ErrPartialFailure):
+		return ExitPartialFailure
 	default:
-		// TODO: Add partial failure detection
+		// Any other failure may have left some interfaces changed
 		return ExitPartialFailure
 	}
 }
@@ -0,0 +1,35 @@
+package set
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_RecordSnapshot_ResolvConf(t *testing.T) {
+	store := state.NewStore(filepath.Join(t.TempDir(), "state.json"))
+	s := &Service{logger: slog.Default(), state: store}
+	configs := []models.DNSConfig{{Interface: models.NetworkInterface{Name: "system"}, DNS: models.DNSServer{IPv4: []string{"10.0.0.53"}}}}
+
+	original := &models.ResolvConf{Servers: []string{"192.168.1.1"}, Search: []string{"home.lan"}}
+	s.recordSnapshot(models.BackendResolvConf, ScopeAll, configs, previousSettings{resolvConf: original})
+
+	// A second set keeps the original, not the file the first one wrote
+	s.recordSnapshot(models.BackendResolvConf, ScopeAll, configs, previousSettings{resolvConf: &models.ResolvConf{Servers: []string{"10.0.0.53"}}})
+
+	snap, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, original, snap.PreviousResolvConf)
+
+	// Switching backends forgets it
+	s.recordSnapshot(models.BackendSystemdResolved, ScopeAll, configs, previousSettings{})
+	snap, err = store.Load()
+	require.NoError(t, err)
+	assert.Nil(t, snap.PreviousResolvConf)
+}
@@ -4,12 +4,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"gitlab.com/junevm/cdns/internal/config"
 	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/state"
 	"log/slog"
 	"testing"
 )
 
 func TestService_InteractiveMode(t *testing.T) {
-	s := NewService(&config.Config{}, slog.Default(), &backend.DefaultSystemOps{})
+	s := NewService(&config.Config{}, slog.Default(), &backend.DefaultSystemOps{}, backend.NewNMClient(backend.NewExecRunner(), nil), backend.NewExecRunner(), nil, backend.NewDetector(&backend.DefaultSystemOps{}, nil), state.NewStore(t.TempDir()+"/state.json"))
 
 	t.Run("interactive set mode exists", func(t *testing.T) {
 		assert.NotNil(t, s)
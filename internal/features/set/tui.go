@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
 	"gitlab.com/junevm/cdns/internal/dns/presets"
 	"gitlab.com/junevm/cdns/internal/ui"
 
@@ -34,7 +35,7 @@ type model struct {
 	input textinput.Model
 
 	// Data
-	interfaces []string
+	interfaces []discovery.Interface
 
 	// Selection
 	isCustom       bool
@@ -49,7 +50,7 @@ type model struct {
 	quitting      bool
 }
 
-func newModel(cfg *config.Config, interfaces []string) model {
+func newModel(cfg *config.Config, interfaces []discovery.Interface) model {
 	input := textinput.New()
 	input.Placeholder = "1.1.1.1, 8.8.8.8"
 	input.CharLimit = 100
@@ -266,7 +267,7 @@ func (m *model) initInterfaceTable() {
 		{"All Interfaces", "Apply to all active network interfaces"},
 	}
 	for _, iface := range m.interfaces {
-		rows = append(rows, table.Row{iface, "Network interface"})
+		rows = append(rows, table.Row{iface.Name, interfaceDescription(iface)})
 	}
 
 	m.table.SetRows([]table.Row{})
@@ -288,6 +289,16 @@ func (m *model) initInterfaceTable() {
 	}
 }
 
+// interfaceDescription describes a discovered interface for the selection table
+func interfaceDescription(iface discovery.Interface) string {
+	kind := string(iface.Kind)
+	desc := strings.ToUpper(kind[:1]) + kind[1:] + " interface"
+	if iface.DefaultRoute {
+		desc += ", default route"
+	}
+	return desc
+}
+
 func (m model) View() string {
 	if m.quitting || m.step == stepDone {
 		return ""
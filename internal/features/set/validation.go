@@ -3,10 +3,13 @@ package set
 import (
 	"errors"
 	"fmt"
-	"net"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"gitlab.com/junevm/cdns/internal/dns/discovery"
+	"gitlab.com/junevm/cdns/internal/dns/models"
 	"gitlab.com/junevm/cdns/internal/dns/presets"
 )
 
@@ -31,19 +34,65 @@ var (
 
 	// ErrEmptyInterfaceName is returned when interface name is empty
 	ErrEmptyInterfaceName = errors.New("interface name cannot be empty")
+
+	// ErrInvalidFamily is returned when --family or --prefer is invalid or selects no servers
+	ErrInvalidFamily = errors.New("invalid address family")
+
+	// ErrInvalidConnection is returned when a connection profile name or UUID is invalid or ambiguous
+	ErrInvalidConnection = errors.New("invalid connection profile")
+
+	// ErrConnectionNotFound is returned when no saved connection profile matches
+	ErrConnectionNotFound = errors.New("connection profile not found")
+
+	// ErrInvalidSearchDomain is returned when a search domain is not a valid domain name
+	ErrInvalidSearchDomain = errors.New("invalid search domain")
+
+	// ErrInvalidResolverOption is returned when a resolver option is unknown or out of range
+	ErrInvalidResolverOption = errors.New("invalid resolver option")
+
+	// ErrInvalidScope is returned when --scope is unknown or conflicts with other flags
+	ErrInvalidScope = errors.New("invalid scope")
+
+	// ErrInvalidLinkSetting is returned when a DNSSEC, LLMNR or MulticastDNS value is not recognised
+	ErrInvalidLinkSetting = errors.New("invalid link setting")
 )
 
-// ValidateDNSAddress validates a single DNS address (IPv4 or IPv6)
+// resolverOptionLimits maps numeric resolver options to their allowed range,
+// matching the caps glibc applies when reading resolv.conf
+var resolverOptionLimits = map[string][2]int{
+	"ndots":    {0, 15},
+	"timeout":  {1, 30},
+	"attempts": {1, 5},
+}
+
+// resolverFlagOptions lists the boolean resolver options cdns accepts
+var resolverFlagOptions = map[string]bool{
+	"rotate":                true,
+	"edns0":                 true,
+	"trust-ad":              true,
+	"single-request":        true,
+	"single-request-reopen": true,
+	"use-vc":                true,
+	"no-tld-query":          true,
+}
+
+var domainLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?$`)
+
+// ValidateDNSAddress validates a single DNS address (IPv4 or IPv6), optionally
+// with a port, interface and DNS-over-TLS server name
 func ValidateDNSAddress(address string) error {
 	if address == "" {
 		return ErrEmptyDNSAddress
 	}
 
-	// Parse as IP address
-	ip := net.ParseIP(address)
-	if ip == nil {
+	// Parse as IP address with optional port, interface and server name
+	server, err := models.ParseServerAddress(address)
+	if err != nil {
 		return fmt.Errorf("%w: %s", ErrInvalidDNSAddress, address)
 	}
+	if reason := unusableReason(server); reason != "" {
+		return fmt.Errorf("%w: %s (%s)", ErrInvalidDNSAddress, address, reason)
+	}
 
 	return nil
 }
@@ -80,13 +129,21 @@ func ValidatePresetName(name string) error {
 	return nil
 }
 
-// ValidateInterfaceName validates a network interface name
+// ValidateInterfaceName validates a network interface name, or a glob or
+// /regex/ pattern matching interface names
 // Valid names: alphanumeric, hyphens, underscores, max 15 chars
 func ValidateInterfaceName(name string) error {
 	if name == "" {
 		return ErrEmptyInterfaceName
 	}
 
+	if discovery.IsPattern(name) {
+		if _, err := discovery.ParsePattern(name); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidInterfaceName, err)
+		}
+		return nil
+	}
+
 	// Interface names on Linux are typically max 15 characters
 	if len(name) > 15 {
 		return fmt.Errorf("%w: name too long (max 15 characters)", ErrInvalidInterfaceName)
@@ -105,6 +162,102 @@ func ValidateInterfaceName(name string) error {
 	return nil
 }
 
+// uuidPattern matches the canonical textual form of a UUID
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidateConnectionName validates a NetworkManager connection profile name
+func ValidateConnectionName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidConnection)
+	}
+	return nil
+}
+
+// ValidateConnectionUUID validates a NetworkManager connection profile UUID
+func ValidateConnectionUUID(uuid string) error {
+	if !uuidPattern.MatchString(uuid) {
+		return fmt.Errorf("%w: %s is not a UUID", ErrInvalidConnection, uuid)
+	}
+	return nil
+}
+
+// ValidateSearchDomain validates a single DNS search domain
+func ValidateSearchDomain(domain string) error {
+	trimmed := strings.TrimSuffix(domain, ".")
+	if trimmed == "" {
+		return fmt.Errorf("%w: domain cannot be empty", ErrInvalidSearchDomain)
+	}
+
+	if len(trimmed) > 253 {
+		return fmt.Errorf("%w: %s (longer than 253 characters)", ErrInvalidSearchDomain, domain)
+	}
+
+	for _, label := range strings.Split(trimmed, ".") {
+		if !domainLabelPattern.MatchString(label) {
+			return fmt.Errorf("%w: %s", ErrInvalidSearchDomain, domain)
+		}
+	}
+
+	return nil
+}
+
+// ValidateResolverOption validates a resolver option in resolv.conf form,
+// either a flag such as "rotate" or a "name:value" pair such as "ndots:5"
+func ValidateResolverOption(option string) error {
+	name, value, hasValue := strings.Cut(option, ":")
+
+	if limits, ok := resolverOptionLimits[name]; ok {
+		if !hasValue {
+			return fmt.Errorf("%w: %s requires a value (e.g. %s:%d)", ErrInvalidResolverOption, name, name, limits[1])
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < limits[0] || n > limits[1] {
+			return fmt.Errorf("%w: %s must be between %d and %d", ErrInvalidResolverOption, name, limits[0], limits[1])
+		}
+		return nil
+	}
+
+	if resolverFlagOptions[name] && !hasValue {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidResolverOption, option)
+}
+
+// ValidateAddressFamily validates the --family and --prefer values
+func ValidateAddressFamily(family, prefer string) error {
+	switch strings.ToLower(family) {
+	case FamilyAuto, FamilyIPv4, FamilyIPv6, FamilyBoth:
+	default:
+		return fmt.Errorf("%w: %s (must be auto, ipv4, ipv6, or both)", ErrInvalidFamily, family)
+	}
+	switch strings.ToLower(prefer) {
+	case "", FamilyIPv4, FamilyIPv6:
+	default:
+		return fmt.Errorf("%w: --prefer %s (must be ipv4 or ipv6)", ErrInvalidFamily, prefer)
+	}
+	return nil
+}
+
+// ValidateLinkSettings validates DNSSEC, LLMNR and MulticastDNS values
+func ValidateLinkSettings(link models.LinkSettings) error {
+	checks := []struct {
+		flag, value string
+		allowed     []string
+	}{
+		{"dnssec", link.DNSSEC, []string{"yes", "allow-downgrade", "no"}},
+		{"llmnr", link.LLMNR, []string{"yes", "resolve", "no"}},
+		{"mdns", link.MulticastDNS, []string{"yes", "resolve", "no"}},
+	}
+
+	for _, c := range checks {
+		if c.value != "" && !slices.Contains(c.allowed, c.value) {
+			return fmt.Errorf("%w: --%s=%s (must be one of: %s)", ErrInvalidLinkSetting, c.flag, c.value, strings.Join(c.allowed, ", "))
+		}
+	}
+	return nil
+}
+
 // SeparateIPv4AndIPv6 separates a list of IP addresses into IPv4 and IPv6
 func SeparateIPv4AndIPv6(addresses []string) (ipv4 []string, ipv6 []string) {
 	for _, addr := range addresses {
@@ -113,15 +266,20 @@ func SeparateIPv4AndIPv6(addresses []string) (ipv4 []string, ipv6 []string) {
 			continue
 		}
 
-		ip := net.ParseIP(addr)
-		if ip == nil {
+		server, err := models.ParseServerAddress(addr)
+		if err != nil {
 			continue // Skip invalid addresses
 		}
+		// Bare IPs are kept as written; other forms are normalized to the
+		// syntax the backends expect
+		if !server.IsPlain() {
+			addr = server.String()
+		}
 
-		if ip.To4() != nil {
-			ipv4 = append(ipv4, addr)
-		} else {
+		if server.Is6() {
 			ipv6 = append(ipv6, addr)
+		} else {
+			ipv4 = append(ipv4, addr)
 		}
 	}
 
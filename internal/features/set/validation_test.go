@@ -1,8 +1,12 @@
 package set
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
+	"gitlab.com/junevm/cdns/internal/dns/models"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,7 +28,15 @@ func TestValidateDNSAddress(t *testing.T) {
 		{name: "valid IPv6 - Google full", address: "2001:4860:4860::8888", wantErr: false},
 		{name: "valid IPv6 - Quad9", address: "2620:fe::fe", wantErr: false},
 		{name: "valid IPv6 - localhost", address: "::1", wantErr: false},
-		{name: "valid IPv6 - full format", address: "2001:0db8:85a3:0000:0000:8a2e:0370:7334", wantErr: false},
+		{name: "valid IPv6 - full format", address: "2606:4700:4700:0000:0000:0000:0000:1111", wantErr: false},
+
+		// Ports, interfaces and server names
+		{name: "valid IPv4 with port", address: "127.0.0.1:5353", wantErr: false},
+		{name: "valid IPv6 with interface", address: "fe80::1%eth0", wantErr: false},
+		{name: "valid IPv4 with server name", address: "9.9.9.9#dns.quad9.net", wantErr: false},
+		{name: "valid IPv6 with port and server name", address: "[2620:fe::fe]:853#dns.quad9.net", wantErr: false},
+		{name: "invalid - port out of range", address: "127.0.0.1:65536", wantErr: true},
+		{name: "invalid - empty server name", address: "9.9.9.9#", wantErr: true},
 
 		// Invalid addresses
 		{name: "invalid - empty", address: "", wantErr: true},
@@ -35,6 +47,15 @@ func TestValidateDNSAddress(t *testing.T) {
 		{name: "invalid - malformed IPv6", address: "::gggg", wantErr: true},
 		{name: "invalid - text", address: "not-an-ip-address", wantErr: true},
 		{name: "invalid - partial", address: "8.8", wantErr: true},
+
+		// Addresses that cannot be DNS servers
+		{name: "invalid - IPv4 unspecified", address: "0.0.0.0", wantErr: true},
+		{name: "invalid - IPv6 unspecified", address: "::", wantErr: true},
+		{name: "invalid - broadcast", address: "255.255.255.255", wantErr: true},
+		{name: "invalid - IPv4 multicast", address: "224.0.0.251", wantErr: true},
+		{name: "invalid - IPv6 multicast", address: "ff02::fb", wantErr: true},
+		{name: "invalid - IPv4 documentation range", address: "192.0.2.53", wantErr: true},
+		{name: "invalid - IPv6 documentation range", address: "2001:0db8:85a3:0000:0000:8a2e:0370:7334", wantErr: true},
 	}
 
 	for _, tt := range tests {
@@ -122,12 +143,17 @@ func TestValidateInterfaceName(t *testing.T) {
 		{name: "valid - lo", interfaceName: "lo", wantErr: false},
 		{name: "valid - docker0", interfaceName: "docker0", wantErr: false},
 		{name: "valid - br-123abc", interfaceName: "br-123abc", wantErr: false},
+		{name: "valid - glob", interfaceName: "wl*", wantErr: false},
+		{name: "valid - character class", interfaceName: "en[op]*", wantErr: false},
+		{name: "valid - regex", interfaceName: "/^(eth|enp[0-9]+s)[0-9]+$/", wantErr: false},
 
 		// Invalid interface names
 		{name: "invalid - empty", interfaceName: "", wantErr: true},
 		{name: "invalid - spaces", interfaceName: "eth 0", wantErr: true},
 		{name: "invalid - special chars", interfaceName: "eth@0", wantErr: true},
 		{name: "invalid - too long", interfaceName: "this_is_a_very_long_interface_name_that_exceeds_limits", wantErr: true},
+		{name: "invalid - unterminated glob", interfaceName: "wl[", wantErr: true},
+		{name: "invalid - bad regex", interfaceName: "/eth(/", wantErr: true},
 	}
 
 	for _, tt := range tests {
@@ -142,6 +168,16 @@ func TestValidateInterfaceName(t *testing.T) {
 	}
 }
 
+func TestValidateConnection(t *testing.T) {
+	assert.NoError(t, ValidateConnectionName("Office WiFi"))
+	assert.ErrorIs(t, ValidateConnectionName("  "), ErrInvalidConnection)
+
+	assert.NoError(t, ValidateConnectionUUID("6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff"))
+	assert.NoError(t, ValidateConnectionUUID("6B8E1F2A-1111-4C3D-8E9F-AABBCCDDEEFF"))
+	assert.ErrorIs(t, ValidateConnectionUUID("6b8e1f2a"), ErrInvalidConnection)
+	assert.ErrorIs(t, ValidateConnectionUUID("Office WiFi"), ErrInvalidConnection)
+}
+
 func TestSeparateIPv4AndIPv6(t *testing.T) {
 	t.Run("separates mixed addresses", func(t *testing.T) {
 		addresses := []string{
@@ -162,6 +198,14 @@ func TestSeparateIPv4AndIPv6(t *testing.T) {
 		assert.Contains(t, ipv6, "2001:4860:4860::8888")
 	})
 
+	t.Run("classifies ports and server names by address family", func(t *testing.T) {
+		addresses := []string{"127.0.0.1:5353", "[fe80::1%eth0]:53", "9.9.9.9#dns.quad9.net"}
+		ipv4, ipv6 := SeparateIPv4AndIPv6(addresses)
+
+		assert.Equal(t, []string{"127.0.0.1:5353", "9.9.9.9#dns.quad9.net"}, ipv4)
+		assert.Equal(t, []string{"[fe80::1]:53%eth0"}, ipv6)
+	})
+
 	t.Run("handles only IPv4", func(t *testing.T) {
 		addresses := []string{"8.8.8.8", "8.8.4.4"}
 		ipv4, ipv6 := SeparateIPv4AndIPv6(addresses)
@@ -186,3 +230,108 @@ func TestSeparateIPv4AndIPv6(t *testing.T) {
 		assert.Len(t, ipv6, 0)
 	})
 }
+
+func TestValidateSearchDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  string
+		wantErr bool
+	}{
+		{name: "valid - simple", domain: "corp.example", wantErr: false},
+		{name: "valid - single label", domain: "lan", wantErr: false},
+		{name: "valid - trailing dot", domain: "svc.cluster.local.", wantErr: false},
+		{name: "valid - hyphenated", domain: "my-office.example.com", wantErr: false},
+
+		{name: "invalid - empty", domain: "", wantErr: true},
+		{name: "invalid - spaces", domain: "corp example", wantErr: true},
+		{name: "invalid - empty label", domain: "corp..example", wantErr: true},
+		{name: "invalid - leading hyphen", domain: "-corp.example", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSearchDomain(tt.domain)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSearchDomain)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResolverOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  string
+		wantErr bool
+	}{
+		{name: "valid - ndots", option: "ndots:5", wantErr: false},
+		{name: "valid - ndots zero", option: "ndots:0", wantErr: false},
+		{name: "valid - timeout", option: "timeout:2", wantErr: false},
+		{name: "valid - attempts", option: "attempts:3", wantErr: false},
+		{name: "valid - rotate", option: "rotate", wantErr: false},
+		{name: "valid - edns0", option: "edns0", wantErr: false},
+
+		{name: "invalid - ndots out of range", option: "ndots:16", wantErr: true},
+		{name: "invalid - ndots missing value", option: "ndots", wantErr: true},
+		{name: "invalid - timeout not a number", option: "timeout:fast", wantErr: true},
+		{name: "invalid - flag with value", option: "rotate:1", wantErr: true},
+		{name: "invalid - unknown", option: "debug", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateResolverOption(tt.option)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidResolverOption)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSetOptions_ResolverOptions(t *testing.T) {
+	t.Run("ndots flag overrides option", func(t *testing.T) {
+		opts := SetOptions{ResolverOptions: []string{"ndots:1", "rotate"}, Ndots: "5"}
+		assert.Equal(t, []string{"rotate", "ndots:5"}, opts.resolverOptions())
+	})
+
+	t.Run("no options", func(t *testing.T) {
+		assert.Empty(t, SetOptions{}.resolverOptions())
+	})
+}
+
+func TestValidateLinkSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    models.LinkSettings
+		wantErr bool
+	}{
+		{name: "empty", link: models.LinkSettings{}, wantErr: false},
+		{name: "valid dnssec", link: models.LinkSettings{DNSSEC: "allow-downgrade"}, wantErr: false},
+		{name: "valid llmnr and mdns", link: models.LinkSettings{LLMNR: "resolve", MulticastDNS: "no"}, wantErr: false},
+		{name: "invalid dnssec", link: models.LinkSettings{DNSSEC: "resolve"}, wantErr: true},
+		{name: "invalid mdns", link: models.LinkSettings{MulticastDNS: "maybe"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLinkSettings(tt.link)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLinkSetting)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExitCodeFromError(t *testing.T) {
+	assert.Equal(t, ExitSuccess, ExitCodeFromError(nil))
+	assert.Equal(t, ExitValidationError, ExitCodeFromError(fmt.Errorf("validation failed: %w", ErrInvalidScope)))
+	assert.Equal(t, ExitPermissionError, ExitCodeFromError(ErrInsufficientPrivileges))
+	assert.Equal(t, ExitPartialFailure, ExitCodeFromError(fmt.Errorf("%w: 1 of 2 interfaces failed", ErrPartialFailure)))
+	assert.Equal(t, ExitPartialFailure, ExitCodeFromError(errors.New("nmcli failed")))
+}
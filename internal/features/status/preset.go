@@ -0,0 +1,201 @@
+package status
+
+import (
+	"slices"
+	"sort"
+
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/presets"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+)
+
+// How closely the servers of an interface match a preset
+const (
+	// MatchExact means every server belongs to the preset and all of its
+	// IPv4 or all of its IPv6 servers are in use
+	MatchExact = "exact"
+	// MatchPartial means some servers belong to the preset
+	MatchPartial = "partial"
+	// MatchUnknown means no server belongs to any preset
+	MatchUnknown = "unknown"
+)
+
+// PresetMatch names the preset the servers of an interface come from
+type PresetMatch struct {
+	Name  string `json:"name,omitempty"`
+	Match string `json:"match"`
+}
+
+// knownPreset is a preset's name and server addresses
+type knownPreset struct {
+	name    string
+	servers []string
+}
+
+// knownPresets lists the custom presets from the config, then the built-in
+// ones, each sorted by name. Custom presets come first so that they win
+// over a built-in preset with the same servers, as they do in 'set'.
+func (s *Service) knownPresets() []knownPreset {
+	var known []knownPreset
+	if s.config != nil {
+		names := make([]string, 0, len(s.config.DNS.CustomPresets))
+		for name := range s.config.DNS.CustomPresets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			known = append(known, knownPreset{name: name, servers: s.config.DNS.CustomPresets[name]})
+		}
+	}
+
+	builtin := presets.All()
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		known = append(known, knownPreset{name: name, servers: append(append([]string{}, builtin[name].IPv4...), builtin[name].IPv6...)})
+	}
+	return known
+}
+
+// CompareServers tells how closely servers match the expected ones:
+// MatchExact when every server is expected and all the expected IPv4 or
+// all the expected IPv6 servers are in use, as 'set' leaves a family out on
+// links without it; MatchPartial when only some servers are expected; and
+// MatchUnknown when none are. A port, zone or DNS-over-TLS name is ignored.
+func CompareServers(servers, expected []string) string {
+	var ipv4, ipv6 []string
+	for _, server := range expected {
+		if models.IsIPv6Server(server) {
+			ipv6 = append(ipv6, serverKey(server))
+		} else {
+			ipv4 = append(ipv4, serverKey(server))
+		}
+	}
+
+	keys := make([]string, 0, len(servers))
+	shared := 0
+	for _, server := range servers {
+		key := serverKey(server)
+		keys = append(keys, key)
+		if slices.Contains(ipv4, key) || slices.Contains(ipv6, key) {
+			shared++
+		}
+	}
+	switch {
+	case shared == 0:
+		return MatchUnknown
+	case shared == len(keys) && (containsAll(keys, ipv4) || containsAll(keys, ipv6)):
+		return MatchExact
+	default:
+		return MatchPartial
+	}
+}
+
+// matchPreset finds the preset the servers belong to. An exact match wins;
+// otherwise the preset sharing the most servers is a partial match. No
+// servers yield no match at all.
+func matchPreset(servers []string, known []knownPreset) *PresetMatch {
+	if len(servers) == 0 {
+		return nil
+	}
+
+	best, bestShared := "", 0
+	for _, preset := range known {
+		switch CompareServers(servers, preset.servers) {
+		case MatchExact:
+			return &PresetMatch{Name: preset.name, Match: MatchExact}
+		case MatchPartial:
+			if shared := sharedServers(servers, preset.servers); shared > bestShared {
+				best, bestShared = preset.name, shared
+			}
+		}
+	}
+	if best != "" {
+		return &PresetMatch{Name: best, Match: MatchPartial}
+	}
+	return &PresetMatch{Match: MatchUnknown}
+}
+
+// sharedServers counts the servers found in both lists
+func sharedServers(a, b []string) int {
+	shared := 0
+	for _, server := range a {
+		if slices.ContainsFunc(b, func(other string) bool { return serverKey(other) == serverKey(server) }) {
+			shared++
+		}
+	}
+	return shared
+}
+
+// matchPresets records the preset each interface uses
+func (s *Service) matchPresets(status *StatusInfo) {
+	known := s.knownPresets()
+	if status.Global != nil {
+		status.Global.Preset = matchPreset(EffectiveAddresses(*status.Global), known)
+	}
+	for i := range status.Interfaces {
+		status.Interfaces[i].Preset = matchPreset(EffectiveAddresses(status.Interfaces[i]), known)
+	}
+}
+
+// markManaged flags the interfaces cdns configured, as recorded in the
+// state file, whose servers are still the ones cdns applied
+func markManaged(status *StatusInfo, snap *state.Snapshot) {
+	for i := range status.Interfaces {
+		iface := &status.Interfaces[i]
+		rec, ok := snap.Interfaces[iface.Name]
+		if !ok {
+			continue
+		}
+		configured := iface.Configured
+		if configured == nil {
+			configured = append(append([]string{}, iface.IPv4...), iface.IPv6...)
+		}
+		iface.Managed = len(rec.Servers) == 0 || sameServers(rec.Servers, configured)
+		status.Managed = status.Managed || iface.Managed
+	}
+}
+
+// EffectiveAddresses returns the servers queries go to on an interface
+func EffectiveAddresses(iface InterfaceStatus) []string {
+	if len(iface.Effective) == 0 {
+		return append(append([]string{}, iface.IPv4...), iface.IPv6...)
+	}
+	addresses := make([]string, 0, len(iface.Effective))
+	for _, server := range iface.Effective {
+		addresses = append(addresses, server.Address)
+	}
+	return addresses
+}
+
+// sameServers reports whether both lists hold the same servers, in any order
+func sameServers(a, b []string) bool {
+	return len(a) == len(b) && sharedServers(a, b) == len(a)
+}
+
+// containsAll reports whether keys holds every server of want. An empty
+// want is never covered.
+func containsAll(keys, want []string) bool {
+	if len(want) == 0 {
+		return false
+	}
+	for _, server := range want {
+		if !slices.Contains(keys, server) {
+			return false
+		}
+	}
+	return true
+}
+
+// serverKey reduces a server address to its IP, so that a port, zone or
+// DNS-over-TLS name does not hide the resolver behind it
+func serverKey(server string) string {
+	addr, err := models.ParseServerAddress(server)
+	if err != nil {
+		return server
+	}
+	return addr.IP.String()
+}
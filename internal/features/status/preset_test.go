@@ -0,0 +1,185 @@
+package status
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPreset(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.DNS.CustomPresets = map[string][]string{
+		"office": {"10.0.0.53", "10.0.0.54"},
+		// Same servers as the built-in preset, so it wins
+		"my-quad9": {"9.9.9.9", "149.112.112.112"},
+	}
+	svc := NewService(cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)), &MockDetector{}, &MockReader{}, nil)
+	known := svc.knownPresets()
+
+	tests := []struct {
+		name    string
+		servers []string
+		want    *PresetMatch
+	}{
+		{name: "no servers", servers: nil, want: nil},
+		{
+			name:    "every server",
+			servers: []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111", "2606:4700:4700::1001"},
+			want:    &PresetMatch{Name: "cloudflare", Match: MatchExact},
+		},
+		{
+			name:    "IPv4 servers only, in any order",
+			servers: []string{"94.140.15.16", "94.140.14.15"},
+			want:    &PresetMatch{Name: "adguard-family", Match: MatchExact},
+		},
+		{
+			name:    "family variant is not the plain preset",
+			servers: []string{"94.140.14.14", "94.140.15.15"},
+			want:    &PresetMatch{Name: "adguard", Match: MatchExact},
+		},
+		{
+			name:    "DNS-over-TLS address",
+			servers: []string{"[2620:fe::fe]:853#dns.quad9.net", "[2620:fe::9]:853#dns.quad9.net"},
+			want:    &PresetMatch{Name: "quad9", Match: MatchExact},
+		},
+		{
+			name:    "one server of two",
+			servers: []string{"8.8.8.8"},
+			want:    &PresetMatch{Name: "google", Match: MatchPartial},
+		},
+		{
+			name:    "mixed with another server",
+			servers: []string{"1.1.1.1", "1.0.0.1", "192.168.1.1"},
+			want:    &PresetMatch{Name: "cloudflare", Match: MatchPartial},
+		},
+		{
+			name:    "custom preset",
+			servers: []string{"10.0.0.54", "10.0.0.53"},
+			want:    &PresetMatch{Name: "office", Match: MatchExact},
+		},
+		{
+			name:    "custom preset ahead of built-in",
+			servers: []string{"9.9.9.9", "149.112.112.112"},
+			want:    &PresetMatch{Name: "my-quad9", Match: MatchExact},
+		},
+		{
+			name:    "unknown servers",
+			servers: []string{"192.168.1.1"},
+			want:    &PresetMatch{Match: MatchUnknown},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchPreset(tt.servers, known))
+		})
+	}
+}
+
+func TestService_GetStatus_Managed(t *testing.T) {
+	tests := []struct {
+		name        string
+		snapshot    *state.Snapshot
+		wantManaged []bool
+		wantStatus  bool
+	}{
+		{
+			name:        "nothing recorded",
+			snapshot:    &state.Snapshot{Backend: models.BackendSystemdResolved, Interfaces: map[string]state.InterfaceRecord{}},
+			wantManaged: []bool{false, false},
+		},
+		{
+			name: "servers still applied",
+			snapshot: &state.Snapshot{Backend: models.BackendSystemdResolved, Interfaces: map[string]state.InterfaceRecord{
+				"eth0": {Servers: []string{"1.0.0.1", "1.1.1.1"}},
+			}},
+			wantManaged: []bool{true, false},
+			wantStatus:  true,
+		},
+		{
+			name: "servers changed since",
+			snapshot: &state.Snapshot{Backend: models.BackendSystemdResolved, Interfaces: map[string]state.InterfaceRecord{
+				"eth0": {Servers: []string{"9.9.9.9"}},
+			}},
+			wantManaged: []bool{false, false},
+		},
+		{
+			name: "recorded for another backend",
+			snapshot: &state.Snapshot{Backend: models.BackendNetworkManager, Interfaces: map[string]state.InterfaceRecord{
+				"eth0": {Servers: []string{"1.1.1.1", "1.0.0.1"}},
+			}},
+			wantManaged: []bool{false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := &MockDetector{}
+			reader := &MockReader{}
+			stateReader := &MockStateReader{}
+
+			detector.On("DetectChain").Return(&models.ResolutionChain{Backend: models.BackendSystemdResolved}, nil)
+			reader.On("ReadDNSConfig", mock.Anything, models.BackendSystemdResolved).Return(&StatusInfo{
+				Backend: models.BackendSystemdResolved,
+				Interfaces: []InterfaceStatus{
+					{
+						Name:       "eth0",
+						IPv4:       []string{"1.1.1.1", "1.0.0.1", "192.168.1.1"},
+						Configured: []string{"1.1.1.1", "1.0.0.1"},
+						DHCP:       []string{"192.168.1.1"},
+					},
+					{Name: "wlan0", IPv4: []string{"192.168.1.1"}},
+				},
+			}, nil)
+			stateReader.On("Load").Return(tt.snapshot, nil)
+
+			svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), detector, reader, stateReader)
+			status, err := svc.GetStatus(context.Background())
+			require.NoError(t, err)
+
+			for i, want := range tt.wantManaged {
+				assert.Equal(t, want, status.Interfaces[i].Managed, status.Interfaces[i].Name)
+			}
+			assert.Equal(t, tt.wantStatus, status.Managed)
+			assert.Equal(t, &PresetMatch{Name: "cloudflare", Match: MatchPartial}, status.Interfaces[0].Preset)
+			assert.Equal(t, &PresetMatch{Match: MatchUnknown}, status.Interfaces[1].Preset)
+		})
+	}
+}
+
+func TestService_FormatStatus_Preset(t *testing.T) {
+	status := &StatusInfo{
+		Backend: models.BackendNetworkManager,
+		Interfaces: []InterfaceStatus{
+			{Name: "eth0", IPv4: []string{"9.9.9.9"}, Preset: &PresetMatch{Name: "quad9", Match: MatchPartial}, Managed: true},
+			{Name: "wlan0", IPv4: []string{"192.168.1.1"}, Preset: &PresetMatch{Match: MatchUnknown}},
+		},
+		Managed: true,
+	}
+	svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), &MockDetector{}, &MockReader{}, nil)
+
+	output, err := svc.FormatStatus(status, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Contains(t, output, "PRESET")
+	assert.Contains(t, output, "quad9 (partial)")
+	assert.Contains(t, output, "unknown")
+	assert.NotContains(t, output, "Unmanaged")
+
+	jsonOutput, err := svc.FormatStatus(status, ui.FormatJSON)
+	require.NoError(t, err)
+	assert.Contains(t, jsonOutput, `"preset": {
+        "name": "quad9",
+        "match": "partial"
+      }`)
+	assert.Contains(t, jsonOutput, `"match": "unknown"`)
+}
@@ -1,14 +1,18 @@
 package status
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
+	"time"
 
 	"gitlab.com/junevm/cdns/internal/config"
 	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/probe"
+	"gitlab.com/junevm/cdns/internal/dns/state"
 	"gitlab.com/junevm/cdns/internal/ui"
 
 	"github.com/charmbracelet/lipgloss"
@@ -28,26 +32,103 @@ var Module = fx.Module("status",
 type Detector interface {
 	Detect() (models.Backend, error)
 	DetectWithReason() (models.Backend, string, error)
+	DetectChain() (*models.ResolutionChain, error)
 }
 
 // Reader defines the interface for reading DNS configuration
 type Reader interface {
 	ReadDNSConfig(ctx context.Context, backend models.Backend) (*StatusInfo, error)
+	ReadConnections(ctx context.Context, backend models.Backend) ([]ConnectionStatus, error)
+}
+
+// StateReader gives access to the record of changes made by cdns
+type StateReader interface {
+	Load() (*state.Snapshot, error)
 }
 
 // StatusInfo holds the current DNS status
 type StatusInfo struct {
-	Backend    models.Backend    `json:"backend"`
-	Interfaces []InterfaceStatus `json:"interfaces"`
-	Managed    bool              `json:"managed"`
-	Warnings   []string          `json:"warnings"`
+	Backend models.Backend `json:"backend"`
+	// Reason explains why the backend was chosen
+	Reason string `json:"reason,omitempty"`
+	// Rejected lists the backends considered and passed over
+	Rejected   []models.Candidate `json:"rejected,omitempty"`
+	Scope      string             `json:"scope,omitempty"`
+	Interfaces []InterfaceStatus  `json:"interfaces"`
+	// Global holds the servers and domains systemd-resolved uses for every
+	// link, or the NetworkManager global DNS overriding every connection
+	Global *InterfaceStatus `json:"global,omitempty"`
+	// GlobalSource is the NetworkManager configuration file setting global DNS
+	GlobalSource string `json:"global_source,omitempty"`
+	// Connections lists every saved connection profile, with --all-connections
+	Connections []ConnectionStatus `json:"connections,omitempty"`
+	// Chain explains how names are resolved, with --explain
+	Chain *models.ResolutionChain `json:"chain,omitempty"`
+	// Managed reports whether cdns configured the DNS in effect, as its
+	// state file or its own configuration files show
+	Managed  bool     `json:"managed"`
+	Warnings []string `json:"warnings"`
 }
 
 // InterfaceStatus holds DNS information for a network interface
 type InterfaceStatus struct {
-	Name string   `json:"name"`
-	IPv4 []string `json:"ipv4"`
-	IPv6 []string `json:"ipv6"`
+	Name    string   `json:"name"`
+	IPv4    []string `json:"ipv4"`
+	IPv6    []string `json:"ipv6"`
+	Search  []string `json:"search,omitempty"`
+	Options []string `json:"options,omitempty"`
+
+	DNSSEC       string `json:"dnssec,omitempty"`
+	LLMNR        string `json:"llmnr,omitempty"`
+	MulticastDNS string `json:"mdns,omitempty"`
+
+	// Configured lists the servers set by hand, in a connection profile,
+	// a configuration file or by cdns
+	Configured []string `json:"configured,omitempty"`
+	// DHCP lists the servers learned from DHCP or router advertisements
+	DHCP []string `json:"dhcp,omitempty"`
+	// Effective lists the servers queries actually go to, each with where
+	// it comes from
+	Effective []EffectiveServer `json:"effective,omitempty"`
+	// Current is the server systemd-resolved is querying right now
+	Current string `json:"current,omitempty"`
+
+	// Preset names the preset the effective servers come from
+	Preset *PresetMatch `json:"preset,omitempty"`
+	// Managed reports whether cdns applied the servers of this interface
+	Managed bool `json:"managed,omitempty"`
+}
+
+// Sources of an effective server
+const (
+	SourceManual   = "manual"
+	SourceDHCP     = "dhcp"
+	SourceGlobal   = "global"
+	SourceFallback = "fallback"
+)
+
+// EffectiveServer is a server in use and where it comes from: "manual",
+// "dhcp", "global" or "fallback"
+type EffectiveServer struct {
+	Address string `json:"address"`
+	Source  string `json:"source"`
+}
+
+// ConnectionStatus holds the DNS configured on a saved NetworkManager connection profile
+type ConnectionStatus struct {
+	Name    string   `json:"name"`
+	UUID    string   `json:"uuid"`
+	Type    string   `json:"type"`
+	Device  string   `json:"device,omitempty"` // empty when the profile is inactive
+	IPv4    []string `json:"ipv4"`
+	IPv6    []string `json:"ipv6"`
+	Search  []string `json:"search,omitempty"`
+	Options []string `json:"options,omitempty"`
+	// Configured lists the servers set in the profile itself; IPv4 and IPv6
+	// hold the ones in use while it is active
+	Configured []string `json:"configured,omitempty"`
+	// IgnoreAutoDNS reports whether DHCP is kept from adding servers
+	IgnoreAutoDNS bool `json:"ignore_auto_dns"`
 }
 
 // Service handles the business logic for status feature
@@ -57,16 +138,21 @@ type Service struct {
 	styles   *ui.Styles
 	detector Detector
 	reader   Reader
+	state    StateReader
+	// launch runs a command chosen on the --watch dashboard
+	launch func(ctx context.Context, argv []string) error
 }
 
 // NewService creates a new status service
-func NewService(cfg *config.Config, logger *slog.Logger, detector Detector, reader Reader) *Service {
+func NewService(cfg *config.Config, logger *slog.Logger, detector Detector, reader Reader, stateReader StateReader) *Service {
 	return &Service{
 		config:   cfg,
 		logger:   logger,
 		styles:   ui.NewStyles(),
 		detector: detector,
 		reader:   reader,
+		state:    stateReader,
+		launch:   launchCommand,
 	}
 }
 
@@ -75,60 +161,109 @@ func (s *Service) GetStatus(ctx context.Context) (*StatusInfo, error) {
 	s.logger.Debug("retrieving DNS status")
 
 	// Detect backend
-	backend, err := s.detector.Detect()
+	chain, err := s.detector.DetectChain()
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect DNS backend: %w", err)
 	}
+	backend := chain.Backend
 
-	s.logger.Debug("detected backend", slog.String("backend", string(backend)))
+	s.logger.Debug("detected backend", slog.String("backend", string(backend)), slog.String("reason", chain.Reason))
 
 	// Read DNS configuration from the detected backend
 	status, err := s.reader.ReadDNSConfig(ctx, backend)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read DNS configuration: %w", err)
 	}
+	status.Reason = chain.Reason
+	status.Rejected = chain.Rejected
+
+	// Report the scope cdns last applied on this backend and the
+	// interfaces it still manages
+	if s.state != nil {
+		snap, err := s.state.Load()
+		if err != nil {
+			s.logger.Debug("failed to load state", slog.Any("error", err))
+		} else if snap.Backend == backend {
+			status.Scope = snap.Scope
+			markManaged(status, snap)
+		}
+	}
+	s.matchPresets(status)
 
 	return status, nil
 }
 
-// FormatStatus formats the status information for output
-func (s *Service) FormatStatus(status *StatusInfo, jsonFormat bool) (string, error) {
-	if jsonFormat {
-		return s.formatJSON(status)
+// DetectChain follows how the system resolves names. The chain is
+// returned with the error when no backend fits.
+func (s *Service) DetectChain() (*models.ResolutionChain, error) {
+	chain, err := s.detector.DetectChain()
+	if err != nil {
+		return chain, fmt.Errorf("failed to detect DNS backend: %w", err)
 	}
-	return s.formatHuman(status), nil
+	return chain, nil
 }
 
-// formatJSON formats status as JSON
-func (s *Service) formatJSON(status *StatusInfo) (string, error) {
-	data, err := json.MarshalIndent(status, "", "  ")
+// AddConnections lists the DNS of every saved connection profile in status
+func (s *Service) AddConnections(ctx context.Context, status *StatusInfo) error {
+	connections, err := s.reader.ReadConnections(ctx, status.Backend)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal status to JSON: %w", err)
+		return fmt.Errorf("failed to read connection profiles: %w", err)
 	}
-	return string(data), nil
+	status.Connections = connections
+	return nil
+}
+
+// FormatStatus formats the status information for output
+func (s *Service) FormatStatus(status *StatusInfo, format ui.Format) (string, error) {
+	return ui.Render(format, status, func(plain bool) string {
+		if plain {
+			return formatPlain(status)
+		}
+		return s.formatHuman(status)
+	})
+}
+
+// formatPlain lists one interface per line, the global settings first:
+// name, comma-separated servers and preset, "-" standing for none
+func formatPlain(status *StatusInfo) string {
+	interfaces := status.Interfaces
+	if status.Global != nil {
+		interfaces = append([]InterfaceStatus{*status.Global}, interfaces...)
+	}
+	rows := make([][]string, 0, len(interfaces))
+	for _, iface := range interfaces {
+		rows = append(rows, []string{iface.Name, valueOrDash(strings.Join(EffectiveAddresses(iface), ",")), presetLabel(iface.Preset)})
+	}
+	return ui.PlainRows(rows)
 }
 
 // formatHuman formats status in human-readable format (Visual & Concise)
 func (s *Service) formatHuman(status *StatusInfo) string {
-	if len(status.Interfaces) == 0 {
+	if len(status.Interfaces) == 0 && len(status.Connections) == 0 && status.Global == nil {
 		return s.styles.RenderDim("No active network interfaces found.")
 	}
 
+	// The global resolved settings are listed ahead of the links
+	interfaces := status.Interfaces
+	if status.Global != nil {
+		interfaces = append([]InterfaceStatus{*status.Global}, interfaces...)
+	}
+
 	termWidth, _, _ := ui.GetTerminalSize()
 	if termWidth <= 0 {
 		termWidth = 80
 	}
 
 	// Calculate available width for DNS servers column
-	// Fixed widths approx: Interface (15) + Backend (15) + Status (12) + Borders/Padding (14) = ~56
-	dnsColWidth := termWidth - 60
+	// Fixed widths approx: Interface (15) + Backend (15) + Preset (16) + Status (12) + Borders/Padding (17) = ~75
+	dnsColWidth := termWidth - 79
 	if dnsColWidth < 20 {
 		dnsColWidth = 20
 	}
 
 	var rows [][]string
-	for _, iface := range status.Interfaces {
-		allIPs := append(iface.IPv4, iface.IPv6...)
+	for _, iface := range interfaces {
+		allIPs := serverLabels(iface)
 		dnsString := "None"
 		if len(allIPs) > 0 {
 			dnsString = strings.Join(allIPs, ", ")
@@ -145,6 +280,7 @@ func (s *Service) formatHuman(status *StatusInfo) string {
 			iface.Name,
 			fmt.Sprintf("%s", string(status.Backend)),
 			dnsString,
+			presetLabel(iface.Preset),
 			fmt.Sprintf("%s %s", statusDot, statusText),
 		})
 	}
@@ -152,7 +288,7 @@ func (s *Service) formatHuman(status *StatusInfo) string {
 	t := table.New().
 		Border(lipgloss.RoundedBorder()).
 		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("63"))).
-		Headers("INTERFACE", "BACKEND", "DNS SERVERS", "STATUS").
+		Headers("INTERFACE", "BACKEND", "DNS SERVERS", "PRESET", "STATUS").
 		Rows(rows...)
 
 	t.StyleFunc(func(row, col int) lipgloss.Style {
@@ -182,9 +318,53 @@ func (s *Service) formatHuman(status *StatusInfo) string {
 
 	var output strings.Builder
 	output.WriteString("\n" + s.styles.Header.Render("Current DNS Status") + "\n\n")
-	output.WriteString(t.Render())
+	if len(rows) > 0 {
+		output.WriteString(t.Render())
+	} else {
+		output.WriteString("  " + s.styles.RenderDim("No active network interfaces found."))
+	}
 	output.WriteString("\n")
 
+	if status.Scope != "" {
+		output.WriteString(fmt.Sprintf("  Scope: %s %s\n", status.Scope, s.styles.RenderDim("(last applied by cdns)")))
+	}
+	if status.GlobalSource != "" {
+		output.WriteString(fmt.Sprintf("  %s %s\n",
+			s.styles.RenderWarning("Global DNS overrides the servers of every connection"),
+			s.styles.RenderDim("(set in "+status.GlobalSource+")")))
+	}
+
+	// Search domains and resolver options
+	for _, iface := range interfaces {
+		if len(iface.Search) > 0 {
+			output.WriteString(fmt.Sprintf("  %s search: %s\n", s.styles.RenderBold(iface.Name), strings.Join(iface.Search, ", ")))
+		}
+		if len(iface.Options) > 0 {
+			output.WriteString(fmt.Sprintf("  %s options: %s\n", s.styles.RenderBold(iface.Name), strings.Join(iface.Options, " ")))
+		}
+		if iface.Current != "" {
+			output.WriteString(fmt.Sprintf("  %s current server: %s\n", s.styles.RenderBold(iface.Name), iface.Current))
+		}
+		if iface.DNSSEC != "" || iface.LLMNR != "" || iface.MulticastDNS != "" {
+			output.WriteString(fmt.Sprintf("  %s dnssec: %s, llmnr: %s, mdns: %s\n", s.styles.RenderBold(iface.Name),
+				valueOrDash(iface.DNSSEC), valueOrDash(iface.LLMNR), valueOrDash(iface.MulticastDNS)))
+		}
+	}
+
+	if len(status.Connections) > 0 {
+		output.WriteString("\n" + s.styles.Header.Render("Saved Connections") + "\n\n")
+		output.WriteString(s.formatConnections(status.Connections, dnsColWidth))
+		output.WriteString("\n")
+	}
+
+	// The chain explains the choice of backend itself
+	if status.Chain != nil {
+		output.WriteString("\n" + s.FormatChain(status.Chain))
+	} else if status.Reason != "" {
+		output.WriteString(fmt.Sprintf("  Backend: %s %s\n", status.Backend, s.styles.RenderDim("("+status.Reason+")")))
+		output.WriteString(s.formatRejected(status.Rejected))
+	}
+
 	// Managed Status
 	if !status.Managed {
 		output.WriteString("  " + s.styles.RenderWarning("(Unmanaged by this tool)"))
@@ -201,13 +381,142 @@ func (s *Service) formatHuman(status *StatusInfo) string {
 	return output.String()
 }
 
+// FormatChain describes each step of the resolution chain, from
+// /etc/resolv.conf to what answers the queries
+func (s *Service) FormatChain(chain *models.ResolutionChain) string {
+	var output strings.Builder
+	output.WriteString(s.styles.Header.Render("Resolution Chain") + "\n\n")
+
+	resolvConf := "regular file"
+	if chain.ResolvConfTarget != "" {
+		resolvConf = "→ " + chain.ResolvConfTarget
+	}
+	owner := "nothing manages it"
+	if chain.ResolvConfOwner != "" {
+		owner = "written by " + chain.ResolvConfOwner
+	}
+	output.WriteString(fmt.Sprintf("  %s %s %s\n", s.styles.RenderBold("/etc/resolv.conf"), resolvConf, s.styles.RenderDim("("+owner+")")))
+
+	if chain.NMDNSMode != "" {
+		output.WriteString(fmt.Sprintf("  %s dns=%s, rc-manager=%s\n", s.styles.RenderBold("NetworkManager"), chain.NMDNSMode, chain.NMRcManager))
+	}
+	if chain.AnsweredBy != "" {
+		output.WriteString(fmt.Sprintf("  %s %s\n", s.styles.RenderBold("Answered by"), chain.AnsweredBy))
+	}
+
+	backend := s.styles.RenderWarning("none")
+	if chain.Backend != "" {
+		backend = s.styles.RenderInfo(string(chain.Backend))
+	}
+	output.WriteString(fmt.Sprintf("  %s %s\n", s.styles.RenderBold("cdns writes to"), backend))
+	output.WriteString("  " + s.styles.RenderDim(chain.Reason) + "\n")
+	output.WriteString(s.formatRejected(chain.Rejected))
+	return output.String()
+}
+
+// formatRejected lists the backends passed over during detection
+func (s *Service) formatRejected(rejected []models.Candidate) string {
+	var output strings.Builder
+	for _, candidate := range rejected {
+		output.WriteString(fmt.Sprintf("  %s %s: %s\n", s.styles.RenderDim("✗"), candidate.Backend, s.styles.RenderDim(candidate.Reason)))
+	}
+	return output.String()
+}
+
+// formatConnections renders the saved connection profiles as a table
+func (s *Service) formatConnections(connections []ConnectionStatus, dnsColWidth int) string {
+	var rows [][]string
+	for _, conn := range connections {
+		allIPs := append(conn.IPv4, conn.IPv6...)
+		dnsString := "Automatic"
+		if len(allIPs) > 0 {
+			dnsString = strings.Join(allIPs, ", ")
+		}
+		if len(conn.Search) > 0 {
+			dnsString += " (search: " + strings.Join(conn.Search, ", ") + ")"
+		}
+
+		device := s.styles.RenderDim("inactive")
+		if conn.Device != "" {
+			device = conn.Device
+		}
+
+		rows = append(rows, []string{conn.Name, conn.Type, device, dnsString})
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("63"))).
+		Headers("CONNECTION", "TYPE", "DEVICE", "DNS SERVERS").
+		Rows(rows...)
+
+	t.StyleFunc(func(row, col int) lipgloss.Style {
+		style := lipgloss.NewStyle().Padding(0, 1)
+		if col == 3 {
+			style = style.Width(dnsColWidth)
+		}
+		switch {
+		case row == 0:
+			return style.Bold(true).Foreground(lipgloss.Color("205")).Align(lipgloss.Center)
+		case col == 3:
+			return style.Foreground(lipgloss.Color("86"))
+		case col == 0:
+			return style.Bold(true)
+		default:
+			return style
+		}
+	})
+
+	return t.Render()
+}
+
+// serverLabels lists the servers in use on an interface, naming the source
+// of those not set by hand
+func serverLabels(iface InterfaceStatus) []string {
+	if len(iface.Effective) == 0 {
+		return append(append([]string{}, iface.IPv4...), iface.IPv6...)
+	}
+	var labels []string
+	for _, server := range iface.Effective {
+		label := server.Address
+		if server.Source != SourceManual {
+			label += " (" + server.Source + ")"
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// presetLabel describes the preset match of an interface for the table
+func presetLabel(match *PresetMatch) string {
+	switch {
+	case match == nil:
+		return "-"
+	case match.Match == MatchUnknown:
+		return "unknown"
+	case match.Match == MatchPartial:
+		return match.Name + " (partial)"
+	default:
+		return match.Name
+	}
+}
+
+// valueOrDash returns value, or "-" when it is empty
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
 // CommandParams holds dependencies for the status command
 type CommandParams struct {
 	fx.In
 
-	Service *Service
-	Logger  *slog.Logger
-	Config  *config.Config
+	Service  *Service
+	Logger   *slog.Logger
+	Config   *config.Config
+	Notifier Notifier `optional:"true"`
 }
 
 // CommandResult wraps the command for Fx
@@ -219,22 +528,62 @@ type CommandResult struct {
 
 // NewCommand creates the status cobra command
 func NewCommand(params CommandParams) CommandResult {
-	var jsonFormat bool
+	var allConnections bool
+	var explain bool
+	var watch bool
+	var interval time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Display current DNS configuration",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			if watch {
+				if format != ui.FormatTable || allConnections || explain {
+					return fmt.Errorf("--watch cannot be combined with --output %s, --all-connections or --explain", format)
+				}
+				if interval <= 0 {
+					return fmt.Errorf("--interval must be positive")
+				}
+				return runWatch(cmd, params, WatchOptions{
+					Interval: interval,
+					Notifier: params.Notifier,
+					Prober:   probe.NewUDPProber(watchProbeTimeout),
+				})
+			}
+
+			// The chain explains a failed detection too
+			var chain *models.ResolutionChain
+			if explain {
+				chain, err = params.Service.DetectChain()
+				if err != nil {
+					if chain != nil && !format.Structured() {
+						fmt.Println(params.Service.FormatChain(chain))
+					}
+					return err
+				}
+			}
 
 			// Get status
 			status, err := params.Service.GetStatus(ctx)
 			if err != nil {
 				return err
 			}
+			status.Chain = chain
+
+			if allConnections {
+				if err := params.Service.AddConnections(ctx, status); err != nil {
+					return err
+				}
+			}
 
 			// Format and display output
-			output, err := params.Service.FormatStatus(status, jsonFormat)
+			output, err := params.Service.FormatStatus(status, format)
 			if err != nil {
 				return err
 			}
@@ -245,11 +594,39 @@ func NewCommand(params CommandParams) CommandResult {
 	}
 
 	// Command-specific flags
-	cmd.Flags().BoolVar(&jsonFormat, "json", false, "Output in JSON format")
+	cmd.Flags().Bool("json", false, "Output in JSON format, same as --output json")
+	cmd.Flags().BoolVar(&allConnections, "all-connections", false, "Also list DNS for every saved NetworkManager connection profile")
+	cmd.Flags().BoolVar(&explain, "explain", false, "Explain how names are resolved and why the backend was chosen")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Show a live dashboard, refreshed on an interval and when NetworkManager or systemd-resolved signal a change")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Refresh interval for --watch")
 
 	return CommandResult{Cmd: cmd}
 }
 
+// runWatch shows the dashboard, running 'set' or 'reset' when chosen from
+// it and coming back once they finish
+func runWatch(cmd *cobra.Command, params CommandParams, opts WatchOptions) error {
+	if !ui.IsTTY() {
+		return fmt.Errorf("--watch needs a terminal")
+	}
+	ctx := cmd.Context()
+	for {
+		choice, err := params.Service.Watch(ctx, opts)
+		if err != nil || choice == "" {
+			return err
+		}
+
+		if err := params.Service.handoff(cmd, choice); err != nil {
+			fmt.Println(params.Service.styles.RenderError(err.Error()))
+		}
+
+		fmt.Print("\n" + params.Service.styles.RenderDim("Press Enter to return to the dashboard"))
+		if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+			return nil
+		}
+	}
+}
+
 // RegisterParams holds dependencies for command registration
 type RegisterParams struct {
 	fx.In
@@ -10,6 +10,8 @@ import (
 
 	"gitlab.com/junevm/cdns/internal/config"
 	"gitlab.com/junevm/cdns/internal/dns/models"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+	"gitlab.com/junevm/cdns/internal/ui"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -31,6 +33,12 @@ func (m *MockDetector) DetectWithReason() (models.Backend, string, error) {
 	return args.Get(0).(models.Backend), args.String(1), args.Error(2)
 }
 
+func (m *MockDetector) DetectChain() (*models.ResolutionChain, error) {
+	args := m.Called()
+	chain, _ := args.Get(0).(*models.ResolutionChain)
+	return chain, args.Error(1)
+}
+
 // MockReader mocks the DNS configuration reader
 type MockReader struct {
 	mock.Mock
@@ -44,13 +52,21 @@ func (m *MockReader) ReadDNSConfig(ctx context.Context, backend models.Backend)
 	return args.Get(0).(*StatusInfo), args.Error(1)
 }
 
+func (m *MockReader) ReadConnections(ctx context.Context, backend models.Backend) ([]ConnectionStatus, error) {
+	args := m.Called(ctx, backend)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]ConnectionStatus), args.Error(1)
+}
+
 func TestNewService(t *testing.T) {
 	cfg := &config.Config{}
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	detector := &MockDetector{}
 	reader := &MockReader{}
 
-	svc := NewService(cfg, logger, detector, reader)
+	svc := NewService(cfg, logger, detector, reader, nil)
 
 	assert.NotNil(t, svc)
 	assert.Equal(t, cfg, svc.config)
@@ -141,12 +157,12 @@ func TestService_GetStatus(t *testing.T) {
 			cfg := &config.Config{}
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-			svc := NewService(cfg, logger, detector, reader)
+			svc := NewService(cfg, logger, detector, reader, nil)
 
 			if tt.backendErr != nil {
-				detector.On("Detect").Return(models.Backend(""), tt.backendErr)
+				detector.On("DetectChain").Return(&models.ResolutionChain{Reason: tt.backendErr.Error()}, tt.backendErr)
 			} else {
-				detector.On("Detect").Return(tt.backend, nil)
+				detector.On("DetectChain").Return(&models.ResolutionChain{Backend: tt.backend}, nil)
 				reader.On("ReadDNSConfig", mock.Anything, tt.backend).Return(tt.statusInfo, tt.statusErr)
 			}
 
@@ -171,11 +187,156 @@ func TestService_GetStatus(t *testing.T) {
 	}
 }
 
+// MockStateReader mocks the cdns state store
+type MockStateReader struct {
+	mock.Mock
+}
+
+func (m *MockStateReader) Load() (*state.Snapshot, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*state.Snapshot), args.Error(1)
+}
+
+func TestService_GetStatus_Scope(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshot  *state.Snapshot
+		wantScope string
+	}{
+		{
+			name:      "scope from matching backend",
+			snapshot:  &state.Snapshot{Backend: models.BackendNetworkManager, Scope: "all"},
+			wantScope: "all",
+		},
+		{
+			name:      "scope ignored for other backend",
+			snapshot:  &state.Snapshot{Backend: models.BackendSystemdResolved, Scope: "explicit"},
+			wantScope: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := &MockDetector{}
+			reader := &MockReader{}
+			stateReader := &MockStateReader{}
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+			detector.On("DetectChain").Return(&models.ResolutionChain{Backend: models.BackendNetworkManager}, nil)
+			reader.On("ReadDNSConfig", mock.Anything, models.BackendNetworkManager).
+				Return(&StatusInfo{Backend: models.BackendNetworkManager}, nil)
+			stateReader.On("Load").Return(tt.snapshot, nil)
+
+			svc := NewService(&config.Config{}, logger, detector, reader, stateReader)
+			status, err := svc.GetStatus(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantScope, status.Scope)
+		})
+	}
+}
+
+func TestService_GetStatus_Reason(t *testing.T) {
+	detector := &MockDetector{}
+	reader := &MockReader{}
+	detector.On("DetectChain").Return(&models.ResolutionChain{
+		Backend: models.BackendSystemdResolved,
+		Reason:  "resolvectl command available and systemd-resolved service is running",
+		Rejected: []models.Candidate{
+			{Backend: models.BackendNetworkManager, Reason: "NetworkManager leaves DNS alone (dns=none)"},
+		},
+	}, nil)
+	reader.On("ReadDNSConfig", mock.Anything, models.BackendSystemdResolved).
+		Return(&StatusInfo{Backend: models.BackendSystemdResolved, Interfaces: []InterfaceStatus{{Name: "eth0", IPv4: []string{"9.9.9.9"}}}}, nil)
+
+	svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), detector, reader, nil)
+	status, err := svc.GetStatus(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "resolvectl command available and systemd-resolved service is running", status.Reason)
+	require.Len(t, status.Rejected, 1)
+
+	output, err := svc.FormatStatus(status, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Backend: systemd-resolved")
+	assert.Contains(t, output, "systemd-resolved service is running")
+	assert.Contains(t, output, "NetworkManager leaves DNS alone (dns=none)")
+
+	jsonOutput, err := svc.FormatStatus(status, ui.FormatJSON)
+	require.NoError(t, err)
+	assert.Contains(t, jsonOutput, `"reason": "resolvectl command available`)
+	assert.Contains(t, jsonOutput, `"rejected": [`)
+}
+
+func TestService_FormatStatus_Sources(t *testing.T) {
+	status := &StatusInfo{
+		Backend: models.BackendSystemdResolved,
+		Interfaces: []InterfaceStatus{{
+			Name:       "eth0",
+			IPv4:       []string{"10.0.0.53", "192.168.1.1"},
+			Configured: []string{"10.0.0.53"},
+			DHCP:       []string{"192.168.1.1"},
+			Effective: []EffectiveServer{
+				{Address: "10.0.0.53", Source: SourceManual},
+				{Address: "192.168.1.1", Source: SourceDHCP},
+			},
+			Current: "192.168.1.1",
+		}},
+		Global: &InterfaceStatus{
+			Name:      "Global",
+			Effective: []EffectiveServer{{Address: "1.1.1.1", Source: SourceFallback}},
+		},
+	}
+	svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), &MockDetector{}, &MockReader{}, nil)
+
+	output, err := svc.FormatStatus(status, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Contains(t, output, "192.168.1.1 (dhcp)")
+	assert.NotContains(t, output, "10.0.0.53 (manual)")
+	assert.Contains(t, output, "1.1.1.1 (fallback)")
+	assert.Contains(t, output, "current server: 192.168.1.1")
+
+	jsonOutput, err := svc.FormatStatus(status, ui.FormatJSON)
+	require.NoError(t, err)
+	var parsed StatusInfo
+	require.NoError(t, json.Unmarshal([]byte(jsonOutput), &parsed))
+	assert.Equal(t, status.Interfaces, parsed.Interfaces)
+	assert.Contains(t, jsonOutput, `"source": "dhcp"`)
+	assert.Contains(t, jsonOutput, `"configured": [`)
+	assert.Contains(t, jsonOutput, `"current": "192.168.1.1"`)
+}
+
+func TestService_AddConnections(t *testing.T) {
+	reader := &MockReader{}
+	connections := []ConnectionStatus{
+		{Name: "Wired connection 1", UUID: "3f2c0a52-8c1e-4a3e-9d6a-0b1c2d3e4f50", Type: "802-3-ethernet", Device: "eth0", IPv4: []string{"1.1.1.1"}},
+		{Name: "Office WiFi", UUID: "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff", Type: "802-11-wireless", IPv4: []string{"10.1.0.53"}},
+	}
+	reader.On("ReadConnections", mock.Anything, models.BackendNetworkManager).Return(connections, nil)
+
+	svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), &MockDetector{}, reader, nil)
+	status := &StatusInfo{Backend: models.BackendNetworkManager}
+	require.NoError(t, svc.AddConnections(context.Background(), status))
+	assert.Equal(t, connections, status.Connections)
+
+	output, err := svc.FormatStatus(status, ui.FormatTable)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Saved Connections")
+	assert.Contains(t, output, "Office WiFi")
+	assert.Contains(t, output, "10.1.0.53")
+	assert.Contains(t, output, "inactive")
+
+	jsonOutput, err := svc.FormatStatus(status, ui.FormatJSON)
+	require.NoError(t, err)
+	assert.Contains(t, jsonOutput, `"uuid": "6b8e1f2a-1111-4c3d-8e9f-aabbccddeeff"`)
+}
+
 func TestService_FormatStatus(t *testing.T) {
 	tests := []struct {
 		name       string
 		statusInfo *StatusInfo
-		jsonFormat bool
+		format     ui.Format
 		contains   []string
 	}{
 		{
@@ -192,7 +353,7 @@ func TestService_FormatStatus(t *testing.T) {
 				Managed:  true,
 				Warnings: []string{},
 			},
-			jsonFormat: false,
+			format: ui.FormatTable,
 			contains: []string{
 				"NetworkManager",
 				"eth0",
@@ -201,6 +362,51 @@ func TestService_FormatStatus(t *testing.T) {
 				"2001:4860:4860", // Check prefix
 			},
 		},
+		{
+			name: "human readable format with NetworkManager global DNS",
+			statusInfo: &StatusInfo{
+				Backend:      models.BackendNetworkManager,
+				Interfaces:   []InterfaceStatus{{Name: "wlan0", IPv4: []string{"192.168.1.1"}}},
+				Global:       &InterfaceStatus{Name: "Global", IPv4: []string{"9.9.9.9"}},
+				GlobalSource: "/etc/NetworkManager/conf.d/90-cdns-global-dns.conf",
+				Managed:      true,
+				Warnings:     []string{},
+			},
+			format: ui.FormatTable,
+			contains: []string{
+				"Global",
+				"9.9.9.9",
+				"Global DNS overrides the servers of every connection",
+				"90-cdns-global-dns.conf",
+			},
+		},
+		{
+			name: "human readable format with the resolution chain",
+			statusInfo: &StatusInfo{
+				Backend:    models.BackendSystemdResolved,
+				Interfaces: []InterfaceStatus{{Name: "eth0", IPv4: []string{"192.168.1.1"}}},
+				Chain: &models.ResolutionChain{
+					Backend:          models.BackendSystemdResolved,
+					Reason:           "NetworkManager leaves DNS alone (dns=none)",
+					ResolvConfTarget: "../run/systemd/resolve/stub-resolv.conf",
+					ResolvConfOwner:  "systemd-resolved",
+					NMDNSMode:        "none",
+					NMRcManager:      "symlink",
+					AnsweredBy:       "systemd-resolved (127.0.0.53)",
+				},
+				Managed:  true,
+				Warnings: []string{},
+			},
+			format: ui.FormatTable,
+			contains: []string{
+				"Resolution Chain",
+				"→ ../run/systemd/resolve/stub-resolv.conf",
+				"written by systemd-resolved",
+				"dns=none, rc-manager=symlink",
+				"Answered by systemd-resolved (127.0.0.53)",
+				"NetworkManager leaves DNS alone (dns=none)",
+			},
+		},
 		{
 			name: "human readable format with multiple interfaces",
 			statusInfo: &StatusInfo{
@@ -220,7 +426,7 @@ func TestService_FormatStatus(t *testing.T) {
 				Managed:  true,
 				Warnings: []string{},
 			},
-			jsonFormat: false,
+			format: ui.FormatTable,
 			contains: []string{
 				"systemd-resolved",
 				"eth0",
@@ -229,6 +435,25 @@ func TestService_FormatStatus(t *testing.T) {
 				"8.8.8.8",
 			},
 		},
+		{
+			name: "human readable with global resolved servers",
+			statusInfo: &StatusInfo{
+				Backend: models.BackendSystemdResolved,
+				Global: &InterfaceStatus{
+					Name:   "Global",
+					IPv4:   []string{"9.9.9.9#dns.quad9.net"},
+					Search: []string{"corp.example"},
+				},
+				Managed:  true,
+				Warnings: []string{},
+			},
+			format: ui.FormatTable,
+			contains: []string{
+				"Global",
+				"9.9.9.9#dns.quad9", // Might wrap in test terminal
+				"Global search: corp.example",
+			},
+		},
 		{
 			name: "human readable unmanaged",
 			statusInfo: &StatusInfo{
@@ -243,7 +468,7 @@ func TestService_FormatStatus(t *testing.T) {
 				Managed:  false,
 				Warnings: []string{},
 			},
-			jsonFormat: false,
+			format: ui.FormatTable,
 			contains: []string{
 				"resolv.conf",
 				"(Unmanaged by this tool)",
@@ -263,7 +488,7 @@ func TestService_FormatStatus(t *testing.T) {
 				Managed:  true,
 				Warnings: []string{"No IPv6 DNS configured", "DNS may be slow"},
 			},
-			jsonFormat: false,
+			format: ui.FormatTable,
 			contains: []string{
 				"No IPv6 DNS configured",
 				"DNS may be slow",
@@ -283,7 +508,7 @@ func TestService_FormatStatus(t *testing.T) {
 				Managed:  true,
 				Warnings: []string{},
 			},
-			jsonFormat: true,
+			format: ui.FormatJSON,
 			contains: []string{
 				`"backend"`,
 				`"NetworkManager"`,
@@ -307,13 +532,13 @@ func TestService_FormatStatus(t *testing.T) {
 			detector := &MockDetector{}
 			reader := &MockReader{}
 
-			svc := NewService(cfg, logger, detector, reader)
+			svc := NewService(cfg, logger, detector, reader, nil)
 
-			output, err := svc.FormatStatus(tt.statusInfo, tt.jsonFormat)
+			output, err := svc.FormatStatus(tt.statusInfo, tt.format)
 			require.NoError(t, err)
 			assert.NotEmpty(t, output)
 
-			if tt.jsonFormat {
+			if tt.format == ui.FormatJSON {
 				// Verify valid JSON
 				var parsed map[string]interface{}
 				err := json.Unmarshal([]byte(output), &parsed)
@@ -411,3 +636,23 @@ func TestExitCodes(t *testing.T) {
 		t.Skip("Command not yet implemented")
 	})
 }
+
+func TestService_DetectChain(t *testing.T) {
+	chain := &models.ResolutionChain{
+		ResolvConfTarget: "../run/resolvconf/resolv.conf",
+		ResolvConfOwner:  "resolvconf",
+		Reason:           "generated by resolvconf",
+	}
+	detector := &MockDetector{}
+	detector.On("DetectChain").Return(chain, errors.New("generated by resolvconf"))
+	svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), detector, &MockReader{}, nil)
+
+	got, err := svc.DetectChain()
+	assert.ErrorContains(t, err, "failed to detect DNS backend")
+	assert.Same(t, chain, got, "the chain explains the failure")
+
+	output := svc.FormatChain(got)
+	assert.Contains(t, output, "written by resolvconf")
+	assert.Contains(t, output, "none")
+	assert.NotContains(t, output, "Answered by")
+}
@@ -0,0 +1,505 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/junevm/cdns/internal/dns/probe"
+	"gitlab.com/junevm/cdns/internal/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// watchProbeTimeout bounds the wait for each server, below the
+	// default refresh interval
+	watchProbeTimeout = time.Second
+	// changeHighlight is how long an interface stays highlighted after its
+	// servers changed
+	changeHighlight = 15 * time.Second
+	// maxEvents is the number of recent changes listed
+	maxEvents = 5
+)
+
+// Notifier signals changes of the network or DNS configuration
+type Notifier interface {
+	Changes(ctx context.Context) (<-chan struct{}, error)
+}
+
+// Prober checks whether a resolver answers queries
+type Prober interface {
+	Probe(ctx context.Context, server string) error
+}
+
+// WatchOptions configure the live dashboard
+type WatchOptions struct {
+	// Interval between refreshes
+	Interval time.Duration
+	// Notifier triggers a refresh on change signals, when set
+	Notifier Notifier
+	// Prober measures reachability and latency, when set
+	Prober Prober
+}
+
+// Watch shows the live status dashboard until the user quits. It returns
+// the command the user asked to run, "set" or "reset", if any.
+func (s *Service) Watch(ctx context.Context, opts WatchOptions) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m := newWatchModel(ctx, s, opts)
+	if opts.Notifier != nil {
+		changes, err := opts.Notifier.Changes(ctx)
+		if err != nil {
+			s.logger.Debug("change signals unavailable, polling only", slog.Any("error", err))
+		} else {
+			m.changes = changes
+		}
+	}
+
+	final, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	if err != nil {
+		return "", err
+	}
+	return final.(watchModel).choice, nil
+}
+
+// probeResult is the outcome of probing one server
+type probeResult struct {
+	latency time.Duration
+	err     error
+	skipped bool
+}
+
+// refreshMsg carries a new status and the probes of its servers
+type refreshMsg struct {
+	status *StatusInfo
+	probes map[string]probeResult
+	err    error
+	at     time.Time
+}
+
+// tickMsg asks for a periodic refresh; ticks of an older generation are
+// dropped so that only one timer runs
+type tickMsg struct {
+	generation int
+}
+
+// changeMsg reports a change signal, or the end of signals when closed
+type changeMsg struct {
+	closed bool
+}
+
+// watchModel is the live status dashboard
+type watchModel struct {
+	ctx      context.Context
+	service  *Service
+	prober   Prober
+	interval time.Duration
+	changes  <-chan struct{}
+	styles   *ui.Styles
+	width    int
+
+	status  *StatusInfo
+	probes  map[string]probeResult
+	err     error
+	updated time.Time
+
+	// servers holds the server labels of each interface at the last refresh
+	servers map[string]string
+	// changed holds when the servers of an interface last changed
+	changed map[string]time.Time
+	events  []string
+
+	refreshing bool
+	pending    bool
+	generation int
+	choice     string
+}
+
+func newWatchModel(ctx context.Context, s *Service, opts WatchOptions) watchModel {
+	return watchModel{
+		ctx:      ctx,
+		service:  s,
+		prober:   opts.Prober,
+		interval: opts.Interval,
+		styles:   s.styles,
+		changed:  map[string]time.Time{},
+		// Init starts the first refresh
+		refreshing: true,
+	}
+}
+
+// Init starts the first refresh, which the model already counts as under way
+func (m watchModel) Init() tea.Cmd {
+	return tea.Batch(m.refresh(), m.waitForChange())
+}
+
+func (m watchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return m, tea.Quit
+		case "s":
+			m.choice = "set"
+			return m, tea.Quit
+		case "r":
+			m.choice = "reset"
+			return m, tea.Quit
+		case " ":
+			return m.startRefresh()
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+
+	case refreshMsg:
+		m.refreshing = false
+		m.apply(msg)
+		m.generation++
+		generation := m.generation
+		tick := tea.Tick(m.interval, func(time.Time) tea.Msg { return tickMsg{generation: generation} })
+		if m.pending {
+			m.pending = false
+			next, cmd := m.startRefresh()
+			return next, tea.Batch(cmd, tick)
+		}
+		return m, tick
+
+	case tickMsg:
+		if msg.generation == m.generation {
+			return m.startRefresh()
+		}
+
+	case changeMsg:
+		if msg.closed {
+			m.changes = nil
+			return m, nil
+		}
+		next, cmd := m.startRefresh()
+		return next, tea.Batch(cmd, next.(watchModel).waitForChange())
+	}
+	return m, nil
+}
+
+// startRefresh refreshes now, or right after the refresh under way
+func (m watchModel) startRefresh() (tea.Model, tea.Cmd) {
+	if m.refreshing {
+		m.pending = true
+		return m, nil
+	}
+	m.refreshing = true
+	return m, m.refresh()
+}
+
+// refresh reads the status and probes its servers
+func (m watchModel) refresh() tea.Cmd {
+	return func() tea.Msg {
+		status, err := m.service.GetStatus(m.ctx)
+		if err != nil {
+			return refreshMsg{err: err, at: time.Now()}
+		}
+		return refreshMsg{status: status, probes: probeServers(m.ctx, m.prober, status), at: time.Now()}
+	}
+}
+
+// waitForChange waits for the next change signal
+func (m watchModel) waitForChange() tea.Cmd {
+	if m.changes == nil {
+		return nil
+	}
+	changes := m.changes
+	return func() tea.Msg {
+		_, ok := <-changes
+		return changeMsg{closed: !ok}
+	}
+}
+
+// apply records a refresh, noting the interfaces whose servers changed
+func (m *watchModel) apply(msg refreshMsg) {
+	m.err = msg.err
+	if msg.err != nil {
+		return
+	}
+
+	servers := map[string]string{}
+	for _, iface := range watchedInterfaces(msg.status) {
+		servers[iface.Name] = strings.Join(serverLabels(iface), ", ")
+	}
+	if m.servers != nil {
+		stamp := msg.at.Format("15:04:05")
+		for _, iface := range watchedInterfaces(msg.status) {
+			before, ok := m.servers[iface.Name]
+			if ok && before == servers[iface.Name] {
+				continue
+			}
+			m.changed[iface.Name] = msg.at
+			m.addEvent(fmt.Sprintf("%s %s: %s → %s", stamp, iface.Name, valueOr(before, "none"), valueOr(servers[iface.Name], "none")))
+		}
+		var gone []string
+		for name := range m.servers {
+			if _, ok := servers[name]; !ok {
+				gone = append(gone, name)
+			}
+		}
+		sort.Strings(gone)
+		for _, name := range gone {
+			delete(m.changed, name)
+			m.addEvent(fmt.Sprintf("%s %s: %s → gone", stamp, name, valueOr(m.servers[name], "none")))
+		}
+	}
+
+	m.status = msg.status
+	m.probes = msg.probes
+	m.servers = servers
+	m.updated = msg.at
+}
+
+// addEvent records a change, keeping the most recent ones
+func (m *watchModel) addEvent(event string) {
+	m.events = append(m.events, event)
+	if len(m.events) > maxEvents {
+		m.events = m.events[len(m.events)-maxEvents:]
+	}
+}
+
+// highlighted reports whether the servers of an interface changed recently
+func (m watchModel) highlighted(name string) bool {
+	at, ok := m.changed[name]
+	return ok && m.updated.Sub(at) < changeHighlight
+}
+
+func (m watchModel) View() string {
+	var output strings.Builder
+	output.WriteString("\n" + m.styles.Header.Render("Live DNS Status") + "\n\n")
+
+	if m.status == nil {
+		if m.err != nil {
+			output.WriteString("  " + m.styles.RenderError(m.err.Error()) + "\n")
+		} else {
+			output.WriteString("  " + m.styles.RenderDim("Reading DNS configuration...") + "\n")
+		}
+		output.WriteString("\n" + m.help())
+		return output.String()
+	}
+
+	trigger := fmt.Sprintf("every %s", m.interval)
+	if m.changes != nil {
+		trigger += " and on change"
+	}
+	output.WriteString(fmt.Sprintf("  Backend: %s  %s\n\n", m.status.Backend,
+		m.styles.RenderDim(fmt.Sprintf("refreshed %s, %s", m.updated.Format("15:04:05"), trigger))))
+
+	output.WriteString(m.renderTable())
+	output.WriteString("\n")
+
+	if m.err != nil {
+		output.WriteString("  " + m.styles.RenderError("refresh failed: "+m.err.Error()) + "\n")
+	}
+	if len(m.events) > 0 {
+		output.WriteString("\n  " + m.styles.RenderBold("Recent changes") + "\n")
+		for _, event := range m.events {
+			output.WriteString("  " + event + "\n")
+		}
+	}
+	output.WriteString("\n" + m.help())
+	return output.String()
+}
+
+// renderTable lists every server of every interface with its reachability
+func (m watchModel) renderTable() string {
+	var rows [][]string
+	var changedRows []bool
+	for _, iface := range watchedInterfaces(m.status) {
+		name := iface.Name
+		changed := m.highlighted(iface.Name)
+		if changed {
+			name += " ●"
+		}
+		preset := presetLabel(iface.Preset)
+
+		servers := iface.Effective
+		if len(servers) == 0 {
+			for _, address := range EffectiveAddresses(iface) {
+				servers = append(servers, EffectiveServer{Address: address, Source: SourceManual})
+			}
+		}
+		if len(servers) == 0 {
+			rows = append(rows, []string{name, "None", "-", preset, "-"})
+			changedRows = append(changedRows, changed)
+			continue
+		}
+		for i, server := range servers {
+			if i > 0 {
+				name, preset = "", ""
+			}
+			rows = append(rows, []string{name, server.Address, server.Source, preset, m.reachability(server.Address)})
+			changedRows = append(changedRows, changed)
+		}
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("63"))).
+		Headers("INTERFACE", "SERVER", "SOURCE", "PRESET", "REACHABILITY").
+		Rows(rows...)
+
+	t.StyleFunc(func(row, col int) lipgloss.Style {
+		style := lipgloss.NewStyle().Padding(0, 1)
+		switch {
+		case row == table.HeaderRow:
+			return style.Bold(true).Foreground(lipgloss.Color("205")).Align(lipgloss.Center)
+		case changedRows[row]:
+			return style.Foreground(lipgloss.Color("214")).Bold(true)
+		case col == 1:
+			return style.Foreground(lipgloss.Color("86"))
+		case col == 0:
+			return style.Bold(true)
+		default:
+			return style
+		}
+	})
+	if m.width > 0 {
+		t.Width(m.width)
+	}
+	return t.Render()
+}
+
+// reachability describes the probe of a server
+func (m watchModel) reachability(server string) string {
+	result, ok := m.probes[server]
+	switch {
+	case !ok:
+		return "-"
+	case result.skipped:
+		return "DNS-over-TLS, not probed"
+	case result.err != nil:
+		return "✗ " + result.err.Error()
+	default:
+		return fmt.Sprintf("✓ %s", result.latency.Round(time.Millisecond/10))
+	}
+}
+
+// help lists the key bindings
+func (m watchModel) help() string {
+	return "  " + m.styles.RenderDim("s set DNS • r reset • space refresh • q quit") + "\n"
+}
+
+// watchedInterfaces lists the global entry, if any, ahead of the links
+func watchedInterfaces(status *StatusInfo) []InterfaceStatus {
+	if status.Global == nil {
+		return status.Interfaces
+	}
+	return append([]InterfaceStatus{*status.Global}, status.Interfaces...)
+}
+
+// probeServers probes every server in use at once and times the answers
+func probeServers(ctx context.Context, prober Prober, status *StatusInfo) map[string]probeResult {
+	if prober == nil {
+		return nil
+	}
+
+	var servers []string
+	seen := map[string]bool{}
+	for _, iface := range watchedInterfaces(status) {
+		for _, server := range EffectiveAddresses(iface) {
+			if !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]probeResult, len(servers))
+	for _, server := range servers {
+		if probe.Skipped(server) {
+			results[server] = probeResult{skipped: true}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := prober.Probe(ctx, server)
+			mu.Lock()
+			results[server] = probeResult{latency: time.Since(start), err: err}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// valueOr returns value, or fallback when it is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// handoff runs the command chosen on the dashboard as a process of its own,
+// so it gets the privileges it needs and a command line of its own rather
+// than the one that started the dashboard
+func (s *Service) handoff(cmd *cobra.Command, choice string) error {
+	if sub, _, err := cmd.Root().Find([]string{choice}); err != nil || sub == cmd.Root() {
+		return fmt.Errorf("command %s is not available", choice)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the cdns executable: %w", err)
+	}
+	return s.launch(cmd.Context(), handoffArgs(cmd, executable, choice, os.Geteuid() == 0))
+}
+
+// handoffArgs builds the command line running choice with the global flags
+// given to status, --output aside. Unless this process is root, it runs
+// through sudo as set and reset change the system; an offline --root needs
+// no privileges.
+func handoffArgs(cmd *cobra.Command, executable, choice string, privileged bool) []string {
+	args := []string{executable}
+	offline := false
+	cmd.Root().PersistentFlags().VisitAll(func(global *pflag.Flag) {
+		flag := cmd.Flags().Lookup(global.Name)
+		if flag == nil || !flag.Changed || flag.Name == "output" {
+			return
+		}
+		if flag.Name == "root" && flag.Value.String() != "" {
+			offline = true
+		}
+		args = append(args, "--"+flag.Name+"="+flag.Value.String())
+	})
+	args = append(args, choice)
+
+	if privileged || offline {
+		return args
+	}
+	return append([]string{"sudo", "--preserve-env"}, args...)
+}
+
+// launchCommand runs argv on the terminal. A command that fails has
+// already reported why.
+func launchCommand(ctx context.Context, argv []string) error {
+	c := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	var exitErr *exec.ExitError
+	if err := c.Run(); err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("failed to run %s: %w", strings.Join(argv, " "), err)
+	}
+	return nil
+}
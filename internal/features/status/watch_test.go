@@ -0,0 +1,208 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/junevm/cdns/internal/config"
+	"gitlab.com/junevm/cdns/internal/dns/models"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProber answers from a table of errors; servers missing from it answer
+type fakeProber map[string]error
+
+func (p fakeProber) Probe(ctx context.Context, server string) error {
+	return p[server]
+}
+
+func newWatchTestModel(t *testing.T, statuses ...*StatusInfo) (watchModel, *MockReader) {
+	t.Helper()
+	detector := &MockDetector{}
+	detector.On("DetectChain").Return(&models.ResolutionChain{Backend: models.BackendSystemdResolved}, nil)
+	reader := &MockReader{}
+	for _, status := range statuses {
+		reader.On("ReadDNSConfig", mock.Anything, models.BackendSystemdResolved).Return(status, nil).Once()
+	}
+
+	svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), detector, reader, nil)
+	m := newWatchModel(context.Background(), svc, WatchOptions{
+		Interval: time.Second,
+		Prober:   fakeProber{"192.168.1.1": errors.New("no answer within 1s")},
+	})
+	return m, reader
+}
+
+// run executes cmd and feeds its message back into the model
+func run(t *testing.T, m watchModel, cmd tea.Cmd) watchModel {
+	t.Helper()
+	require.NotNil(t, cmd)
+	next, _ := m.Update(cmd())
+	return next.(watchModel)
+}
+
+func TestWatchModel_Refresh(t *testing.T) {
+	before := &StatusInfo{
+		Backend: models.BackendSystemdResolved,
+		Interfaces: []InterfaceStatus{
+			{Name: "eth0", IPv4: []string{"1.1.1.1"}},
+			{Name: "wlan0", IPv4: []string{"1.1.1.1"}},
+			{Name: "wg0", IPv4: []string{"10.8.0.1"}},
+		},
+	}
+	after := &StatusInfo{
+		Backend: models.BackendSystemdResolved,
+		Interfaces: []InterfaceStatus{
+			{Name: "eth0", IPv4: []string{"1.1.1.1"}},
+			{
+				Name:      "wlan0",
+				IPv4:      []string{"192.168.1.1", "[2620:fe::fe]:853#dns.quad9.net"},
+				Effective: []EffectiveServer{{Address: "192.168.1.1", Source: SourceDHCP}, {Address: "[2620:fe::fe]:853#dns.quad9.net", Source: SourceManual}},
+			},
+		},
+	}
+	m, reader := newWatchTestModel(t, before, after)
+
+	m = run(t, m, m.Init())
+	assert.False(t, m.refreshing)
+	assert.Empty(t, m.changed, "nothing to compare on the first refresh")
+	assert.Empty(t, m.events)
+
+	// A tick of an older timer is dropped
+	next, cmd := m.Update(tickMsg{generation: m.generation - 1})
+	assert.Nil(t, cmd)
+	m = next.(watchModel)
+
+	next, cmd = m.Update(tickMsg{generation: m.generation})
+	m = run(t, next.(watchModel), cmd)
+	reader.AssertExpectations(t)
+
+	assert.True(t, m.highlighted("wlan0"))
+	assert.False(t, m.highlighted("eth0"))
+	require.Len(t, m.events, 2)
+	assert.Contains(t, m.events[0], "wlan0: 1.1.1.1 → 192.168.1.1 (dhcp), [2620:fe::fe]:853#dns.quad9.net")
+	assert.Contains(t, m.events[1], "wg0: 10.8.0.1 → gone")
+
+	assert.Equal(t, "✗ no answer within 1s", m.reachability("192.168.1.1"))
+	assert.Equal(t, "DNS-over-TLS, not probed", m.reachability("[2620:fe::fe]:853#dns.quad9.net"))
+	assert.True(t, strings.HasPrefix(m.reachability("1.1.1.1"), "✓ "))
+
+	view := m.View()
+	assert.Contains(t, view, "wlan0 ●")
+	assert.Contains(t, view, "Recent changes")
+	assert.Contains(t, view, "every 1s")
+	assert.NotContains(t, view, "on change")
+}
+
+func TestWatchModel_Changes(t *testing.T) {
+	status := &StatusInfo{Backend: models.BackendSystemdResolved, Interfaces: []InterfaceStatus{{Name: "eth0", IPv4: []string{"1.1.1.1"}}}}
+	m, _ := newWatchTestModel(t, status, status)
+	changes := make(chan struct{}, 1)
+	m.changes = changes
+
+	// A signal during a refresh queues another one
+	next, cmd := m.Update(changeMsg{})
+	m = next.(watchModel)
+	assert.True(t, m.pending)
+	changes <- struct{}{}
+	assert.Equal(t, changeMsg{}, cmd())
+
+	m = run(t, m, m.refresh())
+	assert.False(t, m.pending)
+	assert.True(t, m.refreshing, "the queued refresh started")
+	m = run(t, m, m.refresh())
+	assert.False(t, m.refreshing)
+
+	close(changes)
+	next, _ = m.Update(changeMsg{closed: true})
+	assert.Nil(t, next.(watchModel).changes)
+}
+
+func TestWatchModel_Keys(t *testing.T) {
+	tests := []struct {
+		key        tea.KeyMsg
+		wantChoice string
+	}{
+		{key: tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")}, wantChoice: "set"},
+		{key: tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")}, wantChoice: "reset"},
+		{key: tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}, wantChoice: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key.String(), func(t *testing.T) {
+			m, _ := newWatchTestModel(t)
+			next, cmd := m.Update(tt.key)
+			assert.Equal(t, tt.wantChoice, next.(watchModel).choice)
+			require.NotNil(t, cmd)
+			assert.Equal(t, tea.Quit(), cmd())
+		})
+	}
+}
+
+func TestWatchModel_RefreshError(t *testing.T) {
+	detector := &MockDetector{}
+	detector.On("DetectChain").Return(&models.ResolutionChain{}, errors.New("no supported backend found"))
+	svc := NewService(&config.Config{}, slog.New(slog.NewTextHandler(os.Stdout, nil)), detector, &MockReader{}, nil)
+	m := newWatchModel(context.Background(), svc, WatchOptions{Interval: time.Second})
+
+	m = run(t, m, m.Init())
+	assert.Contains(t, m.View(), "no supported backend found")
+}
+
+func TestService_Handoff(t *testing.T) {
+	var launched []string
+	svc := &Service{launch: func(ctx context.Context, argv []string) error {
+		launched = argv
+		return nil
+	}}
+
+	root := &cobra.Command{Use: "cdns"}
+	root.PersistentFlags().String("backend", "", "")
+	root.PersistentFlags().String("root", "", "")
+	root.PersistentFlags().StringP("output", "o", "table", "")
+	statusCmd := &cobra.Command{Use: "status", RunE: func(cmd *cobra.Command, args []string) error {
+		return svc.handoff(cmd, "set")
+	}}
+	statusCmd.Flags().Bool("watch", false, "")
+	noop := func(cmd *cobra.Command, args []string) error { return nil }
+	root.AddCommand(statusCmd, &cobra.Command{Use: "set", RunE: noop}, &cobra.Command{Use: "reset", RunE: noop})
+
+	root.SetArgs([]string{"--backend", "networkmanager", "status", "--watch", "-o", "table"})
+	require.NoError(t, root.Execute())
+
+	// The chosen command runs on its own, not the dashboard again
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	want := []string{executable, "--backend=networkmanager", "set"}
+	if os.Geteuid() != 0 {
+		want = append([]string{"sudo", "--preserve-env"}, want...)
+	}
+	assert.Equal(t, want, launched)
+
+	t.Run("sudo only without root", func(t *testing.T) {
+		assert.Equal(t, []string{"sudo", "--preserve-env", "/usr/bin/cdns", "--backend=networkmanager", "reset"}, handoffArgs(statusCmd, "/usr/bin/cdns", "reset", false))
+		assert.Equal(t, []string{"/usr/bin/cdns", "--backend=networkmanager", "reset"}, handoffArgs(statusCmd, "/usr/bin/cdns", "reset", true))
+	})
+
+	t.Run("offline needs no sudo", func(t *testing.T) {
+		root.SetArgs([]string{"--root", "/mnt/image", "status", "--watch"})
+		require.NoError(t, root.Execute())
+		assert.Equal(t, executable, launched[0])
+		assert.Contains(t, launched, "--root=/mnt/image")
+		assert.Equal(t, "set", launched[len(launched)-1])
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		assert.Error(t, svc.handoff(statusCmd, "bogus"))
+	})
+}
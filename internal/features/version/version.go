@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"runtime"
+	"strings"
 
 	"gitlab.com/junevm/cdns/internal/config"
 	"gitlab.com/junevm/cdns/internal/ui"
@@ -21,10 +22,10 @@ var Module = fx.Module("version",
 
 // BuildInfo holds version information injected at build time
 type BuildInfo struct {
-	Version string
-	Commit  string
-	Date    string
-	BuiltBy string
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+	BuiltBy string `json:"built_by"`
 }
 
 // Service handles the business logic for version feature
@@ -45,29 +46,56 @@ func NewService(cfg *config.Config, logger *slog.Logger, buildInfo BuildInfo) *S
 	}
 }
 
-// PrintVersion displays version information
-func (s *Service) PrintVersion(verbose bool) {
-	// Print large banner
-	fmt.Print(ui.GetBanner())
-	fmt.Println()
-
+// FormatVersion formats the version information. The table format shows
+// the banner, and with verbose the build details, Go version and platform.
+// Plain output is the bare version, or tab-separated details with verbose.
+func (s *Service) FormatVersion(format ui.Format, verbose bool) (string, error) {
 	s.logger.Debug("displaying version information", slog.Bool("verbose", verbose))
 
+	return ui.Render(format, s.buildInfo, func(plain bool) string {
+		if plain {
+			return s.formatPlain(verbose)
+		}
+		return s.formatBanner(verbose)
+	})
+}
+
+// formatPlain lists the version, then the details as name and value rows
+func (s *Service) formatPlain(verbose bool) string {
+	if !verbose {
+		return s.buildInfo.Version
+	}
+	return ui.PlainRows([][]string{
+		{"version", s.buildInfo.Version},
+		{"commit", s.buildInfo.Commit},
+		{"date", s.buildInfo.Date},
+		{"built_by", s.buildInfo.BuiltBy},
+		{"go_version", runtime.Version()},
+		{"platform", runtime.GOOS + "/" + runtime.GOARCH},
+	})
+}
+
+// formatBanner renders the large banner with the version below it
+func (s *Service) formatBanner(verbose bool) string {
+	var output strings.Builder
+	output.WriteString(ui.GetBanner() + "\n")
+
 	// Basic version info
-	fmt.Printf("%s version %s\n",
+	output.WriteString(fmt.Sprintf("%s version %s",
 		s.styles.RenderBold("cdns"),
 		s.styles.Success.Render(s.buildInfo.Version),
-	)
+	))
 
 	// Verbose mode shows additional details
 	if verbose {
-		fmt.Println()
-		fmt.Printf("  %s: %s\n", s.styles.RenderBold("Commit"), s.buildInfo.Commit)
-		fmt.Printf("  %s: %s\n", s.styles.RenderBold("Built"), s.buildInfo.Date)
-		fmt.Printf("  %s: %s\n", s.styles.RenderBold("Built by"), s.buildInfo.BuiltBy)
-		fmt.Printf("  %s: %s\n", s.styles.RenderBold("Go version"), runtime.Version())
-		fmt.Printf("  %s: %s/%s\n", s.styles.RenderBold("Platform"), runtime.GOOS, runtime.GOARCH)
+		output.WriteString("\n\n")
+		output.WriteString(fmt.Sprintf("  %s: %s\n", s.styles.RenderBold("Commit"), s.buildInfo.Commit))
+		output.WriteString(fmt.Sprintf("  %s: %s\n", s.styles.RenderBold("Built"), s.buildInfo.Date))
+		output.WriteString(fmt.Sprintf("  %s: %s\n", s.styles.RenderBold("Built by"), s.buildInfo.BuiltBy))
+		output.WriteString(fmt.Sprintf("  %s: %s\n", s.styles.RenderBold("Go version"), runtime.Version()))
+		output.WriteString(fmt.Sprintf("  %s: %s/%s", s.styles.RenderBold("Platform"), runtime.GOOS, runtime.GOARCH))
 	}
+	return output.String()
 }
 
 // CommandParams holds dependencies for the version command
@@ -93,8 +121,17 @@ func NewCommand(params CommandParams) CommandResult {
 		Use:   "version",
 		Short: "Show version information",
 		Long:  `Display version information for this CLI application.`,
-		Run: func(cmd *cobra.Command, args []string) {
-			params.Service.PrintVersion(verbose)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.OutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			output, err := params.Service.FormatVersion(format, verbose)
+			if err != nil {
+				return err
+			}
+			fmt.Println(output)
+			return nil
 		},
 	}
 
@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Format selects how a command prints its result
+type Format string
+
+const (
+	FormatTable Format = "table" // styled for a terminal, the default
+	FormatPlain Format = "plain" // unstyled, tab-separated rows for scripts
+	FormatJSON  Format = "json"
+	FormatYAML  Format = "yaml"
+)
+
+// Formats lists the values accepted by --output
+var Formats = []Format{FormatTable, FormatPlain, FormatJSON, FormatYAML}
+
+// ErrInvalidFormat is returned for an unknown --output value
+var ErrInvalidFormat = errors.New("invalid output format")
+
+// ParseFormat parses an --output value, an empty one meaning table
+func ParseFormat(value string) (Format, error) {
+	if value == "" {
+		return FormatTable, nil
+	}
+	for _, format := range Formats {
+		if strings.EqualFold(value, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q: use table, plain, json or yaml", ErrInvalidFormat, value)
+}
+
+// Structured reports whether the format encodes the result for programs
+func (f Format) Structured() bool {
+	return f == FormatJSON || f == FormatYAML
+}
+
+// OutputFormat returns the format selected for cmd with the global --output
+// flag, or with the command's own --json flag kept for compatibility
+func OutputFormat(cmd *cobra.Command) (Format, error) {
+	value, _ := cmd.Flags().GetString("output")
+	format, err := ParseFormat(value)
+	if err != nil {
+		return "", err
+	}
+	if jsonFormat, _ := cmd.Flags().GetBool("json"); jsonFormat {
+		if cmd.Flags().Changed("output") && format != FormatJSON {
+			return "", fmt.Errorf("--json cannot be combined with --output %s", format)
+		}
+		return FormatJSON, nil
+	}
+	return format, nil
+}
+
+// Render returns result encoded as JSON or YAML, or the text written by
+// text for table and plain output
+func Render(format Format, result any, text func(plain bool) string) (string, error) {
+	if format.Structured() {
+		return Encode(format, result)
+	}
+	return text(format == FormatPlain), nil
+}
+
+// Encode encodes result as indented JSON or as YAML. The YAML keys follow
+// the JSON field tags, so both formats describe a result the same way.
+func Encode(format Format, result any) (string, error) {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal result to JSON: %w", err)
+	}
+	switch format {
+	case FormatJSON:
+		return string(data), nil
+	case FormatYAML:
+		return jsonToYAML(data)
+	default:
+		return "", fmt.Errorf("%w %q: only json and yaml are encoded", ErrInvalidFormat, format)
+	}
+}
+
+// jsonToYAML converts JSON to block-style YAML, keeping the key order
+func jsonToYAML(data []byte) (string, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return "", fmt.Errorf("failed to convert result to YAML: %w", err)
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return "", fmt.Errorf("failed to marshal result to YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to marshal result to YAML: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// blockStyle drops the flow and quoting style JSON parses with. The encoder
+// still quotes strings that would read as another type; yes, no, on and off
+// stay quoted too, as YAML 1.1 parsers such as Ansible's read them as
+// booleans.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && yaml11Bool(node.Value) {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// yaml11Bool reports whether YAML 1.1 reads value as a boolean
+func yaml11Bool(value string) bool {
+	switch strings.ToLower(value) {
+	case "y", "yes", "n", "no", "on", "off", "true", "false":
+		return true
+	}
+	return false
+}
+
+// PlainRows joins each row's columns with tabs, one row per line
+func PlainRows(rows [][]string) string {
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, strings.Join(row, "\t"))
+	}
+	return strings.Join(lines, "\n")
+}
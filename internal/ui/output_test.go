@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testResult struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+	Enabled string   `json:"enabled"`
+	Note    string   `json:"note,omitempty"`
+}
+
+func TestParseFormat(t *testing.T) {
+	for value, want := range map[string]Format{"": FormatTable, "table": FormatTable, "Plain": FormatPlain, "json": FormatJSON, "YAML": FormatYAML} {
+		format, err := ParseFormat(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, format, value)
+	}
+
+	_, err := ParseFormat("xml")
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestRender(t *testing.T) {
+	result := testResult{Name: "eth0", Servers: []string{"1.1.1.1", "2606:4700:4700::1111"}, Enabled: "yes"}
+	text := func(plain bool) string {
+		if plain {
+			return PlainRows([][]string{{"eth0", "1.1.1.1"}, {"eth0", "2606:4700:4700::1111"}})
+		}
+		return "eth0: 1.1.1.1, 2606:4700:4700::1111"
+	}
+
+	output, err := Render(FormatJSON, result, text)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "name": "eth0",
+  "servers": [
+    "1.1.1.1",
+    "2606:4700:4700::1111"
+  ],
+  "enabled": "yes"
+}`, output)
+
+	// Keys keep the JSON names and order; strings YAML would read as
+	// booleans stay strings
+	output, err = Render(FormatYAML, result, text)
+	require.NoError(t, err)
+	assert.Equal(t, `name: eth0
+servers:
+  - 1.1.1.1
+  - 2606:4700:4700::1111
+enabled: "yes"`, output)
+
+	output, err = Render(FormatPlain, result, text)
+	require.NoError(t, err)
+	assert.Equal(t, "eth0\t1.1.1.1\neth0\t2606:4700:4700::1111", output)
+
+	output, err = Render(FormatTable, result, text)
+	require.NoError(t, err)
+	assert.Equal(t, "eth0: 1.1.1.1, 2606:4700:4700::1111", output)
+}
+
+func TestOutputFormat(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "status"}
+		cmd.Flags().String("output", "table", "")
+		cmd.Flags().Bool("json", false, "")
+		require.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	format, err := OutputFormat(newCmd())
+	require.NoError(t, err)
+	assert.Equal(t, FormatTable, format)
+
+	format, err = OutputFormat(newCmd("--output", "yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, FormatYAML, format)
+
+	format, err = OutputFormat(newCmd("--json"))
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, format)
+
+	_, err = OutputFormat(newCmd("--json", "--output", "yaml"))
+	assert.Error(t, err)
+
+	// Commands built without the root flags print tables
+	format, err = OutputFormat(&cobra.Command{Use: "list"})
+	require.NoError(t, err)
+	assert.Equal(t, FormatTable, format)
+}
@@ -12,6 +12,9 @@ import (
 	"gitlab.com/junevm/cdns/internal/cli"
 	"gitlab.com/junevm/cdns/internal/config"
 	"gitlab.com/junevm/cdns/internal/dns/backend"
+	"gitlab.com/junevm/cdns/internal/dns/state"
+	"gitlab.com/junevm/cdns/internal/features/check"
+	"gitlab.com/junevm/cdns/internal/features/doctor"
 	"gitlab.com/junevm/cdns/internal/features/list"
 	"gitlab.com/junevm/cdns/internal/features/reset"
 	"gitlab.com/junevm/cdns/internal/features/set"
@@ -44,9 +47,17 @@ func main() {
 			NewConfig,
 			NewLogger,
 			NewBuildInfo,
+			NewExecRunner,
+			NewCommandRunner,
+			NewRoot,
 			NewSystemOps,
+			NewNMClient,
 			NewDetector,
+			NewStatusDetector,
 			NewConfigReader,
+			NewStateStore,
+			NewStateReader,
+			NewChangeNotifier,
 		),
 
 		// Register feature modules
@@ -55,6 +66,8 @@ func main() {
 		set.Module,
 		reset.Module,
 		list.Module,
+		doctor.Module,
+		check.Module,
 
 		// Lifecycle hooks
 		fx.Invoke(RegisterLifecycleHooks),
@@ -83,10 +96,13 @@ func main() {
 }
 
 // NewRootCommand creates the root cobra command
-func NewRootCommand(cfg *config.Config, log *slog.Logger) *cobra.Command {
+func NewRootCommand(cfg *config.Config, log *slog.Logger, runner *backend.ExecRunner, root *backend.Root, detector *backend.Detector) *cobra.Command {
 	deps := cli.Dependencies{
-		Config: cfg,
-		Logger: log,
+		Config:  cfg,
+		Logger:  log,
+		Tracer:  runner,
+		Root:    root,
+		Backend: detector,
 	}
 	return cli.NewRootCmd(deps)
 }
@@ -145,19 +161,66 @@ func NewBuildInfo() version.BuildInfo {
 	}
 }
 
+// NewExecRunner creates the runner for external commands
+func NewExecRunner() *backend.ExecRunner {
+	return backend.NewExecRunner()
+}
+
+// NewCommandRunner exposes the host runner to the backends
+func NewCommandRunner(runner *backend.ExecRunner) backend.CommandRunner {
+	return runner
+}
+
+// NewRoot creates the filesystem root cdns configures, the running system
+// unless --root is given
+func NewRoot() *backend.Root {
+	return backend.NewRoot()
+}
+
 // NewSystemOps creates a new SystemOps instance
-func NewSystemOps() backend.SystemOps {
-	return backend.NewDefaultSystemOps()
+func NewSystemOps(runner backend.CommandRunner) backend.SystemOps {
+	return &backend.DefaultSystemOps{Runner: runner}
+}
+
+// NewNMClient creates the NetworkManager client shared by the features
+func NewNMClient(runner backend.CommandRunner, root *backend.Root) backend.NMClient {
+	return backend.NewNMClient(runner, root)
 }
 
-// NewDetector creates a new backend detector
-func NewDetector(sysOps backend.SystemOps) status.Detector {
-	return backend.NewDetector(sysOps)
+// NewDetector creates the backend detector shared by the features, using
+// the backend set in dns.backend if any
+func NewDetector(cfg *config.Config, sysOps backend.SystemOps, root *backend.Root) (*backend.Detector, error) {
+	detector := backend.NewDetector(sysOps, root)
+	if err := detector.SetOverride(cfg.DNS.Backend, "dns.backend"); err != nil {
+		return nil, err
+	}
+	return detector, nil
+}
+
+// NewStatusDetector exposes the detector to the status feature
+func NewStatusDetector(detector *backend.Detector) status.Detector {
+	return detector
 }
 
 // NewConfigReader creates a new DNS config reader
-func NewConfigReader(sysOps backend.SystemOps) status.Reader {
-	return backend.NewConfigReader(sysOps)
+func NewConfigReader(sysOps backend.SystemOps, nm backend.NMClient, runner backend.CommandRunner, root *backend.Root) status.Reader {
+	return backend.NewConfigReader(sysOps, nm, runner, root)
+}
+
+// NewStateStore creates the store recording changes made by cdns
+func NewStateStore() *state.Store {
+	return state.NewStore(state.DefaultPath)
+}
+
+// NewStateReader exposes the state store to the status feature
+func NewStateReader(store *state.Store) status.StateReader {
+	return store
+}
+
+// NewChangeNotifier lets 'status --watch' refresh on NetworkManager and
+// systemd-resolved signals
+func NewChangeNotifier(root *backend.Root) status.Notifier {
+	return backend.NewChangeWatcher(root)
 }
 
 // RunCLI executes the CLI application